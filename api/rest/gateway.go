@@ -0,0 +1,290 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+const PREFIX = "/v1/"
+
+// parameter types, named after the OpenAPI ones
+const (
+	STRING  = "string"
+	INTEGER = "integer"
+	NUMBER  = "number"
+	BOOLEAN = "boolean"
+	ARRAY   = "array" // comma separated list of strings
+)
+
+// Param binds a path segment or a query parameter to a field of the rpc arguments
+type Param struct {
+	Name        string
+	Field       string // the rpc argument field receiving the value
+	Type        string // defaults to string
+	Description string
+}
+
+// Route maps a resource operation on an rpc method
+type Route struct {
+	Method      string // HTTP method
+	Path        string // {name} segments are bound to the path params
+	RPCMethod   string
+	Summary     string
+	PathParams  []*Param
+	QueryParams []*Param
+	FreeQuery   bool        // the query parameters not declared are passed as string arguments
+	Body        bool        // the JSON request body is merged into the rpc arguments
+	Upload      *Param      // multipart/form-data file part passed as bytes, its name goes in FileName and the other parts count as query params
+	Source      string      // the rpc argument field receiving the caller address
+	Args        interface{} // sample of the rpc arguments, used for the OpenAPI document
+	Reply       interface{} // sample of the rpc reply, used for the OpenAPI document
+}
+
+func (r *Route) match(method string, segments []string) (map[string]string, bool) {
+	if r.Method != method {
+		return nil, false
+	}
+	routeSegments := splitPath(r.Path)
+	if len(routeSegments) != len(segments) {
+		return nil, false
+	}
+	vars := make(map[string]string)
+	for i, seg := range routeSegments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			vars[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return vars, true
+}
+
+// buildArgs composes the rpc arguments out of the body, the path and the query parameters
+func (r *Route) buildArgs(req *http.Request, vars map[string]string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	if r.Body {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%v", utils.ErrParserError.Error(), err)
+		}
+		if len(bytes.TrimSpace(body)) != 0 {
+			if err := json.Unmarshal(body, &args); err != nil {
+				return nil, fmt.Errorf("%s:%v", utils.ErrParserError.Error(), err)
+			}
+		}
+	}
+	query := req.URL.Query()
+	if r.Upload != nil {
+		if err := req.ParseMultipartForm(utils.MaxUploadMemory); err != nil {
+			return nil, fmt.Errorf("%s:%v", utils.ErrParserError.Error(), err)
+		}
+		defer req.MultipartForm.RemoveAll()
+		file, header, err := req.FormFile(r.Upload.Name)
+		if err != nil {
+			return nil, utils.NewErrMandatoryIeMissing(r.Upload.Name)
+		}
+		defer file.Close()
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		args[r.Upload.Field] = data
+		args["FileName"] = header.Filename
+		query = req.Form
+	}
+	declared := make(map[string]bool)
+	for _, p := range r.QueryParams {
+		declared[p.Name] = true
+		if value := query.Get(p.Name); value != "" {
+			v, err := p.convert(value)
+			if err != nil {
+				return nil, err
+			}
+			args[p.Field] = v
+		}
+	}
+	if r.FreeQuery {
+		for name := range query {
+			if !declared[name] {
+				args[name] = query.Get(name)
+			}
+		}
+	}
+	// path params are set last so the body can not point to another resource
+	for _, p := range r.PathParams {
+		v, err := p.convert(vars[p.Name])
+		if err != nil {
+			return nil, err
+		}
+		args[p.Field] = v
+	}
+	if r.Source != "" {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+		args[r.Source] = host
+	}
+	return args, nil
+}
+
+func (p *Param) convert(value string) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch p.Type {
+	case "", STRING:
+		v = value
+	case INTEGER:
+		v, err = strconv.ParseInt(value, 10, 64)
+	case NUMBER:
+		v, err = strconv.ParseFloat(value, 64)
+	case BOOLEAN:
+		v, err = strconv.ParseBool(value)
+	case ARRAY:
+		v = strings.Split(value, utils.FIELDS_SEP)
+	default:
+		err = fmt.Errorf("unsupported parameter type %s", p.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", utils.ErrParserError.Error(), p.Name)
+	}
+	return v, nil
+}
+
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+// Gateway serves the REST routes by calling the rpc methods registered in the same process
+type Gateway struct {
+	routes []*Route
+}
+
+func NewGateway(routes []*Route) *Gateway {
+	return &Gateway{routes: routes}
+}
+
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	req.Body = http.MaxBytesReader(w, req.Body, utils.MaxUploadSize)
+	if req.Method == http.MethodGet && req.URL.Path == PREFIX+"openapi.json" {
+		writeJSON(w, http.StatusOK, NewOpenAPI(gw.routes))
+		return
+	}
+	segments := splitPath(req.URL.Path)
+	var allowed []string // the methods of the routes matching the path
+	for _, route := range gw.routes {
+		if _, found := route.match(route.Method, segments); found {
+			allowed = append(allowed, route.Method)
+		}
+		vars, found := route.match(req.Method, segments)
+		if !found {
+			continue
+		}
+		args, err := route.buildArgs(req, vars)
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		result, errMsg, err := callRPC(route.RPCMethod, args)
+		if err != nil {
+			writeError(w, utils.NewErrServerError(err).Error())
+			return
+		}
+		if errMsg != "" {
+			writeError(w, errMsg)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
+		return
+	}
+	if len(allowed) != 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "METHOD_NOT_ALLOWED"})
+		return
+	}
+	writeError(w, utils.ErrNotFound.Error())
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+// callRPC issues a JSON-RPC request against the default rpc server
+func callRPC(method string, args interface{}) (json.RawMessage, string, error) {
+	request, err := json.Marshal(map[string]interface{}{"method": method, "params": []interface{}{args}, "id": 0})
+	if err != nil {
+		return nil, "", err
+	}
+	var resp rpcResponse
+	if err := json.NewDecoder(utils.NewRPCRequest(bytes.NewReader(request)).Call()).Decode(&resp); err != nil {
+		return nil, "", err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Sprint(resp.Error), nil
+	}
+	return resp.Result, "", nil
+}
+
+// HTTP status of the utils errors, the code is the error message up to the first colon
+var errorStatuses = map[string]int{
+	utils.ErrNotFound.Error():                http.StatusNotFound,
+	utils.ErrAccountNotFound.Error():         http.StatusNotFound,
+	utils.ErrRatingPlanNotFound.Error():      http.StatusNotFound,
+	utils.ErrUserNotFound.Error():            http.StatusNotFound,
+	utils.ErrNoActiveSession.Error():         http.StatusNotFound,
+	utils.ErrMandatoryIeMissing.Error():      http.StatusBadRequest,
+	utils.ErrParserError.Error():             http.StatusBadRequest,
+	utils.ErrInvalidPath.Error():             http.StatusBadRequest,
+	utils.ErrInvalidKey.Error():              http.StatusBadRequest,
+	utils.ErrSameAccount.Error():             http.StatusBadRequest,
+	utils.ErrBrokenReference.Error():         http.StatusBadRequest,
+	utils.ErrNotConvertible.Error():          http.StatusBadRequest,
+	utils.ErrExists.Error():                  http.StatusConflict,
+	utils.ErrUnauthorizedDestination.Error(): http.StatusForbidden,
+	utils.ErrAccountDisabled.Error():         http.StatusForbidden,
+	utils.ErrInsufficientCredit.Error():      http.StatusPaymentRequired,
+	utils.ErrQuotaExceeded.Error():           http.StatusTooManyRequests,
+	utils.ErrTooLarge.Error():                http.StatusRequestEntityTooLarge,
+	utils.ErrResourceUnavailable.Error():     http.StatusServiceUnavailable,
+	utils.ErrTimedOut.Error():                http.StatusGatewayTimeout,
+	utils.ErrNotImplemented.Error():          http.StatusNotImplemented,
+}
+
+// ErrorStatus returns the HTTP status for an rpc error message, a SERVER_ERROR takes the status of the error it wraps
+func ErrorStatus(errMsg string) int {
+	for strings.HasPrefix(errMsg, utils.ErrServerError.Error()+":") {
+		errMsg = strings.TrimSpace(strings.TrimPrefix(errMsg, utils.ErrServerError.Error()+":"))
+	}
+	code := strings.TrimSpace(strings.SplitN(errMsg, ":", 2)[0])
+	if status, found := errorStatuses[code]; found {
+		return status
+	}
+	if strings.HasPrefix(errMsg, "rpc: can't find") { // service not enabled on this engine
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, errMsg string) {
+	writeJSON(w, ErrorStatus(errMsg), map[string]string{"error": errMsg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+type AttrTestThing struct {
+	Tenant string
+	ID     string
+	IDs    []string
+	Limit  *int
+	Value  float64
+}
+
+type RestTestV1 struct{}
+
+func (rt *RestTestV1) GetThing(attr AttrTestThing, reply *AttrTestThing) error {
+	if attr.ID == "missing" {
+		return utils.ErrNotFound
+	}
+	*reply = attr
+	return nil
+}
+
+func (rt *RestTestV1) SetThing(attr AttrTestThing, reply *string) error {
+	if attr.Tenant != "t1" {
+		return utils.ErrInvalidPath
+	}
+	if attr.Value == 0 {
+		return utils.NewErrMandatoryIeMissing("Value")
+	}
+	*reply = utils.OK
+	return nil
+}
+
+type AttrTestUpload struct {
+	Archive  []byte
+	FileName string
+	FlushDB  bool
+}
+
+func (rt *RestTestV1) Upload(attr AttrTestUpload, reply *AttrTestUpload) error {
+	*reply = attr
+	return nil
+}
+
+var testRoutes = []*Route{
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/things/{id}", RPCMethod: "RestTestV1.GetThing",
+		PathParams: []*Param{tenantParam, idParam}, QueryParams: append([]*Param{&Param{Name: "ids", Field: "IDs", Type: ARRAY}}, pageParams...),
+		Args: AttrTestThing{}, Reply: AttrTestThing{}},
+	&Route{Method: http.MethodPut, Path: "/v1/tenants/{tenant}/things/{id}", RPCMethod: "RestTestV1.SetThing",
+		PathParams: []*Param{tenantParam, idParam}, Body: true, Args: AttrTestThing{}, Reply: ""},
+}
+
+func init() {
+	rpc.Register(new(RestTestV1))
+}
+
+func doRequest(gw *Gateway, method, url, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return w
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	gw := NewGateway(testRoutes)
+	w := doRequest(gw, http.MethodGet, "/v1/tenants/t1/things/th1?ids=a,b&limit=5", "")
+	var thing AttrTestThing
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &thing) != nil {
+		t.Fatalf("bad reply: %d %s", w.Code, w.Body.String())
+	}
+	if thing.Tenant != "t1" || thing.ID != "th1" || len(thing.IDs) != 2 || thing.Limit == nil || *thing.Limit != 5 {
+		t.Errorf("bad arguments: %+v", thing)
+	}
+	if w = doRequest(gw, http.MethodGet, "/v1/tenants/t1/things/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected not found: %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(gw, http.MethodGet, "/v1/tenants/t1/things/th1?limit=x", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request: %d %s", w.Code, w.Body.String())
+	}
+	// the path wins over the body
+	if w = doRequest(gw, http.MethodPut, "/v1/tenants/t1/things/th1", `{"Tenant":"other", "Value":1.5}`); w.Code != http.StatusOK || w.Body.String() != `"OK"` {
+		t.Errorf("bad set reply: %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(gw, http.MethodPut, "/v1/tenants/t1/things/th1", `{}`); w.Code != http.StatusBadRequest ||
+		!strings.Contains(w.Body.String(), utils.ErrMandatoryIeMissing.Error()) {
+		t.Errorf("expected mandatory error: %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(gw, http.MethodDelete, "/v1/tenants/t1/things/th1", ""); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, PUT" {
+		t.Errorf("expected method not allowed: %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(gw, http.MethodGet, "/v1/tenants/t1/others", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown path: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGatewayErrorStatus(t *testing.T) {
+	for errMsg, status := range map[string]int{
+		utils.ErrNotFound.Error():                           http.StatusNotFound,
+		utils.NewErrMandatoryIeMissing("Tenant").Error():    http.StatusBadRequest,
+		utils.NewErrServerError(utils.ErrNotFound).Error():  http.StatusNotFound,
+		utils.NewErrServerError(errors.New("boom")).Error(): http.StatusInternalServerError,
+		utils.ErrInsufficientCredit.Error():                 http.StatusPaymentRequired,
+		"rpc: can't find service CDRStatsV1.GetQueueIDs":    http.StatusServiceUnavailable,
+		utils.ErrExists.Error():                             http.StatusConflict,
+	} {
+		if s := ErrorStatus(errMsg); s != status {
+			t.Errorf("bad status for %s: %d", errMsg, s)
+		}
+	}
+}
+
+func TestGatewayOpenAPI(t *testing.T) {
+	w := doRequest(NewGateway(Routes), http.MethodGet, PREFIX+"openapi.json", "")
+	var doc struct {
+		OpenAPI    string
+		Paths      map[string]map[string]map[string]interface{}
+		Components struct{ Schemas map[string]interface{} }
+	}
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	account := doc.Paths["/v1/tenants/{tenant}/accounts/{account}"]
+	if doc.OpenAPI != "3.0.0" || account["get"] == nil || account["put"] == nil || account["delete"] == nil {
+		t.Errorf("bad paths: %+v", doc.Paths)
+	}
+	if account["get"]["operationId"] != "ApiV1_GetAccount" || doc.Components.Schemas["engine_Account"] == nil || doc.Components.Schemas["v1_AttrSetAccount"] == nil {
+		t.Errorf("bad operation: %+v, schemas %+v", account["get"], doc.Components.Schemas)
+	}
+}
+
+func TestGatewayUpload(t *testing.T) {
+	gw := NewGateway([]*Route{&Route{Method: http.MethodPost, Path: "/v1/uploads", RPCMethod: "RestTestV1.Upload",
+		Upload: &Param{Name: "archive", Field: "Archive"}, QueryParams: []*Param{&Param{Name: "flush_db", Field: "FlushDB", Type: BOOLEAN}},
+		Args: AttrTestUpload{}, Reply: AttrTestUpload{}}})
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("flush_db", "true")
+	fw, err := mw.CreateFormFile("archive", "tp.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("PK\x03\x04"))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/uploads", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, req)
+	var upload AttrTestUpload
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &upload) != nil {
+		t.Fatalf("bad reply: %d %s", w.Code, w.Body.String())
+	}
+	if string(upload.Archive) != "PK\x03\x04" || upload.FileName != "tp.zip" || !upload.FlushDB {
+		t.Errorf("bad arguments: %+v", upload)
+	}
+	if w = doRequest(gw, http.MethodPost, "/v1/uploads", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request without multipart body: %d %s", w.Code, w.Body.String())
+	}
+	defer func(size int64) { utils.MaxUploadSize = size }(utils.MaxUploadSize)
+	utils.MaxUploadSize = 10
+	if w = doRequest(NewGateway(testRoutes), http.MethodPut, "/v1/tenants/t1/things/th1", `{"Value":1.5, "IDs":["a","b"]}`); w.Code != http.StatusBadRequest ||
+		!strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("expected too large body: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+package rest
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	decType      = reflect.TypeOf(dec.Dec{})
+)
+
+// NewOpenAPI generates the OpenAPI 3 document describing the routes
+func NewOpenAPI(routes []*Route) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		pathItem, found := paths[route.Path].(map[string]interface{})
+		if !found {
+			pathItem = make(map[string]interface{})
+			paths[route.Path] = pathItem
+		}
+		var params []interface{}
+		for _, p := range route.PathParams {
+			params = append(params, p.openAPI("path", true))
+		}
+		for _, p := range route.QueryParams {
+			params = append(params, p.openAPI("query", false))
+		}
+		operation := map[string]interface{}{
+			"operationId": strings.Replace(route.RPCMethod, ".", "_", -1),
+			"summary":     route.Summary,
+			"description": "Maps on " + route.RPCMethod,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Successful reply",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Reply), schemas)}},
+				},
+				"default": map[string]interface{}{
+					"description": "Error, the message starts with the error code",
+					"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{
+						"type": "object", "properties": map[string]interface{}{"error": map[string]interface{}{"type": STRING}},
+					}}},
+				},
+			},
+		}
+		if len(params) != 0 {
+			operation["parameters"] = params
+		}
+		if route.Body {
+			operation["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Args), schemas)}},
+			}
+		}
+		if route.Upload != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{"multipart/form-data": map[string]interface{}{"schema": map[string]interface{}{
+					"type": "object", "required": []string{route.Upload.Name},
+					"properties": map[string]interface{}{route.Upload.Name: map[string]interface{}{"type": STRING, "format": "binary"}},
+				}}},
+			}
+		}
+		pathItem[strings.ToLower(route.Method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi":    "3.0.0",
+		"info":       map[string]interface{}{"title": "accuRate REST API", "version": utils.VERSION},
+		"servers":    []interface{}{map[string]interface{}{"url": "/"}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func (p *Param) openAPI(in string, required bool) map[string]interface{} {
+	schema := map[string]interface{}{"type": STRING}
+	switch p.Type {
+	case INTEGER, NUMBER, BOOLEAN:
+		schema["type"] = p.Type
+	case ARRAY:
+		schema = map[string]interface{}{"type": ARRAY, "items": map[string]interface{}{"type": STRING}}
+	}
+	param := map[string]interface{}{"name": p.Name, "in": in, "required": required, "schema": schema}
+	if p.Type == ARRAY {
+		param["style"] = "form"
+		param["explode"] = false
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+// schemaOf describes the JSON encoding of a Go type, named structs are added to the component schemas
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": STRING, "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": INTEGER, "description": "nanoseconds"}
+	case decType:
+		return map[string]interface{}{"type": NUMBER}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": BOOLEAN}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": INTEGER}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": NUMBER}
+	case reflect.String:
+		return map[string]interface{}{"type": STRING}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": ARRAY, "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := strings.Replace(t.String(), ".", "_", -1)
+		if _, found := schemas[name]; !found {
+			schemas[name] = map[string]interface{}{} // placeholder for the recursive types
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	addStructProperties(t, schemas, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// addStructProperties follows the encoding/json rules for the field names and the embedded structs
+func addStructProperties(t reflect.Type, schemas map[string]interface{}, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructProperties(ft, schemas, properties)
+				continue
+			}
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+	}
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/accurateproject/accurate/api/v1"
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/sessionmanager"
+	"github.com/accurateproject/accurate/utils"
+)
+
+var (
+	tenantParam  = &Param{Name: "tenant", Field: "Tenant"}
+	accountParam = &Param{Name: "account", Field: "Account"}
+	idParam      = &Param{Name: "id", Field: "ID"}
+	pageParams   = []*Param{
+		&Param{Name: "limit", Field: "Limit", Type: INTEGER, Description: "Limit the number of items returned"},
+		&Param{Name: "offset", Field: "Offset", Type: INTEGER, Description: "Offset of the first item returned"},
+	}
+	cdrParams = append([]*Param{
+		&Param{Name: "unique_ids", Field: "UniqueIDs", Type: ARRAY},
+		&Param{Name: "run_ids", Field: "RunIDs", Type: ARRAY},
+		&Param{Name: "not_run_ids", Field: "NotRunIDs", Type: ARRAY},
+		&Param{Name: "tors", Field: "ToRs", Type: ARRAY},
+		&Param{Name: "request_types", Field: "RequestTypes", Type: ARRAY},
+		&Param{Name: "categories", Field: "Categories", Type: ARRAY},
+		&Param{Name: "accounts", Field: "Accounts", Type: ARRAY},
+		&Param{Name: "subjects", Field: "Subjects", Type: ARRAY},
+		&Param{Name: "destination_prefixes", Field: "DestinationPrefixes", Type: ARRAY},
+		&Param{Name: "suppliers", Field: "Suppliers", Type: ARRAY},
+		&Param{Name: "setup_time_start", Field: "SetupTimeStart"},
+		&Param{Name: "setup_time_end", Field: "SetupTimeEnd"},
+		&Param{Name: "answer_time_start", Field: "AnswerTimeStart"},
+		&Param{Name: "answer_time_end", Field: "AnswerTimeEnd"},
+		&Param{Name: "min_usage", Field: "MinUsage"},
+		&Param{Name: "max_usage", Field: "MaxUsage"},
+		&Param{Name: "min_cost", Field: "MinCost", Type: NUMBER},
+		&Param{Name: "max_cost", Field: "MaxCost", Type: NUMBER},
+	}, pageParams...)
+)
+
+// Routes are the REST resources exposed over the ApiV1, CDRStatsV1 and SMGenericV1 methods
+var Routes = []*Route{
+	// accounts
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/accounts", RPCMethod: "ApiV1.GetAccounts", Summary: "List the tenant accounts",
+		PathParams: []*Param{tenantParam}, QueryParams: append([]*Param{&Param{Name: "ids", Field: "IDs", Type: ARRAY}}, pageParams...),
+		Args: v1.AttrGetMultiple{}, Reply: []*engine.Account{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/accounts/{account}", RPCMethod: "ApiV1.GetAccount", Summary: "Get an account with its balances",
+		PathParams: []*Param{tenantParam, accountParam}, Args: v1.AttrGetAccount{}, Reply: engine.Account{}},
+	&Route{Method: http.MethodPut, Path: "/v1/tenants/{tenant}/accounts/{account}", RPCMethod: "ApiV1.SetAccount", Summary: "Create or update an account",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrSetAccount{}, Reply: ""},
+	&Route{Method: http.MethodDelete, Path: "/v1/tenants/{tenant}/accounts/{account}", RPCMethod: "ApiV1.RemoveAccount", Summary: "Remove an account",
+		PathParams: []*Param{tenantParam, accountParam}, QueryParams: []*Param{&Param{Name: "reload_scheduler", Field: "ReloadScheduler", Type: BOOLEAN}},
+		Args: v1.AttrRemoveAccount{}, Reply: ""},
+	// balances
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/accounts/{account}/balances", RPCMethod: "ApiV1.AddBalance", Summary: "Add value to a balance",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrSetBalance{}, Reply: ""},
+	&Route{Method: http.MethodPut, Path: "/v1/tenants/{tenant}/accounts/{account}/balances", RPCMethod: "ApiV1.SetBalance", Summary: "Set the balances matching the filter",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrSetBalance{}, Reply: ""},
+	&Route{Method: http.MethodDelete, Path: "/v1/tenants/{tenant}/accounts/{account}/balances", RPCMethod: "ApiV1.RemoveBalances", Summary: "Remove the balances matching the filter",
+		PathParams:  []*Param{tenantParam, accountParam},
+		QueryParams: []*Param{&Param{Name: "tor", Field: "TOR"}, &Param{Name: "filter", Field: "Filter", Description: "JSON balance filter"}},
+		Args:        v1.AttrSetBalance{}, Reply: ""},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/accounts/{account}/debits", RPCMethod: "ApiV1.DebitBalance", Summary: "Debit value from a balance",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrSetBalance{}, Reply: ""},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/accounts/{account}/transfers", RPCMethod: "ApiV1.TransferBalance", Summary: "Transfer value to another account",
+		PathParams: []*Param{&Param{Name: "tenant", Field: "FromTenant"}, &Param{Name: "account", Field: "FromAccount"}}, Body: true,
+		Args: v1.AttrTransferBalance{}, Reply: ""},
+	&Route{Method: http.MethodPut, Path: "/v1/tenants/{tenant}/accounts/{account}/credit_limit", RPCMethod: "ApiV1.SetCreditLimit",
+		Summary:    "Set or remove the credit limit of the account or of its monetary balances",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrSetCreditLimit{}, Reply: ""},
+	&Route{Method: http.MethodPut, Path: "/v1/tenants/{tenant}/accounts/{account}/state", RPCMethod: "ApiV1.SetAccountState",
+		Summary:    "Move the account to a lifecycle state",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrSetAccountState{}, Reply: ""},
+	// balance ledger
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/accounts/{account}/ledger", RPCMethod: "ApiV1.GetBalanceLedger", Summary: "List the balance changes of the account",
+		PathParams: []*Param{tenantParam, accountParam}, QueryParams: append([]*Param{
+			&Param{Name: "balance_uuid", Field: "BalanceUUID"},
+			&Param{Name: "balance_type", Field: "BalanceType"},
+			&Param{Name: "cause", Field: "Cause"},
+			&Param{Name: "cause_id", Field: "CauseID"},
+			&Param{Name: "time_start", Field: "TimeStart"},
+			&Param{Name: "time_end", Field: "TimeEnd"},
+		}, pageParams...), Args: v1.AttrGetBalanceLedger{}, Reply: []*engine.LedgerEntry{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/accounts/{account}/ledger/{balance_uuid}/check", RPCMethod: "ApiV1.CheckBalanceLedger",
+		Summary:    "Recompute a balance out of its ledger",
+		PathParams: []*Param{tenantParam, accountParam, &Param{Name: "balance_uuid", Field: "BalanceUUID"}}, Args: v1.AttrCheckBalanceLedger{}, Reply: engine.LedgerCheck{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/accounts/{account}/statement", RPCMethod: "ApiV1.GetAccountStatement",
+		Summary: "Get the account statement of a period", PathParams: []*Param{tenantParam, accountParam},
+		QueryParams: []*Param{&Param{Name: "from", Field: "From"}, &Param{Name: "to", Field: "To"}},
+		Args:        v1.AttrGetAccountStatement{}, Reply: engine.AccountStatement{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/accounts/{account}/statement/exports", RPCMethod: "ApiV1.ExportAccountStatement",
+		Summary: "Export the account statement of a period as csv", PathParams: []*Param{tenantParam, accountParam}, Body: true,
+		Args: v1.AttrExportAccountStatement{}, Reply: utils.ExportedFileCdrs{}},
+	// invoices
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/accounts/{account}/invoices", RPCMethod: "ApiV1.GetInvoices", Summary: "List the account invoices",
+		PathParams: []*Param{tenantParam, accountParam}, QueryParams: pageParams, Args: v1.AttrGetInvoices{}, Reply: []*engine.Invoice{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/accounts/{account}/invoices", RPCMethod: "ApiV1.GenerateInvoice", Summary: "Generate the invoice of a billing period",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Args: v1.AttrGenerateInvoice{}, Reply: engine.Invoice{}},
+	// vouchers
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/voucher_batches", RPCMethod: "ApiV1.CreateVoucherBatch", Summary: "Generate a batch of vouchers, the reply holds the only copy of the pins",
+		PathParams: []*Param{tenantParam}, Body: true, Args: v1.AttrCreateVoucherBatch{}, Reply: v1.CreatedVoucherBatch{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/voucher_batches", RPCMethod: "ApiV1.GetVoucherBatches", Summary: "List the tenant voucher batches",
+		PathParams: []*Param{tenantParam}, QueryParams: pageParams, Args: v1.AttrGetVoucherBatches{}, Reply: []*engine.VoucherBatch{}},
+	&Route{Method: http.MethodPut, Path: "/v1/tenants/{tenant}/voucher_batches/{id}/state", RPCMethod: "ApiV1.SetVoucherBatchState", Summary: "Activate, sell or block a voucher batch",
+		PathParams: []*Param{tenantParam, idParam}, Body: true, Args: v1.AttrSetVoucherBatchState{}, Reply: ""},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/voucher_batches/{id}/export", RPCMethod: "ApiV1.ExportVoucherBatch", Summary: "Export the batch vouchers as csv, without the pins",
+		PathParams: []*Param{tenantParam, idParam}, Args: v1.AttrExportVoucherBatch{}, Reply: ""},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/accounts/{account}/vouchers", RPCMethod: "ApiV1.RedeemVoucher", Summary: "Redeem a voucher on the account",
+		PathParams: []*Param{tenantParam, accountParam}, Body: true, Source: "Source", Args: v1.AttrRedeemVoucher{}, Reply: ""},
+	// destinations
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/destinations", RPCMethod: "ApiV1.GetDestinations", Summary: "List the tenant destinations",
+		PathParams: []*Param{tenantParam}, QueryParams: append([]*Param{&Param{Name: "ids", Field: "IDs", Type: ARRAY}}, pageParams...),
+		Args: v1.AttrGetMultiple{}, Reply: engine.Destinations{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/destinations/{id}", RPCMethod: "ApiV1.GetDestination", Summary: "Get the prefixes of a destination",
+		PathParams: []*Param{tenantParam, idParam}, Args: v1.AttrGetSingle{}, Reply: engine.Destinations{}},
+	&Route{Method: http.MethodDelete, Path: "/v1/tenants/{tenant}/destinations/{id}", RPCMethod: "ApiV1.RemoveDestination", Summary: "Remove a destination",
+		PathParams: []*Param{tenantParam, &Param{Name: "id", Field: "DestinationIDs", Type: ARRAY}}, Args: v1.AttrRemoveDestination{}, Reply: ""},
+	// rating
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/rating_plans/{id}", RPCMethod: "ApiV1.GetRatingPlan", Summary: "Get a rating plan",
+		PathParams: []*Param{tenantParam, idParam}, Args: v1.AttrGetSingle{}, Reply: engine.RatingPlan{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/tariff_plan/export", RPCMethod: "ApiV1.ExportTariffPlan", Summary: "Export the tenant tariff plan to a folder",
+		PathParams: []*Param{tenantParam}, Body: true, Args: v1.AttrExportTariffPlan{}, Reply: map[string]int{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tariff_plan/validate", RPCMethod: "ApiV1.ValidateTariffPlan", Summary: "Check a tariff plan folder without loading it",
+		Body: true, Args: v1.AttrLoadTpFromFolder{}, Reply: engine.TpValidationReport{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tariff_plan/upload", RPCMethod: "ApiV1.LoadTariffPlanFromArchive", Summary: "Validate and load a zip or tar.gz tariff plan archive",
+		Upload: &Param{Name: "archive", Field: "Archive"}, QueryParams: []*Param{&Param{Name: "flush_db", Field: "FlushDB", Type: BOOLEAN}, &Param{Name: "csv_separator", Field: "CsvSeparator"}},
+		Args: v1.AttrLoadTpFromArchive{}, Reply: v1.TpArchiveLoad{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/tariff_plan/diff", RPCMethod: "ApiV1.DiffTariffPlan", Summary: "Compare the tenant tariff plan with a folder, or two staged loads",
+		PathParams: []*Param{tenantParam}, Body: true, Args: v1.AttrDiffTariffPlan{}, Reply: engine.TpDiff{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tariff_plan/rate_deck", RPCMethod: "ApiV1.ImportRateDeck", Summary: "Convert a carrier rate deck into a tariff plan folder",
+		Body: true, Args: v1.AttrImportRateDeck{}, Reply: map[string]int{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tariff_plan/stage", RPCMethod: "ApiV1.StageTariffPlan", Summary: "Stage a tariff plan folder as new tenant loads",
+		Body: true, Args: v1.AttrStageTariffPlan{}, Reply: []*utils.LoadInstance{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/tariff_plan/loads", RPCMethod: "ApiV1.GetTariffPlanLoads", Summary: "List the tenant tariff plan loads, the newest first",
+		PathParams: []*Param{tenantParam}, QueryParams: pageParams[:1], Args: v1.AttrGetTariffPlanLoads{}, Reply: []*utils.LoadInstance{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/tariff_plan/loads/{id}/activate", RPCMethod: "ApiV1.ActivateTariffPlanLoad", Summary: "Activate a tenant load, rolling back to a previous one",
+		PathParams: []*Param{tenantParam, &Param{Name: "id", Field: "LoadID"}}, Args: v1.AttrActivateTariffPlanLoad{}, Reply: utils.LoadInstance{}},
+	// cdrs
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/cdrs", RPCMethod: "ApiV1.GetCdrs", Summary: "Query the tenant CDRs",
+		PathParams: []*Param{&Param{Name: "tenant", Field: "Tenants", Type: ARRAY}}, QueryParams: cdrParams,
+		Args: utils.RPCCDRsFilter{}, Reply: []*engine.ExternalCDR{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/cdrs/count", RPCMethod: "ApiV1.CountCdrs", Summary: "Count the tenant CDRs",
+		PathParams: []*Param{&Param{Name: "tenant", Field: "Tenants", Type: ARRAY}}, QueryParams: cdrParams,
+		Args: utils.RPCCDRsFilter{}, Reply: int64(0)},
+	// cdr stats
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/stats_queues", RPCMethod: "CDRStatsV1.GetQueueIDs", Summary: "List the stats queue ids",
+		PathParams: []*Param{tenantParam}, Args: v1.AttrTenant{}, Reply: []string{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/stats_queues/{id}", RPCMethod: "CDRStatsV1.GetQueue", Summary: "Get a stats queue",
+		PathParams: []*Param{tenantParam, idParam}, Args: utils.AttrStatsQueueID{}, Reply: engine.StatsQueue{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/stats_queues/{id}/metrics", RPCMethod: "CDRStatsV1.GetMetrics", Summary: "Get the metrics of a stats queue",
+		PathParams: []*Param{tenantParam, idParam}, Args: utils.AttrStatsQueueID{}, Reply: map[string]*dec.Dec{}},
+	&Route{Method: http.MethodPost, Path: "/v1/tenants/{tenant}/stats_queues/{id}/reset", RPCMethod: "CDRStatsV1.ResetQueues", Summary: "Reset a stats queue",
+		PathParams: []*Param{tenantParam, &Param{Name: "id", Field: "IDs", Type: ARRAY}}, Args: utils.AttrStatsQueueIDs{}, Reply: ""},
+	// sessions
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/sessions", RPCMethod: "SMGenericV1.ActiveSessions", Summary: "List the active sessions, the query parameters filter on the session fields",
+		PathParams: []*Param{tenantParam}, FreeQuery: true, Args: map[string]string{}, Reply: []*sessionmanager.ActiveSession{}},
+	&Route{Method: http.MethodGet, Path: "/v1/tenants/{tenant}/sessions/count", RPCMethod: "SMGenericV1.ActiveSessionsCount", Summary: "Count the active sessions, the query parameters filter on the session fields",
+		PathParams: []*Param{tenantParam}, FreeQuery: true, Args: map[string]string{}, Reply: 0},
+	&Route{Method: http.MethodPost, Path: "/v1/sessions/max_usage", RPCMethod: "SMGenericV1.MaxUsage", Summary: "Get the maximum usage allowed for an event",
+		Body: true, Args: sessionmanager.SMGenericEvent{}, Reply: float64(0)},
+	&Route{Method: http.MethodPost, Path: "/v1/sessions/initiate", RPCMethod: "SMGenericV1.InitiateSession", Summary: "Start a session",
+		Body: true, Args: sessionmanager.SMGenericEvent{}, Reply: float64(0)},
+	&Route{Method: http.MethodPost, Path: "/v1/sessions/update", RPCMethod: "SMGenericV1.UpdateSession", Summary: "Update a running session",
+		Body: true, Args: sessionmanager.SMGenericEvent{}, Reply: float64(0)},
+	&Route{Method: http.MethodPost, Path: "/v1/sessions/terminate", RPCMethod: "SMGenericV1.TerminateSession", Summary: "Terminate a session",
+		Body: true, Args: sessionmanager.SMGenericEvent{}, Reply: ""},
+	&Route{Method: http.MethodPost, Path: "/v1/sessions/charge", RPCMethod: "SMGenericV1.ChargeEvent", Summary: "Charge an event without session",
+		Body: true, Args: sessionmanager.SMGenericEvent{}, Reply: float64(0)},
+	&Route{Method: http.MethodPost, Path: "/v1/sessions/cdrs", RPCMethod: "SMGenericV1.ProcessCDR", Summary: "Process the CDR of an event",
+		Body: true, Args: sessionmanager.SMGenericEvent{}, Reply: ""},
+}
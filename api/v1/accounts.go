@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/accurateproject/accurate/dec"
 	"github.com/accurateproject/accurate/engine"
 	"github.com/accurateproject/accurate/utils"
 )
@@ -147,7 +148,7 @@ func (api *ApiV1) RemoveAccount(attr AttrRemoveAccount, reply *string) error {
 			return 0, err
 		}
 		return 0, nil
-	}, 0, attr.Account)
+	}, 0, utils.ConcatKey(attr.Tenant, attr.Account))
 	if err != nil {
 		return utils.NewErrServerError(err)
 	}
@@ -219,6 +220,7 @@ func (api *ApiV1) modifyBalance(aType string, attr AttrSetBalance, reply *string
 	if attr.Overwrite {
 		aType += "_reset" // => *topup_reset/*debit_reset
 	}
+	at.SetLedgerCause(engine.LEDGER_API, aType)
 	at.SetActions(engine.Actions{&engine.Action{
 		ActionType: aType,
 		TOR:        attr.TOR,
@@ -234,6 +236,195 @@ func (api *ApiV1) modifyBalance(aType string, attr AttrSetBalance, reply *string
 	return nil
 }
 
+type AttrSetCreditLimit struct {
+	Tenant      string
+	Account     string
+	Filter      string   // selects the monetary balances, empty for the account limit
+	CreditLimit *float64 // nil to remove the limit
+}
+
+// SetCreditLimit sets or removes the credit limit of the account or of its monetary balances
+func (api *ApiV1) SetCreditLimit(attr AttrSetCreditLimit, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if _, err := api.accountDB.GetAccount(attr.Tenant, attr.Account); err != nil {
+		return err
+	}
+	a := &engine.Action{ActionType: engine.REMOVE_CREDIT_LIMIT, TOR: utils.MONETARY, Filter1: attr.Filter}
+	if attr.CreditLimit != nil {
+		if *attr.CreditLimit < 0 {
+			return fmt.Errorf("%s:CreditLimit:%s", utils.ErrServerError, "Negative")
+		}
+		a.ActionType = engine.SET_CREDIT_LIMIT
+		a.Params = utils.ToJSON(map[string]*dec.Dec{"CreditLimit": dec.NewFloat(*attr.CreditLimit)})
+	}
+	at := &engine.ActionTiming{}
+	apl := &engine.ActionPlan{
+		Tenant:        attr.Tenant,
+		ActionTimings: []*engine.ActionTiming{at},
+	}
+	apl.SetParentActionPlan()
+	at.SetAccountIDs(utils.StringMap{attr.Account: true})
+	at.SetLedgerCause(engine.LEDGER_API, a.ActionType)
+	at.SetActions(engine.Actions{a})
+	if err := at.Execute(); err != nil {
+		*reply = err.Error()
+		return err
+	}
+	*reply = OK
+	return nil
+}
+
+type AttrSetAccountState struct {
+	Tenant        string
+	Account       string
+	State         string // *pending, *active, *barred_out, *suspended, *grace or *closed
+	Cause         string // recorded with the transition, defaults to *api
+	GraceDuration string // how long the *grace state lasts before suspension, 72h by default
+}
+
+// SetAccountState moves the account to a lifecycle state if the transition is allowed
+func (api *ApiV1) SetAccountState(attr AttrSetAccountState, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "State"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if attr.Cause == "" {
+		attr.Cause = engine.LEDGER_API
+	}
+	_, err := engine.Guardian.Guard(func() (interface{}, error) {
+		acc, err := api.accountDB.GetAccount(attr.Tenant, attr.Account)
+		if err != nil {
+			return 0, err
+		}
+		if attr.State == engine.ACCOUNT_GRACE {
+			var duration time.Duration
+			if attr.GraceDuration != "" {
+				if duration, err = utils.ParseDurationWithSecs(attr.GraceDuration); err != nil {
+					return 0, err
+				}
+			}
+			if err := acc.SetGraceState(duration, attr.Cause); err != nil {
+				return 0, err
+			}
+		} else if err := acc.SetState(attr.State, attr.Cause); err != nil {
+			return 0, err
+		}
+		return 0, api.accountDB.SetAccount(acc)
+	}, 0, utils.ConcatKey(attr.Tenant, attr.Account))
+	if err != nil {
+		return err
+	}
+	*reply = OK
+	return nil
+}
+
+type AttrTransferBalance struct {
+	FromTenant  string
+	FromAccount string
+	ToTenant    string // defaults to FromTenant
+	ToAccount   string
+	TOR         string // *monetary, *voice, *sms (BalanceType)
+	Value       float64
+	FromFilter  string // selects the debited balances, empty for all
+	ToFilter    string // selects the credited balance, empty for the *default one
+}
+
+// TransferBalance atomically moves a value from the balances of one account to a balance of another account
+func (api *ApiV1) TransferBalance(attr AttrTransferBalance, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"FromTenant", "FromAccount", "ToAccount", "TOR", "Value"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if attr.ToTenant == "" {
+		attr.ToTenant = attr.FromTenant
+	}
+	bt := &engine.BalanceTransfer{
+		FromTenant:  attr.FromTenant,
+		FromAccount: attr.FromAccount,
+		ToTenant:    attr.ToTenant,
+		ToAccount:   attr.ToAccount,
+		BalanceType: attr.TOR,
+		Value:       dec.NewFloat(attr.Value),
+		FromFilter:  attr.FromFilter,
+		ToFilter:    attr.ToFilter,
+	}
+	if _, err := bt.Execute(); err != nil {
+		*reply = err.Error()
+		return utils.NewErrServerError(err)
+	}
+	*reply = OK
+	return nil
+}
+
+type AttrReserveBalance struct {
+	Tenant  string
+	Account string
+	ID      string // reservation id, the session OriginID for SMG sessions
+	TOR     string // *monetary, *voice, *sms (BalanceType)
+	Value   float64
+	Filter  string // selects the held balances, empty for all
+	TTL     string // the hold is released automatically after this duration, empty to keep it
+}
+
+// ReserveBalance holds a value on the account balances so it cannot be used by other sessions
+func (api *ApiV1) ReserveBalance(attr AttrReserveBalance, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "ID", "TOR", "Value"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	var ttl time.Duration
+	if attr.TTL != "" {
+		var err error
+		if ttl, err = utils.ParseDurationWithSecs(attr.TTL); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	}
+	if _, err := engine.ReserveBalance(attr.Tenant, attr.Account, attr.ID, attr.TOR, attr.Filter, dec.NewFloat(attr.Value), ttl); err != nil {
+		*reply = err.Error()
+		return utils.NewErrServerError(err)
+	}
+	*reply = OK
+	return nil
+}
+
+type AttrCaptureReservation struct {
+	Tenant  string
+	Account string
+	ID      string
+	Value   float64 // debited from the hold, the rest is released
+}
+
+// CaptureReservation debits a value from a balance hold and releases the rest of it
+func (api *ApiV1) CaptureReservation(attr AttrCaptureReservation, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "ID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := engine.CaptureReservation(attr.Tenant, attr.Account, attr.ID, dec.NewFloat(attr.Value)); err != nil {
+		*reply = err.Error()
+		return utils.NewErrServerError(err)
+	}
+	*reply = OK
+	return nil
+}
+
+type AttrReleaseReservation struct {
+	Tenant  string
+	Account string
+	ID      string
+}
+
+// ReleaseReservation drops a balance hold without debiting anything
+func (api *ApiV1) ReleaseReservation(attr AttrReleaseReservation, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "ID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := engine.ReleaseReservation(attr.Tenant, attr.Account, attr.ID); err != nil {
+		*reply = err.Error()
+		return utils.NewErrServerError(err)
+	}
+	*reply = OK
+	return nil
+}
+
 type AttrGetMultiple struct {
 	Tenant string
 	IDs    []string
@@ -307,7 +498,9 @@ type AttrSetAccount struct {
 	ActionTriggerIDs       *[]string
 	ActionTriggerOverwrite bool
 	AllowNegative          *bool
+	CreditLimit            *float64 // how far below zero the monetary balances may go, negative to remove it
 	Disabled               *bool
+	State                  *string // lifecycle state, any for new accounts, an allowed transition for existing ones
 	ReloadScheduler        bool
 }
 
@@ -327,10 +520,25 @@ func (api *ApiV1) SetAccount(attr AttrSetAccount, reply *string) error {
 				Tenant: attr.Tenant,
 				Name:   attr.Account,
 			}
+			if attr.State != nil {
+				if err := acc.InitState(*attr.State, engine.LEDGER_API); err != nil {
+					return 0, err
+				}
+			}
+		} else if attr.State != nil {
+			if err := acc.SetState(*attr.State, engine.LEDGER_API); err != nil {
+				return 0, err
+			}
 		}
 		if attr.AllowNegative != nil {
 			acc.AllowNegative = *attr.AllowNegative
 		}
+		if attr.CreditLimit != nil {
+			acc.CreditLimit = nil
+			if *attr.CreditLimit >= 0 {
+				acc.CreditLimit = dec.NewFloat(*attr.CreditLimit)
+			}
+		}
 		if attr.Disabled != nil {
 			acc.Disabled = *attr.Disabled
 		}
@@ -406,7 +614,7 @@ func (api *ApiV1) SetAccount(attr AttrSetAccount, reply *string) error {
 			return 0, err
 		}
 		return 0, nil
-	}, 0, attr.Account)
+	}, 0, utils.ConcatKey(attr.Tenant, attr.Account))
 	if err != nil {
 		return utils.NewErrServerError(err)
 	}
@@ -2,10 +2,13 @@ package v1
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math"
 	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/accurateproject/accurate/cache2go"
 	"github.com/accurateproject/accurate/config"
@@ -45,8 +48,9 @@ func NewAPIV1(ratingDB engine.RatingStorage, accountingDB engine.AccountingStora
 }
 
 type AttrLoadTpFromFolder struct {
-	FolderPath string // Take files from folder absolute path
-	FlushDB    bool   // Flush previous data before loading new one
+	FolderPath   string // Take files from folder absolute path
+	FlushDB      bool   // Flush previous data before loading new one
+	CsvSeparator string // Field separator of the csv files, defaults to comma
 }
 
 func (api *ApiV1) LoadTariffPlanFromFolder(attr AttrLoadTpFromFolder, reply *utils.LoadInstance) error {
@@ -61,11 +65,28 @@ func (api *ApiV1) LoadTariffPlanFromFolder(attr AttrLoadTpFromFolder, reply *uti
 	} else if !fi.IsDir() {
 		return utils.ErrInvalidPath
 	}
+	loadInstance, _, err := api.loadTpFolder(attr.FolderPath, attr.FlushDB, attr.CsvSeparator)
+	if err != nil {
+		return err
+	}
+	*reply = *loadInstance
+	return nil
+}
 
-	if attr.FlushDB {
+// loadTpFolder loads the folder through the TpReader and reloads the caches and the services using the loaded tenants
+func (api *ApiV1) loadTpFolder(folderPath string, flushDB bool, csvSeparator string) (*utils.LoadInstance, *engine.LoadStats, error) {
+	if flushDB {
 		api.ratingDB.Flush()
 	}
-	tpReader, err := engine.LoadTariffPlanFromFolder(attr.FolderPath, *api.cfg.General.DefaultTimezone, api.ratingDB, api.accountDB)
+	csvSep := utils.CSV_SEP
+	if csvSeparator != "" {
+		csvSep = []rune(csvSeparator)[0]
+	}
+	loadInstance := &utils.LoadInstance{LoadID: utils.GenUUID(), SourcePath: folderPath, LoadTime: time.Now()}
+	tpReader, err := engine.LoadTariffPlanFromFolder(folderPath, *api.cfg.General.DefaultTimezone, csvSep, api.ratingDB, api.accountDB)
+	if err != nil {
+		return nil, nil, utils.NewErrServerError(err)
+	}
 
 	loadStats := tpReader.LoadStats()
 	// Reload scheduler and cache
@@ -74,8 +95,68 @@ func (api *ApiV1) LoadTariffPlanFromFolder(attr AttrLoadTpFromFolder, reply *uti
 	if err = api.ReloadCache(utils.AttrReloadCache{Tenants: loadStats.Tenants.Slice()}, &r); err != nil {
 		log.Printf("WARNING: Got error on cache reload: %s\n", err.Error())
 	}
+	api.reloadLoadedServices(loadStats)
+	return loadInstance, loadStats, nil
+}
+
+type AttrLoadTpFromArchive struct {
+	Archive      []byte // zip or tar.gz content, base64 over JSON
+	FileName     string // tells the archive format by its .zip, .tar.gz or .tgz extension
+	FlushDB      bool   // Flush previous data before loading new one
+	CsvSeparator string // Field separator of the csv files, defaults to comma
+}
+
+type TpArchiveLoad struct {
+	LoadInstance *utils.LoadInstance
+	LoadStats    *engine.LoadStats
+	Warnings     []*engine.TpIssue
+}
 
-	if err = api.ReloadScheduler("", &r); err != nil {
+// LoadTariffPlanFromArchive unpacks the uploaded archive in a temporary folder, validates and loads it.
+// Nothing is loaded if the validation finds errors, the first one is returned.
+func (api *ApiV1) LoadTariffPlanFromArchive(attr AttrLoadTpFromArchive, reply *TpArchiveLoad) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Archive", "FileName"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	sandbox, err := ioutil.TempDir("", "tp_upload")
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	defer os.RemoveAll(sandbox)
+	tpPath, err := engine.UnpackTariffPlanArchive(attr.Archive, attr.FileName, sandbox)
+	if err == utils.ErrTooLarge {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%s:%v", utils.ErrParserError.Error(), err)
+	}
+	csvSep := utils.CSV_SEP
+	if attr.CsvSeparator != "" {
+		csvSep = []rune(attr.CsvSeparator)[0]
+	}
+	ratingDB := api.ratingDB
+	if attr.FlushDB {
+		ratingDB = nil
+	}
+	report, err := engine.ValidateTariffPlanFolder(tpPath, *api.cfg.General.DefaultTimezone, csvSep, ratingDB)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if !report.IsValid() {
+		return fmt.Errorf("%s:%d errors, %s", utils.ErrParserError.Error(), len(report.Errors), report.Errors[0])
+	}
+	loadInstance, loadStats, err := api.loadTpFolder(tpPath, attr.FlushDB, attr.CsvSeparator)
+	if err != nil {
+		return err
+	}
+	loadInstance.SourcePath = attr.FileName
+	*reply = TpArchiveLoad{LoadInstance: loadInstance, LoadStats: loadStats, Warnings: report.Warnings}
+	return nil
+}
+
+// reloadLoadedServices refreshes the scheduler, the stats queues and the users after a tariff plan load
+func (api *ApiV1) reloadLoadedServices(loadStats *engine.LoadStats) {
+	r := ""
+	if err := api.ReloadScheduler("", &r); err != nil {
 		log.Printf("WARNING: Got error on scheduler reload: %s\n", err.Error())
 	}
 
@@ -97,15 +178,208 @@ func (api *ApiV1) LoadTariffPlanFromFolder(attr AttrLoadTpFromFolder, reply *uti
 			}
 		}
 	}
+}
+
+type AttrStageTariffPlan struct {
+	FolderPath   string // Take files from folder absolute path
+	CsvSeparator string // Field separator of the csv files, defaults to comma
+	Activate     bool   // Activate the staged loads right away
+}
 
-	/*loadHistList, err := api.AccountDb.GetLoadHistory(1, utils.CACHE_SKIP)
+// StageTariffPlan checks the folder by loading it aside and keeps a versioned copy of each tenant in the data db and in the
+// general tpstaging_dir, replies with the new loads of the tenants. The account actions in the folder are ignored.
+func (api *ApiV1) StageTariffPlan(attr AttrStageTariffPlan, reply *[]*utils.LoadInstance) error {
+	if missing := utils.MissingStructFields(&attr, []string{"FolderPath"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if fi, err := os.Stat(attr.FolderPath); err != nil {
+		if os.IsNotExist(err) {
+			return utils.ErrInvalidPath
+		}
+		return utils.NewErrServerError(err)
+	} else if !fi.IsDir() {
+		return utils.ErrInvalidPath
+	}
+	csvSep := utils.CSV_SEP
+	if attr.CsvSeparator != "" {
+		csvSep = []rune(attr.CsvSeparator)[0]
+	}
+	loads, err := engine.StageTariffPlan(attr.FolderPath, *api.cfg.General.TpstagingDir, *api.cfg.General.DefaultTimezone, csvSep, api.accountDB)
 	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if attr.Activate {
+		for i, load := range loads {
+			var activated utils.LoadInstance
+			if err := api.ActivateTariffPlanLoad(AttrActivateTariffPlanLoad{Tenant: load.Tenant, LoadID: load.LoadID}, &activated); err != nil {
+				return err
+			}
+			loads[i] = &activated
+		}
+	}
+	*reply = loads
+	return nil
+}
+
+type AttrActivateTariffPlanLoad struct {
+	Tenant string
+	LoadID string
+}
+
+// ActivateTariffPlanLoad replaces the tenant tariff plan with a staged load, a previous LoadID rolls the tenant back to it
+func (api *ApiV1) ActivateTariffPlanLoad(attr AttrActivateTariffPlanLoad, reply *utils.LoadInstance) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "LoadID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	load, loadStats, err := engine.ActivateTariffPlanLoad(attr.Tenant, attr.LoadID, *api.cfg.General.DefaultTimezone, api.ratingDB, api.accountDB)
+	if err == utils.ErrNotFound {
 		return err
+	} else if err != nil {
+		return utils.NewErrServerError(err)
 	}
-	if len(loadHistList) > 0 {
-		*reply = *loadHistList[0]
-	}*/
+	api.reloadLoadedServices(loadStats)
+	*reply = *load
+	return nil
+}
 
+type AttrGetTariffPlanLoads struct {
+	Tenant string
+	Limit  int // zero for all the loads
+}
+
+// GetTariffPlanLoads replies with the staged loads of the tenant, the newest first
+func (api *ApiV1) GetTariffPlanLoads(attr AttrGetTariffPlanLoads, reply *[]*utils.LoadInstance) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	loads, err := api.accountDB.GetLoadHistory(attr.Tenant, attr.Limit)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if len(loads) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = loads
+	return nil
+}
+
+// ValidateTariffPlan reads the folder files into memory and replies with the problems the load would hit, nothing is written.
+// The references missing from the folder are searched in the tariff plan db unless FlushDB is set.
+func (api *ApiV1) ValidateTariffPlan(attr AttrLoadTpFromFolder, reply *engine.TpValidationReport) error {
+	if len(attr.FolderPath) == 0 {
+		return fmt.Errorf("%s:%s", utils.ErrMandatoryIeMissing.Error(), "FolderPath")
+	}
+	csvSep := utils.CSV_SEP
+	if attr.CsvSeparator != "" {
+		csvSep = []rune(attr.CsvSeparator)[0]
+	}
+	ratingDB := api.ratingDB
+	if attr.FlushDB {
+		ratingDB = nil
+	}
+	report, err := engine.ValidateTariffPlanFolder(attr.FolderPath, *api.cfg.General.DefaultTimezone, csvSep, ratingDB)
+	if os.IsNotExist(err) {
+		return utils.ErrInvalidPath
+	} else if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = *report
+	return nil
+}
+
+type AttrDiffTariffPlan struct {
+	Tenant       string
+	FolderPath   string // Compared with the tenant tariff plan in the storage
+	CsvSeparator string // Field separator of the csv files, defaults to comma
+	OldLoadID    string // Staged loads compared when FolderPath is empty
+	NewLoadID    string
+}
+
+// DiffTariffPlan replies with the tenant elements the folder would add, remove or change, or the differences between two staged loads
+func (api *ApiV1) DiffTariffPlan(attr AttrDiffTariffPlan, reply *engine.TpDiff) error {
+	mandatory := []string{"Tenant", "FolderPath"}
+	if attr.FolderPath == "" {
+		mandatory = []string{"Tenant", "OldLoadID", "NewLoadID"}
+	}
+	if missing := utils.MissingStructFields(&attr, mandatory); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	var diff *engine.TpDiff
+	var err error
+	if attr.FolderPath != "" {
+		csvSep := utils.CSV_SEP
+		if attr.CsvSeparator != "" {
+			csvSep = []rune(attr.CsvSeparator)[0]
+		}
+		if _, err = os.Stat(attr.FolderPath); os.IsNotExist(err) {
+			return utils.ErrInvalidPath
+		}
+		diff, err = engine.DiffTariffPlanFolder(attr.Tenant, attr.FolderPath, *api.cfg.General.DefaultTimezone, csvSep, api.ratingDB, api.accountDB)
+	} else {
+		diff, err = engine.DiffTariffPlanLoads(attr.Tenant, attr.OldLoadID, attr.NewLoadID, *api.cfg.General.DefaultTimezone, api.accountDB)
+	}
+	if err == utils.ErrNotFound {
+		return err
+	} else if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = *diff
+	return nil
+}
+
+type AttrImportRateDeck struct {
+	DeckPath   string
+	FolderPath string // defaults to the Tag folder inside the general tpexport_dir
+	Format     engine.RateDeckFormat
+}
+
+// ImportRateDeck converts a carrier rate deck into a tariff plan folder, replies with the number of elements per file
+func (api *ApiV1) ImportRateDeck(attr AttrImportRateDeck, reply *map[string]int) error {
+	missing := utils.MissingStructFields(&attr, []string{"DeckPath"})
+	missing = append(missing, utils.MissingStructFields(&attr.Format, []string{"Tenant", "Tag", "PrefixColumn", "RateColumn"})...)
+	if len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if attr.FolderPath == "" {
+		attr.FolderPath = path.Join(*api.cfg.General.TpexportDir, attr.Format.Tag)
+	}
+	written, err := engine.ImportRateDeck(attr.DeckPath, attr.FolderPath, &attr.Format)
+	if os.IsNotExist(err) {
+		return utils.ErrInvalidPath
+	} else if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = written
+	return nil
+}
+
+type AttrExportTariffPlan struct {
+	Tenant       string
+	FolderPath   string // defaults to the tenant folder inside the general tpexport_dir
+	Format       string // json or csv, defaults to json
+	CsvSeparator string // Field separator of the csv files, defaults to comma
+}
+
+// ExportTariffPlan writes the tenant tariff plan in the files loaded by LoadTariffPlanFromFolder, replies with the number of elements per file
+func (api *ApiV1) ExportTariffPlan(attr AttrExportTariffPlan, reply *map[string]int) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if attr.FolderPath == "" {
+		attr.FolderPath = path.Join(*api.cfg.General.TpexportDir, attr.Tenant)
+	}
+	if attr.Format == "" {
+		attr.Format = utils.JSON
+	}
+	csvSep := utils.CSV_SEP
+	if attr.CsvSeparator != "" {
+		csvSep = []rune(attr.CsvSeparator)[0]
+	}
+	exported, err := engine.ExportTariffPlanToFolder(attr.Tenant, attr.FolderPath, attr.Format, csvSep, api.ratingDB, api.accountDB)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = exported
 	return nil
 }
 
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+type AttrGenerateInvoice struct {
+	Tenant  string
+	Account string
+	Start   string // billing period start, included
+	End     string // billing period end, excluded
+}
+
+// GenerateInvoice bills the rated cdrs and the recurring charges of an account for a period
+func (api *ApiV1) GenerateInvoice(attr AttrGenerateInvoice, reply *engine.Invoice) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "Start", "End"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	start, err := utils.ParseTimeDetectLayout(attr.Start, *api.cfg.General.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	end, err := utils.ParseTimeDetectLayout(attr.End, *api.cfg.General.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	inv, err := (&engine.InvoiceGenerator{Tenant: attr.Tenant, Account: attr.Account, Start: start, End: end}).Generate()
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = *inv
+	return nil
+}
+
+type AttrGetInvoices struct {
+	Tenant  string
+	Account string // empty for all the tenant invoices
+	utils.Paginator
+}
+
+func (api *ApiV1) GetInvoices(attr AttrGetInvoices, reply *[]*engine.Invoice) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	var offset, limit int
+	if attr.Offset != nil {
+		offset = *attr.Offset
+	}
+	if attr.Limit != nil {
+		limit = *attr.Limit
+	}
+	invs, err := api.accountDB.GetInvoices(attr.Tenant, attr.Account, offset, limit)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if len(invs) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = invs
+	return nil
+}
+
+type AttrRenderInvoice struct {
+	Tenant string
+	Number int64
+	Format string // json or csv, defaults to json
+}
+
+// RenderInvoice returns the stored invoice as a json or csv document
+func (api *ApiV1) RenderInvoice(attr AttrRenderInvoice, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Number"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	inv, err := api.accountDB.GetInvoice(attr.Tenant, attr.Number)
+	if err == utils.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	switch attr.Format {
+	case "", utils.JSON:
+		*reply = utils.ToIJSON(inv)
+	case utils.CSV:
+		out, err := inv.AsCSV()
+		if err != nil {
+			return utils.NewErrServerError(err)
+		}
+		*reply = string(out)
+	default:
+		return fmt.Errorf("%s:Format:%s", utils.ErrNotImplemented.Error(), attr.Format)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+type AttrGetBalanceLedger struct {
+	Tenant      string
+	Account     string
+	BalanceUUID string // empty for all the account balances
+	BalanceType string
+	Cause       string // *debit, *refund, *action, *api, *transfer, *expiry
+	CauseID     string
+	TimeStart   string // included
+	TimeEnd     string // excluded
+	utils.Paginator
+}
+
+// GetBalanceLedger returns the balance changes of an account in the order they happened
+func (api *ApiV1) GetBalanceLedger(attr AttrGetBalanceLedger, reply *[]*engine.LedgerEntry) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	fltr := &engine.LedgerFilter{
+		Tenant:      attr.Tenant,
+		Account:     attr.Account,
+		BalanceUUID: attr.BalanceUUID,
+		BalanceType: attr.BalanceType,
+		Cause:       attr.Cause,
+		CauseID:     attr.CauseID,
+	}
+	var err error
+	if attr.TimeStart != "" {
+		if fltr.TimeStart, err = utils.ParseTimeDetectLayout(attr.TimeStart, *api.cfg.General.DefaultTimezone); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	}
+	if attr.TimeEnd != "" {
+		if fltr.TimeEnd, err = utils.ParseTimeDetectLayout(attr.TimeEnd, *api.cfg.General.DefaultTimezone); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	}
+	var offset, limit int
+	if attr.Offset != nil {
+		offset = *attr.Offset
+	}
+	if attr.Limit != nil {
+		limit = *attr.Limit
+	}
+	entries, err := api.accountDB.GetLedgerEntries(fltr, offset, limit)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if len(entries) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = entries
+	return nil
+}
+
+type AttrCheckBalanceLedger struct {
+	Tenant      string
+	Account     string
+	BalanceUUID string
+}
+
+// CheckBalanceLedger recomputes a balance out of its ledger and compares it with the account value
+func (api *ApiV1) CheckBalanceLedger(attr AttrCheckBalanceLedger, reply *engine.LedgerCheck) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "BalanceUUID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	lc, err := engine.CheckBalanceLedger(attr.Tenant, attr.Account, attr.BalanceUUID)
+	if err == utils.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = *lc
+	return nil
+}
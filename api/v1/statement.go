@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/accurateproject/accurate/cdre"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+type AttrGetAccountStatement struct {
+	Tenant  string
+	Account string
+	From    string // included
+	To      string // excluded, defaults to now
+}
+
+func (api *ApiV1) accountStatement(attr AttrGetAccountStatement) (*engine.AccountStatement, error) {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "From"}); len(missing) != 0 {
+		return nil, utils.NewErrMandatoryIeMissing(missing...)
+	}
+	from, err := utils.ParseTimeDetectLayout(attr.From, *api.cfg.General.DefaultTimezone)
+	if err != nil {
+		return nil, utils.NewErrServerError(err)
+	}
+	to := time.Now()
+	if attr.To != "" {
+		if to, err = utils.ParseTimeDetectLayout(attr.To, *api.cfg.General.DefaultTimezone); err != nil {
+			return nil, utils.NewErrServerError(err)
+		}
+	}
+	st, err := engine.NewAccountStatement(attr.Tenant, attr.Account, from, to)
+	if err == utils.ErrNotFound {
+		return nil, err
+	}
+	if err != nil {
+		return nil, utils.NewErrServerError(err)
+	}
+	return st, nil
+}
+
+// GetAccountStatement returns the opening and closing balances of a period with the balance changes in between
+func (api *ApiV1) GetAccountStatement(attr AttrGetAccountStatement, reply *engine.AccountStatement) error {
+	st, err := api.accountStatement(attr)
+	if err != nil {
+		return err
+	}
+	*reply = *st
+	return nil
+}
+
+type AttrExportAccountStatement struct {
+	AttrGetAccountStatement
+	ExportTemplate  *string // cdre template, the statement lines are exported as cdrs with the *statement run id
+	FieldSeparator  *string
+	ExportDirectory *string
+	ExportFileName  *string
+}
+
+// ExportAccountStatement writes the account statement as a csv file through the cdr exporter
+func (api *ApiV1) ExportAccountStatement(attr AttrExportAccountStatement, reply *utils.ExportedFileCdrs) error {
+	exportTemplate := (*api.cfg.Cdre)[utils.META_DEFAULT]
+	if attr.ExportTemplate != nil && len(*attr.ExportTemplate) != 0 {
+		var hasIt bool
+		if exportTemplate, hasIt = (*api.cfg.Cdre)[*attr.ExportTemplate]; !hasIt {
+			return fmt.Errorf("%s:ExportTemplate", utils.ErrNotFound)
+		}
+	}
+	fieldSep, _ := utf8.DecodeRuneInString(*exportTemplate.FieldSeparator)
+	if attr.FieldSeparator != nil && len(*attr.FieldSeparator) != 0 {
+		if fieldSep, _ = utf8.DecodeRuneInString(*attr.FieldSeparator); fieldSep == utf8.RuneError {
+			return fmt.Errorf("%s:FieldSeparator:%s", utils.ErrServerError, "Invalid")
+		}
+	}
+	st, err := api.accountStatement(attr.AttrGetAccountStatement)
+	if err != nil {
+		return err
+	}
+	exportID := strconv.FormatInt(time.Now().Unix(), 10)
+	eDir := *exportTemplate.ExportDirectory
+	if attr.ExportDirectory != nil && len(*attr.ExportDirectory) != 0 {
+		eDir = *attr.ExportDirectory
+	}
+	fileName := fmt.Sprintf("statement_%s_%s_%s.%s", st.Tenant, st.Account, exportID, utils.CSV)
+	if attr.ExportFileName != nil && len(*attr.ExportFileName) != 0 {
+		fileName = *attr.ExportFileName
+	}
+	cdrs := st.AsCDRs()
+	cdrexp, err := cdre.NewCdrExporter(cdrs, api.cdrDB, exportTemplate, utils.CSV, fieldSep, exportID, 0, 0, 0, 0, 0,
+		*api.cfg.General.RoundingDecimals, *api.cfg.General.HttpSkipTlsVerify)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	filePath := path.Join(eDir, fileName)
+	if err := cdrexp.WriteToFile(filePath); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.ExportedFileCdrs{ExportedFilePath: filePath, TotalRecords: len(cdrs), TotalCost: cdrexp.GetTotalCost()}
+	return nil
+}
@@ -166,6 +166,28 @@ func (api *ApiV1) SetTpCdrStats(tp utils.TpCdrStats, reply *string) (err error)
 	return err
 }
 
+func (api *ApiV1) SetTpExchangeRate(tp utils.TpExchangeRate, reply *string) (err error) {
+	if missing := utils.MissingStructFields(&tp, []string{"Tenant", "From", "To"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	*reply = OK
+	if err = api.getTpReader().LoadExchangeRate(&tp); err != nil {
+		*reply = err.Error()
+	}
+	return err
+}
+
+func (api *ApiV1) SetTpTaxRule(tp utils.TpTaxRule, reply *string) (err error) {
+	if missing := utils.MissingStructFields(&tp, []string{"Tenant", "Tag", "Type"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	*reply = OK
+	if err = api.getTpReader().LoadTaxRule(&tp); err != nil {
+		*reply = err.Error()
+	}
+	return err
+}
+
 func (api *ApiV1) SetTpUser(tp utils.TpUser, reply *string) (err error) {
 	if missing := utils.MissingStructFields(&tp, []string{"Tenant", "Tag"}); len(missing) != 0 {
 		return utils.NewErrMandatoryIeMissing(missing...)
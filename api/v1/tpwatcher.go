@@ -0,0 +1,178 @@
+package v1
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// TpLoadReportFile is written in the processed or failed folder with the outcome of the load
+const TpLoadReportFile = "load_report.json"
+
+// TpLoadReport is the outcome of loading a watched tariff plan folder
+type TpLoadReport struct {
+	FolderPath string
+	StartTime  time.Time
+	EndTime    time.Time
+	Loaded     bool
+	Error      string                       `json:",omitempty"`
+	Validation *engine.TpValidationReport   `json:",omitempty"`
+	Loads      []*utils.LoadInstance        `json:",omitempty"` // the staged loads activated, one per tenant
+	LoadStats  map[string]*engine.LoadStats `json:",omitempty"` // by tenant
+}
+
+// TpWatcher stages and activates the tariff plan folders dropped in the in directory, a folder is complete once its done file is written.
+// The loaded folders are moved to the processed directory and the others to the failed one, each with its load report.
+type TpWatcher struct {
+	api       *ApiV1
+	cfg       *config.TpWatcher
+	closeChan chan struct{}
+	closeOnce sync.Once
+	mux       sync.Mutex // one folder loaded at a time
+	stopped   bool
+}
+
+func NewTpWatcher(api *ApiV1, cfg *config.TpWatcher) (*TpWatcher, error) {
+	for _, dir := range []string{*cfg.InDir, *cfg.ProcessedDir, *cfg.FailedDir} {
+		if _, err := os.Stat(dir); err != nil && os.IsNotExist(err) {
+			return nil, fmt.Errorf("Nonexistent folder: %s", dir)
+		}
+	}
+	return &TpWatcher{api: api, cfg: cfg, closeChan: make(chan struct{})}, nil
+}
+
+// Stop ends Run and waits for the folder being loaded, the folders left in the in directory are loaded on the next engine start
+func (tw *TpWatcher) Stop() {
+	tw.closeOnce.Do(func() { close(tw.closeChan) })
+	tw.mux.Lock()
+	tw.stopped = true
+	tw.mux.Unlock()
+}
+
+// Run loads the complete folders already there, then watches the in directory and its folders for the done files
+func (tw *TpWatcher) Run() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(*tw.cfg.InDir); err != nil {
+		return err
+	}
+	utils.Logger.Info("<TpWatcher> Monitoring for tariff plan folders", zap.String("indir", *tw.cfg.InDir))
+	entries, _ := ioutil.ReadDir(*tw.cfg.InDir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			tw.watchFolder(watcher, path.Join(*tw.cfg.InDir, entry.Name()))
+		}
+	}
+	for {
+		select {
+		case <-tw.closeChan:
+			utils.Logger.Info("<TpWatcher> Shutting down", zap.String("indir", *tw.cfg.InDir))
+			return nil
+		case ev := <-watcher.Events:
+			if ev.Op&fsnotify.Create != fsnotify.Create {
+				continue
+			}
+			dir, name := path.Split(ev.Name)
+			if path.Clean(dir) == path.Clean(*tw.cfg.InDir) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					tw.watchFolder(watcher, ev.Name)
+				}
+			} else if name == *tw.cfg.DoneFile {
+				watcher.Remove(dir)
+				go tw.ProcessFolder(path.Clean(dir))
+			}
+		case err := <-watcher.Errors:
+			utils.Logger.Error("<TpWatcher> inotify:", zap.Error(err))
+		}
+	}
+}
+
+// watchFolder waits for the done file of a new folder, the ones already complete are processed at once
+func (tw *TpWatcher) watchFolder(watcher *fsnotify.Watcher, folderPath string) {
+	if err := watcher.Add(folderPath); err != nil {
+		utils.Logger.Error("<TpWatcher> could not watch", zap.String("folder", folderPath), zap.Error(err))
+		return
+	}
+	// the done file may have been written before the watch was added
+	if _, err := os.Stat(path.Join(folderPath, *tw.cfg.DoneFile)); err == nil {
+		watcher.Remove(folderPath)
+		go tw.ProcessFolder(folderPath)
+	}
+}
+
+// ProcessFolder validates and loads the folder, then moves it out of the in directory together with its report
+func (tw *TpWatcher) ProcessFolder(folderPath string) *TpLoadReport {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.stopped {
+		return nil
+	}
+	if _, err := os.Stat(folderPath); err != nil { // already processed
+		return nil
+	}
+	report := &TpLoadReport{FolderPath: folderPath, StartTime: time.Now()}
+	utils.Logger.Info("<TpWatcher> Loading", zap.String("folder", folderPath))
+	if err := tw.load(folderPath, report); err != nil {
+		report.Error = err.Error()
+		utils.Logger.Error("<TpWatcher> Failed loading", zap.String("folder", folderPath), zap.Error(err))
+	} else {
+		report.Loaded = true
+	}
+	report.EndTime = time.Now()
+	outDir := *tw.cfg.FailedDir
+	if report.Loaded {
+		outDir = *tw.cfg.ProcessedDir
+	}
+	outPath := path.Join(outDir, fmt.Sprintf("%s_%s", path.Base(folderPath), report.StartTime.Format("20060102150405")))
+	if err := os.Rename(folderPath, outPath); err != nil {
+		utils.Logger.Error("<TpWatcher> could not move", zap.String("folder", folderPath), zap.String("to", outPath), zap.Error(err))
+		return report
+	}
+	if err := ioutil.WriteFile(path.Join(outPath, TpLoadReportFile), []byte(utils.ToIJSON(report)), 0644); err != nil {
+		utils.Logger.Error("<TpWatcher> could not write the load report", zap.String("folder", outPath), zap.Error(err))
+	}
+	return report
+}
+
+func (tw *TpWatcher) load(folderPath string, report *TpLoadReport) error {
+	csvSep := utils.CSV_SEP
+	if *tw.cfg.CsvSeparator != "" {
+		csvSep = []rune(*tw.cfg.CsvSeparator)[0]
+	}
+	validation, err := engine.ValidateTariffPlanFolder(folderPath, *tw.api.cfg.General.DefaultTimezone, csvSep, tw.api.ratingDB)
+	if err != nil {
+		return err
+	}
+	report.Validation = validation
+	if !validation.IsValid() {
+		return fmt.Errorf("%d validation errors, first: %s", len(validation.Errors), validation.Errors[0])
+	}
+	// staged like the StageTariffPlan api, the account actions in the folder are ignored
+	loads, err := engine.StageTariffPlan(folderPath, *tw.api.cfg.General.TpstagingDir, *tw.api.cfg.General.DefaultTimezone, csvSep, tw.api.accountDB)
+	if err != nil {
+		return err
+	}
+	report.LoadStats = make(map[string]*engine.LoadStats, len(loads))
+	for _, load := range loads {
+		activated, loadStats, err := engine.ActivateTariffPlanLoad(load.Tenant, load.LoadID, *tw.api.cfg.General.DefaultTimezone, tw.api.ratingDB, tw.api.accountDB)
+		if err != nil {
+			return fmt.Errorf("activating load %s of tenant %s: %v", load.LoadID, load.Tenant, err)
+		}
+		tw.api.reloadLoadedServices(loadStats)
+		report.Loads = append(report.Loads, activated)
+		report.LoadStats[load.Tenant] = loadStats
+	}
+	return nil
+}
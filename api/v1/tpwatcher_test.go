@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestTpWatcher(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_watcher_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	ratingDb, err := engine.NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ratingDb.Close()
+	accountingDb, err := engine.NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accountingDb.Close()
+	cfg := config.NewDefault()
+	cfg.TpWatcher.InDir = utils.StringPointer(path.Join(tmpDir, "in"))
+	cfg.TpWatcher.ProcessedDir = utils.StringPointer(path.Join(tmpDir, "processed"))
+	cfg.TpWatcher.FailedDir = utils.StringPointer(path.Join(tmpDir, "failed"))
+	for _, dir := range []string{*cfg.TpWatcher.InDir, *cfg.TpWatcher.ProcessedDir, *cfg.TpWatcher.FailedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api := NewAPIV1(ratingDb, accountingDb, nil, nil, cfg, nil, nil, nil)
+	cfg.General.TpstagingDir = utils.StringPointer(path.Join(tmpDir, "staging"))
+	tw, err := NewTpWatcher(api, cfg.TpWatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFolder := func(name string, files map[string]string) {
+		folderPath := path.Join(*cfg.TpWatcher.InDir, name)
+		if err := os.MkdirAll(folderPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+		files[*cfg.TpWatcher.DoneFile] = ""
+		for _, fileName := range []string{utils.DESTINATIONS_CSV, utils.RATES_CSV, utils.DESTINATION_RATES_JSON, *cfg.TpWatcher.DoneFile} {
+			if content, found := files[fileName]; found {
+				if err := ioutil.WriteFile(path.Join(folderPath, fileName), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	waitReport := func(dir string) *TpLoadReport {
+		for i := 0; i < 100; i++ {
+			entries, _ := ioutil.ReadDir(dir)
+			if len(entries) != 0 {
+				var report TpLoadReport
+				if content, err := ioutil.ReadFile(path.Join(dir, entries[0].Name(), TpLoadReportFile)); err == nil && json.Unmarshal(content, &report) == nil {
+					return &report
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("no load report in %s", dir)
+		return nil
+	}
+
+	// already complete before the watcher starts
+	writeFolder("good", map[string]string{
+		utils.DESTINATIONS_CSV:       "wtc,GERMANY,49\n",
+		utils.RATES_CSV:              "wtc,RT_1,0,0.1,60s,1s,0s\n",
+		utils.DESTINATION_RATES_JSON: `{"Tenant":"wtc", "Tag":"DR_1", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_1"}]}`,
+	})
+	runDone := make(chan struct{})
+	go func() {
+		tw.Run()
+		close(runDone)
+	}()
+	if report := waitReport(*cfg.TpWatcher.ProcessedDir); !report.Loaded || len(report.Loads) != 1 || !report.Loads[0].Active ||
+		report.LoadStats["wtc"] == nil || !report.LoadStats["wtc"].Tenants["wtc"] {
+		t.Errorf("unexpected report: %s", utils.ToIJSON(report))
+	}
+	if drate, err := ratingDb.GetDestinationRate("wtc", "DR_1"); err != nil || drate == nil {
+		t.Error("the folder was not loaded: ", err)
+	}
+	if loads, err := accountingDb.GetLoadHistory("wtc", 0); err != nil || len(loads) != 1 || !loads[0].Active {
+		t.Errorf("the folder should be staged and activated: %s, %v", utils.ToIJSON(loads), err)
+	}
+
+	// dropped after start, failing validation
+	writeFolder("bad", map[string]string{
+		utils.DESTINATION_RATES_JSON: `{"Tenant":"wtc", "Tag":"DR_2", "Bindings":[{"DestinationTag": "FRANCE", "RatesTag": "RT_1"}]}`,
+	})
+	if report := waitReport(*cfg.TpWatcher.FailedDir); report.Loaded || report.Validation == nil || len(report.Validation.Errors) == 0 {
+		t.Errorf("unexpected report: %s", utils.ToIJSON(report))
+	}
+	if _, err := ratingDb.GetDestinationRate("wtc", "DR_2"); err == nil {
+		t.Error("the invalid folder was loaded")
+	}
+	if entries, _ := ioutil.ReadDir(*cfg.TpWatcher.InDir); len(entries) != 0 {
+		t.Errorf("the folders should be moved out of the in directory: %d", len(entries))
+	}
+
+	tw.Stop()
+	select {
+	case <-runDone:
+	case <-time.After(time.Second):
+		t.Error("the watcher did not stop")
+	}
+}
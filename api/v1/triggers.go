@@ -73,7 +73,7 @@ func (api *ApiV1) AddAccountActionTriggers(attr AttrAddAccountActionTriggers, re
 			return 0, err
 		}
 		return 0, nil
-	}, 0, attr.Account)
+	}, 0, utils.ConcatKey(attr.Tenant, attr.Account))
 	if err != nil {
 		*reply = err.Error()
 		return err
@@ -114,7 +114,7 @@ func (api *ApiV1) RemoveAccountActionTriggers(attr AttrRemoveAccountActionTrigge
 			return 0, err
 		}
 		return 0, nil
-	}, 0, attr.Account)
+	}, 0, utils.ConcatKey(attr.Tenant, attr.Account))
 	if err != nil {
 		*reply = err.Error()
 		return err
@@ -158,7 +158,7 @@ func (api *ApiV1) ResetAccountActionTriggers(attr AttrResetAccountActionTriggers
 			return 0, err
 		}
 		return 0, nil
-	}, 0, attr.Account)
+	}, 0, utils.ConcatKey(attr.Tenant, attr.Account))
 	if err != nil {
 		*reply = err.Error()
 		return err
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+type AttrCreateVoucherBatch struct {
+	Tenant     string
+	ID         string
+	ActionsID  string  // action group executed on the redeeming account
+	Value      float64 // face value, informative
+	ExpiryTime string  // empty for no expiry
+	Size       int
+	PINLength  int // defaults to 12 digits
+}
+
+// CreatedVoucherBatch is the new batch with its vouchers as csv for printing
+type CreatedVoucherBatch struct {
+	*engine.VoucherBatch
+	Cards string // the only copy of the pins, they are stored hashed
+}
+
+// CreateVoucherBatch generates a batch of vouchers with unique pins, the batch starts in the *created state
+func (api *ApiV1) CreateVoucherBatch(attr AttrCreateVoucherBatch, reply *CreatedVoucherBatch) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "ID", "ActionsID", "Size"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	var expiryTime time.Time
+	if attr.ExpiryTime != "" {
+		var err error
+		if expiryTime, err = utils.ParseTimeDetectLayout(attr.ExpiryTime, *api.cfg.General.DefaultTimezone); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	}
+	batch, cards, err := engine.NewVoucherBatch(attr.Tenant, attr.ID, attr.ActionsID, dec.NewFloat(attr.Value), expiryTime, attr.Size, attr.PINLength)
+	if err == utils.ErrExists {
+		return err
+	}
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = CreatedVoucherBatch{VoucherBatch: batch, Cards: string(cards)}
+	return nil
+}
+
+type AttrGetVoucherBatches struct {
+	Tenant string
+	utils.Paginator
+}
+
+func (api *ApiV1) GetVoucherBatches(attr AttrGetVoucherBatches, reply *[]*engine.VoucherBatch) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	var offset, limit int
+	if attr.Offset != nil {
+		offset = *attr.Offset
+	}
+	if attr.Limit != nil {
+		limit = *attr.Limit
+	}
+	batches, err := api.accountDB.GetVoucherBatches(attr.Tenant, offset, limit)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if len(batches) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = batches
+	return nil
+}
+
+type AttrSetVoucherBatchState struct {
+	Tenant string
+	ID     string
+	State  string // *activated, *sold or *blocked
+}
+
+// SetVoucherBatchState moves the batch to a new state if the transition is allowed
+func (api *ApiV1) SetVoucherBatchState(attr AttrSetVoucherBatchState, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "ID", "State"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if _, err := engine.SetVoucherBatchState(attr.Tenant, attr.ID, attr.State); err != nil {
+		return err
+	}
+	*reply = OK
+	return nil
+}
+
+type AttrExportVoucherBatch struct {
+	Tenant string
+	ID     string
+}
+
+// ExportVoucherBatch returns the batch vouchers as csv, the pins are only returned by CreateVoucherBatch
+func (api *ApiV1) ExportVoucherBatch(attr AttrExportVoucherBatch, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "ID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	out, err := engine.ExportVoucherBatch(attr.Tenant, attr.ID)
+	if err == utils.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = string(out)
+	return nil
+}
+
+type AttrRedeemVoucher struct {
+	Tenant  string
+	Account string
+	PIN     string
+	Source  string // the caller address, its failed attempts are limited apart from the account ones
+}
+
+// RedeemVoucher executes the voucher actions on the account
+func (api *ApiV1) RedeemVoucher(attr AttrRedeemVoucher, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"Tenant", "Account", "PIN"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if _, err := engine.RedeemVoucher(attr.Tenant, attr.Account, attr.Source, attr.PIN); err != nil {
+		return err
+	}
+	*reply = OK
+	return nil
+}
@@ -10,5 +10,9 @@ go install github.com/accurateproject/accurate/cmd/cc-console
 cc=$?
 go install github.com/accurateproject/accurate/cmd/cc-tester
 ct=$?
+go install github.com/accurateproject/accurate/cmd/cc-migrator
+cm=$?
+go install github.com/accurateproject/accurate/cmd/cc-simulator
+cs=$?
 
-exit $cr || $cl || $cc || $ct
+exit $cr || $cl || $cc || $ct || $cm || $cs
@@ -2,6 +2,7 @@
 package cache2go
 
 import (
+	"strings"
 	"sync"
 
 	"github.com/accurateproject/accurate/config"
@@ -23,6 +24,8 @@ var (
 	// transaction stuff
 	transactionBuffer = make(map[string][]*transactionItem)
 	transactionMux    sync.Mutex
+	// tenants isolated by a transaction, protected by mux
+	tenantTransactions = make(map[string]string)
 )
 
 type transactionItem struct {
@@ -57,12 +60,42 @@ func BeginTransaction() string {
 	return transID
 }
 
+// BeginTenantTransaction starts a transaction isolating the tenant cache: Get keeps serving the cached values
+// and the direct writes for the tenant are dropped. Committing replaces the tenant cache with a new one holding
+// only the transaction items, so the storage changes made meanwhile become visible all at once.
+func BeginTenantTransaction(tenant string) string {
+	transID := BeginTransaction()
+	mux.Lock()
+	defer mux.Unlock()
+	tenantTransactions[tenant] = transID
+	return transID
+}
+
+// endTenantTransaction resets the cache of the tenants isolated by the transaction, mux must be locked
+func endTenantTransaction(transID string) {
+	for tenant, tenantTransID := range tenantTransactions {
+		if tenantTransID == transID {
+			tenantCache[tenant] = newLruStore()
+			delete(tenantTransactions, tenant)
+		}
+	}
+}
+
+func isolated(tenant string) bool {
+	_, found := tenantTransactions[tenant]
+	return found
+}
+
 func RollbackTransaction(transID string) {
 	transactionMux.Lock()
 	defer transactionMux.Unlock()
 	if transactionBuffer != nil {
 		delete(transactionBuffer, transID)
 	}
+	// the dropped writes left the isolated tenants stale
+	mux.Lock()
+	endTenantTransaction(transID)
+	mux.Unlock()
 }
 
 func CommitTransaction(transID string) {
@@ -79,6 +112,7 @@ func CommitTransaction(transID string) {
 	}
 	// apply all transactioned items
 	mux.Lock()
+	endTenantTransaction(transID)
 	for _, item := range items {
 		switch item.kind {
 		case KIND_REM:
@@ -99,6 +133,9 @@ func Set(tenant, key string, value interface{}, transID string) {
 		if transID == "" {
 			mux.Lock()
 			defer mux.Unlock()
+			if isolated(tenant) {
+				return
+			}
 		}
 		c(tenant).Put(key, value)
 	} else {
@@ -127,6 +164,9 @@ func RemKey(tenant, key, transID string) {
 		if transID == "" {
 			mux.Lock()
 			defer mux.Unlock()
+			if isolated(tenant) {
+				return
+			}
 		}
 		c(tenant).Delete(key)
 	} else {
@@ -148,6 +188,9 @@ func RemPrefixKey(tenant, prefix, transID string) {
 		if transID == "" {
 			mux.Lock()
 			defer mux.Unlock()
+			if isolated(tenant) {
+				return
+			}
 		}
 		c(tenant).DeletePrefix(prefix)
 	} else {
@@ -174,3 +217,14 @@ func Flush(tenant string) {
 		tenantCache[tenant] = newLruStore()
 	}
 }
+
+// FlushPrefix drops the caches of the tenants starting with prefix
+func FlushPrefix(prefix string) {
+	mux.Lock()
+	defer mux.Unlock()
+	for tenant := range tenantCache {
+		if strings.HasPrefix(tenant, prefix) {
+			delete(tenantCache, tenant)
+		}
+	}
+}
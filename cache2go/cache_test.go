@@ -67,6 +67,36 @@ func TestTransactionRollback(t *testing.T) {
 	}
 }
 
+func TestTenantTransaction(t *testing.T) {
+	Set("tt", "t61_mm", "old", "")
+	Set("tt", "t61_nn", "old", "")
+	transID := BeginTenantTransaction("tt")
+	Set("tt", "t61_mm", "new", "")
+	RemKey("tt", "t61_nn", "")
+	Set("tt", "t61_oo", "new", transID)
+	Set("t", "t61_mm", "other", "")
+	if t1, ok := Get("tt", "t61_mm"); !ok || t1 != "old" {
+		t.Error("Error isolating tenant cache: ", ok, t1)
+	}
+	if t1, ok := Get("tt", "t61_nn"); !ok || t1 != "old" {
+		t.Error("Error isolating tenant cache: ", ok, t1)
+	}
+	if t1, ok := Get("t", "t61_mm"); !ok || t1 != "other" {
+		t.Error("Error setting other tenant cache: ", ok, t1)
+	}
+	CommitTransaction(transID)
+	if _, ok := Get("tt", "t61_mm"); ok {
+		t.Error("Error resetting tenant cache")
+	}
+	if t1, ok := Get("tt", "t61_oo"); !ok || t1 != "new" {
+		t.Error("Error commiting tenant transaction: ", ok, t1)
+	}
+	Set("tt", "t61_mm", "new", "")
+	if t1, ok := Get("tt", "t61_mm"); !ok || t1 != "new" {
+		t.Error("Error ending tenant transaction: ", ok, t1)
+	}
+}
+
 func TestTransactionRemBefore(t *testing.T) {
 	transID := BeginTransaction()
 	RemPrefixKey("t", "t41_", transID)
@@ -92,6 +122,17 @@ func TestRemPrefixKey(t *testing.T) {
 	}
 }
 
+func TestFlushPrefix(t *testing.T) {
+	Set("ns:t", "xxx_t1", "test", "")
+	Set("t", "xxx_t1", "test", "")
+	FlushPrefix("ns:")
+	_, okNs := Get("ns:t", "xxx_t1")
+	_, ok := Get("t", "xxx_t1")
+	if okNs || !ok {
+		t.Error("Error flushing prefix: ", okNs, ok)
+	}
+}
+
 func TestTransactionMultiple(t *testing.T) {
 	transID1 := BeginTransaction()
 	transID2 := BeginTransaction()
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/accurateproject/accurate/agents"
+	"github.com/accurateproject/accurate/api/rest"
 	"github.com/accurateproject/accurate/api/v1"
 	"github.com/accurateproject/accurate/balancer2go"
 	"github.com/accurateproject/accurate/cache2go"
@@ -508,6 +509,9 @@ func startRpc(server *utils.Server, internalRaterChan,
 	}
 	go server.ServeJSON(*cfg.Listen.RpcJson)
 	go server.ServeGOB(*cfg.Listen.RpcGob)
+	if *cfg.Listen.HttpRest {
+		server.RegisterHTTPFunc(rest.PREFIX, rest.NewGateway(rest.Routes).ServeHTTP)
+	}
 	go server.ServeHTTP(*cfg.Listen.Http)
 }
 
@@ -581,7 +585,7 @@ func main() {
 	var cdrDb engine.CdrStorage
 
 	if *cfg.Rals.Enabled || *cfg.Scheduler.Enabled || *cfg.CdrStats.Enabled { // Only connect to dataDb if necessary
-		ratingDb, err = engine.ConfigureRatingStorage(*cfg.TariffPlanDb.Host, *cfg.TariffPlanDb.Port,
+		ratingDb, err = engine.ConfigureRatingStorage(*cfg.TariffPlanDb.Type, *cfg.TariffPlanDb.Host, *cfg.TariffPlanDb.Port,
 			*cfg.TariffPlanDb.Name, *cfg.TariffPlanDb.User, *cfg.TariffPlanDb.Password, cfg.Cache, *cfg.DataDb.LoadHistorySize)
 		if err != nil { // Cannot configure getter database, show stopper
 			utils.Logger.Panic("Could not configure ratingDB exiting!", zap.Error(err))
@@ -591,7 +595,7 @@ func main() {
 		engine.SetRatingStorage(ratingDb)
 	}
 	if *cfg.Rals.Enabled || *cfg.CdrStats.Enabled || *cfg.Pubsubs.Enabled || *cfg.Aliases.Enabled || *cfg.Users.Enabled {
-		accountDb, err = engine.ConfigureAccountingStorage(*cfg.DataDb.Host, *cfg.DataDb.Port,
+		accountDb, err = engine.ConfigureAccountingStorage(*cfg.DataDb.Type, *cfg.DataDb.Host, *cfg.DataDb.Port,
 			*cfg.DataDb.Name, *cfg.DataDb.User, *cfg.DataDb.Password, cfg.Cache, *cfg.DataDb.LoadHistorySize)
 		if err != nil { // Cannot configure getter database, show stopper
 			utils.Logger.Panic("Could not configure dataDB exiting!", zap.Error(err))
@@ -605,7 +609,7 @@ func main() {
 		}
 	}
 	if *cfg.Rals.Enabled || *cfg.Cdrs.Enabled || *cfg.Scheduler.Enabled { // Only connect to storDb if necessary
-		cdrDb, err = engine.ConfigureCdrStorage(*cfg.CdrDb.Host, *cfg.CdrDb.Port,
+		cdrDb, err = engine.ConfigureCdrStorage(*cfg.CdrDb.Type, *cfg.CdrDb.Host, *cfg.CdrDb.Port,
 			*cfg.CdrDb.Name, *cfg.CdrDb.User, *cfg.CdrDb.Password, *cfg.CdrDb.MaxOpenConns, *cfg.CdrDb.MaxIdleConns, cfg.CdrDb.CdrsIndexes)
 		if err != nil { // Cannot configure logger database, show stopper
 			utils.Logger.Panic("Could not configure cdrDB exiting!", zap.Error(err))
@@ -616,6 +620,8 @@ func main() {
 
 	}
 	engine.SetRoundingDecimals(*cfg.General.RoundingDecimals)
+	engine.SetRoundingMethod(*cfg.General.RoundingMethod)
+	engine.SetTenantRoundings(cfg.General.TenantRoundings)
 	engine.SetRpSubjectPrefixMatching(*cfg.Rals.RpSubjectPrefixMatching)
 	engine.SetLcrSubjectPrefixMatching(*cfg.Rals.LcrSubjectPrefixMatching)
 	if err := engine.InitSimpleAccounts(); err != nil {
@@ -642,6 +648,7 @@ func main() {
 	internalAliaseSChan := make(chan rpcclient.RpcClientConnection, 1)
 	internalSMGChan := make(chan *sessionmanager.SMGeneric, 1)
 	internalRLSChan := make(chan rpcclient.RpcClientConnection, 1)
+	internalTpWatcherChan := make(chan *v1.TpWatcher, 1)
 	// Start balancer service
 	if *cfg.Balancer.Enabled {
 		go startBalancer(internalBalancerChan, &stopHandled, exitChan) // Not really needed async here but to cope with uniformity
@@ -650,7 +657,7 @@ func main() {
 	// Start rater service
 	if *cfg.Rals.Enabled {
 		go startRater(internalRaterChan, cacheDoneChan, internalBalancerChan, internalSchedulerChan, internalCdrStatSChan, internalHistorySChan, internalPubSubSChan, internalUserSChan, internalAliaseSChan,
-			server, ratingDb, accountDb, cdrDb, &stopHandled, exitChan)
+			internalTpWatcherChan, server, ratingDb, accountDb, cdrDb, &stopHandled, exitChan)
 	}
 
 	// Start Scheduler
@@ -736,6 +743,11 @@ func main() {
 	go startRpc(server, internalRaterChan, internalCdrSChan, internalCdrStatSChan, internalHistorySChan,
 		internalPubSubSChan, internalUserSChan, internalAliaseSChan, internalSMGChan)
 	<-exitChan
+	select {
+	case tpWatcher := <-internalTpWatcherChan:
+		tpWatcher.Stop() // let the folder being loaded finish
+	default:
+	}
 
 	if *pidFile != "" {
 		if err := os.Remove(*pidFile); err != nil {
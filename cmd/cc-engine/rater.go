@@ -34,7 +34,7 @@ func startBalancer(internalBalancerChan chan *balancer2go.Balancer, stopHandled
 // Starts rater and reports on chan
 func startRater(internalRaterChan chan rpcclient.RpcClientConnection, cacheDoneChan chan struct{}, internalBalancerChan chan *balancer2go.Balancer, internalSchedulerChan chan *scheduler.Scheduler,
 	internalCdrStatSChan, internalHistorySChan, internalPubSubSChan, internalUserSChan, internalAliaseSChan chan rpcclient.RpcClientConnection,
-	server *utils.Server,
+	internalTpWatcherChan chan *v1.TpWatcher, server *utils.Server,
 	ratingDb engine.RatingStorage, accountDb engine.AccountingStorage, cdrDb engine.CdrStorage, stopHandled *bool, exitChan chan bool) {
 	var waitTasks []chan struct{}
 
@@ -192,6 +192,21 @@ func startRater(internalRaterChan chan rpcclient.RpcClientConnection, cacheDoneC
 	// internalSchedulerChan shared here
 	server.RPCRegister(responder)
 	server.RPCRegister(apiRpcV1)
+	server.RegisterHTTPUpload("/tariff_plan_upload", "ApiV1.LoadTariffPlanFromArchive", "Archive")
+	if *cfg.TpWatcher.Enabled {
+		tpWatcher, err := v1.NewTpWatcher(apiRpcV1, cfg.TpWatcher)
+		if err != nil {
+			utils.Logger.Panic("<TpWatcher> ", zap.Error(err))
+			exitChan <- true
+			return
+		}
+		go func() {
+			if err := tpWatcher.Run(); err != nil {
+				utils.Logger.Error("<TpWatcher> ", zap.Error(err))
+			}
+		}()
+		internalTpWatcherChan <- tpWatcher // stopped on shutdown
+	}
 
 	utils.RegisterRpcParams("", &engine.Stats{})
 	utils.RegisterRpcParams("", &v1.CDRStatsV1{})
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/rpc"
+	"strings"
 	"time"
 
 	"github.com/accurateproject/accurate/config"
@@ -14,14 +17,16 @@ import (
 )
 
 var (
-	//separator = flag.String("separator", ",", "Default field separator")
-	cfg      = config.Get()
-	tpdbHost = flag.String("tp_host", *cfg.TariffPlanDb.Host, "The TariffPlan host to connect to.")
-	tpdbPort = flag.String("tp_port", *cfg.TariffPlanDb.Port, "The TariffPlan port to bind to.")
-	tpdbName = flag.String("tp_name", *cfg.TariffPlanDb.Name, "The name/number of the TariffPlan to connect to.")
-	tpdbUser = flag.String("tp_user", *cfg.TariffPlanDb.User, "The TariffPlan user to sign in as.")
-	tpdbPass = flag.String("tp_pass", *cfg.TariffPlanDb.Password, "The TariffPlan user's password.")
+	separator = flag.String("separator", ",", "Default field separator")
+	cfg       = config.Get()
+	tpdbType  = flag.String("tp_type", *cfg.TariffPlanDb.Type, "The TariffPlan database type: <mongo|bolt>.")
+	tpdbHost  = flag.String("tp_host", *cfg.TariffPlanDb.Host, "The TariffPlan host to connect to.")
+	tpdbPort  = flag.String("tp_port", *cfg.TariffPlanDb.Port, "The TariffPlan port to bind to.")
+	tpdbName  = flag.String("tp_name", *cfg.TariffPlanDb.Name, "The name/number of the TariffPlan to connect to.")
+	tpdbUser  = flag.String("tp_user", *cfg.TariffPlanDb.User, "The TariffPlan user to sign in as.")
+	tpdbPass  = flag.String("tp_pass", *cfg.TariffPlanDb.Password, "The TariffPlan user's password.")
 
+	datadbType = flag.String("data_type", *cfg.DataDb.Type, "The DataDb database type: <mongo|bolt>.")
 	datadbHost = flag.String("data_host", *cfg.DataDb.Host, "The DataDb host to connect to.")
 	datadbPort = flag.String("data_port", *cfg.DataDb.Port, "The DataDb port to bind to.")
 	datadbName = flag.String("data_name", *cfg.DataDb.Name, "The name/number of the DataDb to connect to.")
@@ -39,6 +44,14 @@ var (
 	//runID           = flag.String("runid", "", "Uniquely identify an import/load, postpended to some automatic fields")
 	loadHistorySize = flag.Int("load_history_size", *cfg.DataDb.LoadHistorySize, "Limit the number of records in the load history")
 	timezone        = flag.String("timezone", *cfg.General.DefaultTimezone, `Timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>`)
+	validate        = flag.Bool("validate", false, "Check the tariff plan files and their references without loading them")
+	exportTenant    = flag.String("export", "", "Export the tariff plan of this tenant into the path folder instead of loading it")
+	exportFormat    = flag.String("export_format", utils.JSON, "Format of the exported files: <json|csv>")
+	diffTenant      = flag.String("diff", "", "Show the changes the path folder would make to the tariff plan of this tenant instead of loading it")
+	diffLoads       = flag.String("diff_loads", "", "With -diff compare two staged loads of the tenant instead: <old_load_id,new_load_id>")
+	diffJSON        = flag.Bool("diff_json", false, "Print the diff as JSON")
+	rateDeck        = flag.String("rate_deck", "", "Convert this carrier rate deck into a tariff plan in the path folder instead of loading it")
+	rateDeckFormat  = flag.String("rate_deck_format", "", "JSON file describing the rate deck columns")
 )
 
 func main() {
@@ -53,8 +66,8 @@ func main() {
 	var rater, cdrstats, users *rpc.Client
 	// Init necessary db connections, only if not already
 	// load from csv files to dataDb
-	ratingDb, errRatingDb = engine.ConfigureRatingStorage(*tpdbHost, *tpdbPort, *tpdbName, *tpdbUser, *tpdbPass, cfg.Cache, *loadHistorySize)
-	accountDb, errAccDb = engine.ConfigureAccountingStorage(*datadbHost, *datadbPort, *datadbName, *datadbUser, *datadbPass, cfg.Cache, *loadHistorySize)
+	ratingDb, errRatingDb = engine.ConfigureRatingStorage(*tpdbType, *tpdbHost, *tpdbPort, *tpdbName, *tpdbUser, *tpdbPass, cfg.Cache, *loadHistorySize)
+	accountDb, errAccDb = engine.ConfigureAccountingStorage(*datadbType, *datadbHost, *datadbPort, *datadbName, *datadbUser, *datadbPass, cfg.Cache, *loadHistorySize)
 	// Defer databases opened to be closed when we are done
 	for _, db := range []engine.Storage{ratingDb, accountDb} {
 		if db != nil {
@@ -67,8 +80,76 @@ func main() {
 			log.Fatalf("Could not open database connection: %v", err)
 		}
 	}
+	if *validate {
+		var validationDb engine.RatingStorage
+		if !*flush {
+			validationDb = ratingDb
+		}
+		report, err := engine.ValidateTariffPlanFolder(*path, *timezone, []rune(*separator)[0], validationDb)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, issue := range report.Warnings {
+			log.Print("WARNING: ", issue)
+		}
+		for _, issue := range report.Errors {
+			log.Print("ERROR: ", issue)
+		}
+		if !report.IsValid() {
+			log.Fatalf("Found %d errors and %d warnings", len(report.Errors), len(report.Warnings))
+		}
+		log.Printf("The tariff plan is valid, found %d warnings", len(report.Warnings))
+		return
+	}
+	if *exportTenant != "" {
+		exported, err := engine.ExportTariffPlanToFolder(*exportTenant, *path, *exportFormat, []rune(*separator)[0], ratingDb, accountDb)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for fileName, count := range exported {
+			log.Printf("Exported %d elements to %s", count, fileName)
+		}
+		return
+	}
+	if *rateDeck != "" {
+		format := &engine.RateDeckFormat{}
+		if content, err := ioutil.ReadFile(*rateDeckFormat); err != nil {
+			log.Fatalf("Could not read the rate deck format: %v", err)
+		} else if err := json.Unmarshal(content, format); err != nil {
+			log.Fatalf("Invalid rate deck format: %v", err)
+		}
+		written, err := engine.ImportRateDeck(*rateDeck, *path, format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for fileName, count := range written {
+			log.Printf("Wrote %d elements to %s", count, fileName)
+		}
+		return
+	}
+	if *diffTenant != "" {
+		var diff *engine.TpDiff
+		if *diffLoads != "" {
+			loadIDs := strings.Split(*diffLoads, utils.FIELDS_SEP)
+			if len(loadIDs) != 2 {
+				log.Fatalf("Invalid -diff_loads %s, expecting <old_load_id,new_load_id>", *diffLoads)
+			}
+			diff, err = engine.DiffTariffPlanLoads(*diffTenant, loadIDs[0], loadIDs[1], *timezone, accountDb)
+		} else {
+			diff, err = engine.DiffTariffPlanFolder(*diffTenant, *path, *timezone, []rune(*separator)[0], ratingDb, accountDb)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *diffJSON {
+			fmt.Println(utils.ToIJSON(diff))
+		} else {
+			fmt.Print(diff)
+		}
+		return
+	}
 	// load from csv files to dataDb
-	tpReader, err := engine.LoadTariffPlanFromFolder(*path, *timezone, ratingDb, accountDb)
+	tpReader, err := engine.LoadTariffPlanFromFolder(*path, *timezone, []rune(*separator)[0], ratingDb, accountDb)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+var (
+	cfg      = config.Get()
+	tpdbType = flag.String("tp_type", *cfg.TariffPlanDb.Type, "The TariffPlan database type: <mongo|bolt>.")
+	tpdbHost = flag.String("tp_host", *cfg.TariffPlanDb.Host, "The TariffPlan host to connect to.")
+	tpdbPort = flag.String("tp_port", *cfg.TariffPlanDb.Port, "The TariffPlan port to bind to.")
+	tpdbName = flag.String("tp_name", *cfg.TariffPlanDb.Name, "The name/number of the TariffPlan to connect to.")
+	tpdbUser = flag.String("tp_user", *cfg.TariffPlanDb.User, "The TariffPlan user to sign in as.")
+	tpdbPass = flag.String("tp_pass", *cfg.TariffPlanDb.Password, "The TariffPlan user's password.")
+
+	datadbType = flag.String("data_type", *cfg.DataDb.Type, "The DataDb database type: <mongo|bolt>.")
+	datadbHost = flag.String("data_host", *cfg.DataDb.Host, "The DataDb host to connect to.")
+	datadbPort = flag.String("data_port", *cfg.DataDb.Port, "The DataDb port to bind to.")
+	datadbName = flag.String("data_name", *cfg.DataDb.Name, "The name/number of the DataDb to connect to.")
+	datadbUser = flag.String("data_user", *cfg.DataDb.User, "The DataDb user to sign in as.")
+	datadbPass = flag.String("data_pass", *cfg.DataDb.Password, "The DataDb user's password.")
+
+	cdrdbType = flag.String("cdr_type", *cfg.CdrDb.Type, "The CdrDb database type: <mongo|bolt|mysql|postgres|sqlite3>.")
+	cdrdbHost = flag.String("cdr_host", *cfg.CdrDb.Host, "The CdrDb host to connect to.")
+	cdrdbPort = flag.String("cdr_port", *cfg.CdrDb.Port, "The CdrDb port to bind to.")
+	cdrdbName = flag.String("cdr_name", *cfg.CdrDb.Name, "The name/number of the CdrDb to connect to.")
+	cdrdbUser = flag.String("cdr_user", *cfg.CdrDb.User, "The CdrDb user to sign in as.")
+	cdrdbPass = flag.String("cdr_pass", *cfg.CdrDb.Password, "The CdrDb user's password.")
+
+	toTpdbType = flag.String("to_tp_type", "", "The destination TariffPlan database type, empty to skip copying tariff plans: <mongo|bolt>.")
+	toTpdbHost = flag.String("to_tp_host", *cfg.TariffPlanDb.Host, "The destination TariffPlan host to connect to.")
+	toTpdbPort = flag.String("to_tp_port", *cfg.TariffPlanDb.Port, "The destination TariffPlan port to bind to.")
+	toTpdbName = flag.String("to_tp_name", *cfg.TariffPlanDb.Name, "The name/number of the destination TariffPlan to connect to.")
+	toTpdbUser = flag.String("to_tp_user", *cfg.TariffPlanDb.User, "The destination TariffPlan user to sign in as.")
+	toTpdbPass = flag.String("to_tp_pass", *cfg.TariffPlanDb.Password, "The destination TariffPlan user's password.")
+
+	toDatadbType = flag.String("to_data_type", "", "The destination DataDb database type, empty to skip copying data: <mongo|bolt>.")
+	toDatadbHost = flag.String("to_data_host", *cfg.DataDb.Host, "The destination DataDb host to connect to.")
+	toDatadbPort = flag.String("to_data_port", *cfg.DataDb.Port, "The destination DataDb port to bind to.")
+	toDatadbName = flag.String("to_data_name", *cfg.DataDb.Name, "The name/number of the destination DataDb to connect to.")
+	toDatadbUser = flag.String("to_data_user", *cfg.DataDb.User, "The destination DataDb user to sign in as.")
+	toDatadbPass = flag.String("to_data_pass", *cfg.DataDb.Password, "The destination DataDb user's password.")
+
+	toCdrdbType = flag.String("to_cdr_type", "", "The destination CdrDb database type, empty to skip copying cdrs: <mongo|bolt|mysql|postgres|sqlite3>.")
+	toCdrdbHost = flag.String("to_cdr_host", *cfg.CdrDb.Host, "The destination CdrDb host to connect to.")
+	toCdrdbPort = flag.String("to_cdr_port", *cfg.CdrDb.Port, "The destination CdrDb port to bind to.")
+	toCdrdbName = flag.String("to_cdr_name", *cfg.CdrDb.Name, "The name/number of the destination CdrDb to connect to.")
+	toCdrdbUser = flag.String("to_cdr_user", *cfg.CdrDb.User, "The destination CdrDb user to sign in as.")
+	toCdrdbPass = flag.String("to_cdr_pass", *cfg.CdrDb.Password, "The destination CdrDb user's password.")
+
+	dryRun          = flag.Bool("dry_run", false, "Report the migration steps and the documents to copy without writing anything")
+	statePath       = flag.String("state", "", "File keeping the copy progress so an interrupted copy can be resumed, empty to disable")
+	version         = flag.Bool("version", false, "Prints the application version.")
+	loadHistorySize = flag.Int("load_history_size", *cfg.DataDb.LoadHistorySize, "Limit the number of records in the load history")
+)
+
+func loadState(path string) (map[string]int, error) {
+	progress := make(map[string]int)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return progress, json.Unmarshal(data, &progress)
+}
+
+func saveState(path string, progress map[string]int) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	// write and rename so an interruption never leaves a truncated state
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Println("accuRate " + utils.VERSION)
+		return
+	}
+	ratingDb, err := engine.ConfigureRatingStorage(*tpdbType, *tpdbHost, *tpdbPort, *tpdbName, *tpdbUser, *tpdbPass, cfg.Cache, *loadHistorySize)
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err)
+	}
+	defer ratingDb.Close()
+	accountDb, err := engine.ConfigureAccountingStorage(*datadbType, *datadbHost, *datadbPort, *datadbName, *datadbUser, *datadbPass, cfg.Cache, *loadHistorySize)
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err)
+	}
+	defer accountDb.Close()
+	var cdrDb engine.CdrStorage
+	if *toCdrdbType != "" { // cdrs are only read when copying
+		if cdrDb, err = engine.ConfigureCdrStorage(*cdrdbType, *cdrdbHost, *cdrdbPort, *cdrdbName, *cdrdbUser, *cdrdbPass,
+			*cfg.CdrDb.MaxOpenConns, *cfg.CdrDb.MaxIdleConns, cfg.CdrDb.CdrsIndexes); err != nil {
+			log.Fatalf("Could not open database connection: %v", err)
+		}
+		defer cdrDb.Close()
+	}
+
+	migrator := engine.NewMigrator(ratingDb, accountDb, cdrDb, *dryRun)
+	if err := migrator.Migrate(); err != nil {
+		fmt.Print(migrator.Report)
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	var toRatingDb engine.RatingStorage
+	var toAccountDb engine.AccountingStorage
+	var toCdrDb engine.CdrStorage
+	if *toTpdbType != "" {
+		if toRatingDb, err = engine.ConfigureRatingStorage(*toTpdbType, *toTpdbHost, *toTpdbPort, *toTpdbName, *toTpdbUser, *toTpdbPass, cfg.Cache, *loadHistorySize); err != nil {
+			log.Fatalf("Could not open destination database connection: %v", err)
+		}
+		defer toRatingDb.Close()
+	}
+	if *toDatadbType != "" {
+		if toAccountDb, err = engine.ConfigureAccountingStorage(*toDatadbType, *toDatadbHost, *toDatadbPort, *toDatadbName, *toDatadbUser, *toDatadbPass, cfg.Cache, *loadHistorySize); err != nil {
+			log.Fatalf("Could not open destination database connection: %v", err)
+		}
+		defer toAccountDb.Close()
+	}
+	if *toCdrdbType != "" {
+		if toCdrDb, err = engine.ConfigureCdrStorage(*toCdrdbType, *toCdrdbHost, *toCdrdbPort, *toCdrdbName, *toCdrdbUser, *toCdrdbPass,
+			*cfg.CdrDb.MaxOpenConns, *cfg.CdrDb.MaxIdleConns, cfg.CdrDb.CdrsIndexes); err != nil {
+			log.Fatalf("Could not open destination database connection: %v", err)
+		}
+		defer toCdrDb.Close()
+	}
+	if toRatingDb != nil || toAccountDb != nil || toCdrDb != nil {
+		if *statePath != "" {
+			if migrator.Progress, err = loadState(*statePath); err != nil {
+				log.Fatalf("Could not read the copy state: %v", err)
+			}
+			migrator.OnProgress = func(progress map[string]int) error { return saveState(*statePath, progress) }
+		}
+		if err := migrator.CopyTo(toRatingDb, toAccountDb, toCdrDb); err != nil {
+			fmt.Print(migrator.Report)
+			log.Fatalf("Copy failed: %v", err)
+		}
+	}
+	fmt.Print(migrator.Report)
+}
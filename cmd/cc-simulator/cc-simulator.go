@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/engine"
+	"github.com/accurateproject/accurate/utils"
+)
+
+var (
+	cfg       = config.Get()
+	cdrdbType = flag.String("cdr_type", *cfg.CdrDb.Type, "The CdrDb database type: <mongo|bolt|mysql|postgres|sqlite3>.")
+	cdrdbHost = flag.String("cdr_host", *cfg.CdrDb.Host, "The CdrDb host to connect to.")
+	cdrdbPort = flag.String("cdr_port", *cfg.CdrDb.Port, "The CdrDb port to bind to.")
+	cdrdbName = flag.String("cdr_name", *cfg.CdrDb.Name, "The name/number of the CdrDb to connect to.")
+	cdrdbUser = flag.String("cdr_user", *cfg.CdrDb.User, "The CdrDb user to sign in as.")
+	cdrdbPass = flag.String("cdr_pass", *cfg.CdrDb.Password, "The CdrDb user's password.")
+
+	path      = flag.String("path", "./", "The path to folder containing the candidate tariff plan files")
+	separator = flag.String("separator", ",", "Field separator of the csv tariff plan files")
+	filter    = flag.String("filter", "{}", "JSON encoded CDRsFilter selecting the cdrs to re-rate")
+	version   = flag.Bool("version", false, "Prints the application version.")
+	timezone  = flag.String("timezone", *cfg.General.DefaultTimezone, `Timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>`)
+)
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Println("accuRate " + utils.VERSION)
+		return
+	}
+	fieldSep, _ := utf8.DecodeRuneInString(*separator)
+	if fieldSep == utf8.RuneError {
+		log.Fatalf("Invalid separator: %q", *separator)
+	}
+	cdrFilter := new(utils.CDRsFilter)
+	if err := json.Unmarshal([]byte(*filter), cdrFilter); err != nil {
+		log.Fatalf("Could not parse the cdr filter: %v", err)
+	}
+	cdrDb, err := engine.ConfigureCdrStorage(*cdrdbType, *cdrdbHost, *cdrdbPort, *cdrdbName, *cdrdbUser, *cdrdbPass,
+		*cfg.CdrDb.MaxOpenConns, *cfg.CdrDb.MaxIdleConns, cfg.CdrDb.CdrsIndexes)
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err)
+	}
+	defer cdrDb.Close()
+	sim, err := engine.SimulateRating(*path, *timezone, fieldSep, cdrDb, cdrFilter)
+	if err != nil {
+		log.Fatalf("Simulation failed: %v", err)
+	}
+	fmt.Println(utils.ToIJSON(sim))
+}
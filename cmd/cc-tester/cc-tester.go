@@ -22,11 +22,13 @@ var (
 	memprofile      = flag.String("memprofile", "", "write memory profile to this file")
 	runs            = flag.Int("runs", 10000, "stress cycle number")
 	parallel        = flag.Int("parallel", 0, "run n requests in parallel")
+	ratingdbType    = flag.String("tp_type", *cfg.TariffPlanDb.Type, "The RatingDb type: <mongo|bolt>.")
 	ratingdbHost    = flag.String("tp_host", *cfg.TariffPlanDb.Host, "The RatingDb host to connect to.")
 	ratingdbPort    = flag.String("tp_port", *cfg.TariffPlanDb.Port, "The RatingDb port to bind to.")
 	ratingdbName    = flag.String("tp_name", *cfg.TariffPlanDb.Name, "The name/number of the RatingDb to connect to.")
 	ratingdbUser    = flag.String("tp_user", *cfg.TariffPlanDb.User, "The RatingDb user to sign in as.")
 	ratingdbPass    = flag.String("tp_passwd", *cfg.TariffPlanDb.Password, "The RatingDb user's password.")
+	accountdbType   = flag.String("data_type", *cfg.DataDb.Type, "The AccountingDb type: <mongo|bolt>.")
 	accountdbHost   = flag.String("data_host", *cfg.DataDb.Host, "The AccountingDb host to connect to.")
 	accountdbPort   = flag.String("data_port", *cfg.DataDb.Port, "The AccountingDb port to bind to.")
 	accountdbName   = flag.String("data_name", *cfg.DataDb.Name, "The name/number of the AccountingDb to connect to.")
@@ -44,13 +46,13 @@ var (
 )
 
 func durInternalRater(cd *engine.CallDescriptor) (time.Duration, error) {
-	ratingDb, err := engine.ConfigureRatingStorage(*ratingdbHost, *ratingdbPort, *ratingdbName, *ratingdbUser, *ratingdbPass, cfg.Cache, *loadHistorySize)
+	ratingDb, err := engine.ConfigureRatingStorage(*ratingdbType, *ratingdbHost, *ratingdbPort, *ratingdbName, *ratingdbUser, *ratingdbPass, cfg.Cache, *loadHistorySize)
 	if err != nil {
 		return nilDuration, fmt.Errorf("Could not connect to rating database: %s", err.Error())
 	}
 	defer ratingDb.Close()
 	engine.SetRatingStorage(ratingDb)
-	accountDb, err := engine.ConfigureAccountingStorage(*accountdbHost, *accountdbPort, *accountdbName, *accountdbUser, *accountdbPass, cfg.Cache, *loadHistorySize)
+	accountDb, err := engine.ConfigureAccountingStorage(*accountdbType, *accountdbHost, *accountdbPort, *accountdbName, *accountdbUser, *accountdbPass, cfg.Cache, *loadHistorySize)
 	if err != nil {
 		return nilDuration, fmt.Errorf("Could not connect to accounting database: %s", err.Error())
 	}
@@ -91,7 +91,10 @@ func NewDefault() *Config {
 			InstanceID:         utils.StringPointer(utils.GenUUID()),
 			HttpSkipTlsVerify:  utils.BoolPointer(false),
 			RoundingDecimals:   utils.IntPointer(5),
+			RoundingMethod:     utils.StringPointer(""),
+			TenantRoundings:    make(map[string]*Rounding),
 			TpexportDir:        utils.StringPointer("/var/spool/accurate/tpe"),
+			TpstagingDir:       utils.StringPointer("/var/spool/accurate/tps"),
 			HttpPosterAttempts: utils.IntPointer(3),
 			HttpFailedDir:      utils.StringPointer("/var/spool/accurate/http_failed"),
 			DefaultRequestType: utils.StringPointer("*rated"),
@@ -122,12 +125,14 @@ func NewDefault() *Config {
 		},
 
 		Listen: &Listen{
-			RpcJson: utils.StringPointer("127.0.0.1:2012"),
-			RpcGob:  utils.StringPointer("127.0.0.1:2013"),
-			Http:    utils.StringPointer("127.0.0.1:2080"),
+			RpcJson:  utils.StringPointer("127.0.0.1:2012"),
+			RpcGob:   utils.StringPointer("127.0.0.1:2013"),
+			Http:     utils.StringPointer("127.0.0.1:2080"),
+			HttpRest: utils.BoolPointer(false),
 		},
 
 		TariffPlanDb: &TariffPlanDb{
+			Type:     utils.StringPointer(utils.MONGO),
 			Host:     utils.StringPointer("127.0.0.1"),
 			Port:     utils.StringPointer("27017"),
 			Name:     utils.StringPointer("tpdb"),
@@ -136,6 +141,7 @@ func NewDefault() *Config {
 		},
 
 		DataDb: &DataDb{
+			Type:            utils.StringPointer(utils.MONGO),
 			Host:            utils.StringPointer("127.0.0.1"),
 			Port:            utils.StringPointer("27017"),
 			Name:            utils.StringPointer("datadb"),
@@ -145,6 +151,7 @@ func NewDefault() *Config {
 		},
 
 		CdrDb: &CdrDb{
+			Type:         utils.StringPointer(utils.MONGO),
 			Host:         utils.StringPointer("127.0.0.1"),
 			Port:         utils.StringPointer("27017"),
 			Name:         utils.StringPointer("cdrdb"),
@@ -180,6 +187,7 @@ func NewDefault() *Config {
 			ExtraFields:    nil,
 			StoreCdrs:      utils.BoolPointer(true),
 			AccountSummary: utils.BoolPointer(false),
+			Taxes:          utils.BoolPointer(false),
 			SmCostRetries:  utils.IntPointer(5),
 			RalsConns:      []*HaPool{&HaPool{Address: "*internal"}},
 			PubsubsConns:   []*HaPool{},
@@ -400,6 +408,15 @@ func NewDefault() *Config {
 			SaveInterval: durPointer(1 * time.Second),
 		},
 
+		TpWatcher: &TpWatcher{
+			Enabled:      utils.BoolPointer(false),
+			InDir:        utils.StringPointer("/var/spool/accurate/tpwatcher/in"),
+			ProcessedDir: utils.StringPointer("/var/spool/accurate/tpwatcher/processed"),
+			FailedDir:    utils.StringPointer("/var/spool/accurate/tpwatcher/failed"),
+			DoneFile:     utils.StringPointer(".done"),
+			CsvSeparator: utils.StringPointer(","),
+		},
+
 		Pubsubs: &Pubsubs{
 			Enabled: utils.BoolPointer(false),
 		},
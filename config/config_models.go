@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/accurateproject/accurate/dec"
 	"github.com/accurateproject/accurate/utils"
 )
 
@@ -32,6 +33,7 @@ type Config struct {
 	SmAsterisk    *SmAsterisk       `json:"sm_asterisk"`
 	DiameterAgent *DiameterAgent    `json:"diameter_agent"`
 	Historys      *Historys         `json:"historys"`
+	TpWatcher     *TpWatcher        `json:"tp_watcher"`
 	Pubsubs       *Pubsubs          `json:"pubsubs"`
 	Aliases       *Aliases          `json:"aliases"`
 	Users         *Users            `json:"users"`
@@ -44,7 +46,9 @@ type General struct {
 	InstanceID         *string // Identifier for this engine instance
 	HttpSkipTlsVerify  *bool   `json:"http_skip_tls_verify"`      // if enabled Http Client will accept any TLS certificate
 	RoundingDecimals   *int    `json:"rounding_decimals"`         // system level precision for floats
+	RoundingMethod     *string `json:"rounding_method"`           // system level rounding of the costs to rounding_decimals: <""|*up|*middle|*down|*half_even|*ceiling|*floor>, empty keeps the significant digits
 	TpexportDir        *string `json:"tpexport_dir"`              // path towards export folder for offline Tariff Plans
+	TpstagingDir       *string `json:"tpstaging_dir"`             // path towards the versioned copies of the staged Tariff Plans
 	HttpPosterAttempts *int    `json:"httpposter_attempts"`       // number of http attempts before considering request failed (eg: *call_url)
 	HttpFailedDir      *string `json:"http_failed_dir"`           // directory path where we store failed http requests
 	DefaultRequestType *string `json:"default_request_type"`      // default request type to consider when missing from requests: <""|*prepaid|*postpaid|*pseudoprepaid|*rated>
@@ -60,6 +64,15 @@ type General struct {
 	LockingTimeout     *dur    `json:"locking_timeout,string"`    // timeout internal locks to avoid deadlocks
 	LogLevel           *int    `json:"log_level"`                 // control the level of messages logged (0-emerg to 7-debug)
 	DataFolderPath     string  // Path towards data folder, for tests internal usage, not loading out of .json options
+
+	TenantRoundings map[string]*Rounding `json:"tenant_roundings"` // rounding of the tenant costs, overriding the system level one
+}
+
+// Rounding of a tenant costs, on each call or only on the invoice total
+type Rounding struct {
+	Decimals int    `json:"decimals"`
+	Method   string `json:"method"` // <*up|*middle|*down|*half_even|*ceiling|*floor>
+	Scope    string `json:"scope"`  // <*call|*invoice>, the calls of *invoice tenants keep the system level rounding
 }
 
 type Cache struct {
@@ -76,12 +89,14 @@ type Cache struct {
 }
 
 type Listen struct {
-	RpcJson *string `json:"rpc_json"` // RPC JSON listening address
-	RpcGob  *string `json:"rpc_gob"`  // RPC GOB listening address
-	Http    *string `json:"http"`     // HTTP listening address
+	RpcJson  *string `json:"rpc_json"`  // RPC JSON listening address
+	RpcGob   *string `json:"rpc_gob"`   // RPC GOB listening address
+	Http     *string `json:"http"`      // HTTP listening address
+	HttpRest *bool   `json:"http_rest"` // expose the REST gateway over the APIs on the HTTP listener
 }
 
 type TariffPlanDb struct { // database used to store active tariff plan configuration
+	Type     *string `json:"db_type"`     // tariffplan_db type: <mongo|bolt>
 	Host     *string `json:"db_host"`     // tariffplan_db host address
 	Port     *string `json:"db_port"`     // port to reach the tariffplan_db
 	Name     *string `json:"db_name"`     // tariffplan_db name to connect to
@@ -90,6 +105,7 @@ type TariffPlanDb struct { // database used to store active tariff plan configur
 }
 
 type DataDb struct { // database used to store runtime data (eg: accounts, cdr stats)
+	Type            *string `json:"db_type"`           // data_db type: <mongo|bolt>
 	Host            *string `json:"db_host"`           // data_db host address
 	Port            *string `json:"db_port"`           // data_db port to reach the database
 	Name            *string `json:"db_name"`           // data_db database name to connect to
@@ -99,6 +115,7 @@ type DataDb struct { // database used to store runtime data (eg: accounts, cdr s
 }
 
 type CdrDb struct { // database used to store offline tariff plans and CDRs
+	Type         *string  `json:"db_type"`        // cdr_db type: <mongo|bolt|mysql|postgres|sqlite3>
 	Host         *string  `json:"db_host"`        // the host to connect to
 	Port         *string  `json:"db_port"`        // the port to reach the stordb
 	Name         *string  `json:"db_name"`        // stor database name
@@ -134,6 +151,7 @@ type Cdrs struct {
 	ExtraFields    RsrList           `json:"extra_fields"`    // extra fields to store in CDRs for non-generic CDRs
 	StoreCdrs      *bool             `json:"store_cdrs"`      // store cdrs in storDb
 	AccountSummary *bool             `json:"account_summary"` // add account information from dataDB
+	Taxes          *bool             `json:"taxes"`           // apply the tariff plan tax rules on the rated CDRs
 	SmCostRetries  *int              `json:"sm_cost_retries"` // number of queries to sm_costs before recalculating CDR
 	RalsConns      []*HaPool         `json:"rals_conns"`      // address where to reach the Rater for cost calculation, empty to disable functionality: <""|*internal|x.y.z.y:1234>
 	PubsubsConns   []*HaPool         `json:"pubsubs_conns"`   // address where to reach the pubusb service, empty to disable pubsub functionality: <""|*internal|x.y.z.y:1234>
@@ -335,6 +353,15 @@ type Historys struct {
 	SaveInterval *dur    `json:"save_interval,string"` // interval to save changed cache into .git archive
 }
 
+type TpWatcher struct {
+	Enabled      *bool   `json:"enabled"`       // stage and activate the tariff plan folders dropped in the in_dir: <true|false>
+	InDir        *string `json:"in_dir"`        // absolute path towards the directory where the tariff plan folders are dropped
+	ProcessedDir *string `json:"processed_dir"` // the loaded folders are moved here together with their load report
+	FailedDir    *string `json:"failed_dir"`    // the folders failing validation or load are moved here together with their load report
+	DoneFile     *string `json:"done_file"`     // file written last inside the folder to mark it complete
+	CsvSeparator *string `json:"csv_separator"` // field separator of the csv files
+}
+
 type Pubsubs struct {
 	Enabled *bool `json:"enabled"` // starts PubSub service: <true|false>.
 }
@@ -393,6 +420,17 @@ type SureTax struct {
 }
 
 func (c *Config) checkConfigSanity() error {
+	if *c.General.RoundingMethod != "" && !dec.IsRoundingMethod(*c.General.RoundingMethod) {
+		return fmt.Errorf("Unknown rounding method: %s", *c.General.RoundingMethod)
+	}
+	for tenant, rounding := range c.General.TenantRoundings {
+		if !dec.IsRoundingMethod(rounding.Method) {
+			return fmt.Errorf("Unknown rounding method for tenant %s: %s", tenant, rounding.Method)
+		}
+		if rounding.Scope != "" && rounding.Scope != utils.ROUNDING_SCOPE_CALL && rounding.Scope != utils.ROUNDING_SCOPE_INVOICE {
+			return fmt.Errorf("Unknown rounding scope for tenant %s: %s", tenant, rounding.Scope)
+		}
+	}
 	if *c.Rals.Enabled {
 		if *c.Rals.Balancer == utils.MetaInternal && !*c.Balancer.Enabled {
 			return errors.New("Balancer not enabled but requested by Rater component.")
@@ -0,0 +1,44 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdSetAccountState{
+		name:      "account_state_set",
+		rpcMethod: "ApiV1.SetAccountState",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdSetAccountState struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrSetAccountState
+	*CommandExecuter
+}
+
+func (self *CmdSetAccountState) Name() string {
+	return self.name
+}
+
+func (self *CmdSetAccountState) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdSetAccountState) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrSetAccountState{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdSetAccountState) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdSetAccountState) RpcResult() interface{} {
+	var s string
+	return &s
+}
@@ -0,0 +1,48 @@
+package console
+
+import (
+	"github.com/accurateproject/accurate/api/v1"
+	"github.com/accurateproject/accurate/engine"
+)
+
+func init() {
+	c := &CmdGetAccountStatement{
+		name:      "account_statement",
+		rpcMethod: "ApiV1.GetAccountStatement",
+		rpcParams: &v1.AttrGetAccountStatement{},
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdGetAccountStatement struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrGetAccountStatement
+	*CommandExecuter
+}
+
+func (self *CmdGetAccountStatement) Name() string {
+	return self.name
+}
+
+func (self *CmdGetAccountStatement) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdGetAccountStatement) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrGetAccountStatement{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdGetAccountStatement) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdGetAccountStatement) RpcResult() interface{} {
+	var st engine.AccountStatement
+	return &st
+}
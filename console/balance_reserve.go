@@ -0,0 +1,45 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdBalanceReserve{
+		name:      "balance_reserve",
+		rpcMethod: "ApiV1.ReserveBalance",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdBalanceReserve struct {
+	name       string
+	rpcMethod  string
+	rpcParams  *v1.AttrReserveBalance
+	clientArgs []string
+	*CommandExecuter
+}
+
+func (self *CmdBalanceReserve) Name() string {
+	return self.name
+}
+
+func (self *CmdBalanceReserve) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdBalanceReserve) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrReserveBalance{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdBalanceReserve) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdBalanceReserve) RpcResult() interface{} {
+	var s string
+	return &s
+}
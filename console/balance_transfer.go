@@ -0,0 +1,45 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdBalanceTransfer{
+		name:      "balance_transfer",
+		rpcMethod: "ApiV1.TransferBalance",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdBalanceTransfer struct {
+	name       string
+	rpcMethod  string
+	rpcParams  *v1.AttrTransferBalance
+	clientArgs []string
+	*CommandExecuter
+}
+
+func (self *CmdBalanceTransfer) Name() string {
+	return self.name
+}
+
+func (self *CmdBalanceTransfer) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdBalanceTransfer) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrTransferBalance{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdBalanceTransfer) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdBalanceTransfer) RpcResult() interface{} {
+	var s string
+	return &s
+}
@@ -0,0 +1,44 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdSetCreditLimit{
+		name:      "credit_limit_set",
+		rpcMethod: "ApiV1.SetCreditLimit",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdSetCreditLimit struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrSetCreditLimit
+	*CommandExecuter
+}
+
+func (self *CmdSetCreditLimit) Name() string {
+	return self.name
+}
+
+func (self *CmdSetCreditLimit) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdSetCreditLimit) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrSetCreditLimit{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdSetCreditLimit) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdSetCreditLimit) RpcResult() interface{} {
+	var s string
+	return &s
+}
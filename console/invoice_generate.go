@@ -0,0 +1,47 @@
+package console
+
+import (
+	"github.com/accurateproject/accurate/api/v1"
+	"github.com/accurateproject/accurate/engine"
+)
+
+func init() {
+	c := &CmdInvoiceGenerate{
+		name:      "invoice_generate",
+		rpcMethod: "ApiV1.GenerateInvoice",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdInvoiceGenerate struct {
+	name       string
+	rpcMethod  string
+	rpcParams  *v1.AttrGenerateInvoice
+	clientArgs []string
+	*CommandExecuter
+}
+
+func (self *CmdInvoiceGenerate) Name() string {
+	return self.name
+}
+
+func (self *CmdInvoiceGenerate) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdInvoiceGenerate) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrGenerateInvoice{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdInvoiceGenerate) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdInvoiceGenerate) RpcResult() interface{} {
+	return &engine.Invoice{}
+}
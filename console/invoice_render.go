@@ -0,0 +1,45 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdInvoiceRender{
+		name:      "invoice_render",
+		rpcMethod: "ApiV1.RenderInvoice",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdInvoiceRender struct {
+	name       string
+	rpcMethod  string
+	rpcParams  *v1.AttrRenderInvoice
+	clientArgs []string
+	*CommandExecuter
+}
+
+func (self *CmdInvoiceRender) Name() string {
+	return self.name
+}
+
+func (self *CmdInvoiceRender) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdInvoiceRender) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrRenderInvoice{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdInvoiceRender) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdInvoiceRender) RpcResult() interface{} {
+	var s string
+	return &s
+}
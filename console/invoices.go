@@ -0,0 +1,48 @@
+package console
+
+import (
+	"github.com/accurateproject/accurate/api/v1"
+	"github.com/accurateproject/accurate/engine"
+)
+
+func init() {
+	c := &CmdGetInvoices{
+		name:      "invoices",
+		rpcMethod: "ApiV1.GetInvoices",
+		rpcParams: &v1.AttrGetInvoices{},
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdGetInvoices struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrGetInvoices
+	*CommandExecuter
+}
+
+func (self *CmdGetInvoices) Name() string {
+	return self.name
+}
+
+func (self *CmdGetInvoices) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdGetInvoices) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrGetInvoices{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdGetInvoices) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdGetInvoices) RpcResult() interface{} {
+	a := make([]*engine.Invoice, 0)
+	return &a
+}
@@ -0,0 +1,45 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdReservationCapture{
+		name:      "reservation_capture",
+		rpcMethod: "ApiV1.CaptureReservation",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdReservationCapture struct {
+	name       string
+	rpcMethod  string
+	rpcParams  *v1.AttrCaptureReservation
+	clientArgs []string
+	*CommandExecuter
+}
+
+func (self *CmdReservationCapture) Name() string {
+	return self.name
+}
+
+func (self *CmdReservationCapture) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdReservationCapture) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrCaptureReservation{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdReservationCapture) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdReservationCapture) RpcResult() interface{} {
+	var s string
+	return &s
+}
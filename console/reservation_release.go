@@ -0,0 +1,45 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdReservationRelease{
+		name:      "reservation_release",
+		rpcMethod: "ApiV1.ReleaseReservation",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdReservationRelease struct {
+	name       string
+	rpcMethod  string
+	rpcParams  *v1.AttrReleaseReservation
+	clientArgs []string
+	*CommandExecuter
+}
+
+func (self *CmdReservationRelease) Name() string {
+	return self.name
+}
+
+func (self *CmdReservationRelease) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdReservationRelease) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrReleaseReservation{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdReservationRelease) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdReservationRelease) RpcResult() interface{} {
+	var s string
+	return &s
+}
@@ -0,0 +1,43 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdCreateVoucherBatch{
+		name:      "voucher_batch_create",
+		rpcMethod: "ApiV1.CreateVoucherBatch",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdCreateVoucherBatch struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrCreateVoucherBatch
+	*CommandExecuter
+}
+
+func (self *CmdCreateVoucherBatch) Name() string {
+	return self.name
+}
+
+func (self *CmdCreateVoucherBatch) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdCreateVoucherBatch) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrCreateVoucherBatch{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdCreateVoucherBatch) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdCreateVoucherBatch) RpcResult() interface{} {
+	return &v1.CreatedVoucherBatch{}
+}
@@ -0,0 +1,44 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdExportVoucherBatch{
+		name:      "voucher_batch_export",
+		rpcMethod: "ApiV1.ExportVoucherBatch",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdExportVoucherBatch struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrExportVoucherBatch
+	*CommandExecuter
+}
+
+func (self *CmdExportVoucherBatch) Name() string {
+	return self.name
+}
+
+func (self *CmdExportVoucherBatch) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdExportVoucherBatch) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrExportVoucherBatch{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdExportVoucherBatch) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdExportVoucherBatch) RpcResult() interface{} {
+	var s string
+	return &s
+}
@@ -0,0 +1,44 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdSetVoucherBatchState{
+		name:      "voucher_batch_state",
+		rpcMethod: "ApiV1.SetVoucherBatchState",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdSetVoucherBatchState struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrSetVoucherBatchState
+	*CommandExecuter
+}
+
+func (self *CmdSetVoucherBatchState) Name() string {
+	return self.name
+}
+
+func (self *CmdSetVoucherBatchState) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdSetVoucherBatchState) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrSetVoucherBatchState{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdSetVoucherBatchState) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdSetVoucherBatchState) RpcResult() interface{} {
+	var s string
+	return &s
+}
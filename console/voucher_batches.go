@@ -0,0 +1,47 @@
+package console
+
+import (
+	"github.com/accurateproject/accurate/api/v1"
+	"github.com/accurateproject/accurate/engine"
+)
+
+func init() {
+	c := &CmdGetVoucherBatches{
+		name:      "voucher_batches",
+		rpcMethod: "ApiV1.GetVoucherBatches",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdGetVoucherBatches struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrGetVoucherBatches
+	*CommandExecuter
+}
+
+func (self *CmdGetVoucherBatches) Name() string {
+	return self.name
+}
+
+func (self *CmdGetVoucherBatches) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdGetVoucherBatches) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrGetVoucherBatches{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdGetVoucherBatches) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdGetVoucherBatches) RpcResult() interface{} {
+	a := make([]*engine.VoucherBatch, 0)
+	return &a
+}
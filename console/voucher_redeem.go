@@ -0,0 +1,44 @@
+package console
+
+import "github.com/accurateproject/accurate/api/v1"
+
+func init() {
+	c := &CmdRedeemVoucher{
+		name:      "voucher_redeem",
+		rpcMethod: "ApiV1.RedeemVoucher",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdRedeemVoucher struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrRedeemVoucher
+	*CommandExecuter
+}
+
+func (self *CmdRedeemVoucher) Name() string {
+	return self.name
+}
+
+func (self *CmdRedeemVoucher) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdRedeemVoucher) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &v1.AttrRedeemVoucher{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdRedeemVoucher) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdRedeemVoucher) RpcResult() interface{} {
+	var s string
+	return &s
+}
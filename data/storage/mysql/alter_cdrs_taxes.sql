@@ -0,0 +1,5 @@
+--
+-- Add the tax breakdown to the cdrs table
+--
+
+ALTER TABLE cdrs ADD COLUMN taxes text AFTER account_summary;
@@ -29,6 +29,7 @@ CREATE TABLE cdrs (
   cost DECIMAL(20,4) NOT NULL,
   cost_details text,
   account_summary text,
+  taxes text,
   extra_info text,
   created_at TIMESTAMP NULL,
   updated_at TIMESTAMP NULL,
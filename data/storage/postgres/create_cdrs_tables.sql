@@ -29,6 +29,7 @@ CREATE TABLE cdrs (
  cost NUMERIC(20,4) DEFAULT NULL,
  cost_details jsonb,
  account_summary jsonb,
+ taxes jsonb,
  extra_info text,
  created_at TIMESTAMP,
  updated_at TIMESTAMP NULL,
@@ -118,6 +118,45 @@ func (d *Dec) Round(n int32) *Dec {
 	return d
 }
 
+// rounding methods for RoundTo, *up and *down round away from and toward zero
+const (
+	RoundUp       = "*up"
+	RoundMiddle   = "*middle"
+	RoundDown     = "*down"
+	RoundHalfEven = "*half_even"
+	RoundCeiling  = "*ceiling"
+	RoundFloor    = "*floor"
+)
+
+var roundingModes = map[string]decimal.RoundingMode{
+	RoundUp:       decimal.AwayFromZero,
+	RoundMiddle:   decimal.ToNearestAway,
+	RoundDown:     decimal.ToZero,
+	RoundHalfEven: decimal.ToNearestEven,
+	RoundCeiling:  decimal.ToPositiveInf,
+	RoundFloor:    decimal.ToNegativeInf,
+}
+
+// IsRoundingMethod tells if RoundTo knows the method
+func IsRoundingMethod(method string) bool {
+	_, found := roundingModes[method]
+	return found
+}
+
+// RoundTo rounds d to the number of decimals with the rounding method, unlike Round that keeps significant digits.
+// The values with less decimals are left as they are.
+func (d *Dec) RoundTo(decimals int32, method string) *Dec {
+	mode, found := roundingModes[method]
+	if !found || !d.Big.IsFinite() || d.Big.Scale() <= int(decimals) {
+		return d
+	}
+	ctx := d.Big.Context
+	ctx.RoundingMode = mode
+	ctx.Precision = d.Big.Precision() // only dropping digits
+	ctx.Quantize(d.Big, int(decimals))
+	return d
+}
+
 func (d *Dec) Cmp(o *Dec) int {
 	return d.Big.Cmp(o.Big)
 }
@@ -172,6 +172,36 @@ func TestDecRoundMode(t *testing.T) {
 	}
 }
 
+func TestDecRoundTo(t *testing.T) {
+	for _, test := range []struct {
+		value, method, expected string
+	}{
+		{"1.125", RoundUp, "1.13"},
+		{"1.121", RoundUp, "1.13"},
+		{"-1.121", RoundUp, "-1.13"},
+		{"1.125", RoundMiddle, "1.13"},
+		{"1.125", RoundHalfEven, "1.12"},
+		{"1.135", RoundHalfEven, "1.14"},
+		{"1.129", RoundDown, "1.12"},
+		{"-1.129", RoundDown, "-1.12"},
+		{"1.121", RoundCeiling, "1.13"},
+		{"-1.129", RoundCeiling, "-1.12"},
+		{"1.129", RoundFloor, "1.12"},
+		{"-1.121", RoundFloor, "-1.13"},
+		{"1.1", RoundUp, "1.1"},
+		{"123456789012345.125", RoundHalfEven, "123456789012345.12"},
+		{"1.129", "*unknown", "1.129"},
+	} {
+		x, err := New().SetString(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := x.RoundTo(2, test.method).String(); result != test.expected {
+			t.Errorf("%s %s: expected %s, got %s", test.value, test.method, test.expected, result)
+		}
+	}
+}
+
 func TestDecInt64(t *testing.T) {
 	x := NewVal(10240000000000, 0).MulS(NewFloat(0.000976563))
 	if x.Int64() != 10000005120 {
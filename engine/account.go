@@ -25,9 +25,17 @@ type Account struct {
 	TriggerIDs        utils.StringMap                 `bson:"trigger_ids"` // trigger groups ids
 	TriggerRecords    map[string]*ActionTriggerRecord `bson:"trigger_records"`
 	AllowNegative     bool                            `bson:"allow_negative"`
+	CreditLimit       *dec.Dec                        `bson:"credit_limit,omitempty"` // how far below zero the monetary balances may go, supersedes AllowNegative
 	Disabled          bool                            `bson:"disabled"`
+	State             string                          `bson:"state"`         // lifecycle state, empty for *active
+	StateChanges      []*AccountStateChange           `bson:"state_changes"` // state transitions history
+	GraceUntil        time.Time                       `bson:"grace_until"`   // end of the *grace state, suspended afterwards
+	Reservations      map[string]*Reservation         `bson:"reservations"`  // balance holds by id
+	Vouchers          utils.StringMap                 `bson:"vouchers"`      // serials of the vouchers redeemed on the account
 	executingTriggers bool
 	triggers          ActionTriggers
+	ledger            *accountLedger        // balance changes to journal on save
+	unpublishedStates []*AccountStateChange // state changes to publish on save
 }
 
 func (acc *Account) getTriggers() ActionTriggers {
@@ -54,7 +62,7 @@ func (acc *Account) getTriggers() ActionTriggers {
 }
 
 // User's available minutes for the specified destination
-func (ub *Account) getCreditForPrefix(cd *CallDescriptor) (duration time.Duration, credit *dec.Dec, balances Balances) {
+func (ub *Account) getCreditForPrefix(cd *CallDescriptor) (duration time.Duration, credit *dec.Dec, balances Balances, err error) {
 	creditBalances := ub.getBalancesForPrefix(cd.Destination, cd.Category, cd.Direction, utils.MONETARY, "")
 
 	unitBalances := ub.getBalancesForPrefix(cd.Destination, cd.Category, cd.Direction, cd.TOR, "")
@@ -89,6 +97,34 @@ func (ub *Account) getCreditForPrefix(cd *CallDescriptor) (duration time.Duratio
 		}
 	}
 	credit = extendedCreditBalances.GetTotalValue()
+	currency := ""
+	if extendedCreditBalances.hasCurrency() {
+		// sum the credit in the rating currency
+		currency = cd.getRatingCurrency()
+		credit = dec.New()
+		for _, cb := range extendedCreditBalances {
+			if cb.IsExpired() || !cb.IsActive() {
+				continue
+			}
+			conv, err := getCurrencyConversion(ub.Tenant, cb.Currency, currency, cd.TimeStart)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			credit.AddS(conv.Convert(cb.GetValue()))
+		}
+	}
+	// the credit limit can be spent as well, it is set in the currency of the default balance
+	defaultBalance := ub.defaultMoneyBalance()
+	if limit := ub.creditLimit(defaultBalance); limit != nil {
+		if defaultBalance != nil {
+			conv, err := getCurrencyConversion(ub.Tenant, defaultBalance.Currency, currency, cd.TimeStart)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			limit = conv.Convert(limit)
+		}
+		credit = dec.New().Add(credit, limit)
+	}
 	balances = extendedMinuteBalances
 	for _, b := range balances {
 		d, c := b.GetMinutesForCredit(cd, credit)
@@ -408,6 +444,7 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 					//log.Printf("partCC: %+v", utils.ToIJSON(partCC))
 					cc.Timespans = append(cc.Timespans, partCC.Timespans...)
 					cc.negativeConnectFee = partCC.negativeConnectFee
+					cc.mergeCurrency(partCC)
 					// for i, ts := range cc.Timespans {
 					//  log.Printf("cc.times[an[%d]: %+v\n", i, ts)
 					// }
@@ -450,6 +487,7 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 				if partCC != nil {
 					cc.Timespans = append(cc.Timespans, partCC.Timespans...)
 					cc.negativeConnectFee = partCC.negativeConnectFee
+					cc.mergeCurrency(partCC)
 					//log.Print("partCC: ", utils.ToIJSON(partCC))
 					/*for i, ts := range cc.Timespans {
 						log.Printf("cc.times[an[%d]: %+v\n", i, ts)
@@ -483,6 +521,7 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 	if err != nil {
 		utils.Logger.Error("Error getting cost for left CC: ", zap.String("tenant", cd.Tenant), zap.String("subject", cd.Subject), zap.Error(err))
 	}
+	cc.mergeCurrency(leftCC)
 	if leftCC.GetCost().IsZero() && len(leftCC.Timespans) > 0 {
 		// put AccountID ubformation in increments
 		for _, ts := range leftCC.Timespans {
@@ -500,14 +539,26 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 		cc.Timespans = append(cc.Timespans, leftCC.Timespans...)
 		if initialLength == 0 {
 			// this is the first add, debit the connect fee
-			ub.DebitConnectionFee(cc, usefulMoneyBalances, count, true)
+			if _, err := ub.DebitConnectionFee(cc, usefulMoneyBalances, count, true); err != nil {
+				return nil, err
+			}
 		}
 		//log.Printf("Left CC: %+v ", leftCC)
 		// get the default money balanance
 		// and go negative on it with the amount still unpaid
-		if len(leftCC.Timespans) > 0 && leftCC.GetCost().GtZero() && !ub.AllowNegative && !dryRun {
+		defaultBalance := ub.GetDefaultMoneyBalance()
+		if len(leftCC.Timespans) > 0 && leftCC.GetCost().GtZero() && !ub.AllowNegative && !ub.hasCreditLimit(defaultBalance) && !dryRun {
 			utils.Logger.Warn("<Rater> Going negative on account with AllowNegative: false", zap.String("tenant", cd.Tenant), zap.String("accID", cd.getAccountName()))
 		}
+		conv, err := getCurrencyConversion(cd.Tenant, leftCC.Currency, defaultBalance.Currency, cd.TimeStart)
+		if err != nil {
+			return nil, err
+		}
+		// the credit limit is enforced when authorizing, the used service is always debited
+		if ub.hasCreditLimit(defaultBalance) && !dryRun && ub.creditLeft(defaultBalance).Cmp(conv.Convert(leftCC.GetCost())) < 0 {
+			utils.Logger.Warn("<Rater> Going past the credit limit", zap.String("tenant", cd.Tenant), zap.String("accID", cd.getAccountName()))
+		}
+		cc.addConversion(conv)
 		leftCC.Timespans.Decompress()
 		for _, ts := range leftCC.Timespans {
 			if ts.Increments == nil {
@@ -515,8 +566,7 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 			}
 			ts.Increments.Reset()
 			for incrIndex, increment := ts.Increments.Next(); increment != nil; incrIndex, increment = ts.Increments.Next() {
-				cost := increment.Cost
-				defaultBalance := ub.GetDefaultMoneyBalance()
+				cost := conv.Convert(increment.getCost())
 				defaultBalance.SubstractValue(cost)
 				if increment.BalanceInfo.Monetary == nil {
 					increment.BalanceInfo.Monetary = &MonetaryInfo{
@@ -525,6 +575,7 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 					}
 				}
 				increment.BalanceInfo.Monetary.getValue().Set(defaultBalance.Value)
+				increment.BalanceInfo.Monetary.ExchangeRate = conv.GetRate()
 				increment.BalanceInfo.AccountID = ub.Name
 				increment.paid++
 				if count {
@@ -665,6 +716,22 @@ func (acc *Account) ExecuteActionTriggers(a *Action, post bool) (postATIDs []str
 							postATIDs = append(postATIDs, at.UniqueID)
 						}
 					}
+				case utils.TRIGGER_MIN_CREDIT:
+					match, err := b.MatchActionTrigger(at)
+					if err != nil {
+						utils.Logger.Error(fmt.Sprintf("<ActionTrigger> action trigger filter error %s %s", at.Filter, err.Error()))
+					}
+					if !match || !acc.hasCreditLimit(b) {
+						continue
+					}
+					if acc.creditLeft(b).Cmp(at.ThresholdValue) <= 0 {
+						if !post {
+							at.Execute(acc, nil)
+						} else {
+							acc.TriggerRecords[at.UniqueID].Executed = true
+							postATIDs = append(postATIDs, at.UniqueID)
+						}
+					}
 				case utils.TRIGGER_BALANCE_EXPIRED:
 					match, err := b.MatchActionTrigger(at)
 					if err != nil {
@@ -903,36 +970,51 @@ func (acc *Account) Clone() *Account {
 		TriggerIDs:     nil, // not used when cloned (dryRun)
 		TriggerRecords: nil, // not used when cloned (dryRun)
 		AllowNegative:  acc.AllowNegative,
+		CreditLimit:    acc.CreditLimit,
 		Disabled:       acc.Disabled,
+		State:          acc.State,
+		GraceUntil:     acc.GraceUntil,
 	}
 	for key, balanceChain := range acc.BalanceMap {
 		newAcc.BalanceMap[key] = balanceChain.Clone()
 	}
+	if acc.Reservations != nil {
+		newAcc.Reservations = make(map[string]*Reservation, len(acc.Reservations))
+		for id, r := range acc.Reservations {
+			newAcc.Reservations[id] = r.Clone()
+		}
+	}
 	return newAcc
 }
 
-func (acc *Account) DebitConnectionFee(cc *CallCost, usefulMoneyBalances Balances, count bool, block bool) bool {
+// DebitConnectionFee returns false if a blocker balance could not pay the fee, a missing exchange rate fails the debit
+func (acc *Account) DebitConnectionFee(cc *CallCost, usefulMoneyBalances Balances, count bool, block bool) (bool, error) {
 	if cc.deductConnectFee {
 		connectFee := cc.GetConnectFee()
 		if connectFee.IsZero() {
-			return true
+			return true, nil
 		}
 		//log.Print("CONNECT FEE: %f", connectFee)
 		connectFeePaid := false
 		for _, b := range usefulMoneyBalances {
-			if b.GetValue().Cmp(connectFee) >= 0 {
-				b.SubstractValue(connectFee)
+			conv, err := getCurrencyConversion(cc.Tenant, cc.Currency, b.Currency, cc.GetStartTime())
+			if err != nil {
+				return false, err
+			}
+			if amount := conv.Convert(connectFee); b.GetValue().Cmp(amount) >= 0 {
+				b.SubstractValue(amount)
+				cc.addConversion(conv)
 				// the conect fee is not refundable!
 				if count {
 					inc := cc.GetFirstIncrement()
-					pats := acc.countUnits(connectFee, utils.MONETARY, cc, b)
+					pats := acc.countUnits(amount, utils.MONETARY, cc, b)
 					inc.AddPostATIDs(1, pats)
 				}
 				connectFeePaid = true
 				break
 			}
 			if b.Blocker && block { // stop here
-				return false
+				return false, nil
 			}
 		}
 		// debit connect fee
@@ -940,16 +1022,22 @@ func (acc *Account) DebitConnectionFee(cc *CallCost, usefulMoneyBalances Balance
 			cc.negativeConnectFee = true
 			// there are no money for the connect fee; go negative
 			b := acc.GetDefaultMoneyBalance()
-			b.SubstractValue(connectFee)
+			conv, err := getCurrencyConversion(cc.Tenant, cc.Currency, b.Currency, cc.GetStartTime())
+			if err != nil {
+				return false, err
+			}
+			amount := conv.Convert(connectFee)
+			b.SubstractValue(amount)
+			cc.addConversion(conv)
 			// the conect fee is not refundable!
 			if count {
 				inc := cc.GetFirstIncrement()
-				pats := acc.countUnits(connectFee, utils.MONETARY, cc, b)
+				pats := acc.countUnits(amount, utils.MONETARY, cc, b)
 				inc.AddPostATIDs(1, pats)
 			}
 		}
 	}
-	return true
+	return true, nil
 }
 
 func (acc *Account) matchActionFilter(condition string) (bool, error) {
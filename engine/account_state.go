@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+// account lifecycle states
+const (
+	ACCOUNT_PENDING    = "*pending"    // created, waiting for activation
+	ACCOUNT_ACTIVE     = "*active"     // all calls allowed
+	ACCOUNT_BARRED_OUT = "*barred_out" // incoming and emergency calls only
+	ACCOUNT_SUSPENDED  = "*suspended"  // emergency calls only
+	ACCOUNT_GRACE      = "*grace"      // all calls allowed for a while before suspension
+	ACCOUNT_CLOSED     = "*closed"     // no calls, final
+)
+
+// AccountGraceDuration is how long the *grace state lasts when no duration is given
+var AccountGraceDuration = 72 * time.Hour
+
+// accountTransitions are the states reachable from each state
+var accountTransitions = map[string]utils.StringMap{
+	ACCOUNT_PENDING:    utils.NewStringMap(ACCOUNT_ACTIVE, ACCOUNT_CLOSED),
+	ACCOUNT_ACTIVE:     utils.NewStringMap(ACCOUNT_BARRED_OUT, ACCOUNT_SUSPENDED, ACCOUNT_GRACE, ACCOUNT_CLOSED),
+	ACCOUNT_BARRED_OUT: utils.NewStringMap(ACCOUNT_ACTIVE, ACCOUNT_SUSPENDED, ACCOUNT_GRACE, ACCOUNT_CLOSED),
+	ACCOUNT_GRACE:      utils.NewStringMap(ACCOUNT_ACTIVE, ACCOUNT_BARRED_OUT, ACCOUNT_SUSPENDED, ACCOUNT_CLOSED),
+	ACCOUNT_SUSPENDED:  utils.NewStringMap(ACCOUNT_ACTIVE, ACCOUNT_GRACE, ACCOUNT_CLOSED),
+	ACCOUNT_CLOSED:     utils.StringMap{},
+}
+
+// AccountStateChange records a lifecycle transition of the account
+type AccountStateChange struct {
+	From  string    `bson:"from"`
+	To    string    `bson:"to"`
+	Time  time.Time `bson:"time"`
+	Cause string    `bson:"cause"` // the action group or the api reason
+}
+
+// IsAccountState tells if the state is a known lifecycle state
+func IsAccountState(state string) bool {
+	_, found := accountTransitions[state]
+	return found
+}
+
+// GetState returns the lifecycle state, the accounts without one are active and the expired grace is suspended
+func (acc *Account) GetState() string {
+	if acc.State == "" {
+		return ACCOUNT_ACTIVE
+	}
+	if acc.State == ACCOUNT_GRACE && !acc.GraceUntil.IsZero() && !time.Now().Before(acc.GraceUntil) {
+		return ACCOUNT_SUSPENDED
+	}
+	return acc.State
+}
+
+// InitState sets the state of a new account, without transition checks
+func (acc *Account) InitState(state, cause string) error {
+	if !IsAccountState(state) {
+		return fmt.Errorf("unknown account state: %s", state)
+	}
+	acc.changeState(state, cause)
+	return nil
+}
+
+// SetState moves the account to the state if the transition is allowed, the change is published when the account is saved
+func (acc *Account) SetState(state, cause string) error {
+	if !IsAccountState(state) {
+		return fmt.Errorf("unknown account state: %s", state)
+	}
+	acc.expireGrace()
+	if state == acc.GetState() {
+		return nil
+	}
+	if !accountTransitions[acc.GetState()][state] {
+		return utils.ErrInvalidStateTransition
+	}
+	acc.changeState(state, cause)
+	return nil
+}
+
+// SetGraceState moves the account to *grace for the duration, AccountGraceDuration when zero
+func (acc *Account) SetGraceState(duration time.Duration, cause string) error {
+	if duration <= 0 {
+		duration = AccountGraceDuration
+	}
+	if err := acc.SetState(ACCOUNT_GRACE, cause); err != nil {
+		return err
+	}
+	acc.GraceUntil = time.Now().Add(duration)
+	return nil
+}
+
+// expireGrace records the suspension of an account whose grace has ended, published when the account is saved
+func (acc *Account) expireGrace() {
+	if acc.State == ACCOUNT_GRACE && acc.GetState() == ACCOUNT_SUSPENDED {
+		acc.changeState(ACCOUNT_SUSPENDED, "*grace_expired")
+	}
+}
+
+func (acc *Account) changeState(state, cause string) {
+	change := &AccountStateChange{From: acc.State, To: state, Time: time.Now(), Cause: cause}
+	acc.State = state
+	if state != ACCOUNT_GRACE {
+		acc.GraceUntil = time.Time{}
+	}
+	acc.StateChanges = append(acc.StateChanges, change)
+	acc.unpublishedStates = append(acc.unpublishedStates, change)
+}
+
+// publishStateChanges publishes the state changes once the account was saved
+func (acc *Account) publishStateChanges() {
+	for _, change := range acc.unpublishedStates {
+		Publish(CgrEvent{
+			"EventName": utils.EVT_ACCOUNT_STATE_CHANGED,
+			"Tenant":    acc.Tenant,
+			"Account":   acc.Name,
+			"From":      change.From,
+			"To":        change.To,
+			"Time":      change.Time.String(),
+			"Cause":     change.Cause,
+		})
+	}
+	acc.unpublishedStates = nil
+}
+
+// authorizeCall checks the lifecycle state allows a call in the direction to the destination
+func (acc *Account) authorizeCall(direction, destination string) error {
+	switch acc.GetState() {
+	case ACCOUNT_ACTIVE, ACCOUNT_GRACE:
+		return nil
+	case ACCOUNT_BARRED_OUT:
+		if direction == utils.IN || isEmergency(acc.Tenant, destination) {
+			return nil
+		}
+		return utils.ErrAccountBarred
+	case ACCOUNT_SUSPENDED:
+		if isEmergency(acc.Tenant, destination) {
+			return nil
+		}
+		return utils.ErrAccountSuspended
+	case ACCOUNT_PENDING:
+		if isEmergency(acc.Tenant, destination) {
+			return nil
+		}
+		return utils.ErrAccountNotActive
+	}
+	return utils.ErrAccountClosed
+}
+
+// authorizeTransfer checks the lifecycle state allows moving value out of the account (out) or into it
+func (acc *Account) authorizeTransfer(out bool) error {
+	if out {
+		return acc.authorizeCall(utils.OUT, "")
+	}
+	if acc.GetState() == ACCOUNT_CLOSED {
+		return utils.ErrAccountClosed
+	}
+	return nil
+}
+
+// isEmergency tells if the number belongs to the *emergency destination of the tenant
+func isEmergency(tenant, number string) bool {
+	if number == "" {
+		return false
+	}
+	dests, err := ratingStorage.GetDestinations(tenant, number, "", utils.DestMatching, utils.CACHED)
+	if err != nil {
+		return false
+	}
+	for _, dest := range dests {
+		if dest.Name == utils.META_EMERGENCY {
+			return true
+		}
+	}
+	return false
+}
+
+// accountStateAction returns the action function moving the account to the state
+func accountStateAction(state string) actionTypeFunc {
+	return func(acc *Account, sq *StatsQueueTriggered, a *Action, acs Actions) error {
+		if acc == nil {
+			return errors.New("nil account")
+		}
+		return acc.SetState(state, stateActionCause(a))
+	}
+}
+
+// graceAccountAction moves the account to *grace for the params duration ({"Duration":"72h"}), AccountGraceDuration by default
+func graceAccountAction(acc *Account, sq *StatsQueueTriggered, a *Action, acs Actions) error {
+	if acc == nil {
+		return errors.New("nil account")
+	}
+	var duration time.Duration
+	if a.Params != "" {
+		var x struct {
+			Duration string
+		}
+		if err := json.Unmarshal([]byte(a.Params), &x); err != nil {
+			return err
+		}
+		if x.Duration != "" {
+			var err error
+			if duration, err = utils.ParseDurationWithSecs(x.Duration); err != nil {
+				return err
+			}
+		}
+	}
+	return acc.SetGraceState(duration, stateActionCause(a))
+}
+
+// stateActionCause is the action group name, the action type for the actions without group
+func stateActionCause(a *Action) string {
+	if a.parentGroup != nil {
+		return a.parentGroup.Name
+	}
+	return a.ActionType
+}
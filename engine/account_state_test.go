@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/cache2go"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestAccountStateTransitions(t *testing.T) {
+	acc := &Account{Tenant: "state", Name: "trans"}
+	if acc.GetState() != ACCOUNT_ACTIVE {
+		t.Errorf("accounts without state should be active: %s", acc.GetState())
+	}
+	if err := acc.SetState(ACCOUNT_PENDING, "test"); err != utils.ErrInvalidStateTransition {
+		t.Errorf("expected invalid transition, got: %v", err)
+	}
+	if err := acc.SetState("*unknown", "test"); err == nil {
+		t.Error("unknown state accepted")
+	}
+	for _, state := range []string{ACCOUNT_BARRED_OUT, ACCOUNT_SUSPENDED, ACCOUNT_GRACE, ACCOUNT_CLOSED} {
+		if err := acc.SetState(state, "test"); err != nil {
+			t.Fatalf("%s: %v", state, err)
+		}
+	}
+	if err := acc.SetState(ACCOUNT_ACTIVE, "test"); err != utils.ErrInvalidStateTransition {
+		t.Errorf("closed accounts should stay closed, got: %v", err)
+	}
+	if len(acc.StateChanges) != 4 || acc.StateChanges[0].From != "" || acc.StateChanges[0].To != ACCOUNT_BARRED_OUT ||
+		acc.StateChanges[3].From != ACCOUNT_GRACE || acc.StateChanges[3].Time.IsZero() {
+		t.Errorf("bad state changes: %s", utils.ToIJSON(acc.StateChanges))
+	}
+}
+
+func TestAccountStateAuthorize(t *testing.T) {
+	cache2go.Set("state", utils.DESTINATION_PREFIX+utils.ConcatKey("112", "", utils.DestMatching),
+		Destinations{&Destination{Tenant: "state", Code: "112", Name: utils.META_EMERGENCY}}, "")
+	acc := &Account{Tenant: "state", Name: "auth"}
+	for _, c := range []struct {
+		state, direction, destination string
+		err                           error
+	}{
+		{ACCOUNT_ACTIVE, utils.OUT, "0723", nil},
+		{ACCOUNT_GRACE, utils.OUT, "0723", nil},
+		{ACCOUNT_BARRED_OUT, utils.OUT, "0723", utils.ErrAccountBarred},
+		{ACCOUNT_BARRED_OUT, utils.IN, "0723", nil},
+		{ACCOUNT_BARRED_OUT, utils.OUT, "112", nil},
+		{ACCOUNT_SUSPENDED, utils.IN, "0723", utils.ErrAccountSuspended},
+		{ACCOUNT_SUSPENDED, utils.OUT, "112", nil},
+		{ACCOUNT_PENDING, utils.OUT, "0723", utils.ErrAccountNotActive},
+		{ACCOUNT_CLOSED, utils.OUT, "112", utils.ErrAccountClosed},
+	} {
+		acc.State = c.state
+		if err := acc.authorizeCall(c.direction, c.destination); err != c.err {
+			t.Errorf("%s %s %s: expected %v, got: %v", c.state, c.direction, c.destination, c.err, err)
+		}
+	}
+}
+
+func TestAccountStateActions(t *testing.T) {
+	acc := &Account{Tenant: "state", Name: "actions"}
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	f, exists := getActionFunc(SUSPEND_ACCOUNT)
+	if !exists {
+		t.Fatal("missing *suspend_account action")
+	}
+	ag := &ActionGroup{Tenant: "state", Name: "SUSPEND", Actions: Actions{&Action{ActionType: SUSPEND_ACCOUNT}}}
+	ag.SetParentGroup()
+	if err := f(acc, nil, ag.Actions[0], ag.Actions); err != nil || acc.State != ACCOUNT_SUSPENDED || acc.StateChanges[0].Cause != "SUSPEND" {
+		t.Errorf("bad suspended account: %s, %v", utils.ToIJSON(acc), err)
+	}
+	if len(acc.unpublishedStates) != 1 {
+		t.Errorf("state change published before save: %d", len(acc.unpublishedStates))
+	}
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if len(acc.unpublishedStates) != 0 {
+		t.Errorf("state change not published on save: %d", len(acc.unpublishedStates))
+	}
+	cd := &CallDescriptor{Direction: utils.OUT, Tenant: "state", Account: "actions", Destination: "0723"}
+	var reply float64
+	if err := (&Responder{}).GetMaxSessionTime(cd, &reply); err != utils.ErrAccountSuspended {
+		t.Errorf("expected suspended account, got: %v", err)
+	}
+	// the used service is billed whatever the state
+	if _, err := cd.Debit(); err != nil {
+		t.Errorf("debit refused on a suspended account: %v", err)
+	}
+	if _, err := cd.MaxDebit(); err != utils.ErrAccountSuspended {
+		t.Errorf("max debit on a suspended account, got: %v", err)
+	}
+	if f, _ = getActionFunc(ACTIVATE_ACCOUNT); f(acc, nil, &Action{ActionType: ACTIVATE_ACCOUNT}, nil) != nil || acc.GetState() != ACCOUNT_ACTIVE {
+		t.Errorf("account not activated: %s", acc.GetState())
+	}
+}
+
+func TestAccountStateGraceExpiry(t *testing.T) {
+	acc := &Account{Tenant: "state", Name: "grace"}
+	if err := graceAccountAction(acc, nil, &Action{ActionType: GRACE_ACCOUNT, Params: `{"Duration":"1h"}`}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if acc.GetState() != ACCOUNT_GRACE || acc.GraceUntil.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("bad grace: %s until %v", acc.GetState(), acc.GraceUntil)
+	}
+	acc.GraceUntil = time.Now().Add(-time.Second)
+	if acc.GetState() != ACCOUNT_SUSPENDED {
+		t.Errorf("expired grace not suspended: %s", acc.GetState())
+	}
+	acc.expireGrace()
+	if acc.State != ACCOUNT_SUSPENDED || !acc.GraceUntil.IsZero() || acc.StateChanges[1].From != ACCOUNT_GRACE {
+		t.Errorf("grace expiry not recorded: %s", utils.ToIJSON(acc))
+	}
+	if err := acc.SetGraceState(0, "test"); err != nil || acc.GraceUntil.Before(time.Now().Add(AccountGraceDuration-time.Minute)) {
+		t.Errorf("bad default grace: %v, %v", acc.GraceUntil, err)
+	}
+}
@@ -44,7 +44,7 @@ func TestGetSecondsForPrefix(t *testing.T) {
 		Destination: "0723",
 		TOR:         utils.VOICE,
 	}
-	seconds, credit, bucketList := ub1.getCreditForPrefix(cd)
+	seconds, credit, bucketList, _ := ub1.getCreditForPrefix(cd)
 	expected := 110 * time.Second
 	if credit.String() != "200" || seconds != expected || bucketList[0].Weight < bucketList[1].Weight {
 		t.Log(seconds, credit, bucketList)
@@ -74,7 +74,7 @@ func TestGetSpecialPricedSeconds(t *testing.T) {
 		Destination: "0723",
 		TOR:         utils.VOICE,
 	}
-	seconds, credit, bucketList := ub1.getCreditForPrefix(cd)
+	seconds, credit, bucketList, _ := ub1.getCreditForPrefix(cd)
 	expected := 20 * time.Second
 	if credit.String() != "0" || seconds != expected || len(bucketList) != 2 || bucketList[0].Weight < bucketList[1].Weight {
 		t.Errorf("Expected %v was %v, \n %v \n, %s", expected, seconds, credit, utils.ToIJSON(bucketList))
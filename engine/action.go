@@ -163,6 +163,13 @@ const (
 	UNSET_RECURRENT           = "*unset_recurrent"
 	ALLOW_NEGATIVE            = "*allow_negative"
 	DENY_NEGATIVE             = "*deny_negative"
+	SET_CREDIT_LIMIT          = "*set_credit_limit"
+	REMOVE_CREDIT_LIMIT       = "*remove_credit_limit"
+	ACTIVATE_ACCOUNT          = "*activate_account"
+	BAR_OUTGOING              = "*bar_outgoing"
+	SUSPEND_ACCOUNT           = "*suspend_account"
+	GRACE_ACCOUNT             = "*grace_account"
+	CLOSE_ACCOUNT             = "*close_account"
 	RESET_ACCOUNT             = "*reset_account"
 	REMOVE_ACCOUNT            = "*remove_account"
 	SET_BALANCE               = "*set_balance"
@@ -207,6 +214,13 @@ func getActionFunc(typ string) (actionTypeFunc, bool) {
 		UNSET_RECURRENT:           unsetRecurrentAction,
 		ALLOW_NEGATIVE:            allowNegativeAction,
 		DENY_NEGATIVE:             denyNegativeAction,
+		SET_CREDIT_LIMIT:          setCreditLimitAction,
+		REMOVE_CREDIT_LIMIT:       removeCreditLimitAction,
+		ACTIVATE_ACCOUNT:          accountStateAction(ACCOUNT_ACTIVE),
+		BAR_OUTGOING:              accountStateAction(ACCOUNT_BARRED_OUT),
+		SUSPEND_ACCOUNT:           accountStateAction(ACCOUNT_SUSPENDED),
+		GRACE_ACCOUNT:             graceAccountAction,
+		CLOSE_ACCOUNT:             accountStateAction(ACCOUNT_CLOSED),
 		RESET_ACCOUNT:             resetAccountAction,
 		TOPUP_RESET:               topupResetAction,
 		TOPUP:                     topupAction,
@@ -22,6 +22,8 @@ type ActionTiming struct {
 	actionPlan *ActionPlan
 	accountIDs utils.StringMap
 	stCache    time.Time // cached time of the next start
+	cause      string    // ledger cause, defaults to the action group
+	causeID    string
 }
 
 type Task struct {
@@ -88,6 +90,18 @@ func (at *ActionTiming) SetActions(as Actions) {
 	at.actions = as
 }
 
+// SetLedgerCause attributes the balance changes of the execution, by default they are attributed to the action group
+func (at *ActionTiming) SetLedgerCause(cause, causeID string) {
+	at.cause, at.causeID = cause, causeID
+}
+
+func (at *ActionTiming) ledgerCause() (string, string) {
+	if at.cause != "" {
+		return at.cause, at.causeID
+	}
+	return LEDGER_ACTION, at.ActionsID
+}
+
 func (at *ActionTiming) SetAccountIDs(accIDs utils.StringMap) {
 	at.accountIDs = accIDs
 }
@@ -142,6 +156,7 @@ func (at *ActionTiming) Execute() (err error) {
 				utils.Logger.Warn("Could not get account. Skipping!", zap.String("tenant", apb.Tenant), zap.String("id", apb.Account))
 				return 0, err
 			}
+			acc.SetLedgerCause(at.ledgerCause())
 			transactionFailed := false
 			removeAccountActionFound := false
 			for _, a := range aac {
@@ -168,6 +183,7 @@ func (at *ActionTiming) Execute() (err error) {
 					transactionFailed = true
 					break
 				}
+				acc.setLedgerAction(a.ActionType)
 				if err := actionFunction(acc, nil, a, aac); err != nil {
 					//log.Print("err: ", err)
 					utils.Logger.Error("Error executing action ", zap.String("action type", a.ActionType), zap.Error(err))
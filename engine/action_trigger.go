@@ -25,7 +25,7 @@ func (atrg *ActionTriggerGroup) SetParentGroup() {
 
 type ActionTrigger struct {
 	UniqueID      string `bson:"unique_id"`      // individual id
-	ThresholdType string `bson:"threshold_type"` //*min_event_counter, *max_event_counter, *min_balance_counter, *max_balance_counter, *min_balance, *max_balance, *balance_expired
+	ThresholdType string `bson:"threshold_type"` //*min_event_counter, *max_event_counter, *min_balance_counter, *max_balance_counter, *min_balance, *max_balance, *balance_expired, *min_credit
 	// stats: `bson:""` *min_asr, *max_asr, *min_acd, *max_acd, *min_tcd, *max_tcd, *min_acc, *max_acc, *min_tcc, *max_tcc, *min_ddc, *max_ddc
 	ThresholdValue *dec.Dec      `bson:"threshold_value"`
 	Recurrent      bool          `bson:"recurrent"` // reset excuted flag each run
@@ -84,6 +84,9 @@ func (at *ActionTrigger) Execute(acc *Account, sq *StatsQueueTriggered) (err err
 		return
 	}
 	aag.Actions.Sort()
+	if acc != nil {
+		defer acc.pushLedgerCause(LEDGER_ACTION, at.ActionsID)()
+	}
 	trRec.Executed = true
 	transactionFailed := false
 	removeAccountActionFound := false
@@ -106,6 +109,9 @@ func (at *ActionTrigger) Execute(acc *Account, sq *StatsQueueTriggered) (err err
 			break
 		}
 		//go utils.Logger.Info(fmt.Sprintf("Executing %v, %v: %v", acc, sq, a))
+		if acc != nil {
+			acc.setLedgerAction(a.ActionType)
+		}
 		if err := actionFunction(acc, sq, a, aag.Actions); err != nil {
 			utils.Logger.Error("Error executing action ", zap.String("action type", a.ActionType), zap.Error(err))
 			transactionFailed = false
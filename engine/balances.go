@@ -30,6 +30,8 @@ type Balance struct {
 	Factor         ValueFactor     `bson:"factor"`
 	Blocker        bool            `bson:"blocker"`
 	Unlimited      bool            `bson:"unlimited"`
+	Currency       string          `bson:"currency"`               // monetary balance currency, empty for the rating currency
+	CreditLimit    *dec.Dec        `bson:"credit_limit,omitempty"` // how far below zero the balance may go, capped by the account one
 	precision      int
 	account        *Account // used to store ub reference for shared balances
 	dirty          bool
@@ -52,7 +54,8 @@ func (b *Balance) Equal(o *Balance) bool {
 		b.Categories.Equal(o.Categories) &&
 		b.SharedGroups.Equal(o.SharedGroups) &&
 		b.Disabled == o.Disabled &&
-		b.Blocker == o.Blocker
+		b.Blocker == o.Blocker &&
+		b.Currency == o.Currency
 }
 
 // the default balance has standard Id
@@ -126,6 +129,8 @@ func (b *Balance) Clone() *Balance {
 		Timings:        b.Timings, // should not be a problem with aliasing
 		Blocker:        b.Blocker,
 		Disabled:       b.Disabled,
+		Currency:       b.Currency,
+		CreditLimit:    b.CreditLimit,
 		dirty:          b.dirty,
 	}
 	if b.DestinationIDs != nil {
@@ -352,7 +357,9 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 		//log.Printf("CC: %s", utils.ToIJSON(cc))
 		if debitConnectFee {
 			// this is the first add, debit the connect fee
-			if ub.DebitConnectionFee(cc, moneyBalances, count, true) == false {
+			if paid, err := ub.DebitConnectionFee(cc, moneyBalances, count, true); err != nil {
+				return nil, err
+			} else if !paid {
 				// found blocker balance
 				return nil, nil
 			}
@@ -379,7 +386,11 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 				}
 				cost := inc.Cost
 				inc.paid++
-				if strategy == utils.MAX_COST_DISCONNECT && cd.GetMaxCostSoFar().Cmp(maxCost) >= 0 {
+				maxCostReached, err := cd.maxCostReached(strategy, maxCost, cc.Currency)
+				if err != nil {
+					return nil, err
+				}
+				if strategy == utils.MAX_COST_DISCONNECT && maxCostReached {
 					// cat the entire current timespan
 					cc.maxCostDisconect = true
 					if dryRun {
@@ -393,7 +404,7 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 						return cc, nil
 					}
 				}
-				if strategy == utils.MAX_COST_FREE && cd.GetMaxCostSoFar().Cmp(maxCost) >= 0 {
+				if strategy == utils.MAX_COST_FREE && maxCostReached {
 					cost = dec.New()
 					ts.Increments.MaxCostFreeIndex = incIndex - 1
 					if inc.BalanceInfo.Monetary == nil {
@@ -414,9 +425,14 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					break
 				}
 				var moneyBal *Balance
+				var moneyConv *CurrencyConversion
 				for _, mb := range moneyBalances {
-					if mb.Unlimited || mb.GetValue().Cmp(cost) >= 0 {
-						moneyBal = mb
+					conv, err := getCurrencyConversion(cd.Tenant, cc.Currency, mb.Currency, cd.TimeStart)
+					if err != nil {
+						return nil, err
+					}
+					if mb.Unlimited || mb.GetValue().Cmp(conv.Convert(cost)) >= 0 {
+						moneyBal, moneyConv = mb, conv
 						break
 					}
 				}
@@ -436,7 +452,7 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					inc.BalanceInfo.Unit.Consumed = amount.String()
 					inc.BalanceInfo.AccountID = ub.Name
 					if !cost.IsZero() {
-						moneyBal.SubstractValue(cost)
+						moneyBal.SubstractValue(moneyConv.Convert(cost))
 						if inc.BalanceInfo.Monetary == nil {
 							inc.BalanceInfo.Monetary = &MonetaryInfo{
 								UUID: moneyBal.UUID,
@@ -444,14 +460,18 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 							}
 						}
 						inc.BalanceInfo.Monetary.getValue().Set(moneyBal.GetValue())
-						cd.GetMaxCostSoFar().AddS(cost)
+						inc.BalanceInfo.Monetary.ExchangeRate = moneyConv.GetRate()
+						cc.addConversion(moneyConv)
+						if err := cd.AddMaxCostSoFar(cost, cc.Currency); err != nil {
+							return nil, err
+						}
 					}
 					inc.paid++
 					if count {
 						pats := ub.countUnits(amount, cc.TOR, cc, b)
 						inc.AddPostATIDs(incIndex, pats)
 						if !cost.IsZero() {
-							pats = ub.countUnits(cost, utils.MONETARY, cc, moneyBal)
+							pats = ub.countUnits(moneyConv.Convert(cost), utils.MONETARY, cc, moneyBal)
 							inc.AddPostATIDs(incIndex, pats)
 						}
 					}
@@ -486,10 +506,17 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 	if err != nil {
 		return nil, err
 	}
+	// the balance is debited in its own currency
+	conv, err := getCurrencyConversion(cd.Tenant, cc.Currency, b.Currency, cd.TimeStart)
+	if err != nil {
+		return nil, err
+	}
 	//log.Print("cc: " + utils.ToIJSON(cc))
 	if debitConnectFee {
 		// this is the first add, debit the connect fee
-		if ub.DebitConnectionFee(cc, moneyBalances, count, true) == false {
+		if paid, err := ub.DebitConnectionFee(cc, moneyBalances, count, true); err != nil {
+			return nil, err
+		} else if !paid {
 			// balance is blocker
 			return nil, nil
 		}
@@ -515,8 +542,12 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 		for incIndex, inc := ts.Increments.Next(); inc != nil; incIndex, inc = ts.Increments.Next() {
 			// check standard subject tags
 			//log.Print("INC: ", utils.ToIJSON(inc))
-			amount := inc.Cost
-			if strategy == utils.MAX_COST_DISCONNECT && cd.GetMaxCostSoFar().Cmp(maxCost) >= 0 {
+			amount := conv.Convert(inc.getCost())
+			maxCostReached, err := cd.maxCostReached(strategy, maxCost, cc.Currency)
+			if err != nil {
+				return nil, err
+			}
+			if strategy == utils.MAX_COST_DISCONNECT && maxCostReached {
 				// cat the entire current timespan
 				cc.maxCostDisconect = true
 				if dryRun {
@@ -530,7 +561,7 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					return cc, nil
 				}
 			}
-			if strategy == utils.MAX_COST_FREE && cd.GetMaxCostSoFar().Cmp(maxCost) >= 0 {
+			if strategy == utils.MAX_COST_FREE && maxCostReached {
 				amount = dec.New()
 				ts.Increments.MaxCostFreeIndex = incIndex - 1
 				if inc.BalanceInfo.Monetary == nil {
@@ -555,7 +586,9 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 			}
 			if b.Unlimited || b.GetValue().Cmp(amount) >= 0 {
 				b.SubstractValue(amount)
-				cd.GetMaxCostSoFar().AddS(amount)
+				if err := cd.AddMaxCostSoFar(inc.getCost(), cc.Currency); err != nil {
+					return nil, err
+				}
 				if inc.BalanceInfo.Monetary == nil {
 					inc.BalanceInfo.Monetary = &MonetaryInfo{
 						UUID: b.UUID,
@@ -563,6 +596,8 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					}
 				}
 				inc.BalanceInfo.Monetary.getValue().Set(b.GetValue())
+				inc.BalanceInfo.Monetary.ExchangeRate = conv.GetRate()
+				cc.addConversion(conv)
 				inc.BalanceInfo.AccountID = ub.Name
 				if b.RatingSubject != "" {
 					inc.BalanceInfo.Monetary.RateInterval = ts.RateInterval
@@ -597,7 +632,7 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 
 // Converts the balance towards compressed information to be displayed
 func (b *Balance) AsBalanceSummary(typ string) *BalanceSummary {
-	bd := &BalanceSummary{ID: b.ID, Type: typ, Value: b.GetValue().String(), Disabled: b.Disabled}
+	bd := &BalanceSummary{ID: b.ID, Type: typ, Value: b.GetValue().String(), Currency: b.Currency, Disabled: b.Disabled}
 	if bd.ID == "" {
 		bd.ID = b.UUID
 	}
@@ -628,6 +663,15 @@ func (bc Balances) GetTotalValue() (total *dec.Dec) {
 	return
 }
 
+func (bc Balances) hasCurrency() bool {
+	for _, b := range bc {
+		if b.Currency != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (bc Balances) Equal(o Balances) bool {
 	if len(bc) != len(o) {
 		return false
@@ -724,5 +768,6 @@ type BalanceSummary struct {
 	ID       string // ID or UUID if not defined
 	Type     string // *voice, *data, etc
 	Value    string
+	Currency string // empty for the default currency
 	Disabled bool
 }
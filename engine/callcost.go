@@ -15,6 +15,9 @@ type CallCost struct {
 	Cost                                                            *dec.Dec
 	Timespans                                                       TimeSpans
 	RatedUsage                                                      float64
+	Currency                                                        string                // currency of the cost, empty for the default one
+	Conversions                                                     []*CurrencyConversion // exchange rates used to debit balances in other currencies
+	Rounding                                                        *Rounding             // rounding applied to the cost
 	deductConnectFee                                                bool
 	negativeConnectFee                                              bool // the connect fee went negative on default balance
 	maxCostDisconect                                                bool
@@ -32,6 +35,33 @@ func (cc *CallCost) GetCost() *dec.Dec {
 func (cc *CallCost) Merge(other *CallCost) {
 	cc.Timespans = append(cc.Timespans, other.Timespans...)
 	cc.GetCost().AddS(other.GetCost())
+	cc.mergeCurrency(other)
+	if cc.Rounding == nil {
+		cc.Rounding = other.Rounding
+	}
+}
+
+// mergeCurrency takes over the currency and the conversions of the other call cost
+func (cc *CallCost) mergeCurrency(other *CallCost) {
+	if cc.Currency == "" {
+		cc.Currency = other.Currency
+	}
+	for _, conv := range other.Conversions {
+		cc.addConversion(conv)
+	}
+}
+
+// addConversion records the conversion once
+func (cc *CallCost) addConversion(conv *CurrencyConversion) {
+	if conv == nil {
+		return
+	}
+	for _, existing := range cc.Conversions {
+		if existing.Equal(conv) {
+			return
+		}
+	}
+	cc.Conversions = append(cc.Conversions, conv)
 }
 
 func (cc *CallCost) GetStartTime() time.Time {
@@ -170,10 +200,26 @@ func (cc *CallCost) updateCost() {
 		ts.Cost = ts.CalculateCost()
 		cost.AddS(ts.Cost)
 	}
-	cost.Round(globalRoundingDecimals)
+	cc.round(cost)
 	cc.Cost = cost
 }
 
+// round applies the rounding of the first rate interval overriding it, else the tenant one, and records it
+func (cc *CallCost) round(cost *dec.Dec) {
+	if cc.Rounding == nil {
+		for _, ts := range cc.Timespans {
+			if ts.RateInterval != nil && ts.RateInterval.Rating != nil && ts.RateInterval.Rating.Rounding != nil {
+				cc.Rounding = ts.RateInterval.Rating.Rounding
+				break
+			}
+		}
+	}
+	if cc.Rounding == nil {
+		cc.Rounding = tenantCallRounding(cc.Tenant)
+	}
+	cc.Rounding.Round(cost)
+}
+
 func (cc *CallCost) TruncateTimespansAtDuration(truncateDuration time.Duration) []*Increment {
 	cc.Timespans.Decompress()
 	var refundIncrements []*Increment
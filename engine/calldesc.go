@@ -134,16 +134,28 @@ type CallDescriptor struct {
 	MaxRate           float64
 	MaxRateUnit       time.Duration
 	MaxCostSoFar      *dec.Dec
+	MaxCostCurrency   string // currency of MaxCostSoFar, set by the first cost added
 	UniqueID          string
+	OriginID          string // the call id, attributes the balance changes
 	RunID             string
 	ForceDuration     bool // for Max debit if less than duration return err
 	PerformRounding   bool // flag for rating info rounding
 	DryRun            bool
 	PostActionTrigger bool
+	ReservationID     string // balance hold used by this session, the other holds are not available
 	ExeATIDs          map[string][]string
 	UnexeATIDs        map[string][]string
 	account           *Account
-	testCallcost      *CallCost // testing purpose only!
+	ratingDB          RatingStorage // rates from this storage instead of the engine one, see SimulateRating
+	testCallcost      *CallCost     // testing purpose only!
+}
+
+// getRatingStorage returns the storage holding the rating plans of the call
+func (cd *CallDescriptor) getRatingStorage() RatingStorage {
+	if cd.ratingDB != nil {
+		return cd.ratingDB
+	}
+	return ratingStorage
 }
 
 func (cd *CallDescriptor) GetMaxCostSoFar() *dec.Dec {
@@ -153,6 +165,31 @@ func (cd *CallDescriptor) GetMaxCostSoFar() *dec.Dec {
 	return cd.MaxCostSoFar
 }
 
+// AddMaxCostSoFar adds a cost in the currency to MaxCostSoFar, converted into MaxCostCurrency
+func (cd *CallDescriptor) AddMaxCostSoFar(cost *dec.Dec, currency string) error {
+	if cd.MaxCostCurrency == "" && cd.GetMaxCostSoFar().IsZero() {
+		cd.MaxCostCurrency = currency
+	}
+	conv, err := getCurrencyConversion(cd.Tenant, currency, cd.MaxCostCurrency, cd.TimeStart)
+	if err != nil {
+		return err
+	}
+	cd.GetMaxCostSoFar().AddS(conv.Convert(cost))
+	return nil
+}
+
+// maxCostReached compares MaxCostSoFar with the max cost of the rate interval, given in the currency
+func (cd *CallDescriptor) maxCostReached(strategy string, maxCost *dec.Dec, currency string) (bool, error) {
+	if strategy == "" {
+		return false, nil
+	}
+	conv, err := getCurrencyConversion(cd.Tenant, cd.MaxCostCurrency, currency, cd.TimeStart)
+	if err != nil {
+		return false, err
+	}
+	return conv.Convert(cd.GetMaxCostSoFar()).Cmp(maxCost) >= 0, nil
+}
+
 func (cd *CallDescriptor) ValidateCallData() error {
 	if cd.TimeStart.After(cd.TimeEnd) || cd.TimeStart.Equal(cd.TimeEnd) {
 		return errors.New("TimeStart must be strctly before TimeEnd")
@@ -176,6 +213,9 @@ func (cd *CallDescriptor) getAccount() (ub *Account, err error) {
 	if cd.account != nil && cd.account.Disabled {
 		return nil, utils.ErrAccountDisabled
 	}
+	if cd.account != nil {
+		cd.account.expireGrace()
+	}
 	if err != nil || cd.account == nil {
 		utils.Logger.Warn("account not found", zap.String("tenant", cd.Tenant), zap.String("name", cd.getAccountName()), zap.Error(err))
 		return nil, utils.ErrAccountNotFound
@@ -207,13 +247,31 @@ func (cd *CallDescriptor) LoadRatingPlans() (err error) {
 	return
 }
 
+// getRatingCurrency returns the currency of the rating plans matching the call, empty if they cannot be loaded
+func (cd *CallDescriptor) getRatingCurrency() string {
+	ratingInfos := cd.RatingInfos
+	if len(ratingInfos) == 0 {
+		clone := cd.Clone()
+		if err := clone.LoadRatingPlans(); err != nil {
+			return ""
+		}
+		ratingInfos = clone.RatingInfos
+	}
+	for _, ri := range ratingInfos {
+		if ri.Currency != "" {
+			return ri.Currency
+		}
+	}
+	return ""
+}
+
 // FIXME: this method is not exhaustive but will cover 99% of cases just good
 // it will not cover very long calls with very short activation periods for rates
 func (cd *CallDescriptor) getRatingPlansForPrefix(direction, tenant, category, subject string, recursionDepth int) (error, int) {
 	if recursionDepth > RECURSION_MAX_DEPTH {
 		return utils.ErrMaxRecursionDepth, recursionDepth
 	}
-	rpf, err := ratingStorage.GetRatingProfile(direction, tenant, category, subject, rpSubjectPrefixMatching, utils.CACHED)
+	rpf, err := cd.getRatingStorage().GetRatingProfile(direction, tenant, category, subject, rpSubjectPrefixMatching, utils.CACHED)
 	//log.Print("rating profile: ", utils.ToIJSON(rpf))
 	if err != nil || rpf == nil {
 		return utils.ErrNotFound, recursionDepth
@@ -233,6 +291,7 @@ func (cd *CallDescriptor) getRatingPlansForPrefix(direction, tenant, category, s
 					Direction:   cd.Direction,
 					Tenant:      cd.Tenant,
 					Destination: cd.Destination,
+					ratingDB:    cd.ratingDB,
 				}
 				if index == 0 {
 					tempCD.TimeStart = cd.TimeStart
@@ -330,6 +389,16 @@ func (cd *CallDescriptor) getAccountName() string {
 	return subj
 }
 
+// accountLockKeys returns the guardian keys of the named accounts, the same
+// tenant:account keys locked by the responder, the action plans and the transfers.
+func accountLockKeys(tenant string, names utils.StringMap) []string {
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, utils.ConcatKey(tenant, name))
+	}
+	return keys
+}
+
 // Splits the received timespan into sub time spans according to the activation periods intervals.
 func (cd *CallDescriptor) splitInTimeSpans() (timespans []*TimeSpan) {
 	firstSpan := &TimeSpan{TimeStart: cd.TimeStart, TimeEnd: cd.TimeEnd, DurationIndex: cd.DurationIndex}
@@ -471,21 +540,26 @@ func (cd *CallDescriptor) GetCost() (*CallCost, error) {
 
 		ts.Cost = ts.CalculateCost()
 		cost.AddS(ts.Cost)
-		cd.GetMaxCostSoFar().AddS(cost)
+		if err := cd.AddMaxCostSoFar(cost, cc.Currency); err != nil {
+			return nil, err
+		}
 		//log.Print("Before: ", cost)
 		if strategy != "" && maxCost.GtZero() {
 			//log.Print("HERE: ", strategy, maxCost)
-			if strategy == utils.MAX_COST_FREE && cd.GetMaxCostSoFar().Cmp(maxCost) >= 0 {
+			maxCostReached, err := cd.maxCostReached(strategy, maxCost, cc.Currency)
+			if err != nil {
+				return nil, err
+			}
+			if strategy == utils.MAX_COST_FREE && maxCostReached {
 				cost = maxCost
-				cd.MaxCostSoFar = maxCost
+				cd.MaxCostSoFar, cd.MaxCostCurrency = maxCost, cc.Currency
 			}
 
 		}
 		//log.Print("Cost: ", cost)
 	}
 	cc.GetCost().Set(cost)
-	// global rounding
-	cc.GetCost().Round(globalRoundingDecimals)
+	cc.round(cc.GetCost())
 
 	return cc, nil
 }
@@ -504,6 +578,7 @@ func (cd *CallDescriptor) getCost() (*CallCost, error) {
 			}
 			ts.setRatingInfo(cd.RatingInfos[0])
 			cc.Timespans = append(cc.Timespans, ts)
+			cc.Currency = ts.Currency
 		}
 		return cc, nil
 	}
@@ -535,9 +610,14 @@ func (cd *CallDescriptor) getCost() (*CallCost, error) {
 	cc := cd.CreateCallCost()
 	cc.GetCost().Set(cost)
 	cc.Timespans = timespans
+	for _, ts := range timespans {
+		if ts.Currency != "" {
+			cc.Currency = ts.Currency
+			break
+		}
+	}
 
-	// global rounding
-	cc.GetCost().Round(globalRoundingDecimals)
+	cc.round(cc.GetCost())
 	//utils.Logger.Info(fmt.Sprintf("<Rater> Get Cost: %s => %v", cd.GetKey(), cc))
 	cc.Timespans.Compress()
 	cc.UpdateRatedUsage()
@@ -553,9 +633,15 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 	// clone the account for discarding chenges on debit dry run
 	//log.Print("ORIG CD: ", utils.ToIJSON(origCD))
 	account := origAcc.Clone()
-	if account.AllowNegative {
+	defaultBalance := account.GetDefaultMoneyBalance()
+	limit := account.creditLimit(defaultBalance)
+	if limit == nil {
 		return -1, nil
 	}
+	// the credit limit is spent from the default balance of the clone
+	defaultBalance.AddValue(limit)
+	// the value held by other sessions is not available
+	account.substractReservations(origCD.ReservationID)
 	//log.Print("ACC: ", utils.ToIJSON(account))
 	// for zero duration index
 	if origCD.DurationIndex < origCD.TimeEnd.Sub(origCD.TimeStart) {
@@ -566,7 +652,6 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 	}
 	cd := origCD.Clone()
 	initialDuration := cd.TimeEnd.Sub(cd.TimeStart)
-	defaultBalance := account.GetDefaultMoneyBalance()
 
 	//use this to check what increment was payed with debt
 	initialDefaultBalanceValue := dec.New().Set(defaultBalance.GetValue())
@@ -630,18 +715,22 @@ func (cd *CallDescriptor) GetMaxSessionDuration() (duration time.Duration, err e
 		if err != nil || account == nil {
 			return 0, err
 		}
+		// the state is checked only when authorizing, the used service is always debited
+		if err := account.authorizeCall(cd.Direction, cd.Destination); err != nil {
+			return 0, err
+		}
 		if memberIds, sgerr := account.GetUniqueSharedGroupMembers(cd); sgerr == nil {
 			if _, err := Guardian.Guard(func() (interface{}, error) {
 				duration, err = cd.getMaxSessionDuration(account)
 				return 0, err
-			}, 0, memberIds.Slice()...); err != nil {
+			}, 0, accountLockKeys(cd.Tenant, memberIds)...); err != nil {
 				return 0, err
 			}
 		} else {
 			return 0, sgerr
 		}
 		return 0, err
-	}, 0, cd.getAccountName()); err != nil {
+	}, 0, utils.ConcatKey(cd.Tenant, cd.getAccountName())); err != nil {
 		return 0, err
 	}
 	return duration, err
@@ -664,14 +753,30 @@ func (cd *CallDescriptor) debit(account *Account, dryRun bool, goNegative bool)
 			}
 			ts.setRatingInfo(cd.RatingInfos[0])
 			cc.Timespans = append(cc.Timespans, ts)
+			cc.Currency = ts.Currency
 		}
 		return cc, nil
 	}
 	if cd.TOR == "" {
 		cd.TOR = utils.VOICE
 	}
+	var before map[string]*dec.Dec
+	if cd.ReservationID != "" && !dryRun {
+		// snapshot the values so the debited amount can be taken out of the session hold
+		before = account.getBalanceValues(utils.MONETARY)
+		for uuid, value := range account.getBalanceValues(cd.TOR) {
+			before[uuid] = value
+		}
+	}
 	//log.Printf("Debit CD: %s", utils.ToIJSON(cd))
+	var held map[string]*dec.Dec
+	if !dryRun {
+		account.SetLedgerCause(LEDGER_DEBIT, cd.OriginID)
+		// the value held by other sessions is spent last, it is put back before saving
+		held = account.substractReservations(cd.ReservationID)
+	}
 	cc, err = account.debitCreditBalance(cd, !dryRun, dryRun, goNegative)
+	account.restoreReservations(held)
 	//log.Printf("HERE: %s %v", utils.ToIJSON(cc), err)
 	if err != nil {
 		utils.Logger.Error("<Rater> Error getting cost for account key ", zap.String("tenant", cd.Tenant), zap.String("name", cd.getAccountName()), zap.Error(err))
@@ -680,6 +785,10 @@ func (cd *CallDescriptor) debit(account *Account, dryRun bool, goNegative bool)
 	cc.updateCost()
 	cc.UpdateRatedUsage()
 	cc.Timespans.Compress()
+	if before != nil {
+		account.purgeReservations()
+		account.consumeReservation(cd.ReservationID, before)
+	}
 	if !dryRun {
 		if err := accountingStorage.SetAccount(account); err != nil {
 			return cc, err
@@ -702,7 +811,7 @@ func (cd *CallDescriptor) Debit() (cc *CallCost, err error) {
 			if _, err := Guardian.Guard(func() (interface{}, error) {
 				cc, err = cd.debit(account, cd.DryRun, true)
 				return 0, err
-			}, 0, memberIds.Slice()...); err != nil {
+			}, 0, accountLockKeys(cd.Tenant, memberIds)...); err != nil {
 				return nil, err
 			}
 
@@ -710,7 +819,7 @@ func (cd *CallDescriptor) Debit() (cc *CallCost, err error) {
 			return nil, sgerr
 		}
 		return nil, err
-	}, 0, cd.getAccountName()); err != nil {
+	}, 0, utils.ConcatKey(cd.Tenant, cd.getAccountName())); err != nil {
 		return nil, err
 	}
 	return cc, err
@@ -728,6 +837,9 @@ func (cd *CallDescriptor) MaxDebit() (cc *CallCost, err error) {
 		if err != nil || account == nil {
 			return 0, err
 		}
+		if err := account.authorizeCall(cd.Direction, cd.Destination); err != nil {
+			return 0, err
+		}
 		//log.Printf("ACC: %+v", account)
 		if memberIDs, sgerr := account.GetUniqueSharedGroupMembers(cd); sgerr == nil {
 			if _, err := Guardian.Guard(func() (interface{}, error) {
@@ -736,7 +848,7 @@ func (cd *CallDescriptor) MaxDebit() (cc *CallCost, err error) {
 					return 0, err
 				}
 				// check ForceDuration
-				if cd.ForceDuration && !account.AllowNegative && remainingDuration < cd.GetDuration() {
+				if cd.ForceDuration && account.creditLimit(account.defaultMoneyBalance()) != nil && remainingDuration < cd.GetDuration() {
 					return 0, utils.ErrInsufficientCredit
 				}
 				//log.Print("AFTER MAX SESSION: ", cd)
@@ -772,14 +884,14 @@ func (cd *CallDescriptor) MaxDebit() (cc *CallCost, err error) {
 				//log.Print("ACCHERE: ", utils.ToIJSON(account))
 				cc, err = cd.debit(account, cd.DryRun, true)
 				return 0, err
-			}, 0, memberIDs.Slice()...); err != nil {
+			}, 0, accountLockKeys(cd.Tenant, memberIDs)...); err != nil {
 				return nil, err
 			}
 		} else {
 			return nil, sgerr
 		}
 		return nil, err
-	}, 0, cd.getAccountName()); err != nil {
+	}, 0, utils.ConcatKey(cd.Tenant, cd.getAccountName())); err != nil {
 		return nil, err
 	}
 	return cc, err
@@ -807,6 +919,7 @@ func (cd *CallDescriptor) RefundIncrements() error {
 				if acc, err := accountingStorage.GetAccount(cd.Tenant, increment.BalanceInfo.AccountID); err == nil && acc != nil {
 					account = acc
 					accountsCache[increment.BalanceInfo.AccountID] = account
+					account.SetLedgerCause(LEDGER_REFUND, cd.OriginID)
 
 					account.processPostActionTriggers(cd.ExeATIDs[account.Name], cd.UnexeATIDs[account.Name])
 					// will save the account only once at the end of the function
@@ -835,12 +948,17 @@ func (cd *CallDescriptor) RefundIncrements() error {
 				if balance = account.BalanceMap[utils.MONETARY].GetBalance(increment.BalanceInfo.Monetary.UUID); balance == nil {
 					return 0, nil
 				}
-				balance.AddValue(increment.GetTotalCost())
-				account.countUnits(dec.New().Neg(increment.GetTotalCost()), utils.MONETARY, cc, balance)
+				// refund in the balance currency
+				amount := increment.GetTotalCost()
+				if increment.BalanceInfo.Monetary.ExchangeRate != nil {
+					amount = dec.New().Mul(amount, increment.BalanceInfo.Monetary.ExchangeRate)
+				}
+				balance.AddValue(amount)
+				account.countUnits(dec.New().Neg(amount), utils.MONETARY, cc, balance)
 			}
 		}
 		return 0, nil
-	}, 0, accMap.Slice()...)
+	}, 0, accountLockKeys(cd.Tenant, accMap)...)
 	return err
 }
 
@@ -886,6 +1004,7 @@ func (cd *CallDescriptor) Clone() *CallDescriptor {
 		MaxRate:         cd.MaxRate,
 		MaxRateUnit:     cd.MaxRateUnit,
 		MaxCostSoFar:    dec.New().Set(cd.GetMaxCostSoFar()),
+		MaxCostCurrency: cd.MaxCostCurrency,
 		FallbackSubject: cd.FallbackSubject,
 		//RatingInfos:     cd.RatingInfos,
 		//Increments:      cd.Increments,
@@ -894,10 +1013,13 @@ func (cd *CallDescriptor) Clone() *CallDescriptor {
 		PerformRounding:   cd.PerformRounding,
 		DryRun:            cd.DryRun,
 		UniqueID:          cd.UniqueID,
+		OriginID:          cd.OriginID,
 		RunID:             cd.RunID,
 		PostActionTrigger: cd.PostActionTrigger,
+		ReservationID:     cd.ReservationID,
 		ExeATIDs:          cd.ExeATIDs,
 		UnexeATIDs:        cd.UnexeATIDs,
+		ratingDB:          cd.ratingDB,
 	}
 }
 
@@ -87,6 +87,7 @@ type CDR struct {
 	Cost            *dec.Dec
 	CostDetails     *CallCost       // Attach the cost details to CDR when possible
 	AccountSummary  *AccountSummary // Store AccountSummary information
+	Taxes           []*CDRTax       // tax breakdown computed out of the tariff plan tax rules
 	ExtraInfo       string          // Container for extra information related to this CDR, eg: populated with error reason in case of error on calculation
 	Rated           bool            // Mark the CDR as rated so we do not process it during rating
 	Partial         bool            // Used for partial record processing by CDRC
@@ -253,6 +253,18 @@ func (cdrs *CdrServer) deriveRateStoreStatsReplicate(cdr *CDR, store, stats, rep
 			}
 		}
 	}
+	// Apply the tariff plan taxes
+	if *cdrs.cfg.Cdrs.Taxes {
+		for _, ratedCDR := range ratedCDRs {
+			if ratedCDR.RunID == utils.META_SURETAX || ratedCDR.GetCost().LtZero() {
+				continue
+			}
+			if err := TaxProcessCdr(ratedCDR); err != nil {
+				utils.Logger.Error("<CDRS> Applying taxes ", zap.String("uniqueid", ratedCDR.UniqueID), zap.String("runid", ratedCDR.RunID), zap.Error(err))
+				ratedCDR.ExtraInfo = err.Error()
+			}
+		}
+	}
 	// Store AccountSummary if requested
 	if *cdrs.cfg.Cdrs.AccountSummary {
 		for _, ratedCDR := range ratedCDRs {
@@ -410,6 +422,7 @@ func (cdrs *CdrServer) getCostFromRater(cdr *CDR) (*CallCost, error) {
 		timeStart = cdr.SetupTime
 	}
 	cd := &CallDescriptor{
+		OriginID:        cdr.OriginID,
 		TOR:             cdr.ToR,
 		Direction:       cdr.Direction,
 		Tenant:          cdr.Tenant,
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// creditLimit returns how far below zero the monetary balance may go, nil when it is not limited.
+// Without credit limits the accounts allowed negative are not limited and the other ones are limited to zero.
+// The limit is checked when authorizing (max session duration, max debit), the completed usage is always debited.
+func (acc *Account) creditLimit(b *Balance) *dec.Dec {
+	limit := acc.CreditLimit
+	if b != nil && b.CreditLimit != nil && (limit == nil || b.CreditLimit.Cmp(limit) < 0) {
+		limit = b.CreditLimit
+	}
+	if limit == nil && !acc.AllowNegative {
+		return dec.New()
+	}
+	return limit
+}
+
+// hasCreditLimit tells if a credit limit was set for the balance, the accounts without are kept on AllowNegative
+func (acc *Account) hasCreditLimit(b *Balance) bool {
+	return acc.CreditLimit != nil || (b != nil && b.CreditLimit != nil)
+}
+
+// creditLeft returns the value the balance can still be debited with, nil when it is not limited
+func (acc *Account) creditLeft(b *Balance) *dec.Dec {
+	limit := acc.creditLimit(b)
+	if limit == nil {
+		return nil
+	}
+	return dec.New().Add(b.GetValue(), limit)
+}
+
+// defaultMoneyBalance returns the default monetary balance without creating it
+func (acc *Account) defaultMoneyBalance() *Balance {
+	for _, b := range acc.BalanceMap[utils.MONETARY] {
+		if b.IsDefault() {
+			return b
+		}
+	}
+	return nil
+}
+
+// setCreditLimitAction sets the credit limit from the params ({"CreditLimit": 10}) on the account,
+// or on the monetary balances matching the action filter
+func setCreditLimitAction(acc *Account, sq *StatsQueueTriggered, a *Action, acs Actions) error {
+	if acc == nil {
+		return fmt.Errorf("nil account for %s action", utils.ToJSON(a))
+	}
+	var x struct {
+		CreditLimit *dec.Dec
+	}
+	if err := json.Unmarshal([]byte(a.Params), &x); err != nil {
+		return err
+	}
+	if x.CreditLimit == nil || x.CreditLimit.Cmp(dec.New()) < 0 {
+		return fmt.Errorf("invalid credit limit params: %s", a.Params)
+	}
+	return acc.applyCreditLimit(a, x.CreditLimit)
+}
+
+// removeCreditLimitAction removes the credit limit of the account, or of the monetary balances matching the action filter
+func removeCreditLimitAction(acc *Account, sq *StatsQueueTriggered, a *Action, acs Actions) error {
+	if acc == nil {
+		return fmt.Errorf("nil account for %s action", utils.ToJSON(a))
+	}
+	return acc.applyCreditLimit(a, nil)
+}
+
+func (acc *Account) applyCreditLimit(a *Action, limit *dec.Dec) error {
+	if a.Filter1 == "" {
+		acc.CreditLimit = limit
+		return nil
+	}
+	found := false
+	for _, b := range acc.BalanceMap[utils.MONETARY] {
+		match, err := a.getFilter().Query(b, false)
+		if err != nil {
+			utils.Logger.Warn(fmt.Sprintf("<applyCreditLimit> action filter (%s) errored : (%s)", a.Filter1, err.Error()))
+		}
+		if match {
+			b.CreditLimit = limit
+			found = true
+		}
+	}
+	if !found {
+		return utils.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestCreditLimitResolution(t *testing.T) {
+	b := &Balance{Value: dec.NewFloat(3)}
+	acc := &Account{}
+	if limit := acc.creditLimit(b); limit == nil || !limit.IsZero() {
+		t.Errorf("expected zero limit, got: %v", limit)
+	}
+	acc.AllowNegative = true
+	if limit := acc.creditLimit(b); limit != nil || acc.creditLeft(b) != nil {
+		t.Errorf("expected no limit, got: %v", limit)
+	}
+	b.CreditLimit = dec.NewFloat(5)
+	if limit := acc.creditLimit(b); limit == nil || limit.String() != "5" {
+		t.Errorf("expected the balance limit, got: %v", limit)
+	}
+	acc.CreditLimit = dec.NewFloat(2)
+	if left := acc.creditLeft(b); left == nil || left.String() != "5" {
+		t.Errorf("expected the account limit to cap the balance one, got: %v", left)
+	}
+}
+
+func TestCreditLimitDebit(t *testing.T) {
+	acc := &Account{Tenant: "test", Name: "credit_limited", CreditLimit: dec.NewFloat(0.1), BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{&Balance{UUID: "limited_money", ID: utils.META_DEFAULT, Value: dec.NewFloat(0.5)}},
+	}}
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	newCD := func() *CallDescriptor {
+		return &CallDescriptor{
+			Direction:   "*out",
+			Category:    "call",
+			Tenant:      "test",
+			Subject:     "12345",
+			Account:     "credit_limited",
+			Destination: "447956",
+			TimeStart:   time.Date(2014, 3, 4, 6, 0, 0, 0, time.UTC),
+			TimeEnd:     time.Date(2014, 3, 4, 6, 1, 40, 0, time.UTC),
+		}
+	}
+	duration, err := newCD().GetMaxSessionDuration()
+	if err != nil || duration <= 0 || duration >= 100*time.Second {
+		t.Errorf("bad max session duration: %v, %v", duration, err)
+	}
+	cd := newCD()
+	if cc, err := cd.MaxDebit(); err != nil || cc.GetDuration() != duration {
+		t.Errorf("max debit not capped by the credit limit: %v, %v", cc, err)
+	}
+	// the completed usage is billed past the limit, only the authorization is refused
+	if _, err := newCD().Debit(); err != nil {
+		t.Fatal(err)
+	}
+	if acc, err = accountingStorage.GetAccount("test", "credit_limited"); err != nil || acc.BalanceMap[utils.MONETARY][0].GetValue().Cmp(dec.NewFloat(-0.1)) >= 0 {
+		t.Errorf("expected the default balance past the limit: %s, %v", utils.ToIJSON(acc), err)
+	}
+	if duration, err := newCD().GetMaxSessionDuration(); err != nil || duration != 0 {
+		t.Errorf("authorized past the credit limit: %v, %v", duration, err)
+	}
+	acc.CreditLimit = dec.NewFloat(100)
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if duration, err := newCD().GetMaxSessionDuration(); err != nil || duration != 100*time.Second {
+		t.Errorf("bad max session duration: %v, %v", duration, err)
+	}
+	if _, err := newCD().Debit(); err != nil {
+		t.Fatal(err)
+	}
+	if acc, err = accountingStorage.GetAccount("test", "credit_limited"); err != nil || !acc.BalanceMap[utils.MONETARY][0].GetValue().LtZero() {
+		t.Errorf("expected the default balance below zero: %s, %v", utils.ToIJSON(acc), err)
+	}
+}
+
+func TestCreditLimitTrigger(t *testing.T) {
+	at := &ActionTrigger{UniqueID: "credit", ThresholdType: utils.TRIGGER_MIN_CREDIT, ThresholdValue: dec.NewFloat(10), TOR: utils.MONETARY}
+	acc := &Account{Tenant: "test", Name: "limited", CreditLimit: dec.NewFloat(20), BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{&Balance{UUID: "money", ID: utils.META_DEFAULT, Value: dec.NewFloat(-5), dirty: true}},
+	}, triggers: ActionTriggers{at}, TriggerRecords: map[string]*ActionTriggerRecord{"credit": &ActionTriggerRecord{}}}
+	if ids := acc.ExecuteActionTriggers(nil, true); len(ids) != 0 {
+		t.Errorf("trigger fired with 15 credit left: %v", ids)
+	}
+	acc.BalanceMap[utils.MONETARY][0].SetValue(dec.NewFloat(-12))
+	acc.BalanceMap[utils.MONETARY][0].dirty = true
+	if ids := acc.ExecuteActionTriggers(nil, true); len(ids) != 1 || ids[0] != "credit" {
+		t.Errorf("trigger not fired with 8 credit left: %v", ids)
+	}
+}
+
+func TestCreditLimitActions(t *testing.T) {
+	acc := &Account{Tenant: "test", Name: "limited", BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{&Balance{UUID: "money", ID: utils.META_DEFAULT}, &Balance{UUID: "bonus", ID: "bonus"}},
+	}}
+	if err := setCreditLimitAction(acc, nil, &Action{Params: `{"CreditLimit": 15}`}, nil); err != nil || acc.CreditLimit == nil || acc.CreditLimit.String() != "15" {
+		t.Errorf("bad account limit: %v, %v", acc.CreditLimit, err)
+	}
+	if err := setCreditLimitAction(acc, nil, &Action{Params: `{"CreditLimit": 3}`, Filter1: `{"ID":"bonus"}`}, nil); err != nil ||
+		acc.BalanceMap[utils.MONETARY][1].CreditLimit == nil || acc.BalanceMap[utils.MONETARY][0].CreditLimit != nil {
+		t.Errorf("bad balance limits: %s, %v", utils.ToIJSON(acc.BalanceMap), err)
+	}
+	if err := setCreditLimitAction(acc, nil, &Action{Params: `{"CreditLimit": 3}`, Filter1: `{"ID":"missing"}`}, nil); err != utils.ErrNotFound {
+		t.Errorf("expected not found, got: %v", err)
+	}
+	if err := setCreditLimitAction(acc, nil, &Action{Params: `{"CreditLimit": -3}`}, nil); err == nil {
+		t.Error("negative credit limit accepted")
+	}
+	if err := removeCreditLimitAction(acc, nil, &Action{}, nil); err != nil || acc.CreditLimit != nil {
+		t.Errorf("account limit not removed: %v, %v", acc.CreditLimit, err)
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// ExchangeRate converts the amounts from one currency to another, the rate changes at each activation
+type ExchangeRate struct {
+	Tenant      string                    `bson:"tenant"`
+	From        string                    `bson:"from"`
+	To          string                    `bson:"to"`
+	Activations []*ExchangeRateActivation `bson:"activations"`
+}
+
+type ExchangeRateActivation struct {
+	ActivationTime time.Time `bson:"activation_time"`
+	Rate           *dec.Dec  `bson:"rate"` // units of To for one unit of From
+}
+
+// Sort orders the activations by activation time
+func (er *ExchangeRate) Sort() {
+	sort.SliceStable(er.Activations, func(i, j int) bool {
+		return er.Activations[i].ActivationTime.Before(er.Activations[j].ActivationTime)
+	})
+}
+
+// GetActiveAt returns the activation in force at the specified time, nil if none
+func (er *ExchangeRate) GetActiveAt(t time.Time) (active *ExchangeRateActivation) {
+	for _, era := range er.Activations {
+		if era.ActivationTime.After(t) {
+			continue
+		}
+		if active == nil || era.ActivationTime.After(active.ActivationTime) {
+			active = era
+		}
+	}
+	return
+}
+
+// CurrencyConversion is the exchange rate used to debit a balance in a different currency than the rating one
+type CurrencyConversion struct {
+	From           string
+	To             string
+	Rate           *dec.Dec
+	ActivationTime time.Time
+}
+
+// Convert returns the amount in the To currency, a nil conversion leaves the amount unchanged
+func (conv *CurrencyConversion) Convert(amount *dec.Dec) *dec.Dec {
+	if conv == nil {
+		return amount
+	}
+	return dec.New().Mul(amount, conv.Rate)
+}
+
+// GetRate returns the rate of the conversion, nil for no conversion
+func (conv *CurrencyConversion) GetRate() *dec.Dec {
+	if conv == nil {
+		return nil
+	}
+	return conv.Rate
+}
+
+func (conv *CurrencyConversion) Equal(other *CurrencyConversion) bool {
+	return conv.From == other.From &&
+		conv.To == other.To &&
+		conv.ActivationTime.Equal(other.ActivationTime) &&
+		conv.Rate.Cmp(other.Rate) == 0
+}
+
+// getCurrencyConversion returns the conversion in force at the specified time, nil if the currencies do not differ.
+// When there is no direct rate the inverse of the opposite one is used.
+func getCurrencyConversion(tenant, from, to string, t time.Time) (*CurrencyConversion, error) {
+	if from == "" || to == "" || from == to {
+		return nil, nil
+	}
+	if ratingStorage == nil { // e.g. a session manager without storage
+		return nil, fmt.Errorf("no exchange rates to convert %s to %s", from, to)
+	}
+	er, err := ratingStorage.GetExchangeRate(tenant, from, to, utils.CACHED)
+	if err != nil && err != utils.ErrNotFound {
+		return nil, err
+	}
+	if er != nil {
+		if era := er.GetActiveAt(t); era != nil {
+			return &CurrencyConversion{From: from, To: to, Rate: dec.New().Set(era.Rate), ActivationTime: era.ActivationTime}, nil
+		}
+	}
+	er, err = ratingStorage.GetExchangeRate(tenant, to, from, utils.CACHED)
+	if err != nil && err != utils.ErrNotFound {
+		return nil, err
+	}
+	if er != nil {
+		if era := er.GetActiveAt(t); era != nil && !era.Rate.IsZero() {
+			return &CurrencyConversion{From: from, To: to, Rate: dec.New().Quo(dec.NewVal(1, 0), era.Rate), ActivationTime: era.ActivationTime}, nil
+		}
+	}
+	return nil, fmt.Errorf("no exchange rate from %s to %s at %s", from, to, t.Format(time.RFC3339))
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func setTestExchangeRates(t *testing.T) {
+	for _, er := range []*ExchangeRate{
+		&ExchangeRate{Tenant: "test", From: "USD", To: "EUR", Activations: []*ExchangeRateActivation{
+			&ExchangeRateActivation{ActivationTime: time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC), Rate: dec.NewVal(5, 1)},
+			&ExchangeRateActivation{ActivationTime: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), Rate: dec.NewVal(8, 1)},
+		}},
+		&ExchangeRate{Tenant: "test", From: "GBP", To: "USD", Activations: []*ExchangeRateActivation{
+			&ExchangeRateActivation{ActivationTime: time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC), Rate: dec.NewVal(2, 0)},
+		}},
+	} {
+		if err := ratingStorage.SetExchangeRate(er); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExchangeRateConversion(t *testing.T) {
+	setTestExchangeRates(t)
+	if conv, err := getCurrencyConversion("test", "USD", "USD", time.Now()); err != nil || conv != nil {
+		t.Error("conversion for the same currency: ", conv, err)
+	}
+	conv, err := getCurrencyConversion("test", "USD", "EUR", time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || conv.Rate.Cmp(dec.NewVal(5, 1)) != 0 {
+		t.Errorf("bad conversion: %s, %v", utils.ToIJSON(conv), err)
+	}
+	if conv, err = getCurrencyConversion("test", "USD", "EUR", time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC)); err != nil || conv.Rate.Cmp(dec.NewVal(8, 1)) != 0 {
+		t.Errorf("bad later conversion: %s, %v", utils.ToIJSON(conv), err)
+	}
+	// the inverse of the opposite rate
+	if conv, err = getCurrencyConversion("test", "USD", "GBP", time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC)); err != nil || conv.Rate.Cmp(dec.NewVal(5, 1)) != 0 {
+		t.Errorf("bad inverse conversion: %s, %v", utils.ToIJSON(conv), err)
+	}
+	if _, err = getCurrencyConversion("test", "USD", "EUR", time.Date(2012, 6, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected no rate before the first activation")
+	}
+	if _, err = getCurrencyConversion("test", "USD", "RON", time.Now()); err == nil {
+		t.Error("expected missing rate error")
+	}
+}
+
+func TestExchangeRateDebitMoney(t *testing.T) {
+	setTestExchangeRates(t)
+	cc := &CallCost{
+		Tenant:      "test",
+		Direction:   utils.OUT,
+		Destination: "0723045326",
+		Currency:    "USD",
+		Timespans: []*TimeSpan{
+			&TimeSpan{
+				TimeStart:    time.Date(2013, 9, 24, 10, 48, 0, 0, time.UTC),
+				TimeEnd:      time.Date(2013, 9, 24, 10, 48, 10, 0, time.UTC),
+				Currency:     "USD",
+				RateInterval: &RateInterval{Rating: &RIRate{ConnectFee: dec.NewVal(1, 0), Rates: RateGroups{&RateInfo{GroupIntervalStart: 0, Value: dec.NewVal(1, 0), RateIncrement: time.Second, RateUnit: time.Second}}}},
+			},
+		},
+		deductConnectFee: true,
+		TOR:              utils.VOICE,
+	}
+	cd := &CallDescriptor{
+		TimeStart:    time.Date(2013, 9, 24, 10, 48, 0, 0, time.UTC),
+		TimeEnd:      time.Date(2013, 9, 24, 10, 48, 10, 0, time.UTC),
+		Direction:    utils.OUT,
+		Tenant:       "test",
+		Destination:  "0723045326",
+		Category:     "0",
+		TOR:          utils.VOICE,
+		testCallcost: cc,
+	}
+	acc := &Account{Tenant: "test", Name: "currency", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+		&Balance{UUID: "ron", Value: dec.NewVal(100, 0), Weight: 20, Currency: "RON"}, // no exchange rate
+		&Balance{UUID: "eur", Value: dec.NewVal(100, 0), Weight: 10, Currency: "EUR"},
+	}}}
+	if _, err := acc.debitCreditBalance(cd, false, false, true); err == nil {
+		t.Error("balance without exchange rate skipped instead of failing the debit")
+	}
+	acc.BalanceMap[utils.MONETARY] = acc.BalanceMap[utils.MONETARY][1:]
+	acc.BalanceMap[utils.MONETARY][0].SetValue(dec.NewVal(100, 0))
+	cd.MaxCostSoFar = nil
+	result, err := acc.debitCreditBalance(cd, false, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 USD plus 1 USD connect fee at 0.5
+	if v := acc.BalanceMap[utils.MONETARY].GetBalance("eur").GetValue(); v.Cmp(dec.NewVal(945, 1)) != 0 {
+		t.Error("bad converted debit: ", v)
+	}
+	if len(result.Conversions) != 1 || result.Conversions[0].From != "USD" || result.Conversions[0].To != "EUR" ||
+		result.Conversions[0].Rate.Cmp(dec.NewVal(5, 1)) != 0 || result.Currency != "USD" {
+		t.Errorf("conversion not recorded: %s", utils.ToIJSON(result))
+	}
+	inc := result.Timespans[0].Increments.CompIncrement
+	if inc.BalanceInfo.Monetary == nil || inc.BalanceInfo.Monetary.ExchangeRate.Cmp(dec.NewVal(5, 1)) != 0 {
+		t.Errorf("exchange rate missing from increment: %s", utils.ToIJSON(inc))
+	}
+}
+
+func TestExchangeRateMaxCostSoFar(t *testing.T) {
+	setTestExchangeRates(t)
+	cd := &CallDescriptor{Tenant: "test", TimeStart: time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if err := cd.AddMaxCostSoFar(dec.NewVal(10, 0), "USD"); err != nil || cd.MaxCostCurrency != "USD" {
+		t.Fatal(cd.MaxCostCurrency, err)
+	}
+	// 8 EUR are 10 USD at 0.8
+	if err := cd.AddMaxCostSoFar(dec.NewVal(8, 0), "EUR"); err != nil || cd.GetMaxCostSoFar().Cmp(dec.NewVal(20, 0)) != 0 {
+		t.Errorf("bad max cost so far: %s, %v", cd.GetMaxCostSoFar(), err)
+	}
+	if reached, err := cd.maxCostReached(utils.MAX_COST_FREE, dec.NewVal(16, 0), "EUR"); err != nil || !reached {
+		t.Error("max cost in another currency not reached: ", reached, err)
+	}
+	if reached, err := cd.maxCostReached(utils.MAX_COST_FREE, dec.NewVal(17, 0), "EUR"); err != nil || reached {
+		t.Error("max cost in another currency reached: ", reached, err)
+	}
+	if err := cd.AddMaxCostSoFar(dec.NewVal(1, 0), "RON"); err == nil {
+		t.Error("cost without exchange rate added")
+	}
+}
@@ -55,6 +55,7 @@ type MonetaryInfo struct {
 	ID           string
 	Value        *dec.Dec
 	RateInterval *RateInterval
+	ExchangeRate *dec.Dec // rating to balance currency rate, nil if the currencies do not differ
 }
 
 func (mi *MonetaryInfo) getValue() *dec.Dec {
@@ -76,6 +77,10 @@ func (mi *MonetaryInfo) Equal(other *MonetaryInfo) bool {
 	if mi == nil || other == nil {
 		return false
 	}
+	if (mi.ExchangeRate == nil) != (other.ExchangeRate == nil) ||
+		mi.ExchangeRate != nil && mi.ExchangeRate.Cmp(other.ExchangeRate) != 0 {
+		return false
+	}
 	return mi.UUID == other.UUID &&
 		reflect.DeepEqual(mi.RateInterval, other.RateInterval)
 }
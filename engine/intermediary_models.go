@@ -37,9 +37,10 @@ type DestinationRate struct {
 }
 
 type DestinationRateBinding struct {
-	DestinationCode string  `bson:"destination_code"`
-	DestinationName string  `bson:"destination_name"`
-	RateID          string  `bson:"rate_name"`
-	MaxCost         float64 `bson:"max_cost"`
-	MaxCostStrategy string  `bson:"max_cost_strategy"`
+	DestinationCode string    `bson:"destination_code"`
+	DestinationName string    `bson:"destination_name"`
+	RateID          string    `bson:"rate_name"`
+	MaxCost         float64   `bson:"max_cost"`
+	MaxCostStrategy string    `bson:"max_cost_strategy"`
+	Rounding        *Rounding `bson:"rounding,omitempty"`
 }
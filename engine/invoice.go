@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+	"go.uber.org/zap"
+)
+
+const INVOICE_USAGE = "*usage"
+
+// InvoiceLine sums the cdrs of one kind, tor, category and destination
+type InvoiceLine struct {
+	Type        string        `bson:"type"` // *usage, *debit or *topup (recurring charges and credits out of the ledger)
+	ToR         string        `bson:"tor"`
+	Category    string        `bson:"category"`
+	Destination string        `bson:"destination"` // matched destination id when the cost details are available
+	Count       int           `bson:"count"`
+	Usage       time.Duration `bson:"usage"`
+	Amount      *dec.Dec      `bson:"amount"`
+}
+
+// InvoiceTax sums the cdr taxes of one tax rule
+type InvoiceTax struct {
+	Name   string   `bson:"name"`
+	Type   string   `bson:"type"`
+	Value  *dec.Dec `bson:"value"` // the percent or the amount per unit of the rule
+	Base   *dec.Dec `bson:"base"`
+	Amount *dec.Dec `bson:"amount"`
+}
+
+// Invoice is the immutable bill of one account for a billing period, numbered sequentially per tenant
+type Invoice struct {
+	Tenant    string         `bson:"tenant"`
+	Number    int64          `bson:"number"`
+	Account   string         `bson:"account"`
+	Start     time.Time      `bson:"start"` // billing period start, included
+	End       time.Time      `bson:"end"`   // billing period end, excluded
+	Lines     []*InvoiceLine `bson:"lines"`
+	Subtotal  *dec.Dec       `bson:"subtotal"` // usage and recurring charges, the taxed base
+	Taxes     []*InvoiceTax  `bson:"taxes"`
+	Credits   *dec.Dec       `bson:"credits"` // topups, deducted after the taxes
+	Total     *dec.Dec       `bson:"total"`
+	CreatedAt time.Time      `bson:"created_at"`
+}
+
+func (inv *Invoice) overlaps(start, end time.Time) bool {
+	return inv.Start.Before(end) && start.Before(inv.End)
+}
+
+// AsCSV renders the invoice as header, lines and totals records
+func (inv *Invoice) AsCSV() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	records := [][]string{
+		{"Invoice", inv.Tenant, strconv.FormatInt(inv.Number, 10), inv.Account, inv.Start.Format(time.RFC3339), inv.End.Format(time.RFC3339)},
+		{"Type", "ToR", "Category", "Destination", "Count", "Usage", "Amount"},
+	}
+	for _, l := range inv.Lines {
+		records = append(records, []string{l.Type, l.ToR, l.Category, l.Destination, strconv.Itoa(l.Count), l.Usage.String(), l.Amount.String()})
+	}
+	records = append(records, []string{"Subtotal", "", "", "", "", "", inv.Subtotal.String()})
+	for _, tax := range inv.Taxes {
+		records = append(records, []string{"Tax", tax.Name, tax.Type, tax.Value.String(), "", tax.Base.String(), tax.Amount.String()})
+	}
+	if inv.Credits != nil && !inv.Credits.IsZero() {
+		records = append(records, []string{"Credits", "", "", "", "", "", inv.Credits.String()})
+	}
+	records = append(records, []string{"Total", "", "", "", "", "", inv.Total.String()})
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// InvoiceGenerator bills the rated cdrs and the recurring charges of an account for a period
+type InvoiceGenerator struct {
+	Tenant  string
+	Account string
+	Start   time.Time
+	End     time.Time
+}
+
+// Generate creates and stores the invoice, periods already invoiced for the account are refused
+func (ig *InvoiceGenerator) Generate() (inv *Invoice, err error) {
+	if !ig.Start.Before(ig.End) {
+		return nil, errors.New("invoice period start must be before end")
+	}
+	if cdrStorage == nil {
+		return nil, errors.New("no cdr storage")
+	}
+	// serialize the invoicing of the same account
+	_, err = Guardian.Guard(func() (interface{}, error) {
+		inv, err = ig.generate()
+		return 0, err
+	}, 0, utils.ConcatKey(ColInv, ig.Tenant, ig.Account))
+	return inv, err
+}
+
+func (ig *InvoiceGenerator) generate() (*Invoice, error) {
+	invoices, err := accountingStorage.GetInvoices(ig.Tenant, ig.Account, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range invoices {
+		if existing.overlaps(ig.Start, ig.End) {
+			return nil, utils.ErrExists
+		}
+	}
+	cdrs, _, err := cdrStorage.GetCDRs(&utils.CDRsFilter{
+		Tenants:         []string{ig.Tenant},
+		Accounts:        []string{ig.Account},
+		NotRunIDs:       []string{utils.MetaRaw, TRANSFER},
+		AnswerTimeStart: &ig.Start,
+		AnswerTimeEnd:   &ig.End,
+	}, false)
+	if err != nil && err != utils.ErrNotFound {
+		return nil, err
+	}
+	inv := &Invoice{
+		Tenant:    ig.Tenant,
+		Account:   ig.Account,
+		Start:     ig.Start,
+		End:       ig.End,
+		Subtotal:  dec.New(),
+		Credits:   dec.New(),
+		Total:     dec.New(),
+		CreatedAt: time.Now(),
+	}
+	lines := make(map[string]*InvoiceLine)
+	taxes := make(map[string]*InvoiceTax)
+	for _, cdr := range cdrs {
+		line := ig.lineFor(cdr, lines)
+		if line == nil {
+			continue
+		}
+		line.Count++
+		line.Usage += cdr.Usage
+		line.Amount.AddS(cdr.GetCost())
+		inv.Subtotal.AddS(cdr.GetCost())
+		for _, cdrTax := range cdr.Taxes {
+			key := utils.ConcatKey(cdrTax.Name, cdrTax.Type, cdrTax.Value.String())
+			tax, found := taxes[key]
+			if !found {
+				tax = &InvoiceTax{Name: cdrTax.Name, Type: cdrTax.Type, Value: dec.New().Set(cdrTax.Value), Base: dec.New(), Amount: dec.New()}
+				taxes[key] = tax
+				inv.Taxes = append(inv.Taxes, tax)
+			}
+			tax.Base.AddS(cdrTax.Base)
+			tax.Amount.AddS(cdrTax.Amount)
+		}
+	}
+	// the recurring charges and credits are journaled whether or not they are logged as cdrs
+	entries, err := accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: ig.Tenant, Account: ig.Account,
+		BalanceType: utils.MONETARY, TimeStart: ig.Start, TimeEnd: ig.End}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		line := ig.ledgerLineFor(e, lines)
+		if line == nil {
+			continue
+		}
+		line.Count++
+		if line.Type == TOPUP {
+			line.Amount.AddS(e.Delta)
+			inv.Credits.AddS(e.Delta)
+			continue
+		}
+		amount := dec.New().Neg(e.Delta)
+		line.Amount.AddS(amount)
+		inv.Subtotal.AddS(amount)
+	}
+	for _, line := range lines {
+		inv.Lines = append(inv.Lines, line)
+	}
+	sort.Slice(inv.Lines, func(i, j int) bool {
+		return utils.ConcatKey(inv.Lines[i].Type, inv.Lines[i].ToR, inv.Lines[i].Category, inv.Lines[i].Destination) <
+			utils.ConcatKey(inv.Lines[j].Type, inv.Lines[j].ToR, inv.Lines[j].Category, inv.Lines[j].Destination)
+	})
+	sort.SliceStable(inv.Taxes, func(i, j int) bool { return inv.Taxes[i].Name < inv.Taxes[j].Name })
+	inv.Total.Set(inv.Subtotal)
+	for _, tax := range inv.Taxes {
+		tax.Amount.Round(globalRoundingDecimals)
+		inv.Total.AddS(tax.Amount)
+	}
+	inv.Total.SubS(inv.Credits)
+	if rounding := tenantInvoiceRounding(inv.Tenant); rounding != nil {
+		rounding.Round(inv.Total)
+	}
+	if err := accountingStorage.AddInvoice(inv); err != nil {
+		return nil, err
+	}
+	utils.Logger.Info("<Invoice> generated", zap.String("tenant", inv.Tenant), zap.Int64("number", inv.Number),
+		zap.String("account", inv.Account), zap.Int("cdrs", len(cdrs)), zap.String("total", inv.Total.String()))
+	return inv, nil
+}
+
+// lineFor returns the line collecting the cdr, nil if the cdr is not billed
+// the cdrlog cdrs are skipped, their balance changes are billed out of the ledger
+func (ig *InvoiceGenerator) lineFor(cdr *CDR, lines map[string]*InvoiceLine) *InvoiceLine {
+	if cdr.Source == CDRLOG || cdr.GetCost().LtZero() { // not rated
+		return nil
+	}
+	destination := cdr.Destination
+	if cdr.CostDetails != nil && len(cdr.CostDetails.Timespans) > 0 && cdr.CostDetails.Timespans[0].MatchedDestID != "" {
+		destination = cdr.CostDetails.Timespans[0].MatchedDestID
+	}
+	return invoiceLine(lines, INVOICE_USAGE, cdr.ToR, cdr.Category, destination)
+}
+
+// ledgerLineFor returns the line collecting the balance change, nil if it is neither a charge nor a credit
+func (ig *InvoiceGenerator) ledgerLineFor(e *LedgerEntry, lines map[string]*InvoiceLine) *InvoiceLine {
+	actionType := e.Action
+	switch e.Cause {
+	case LEDGER_ACTION:
+	case LEDGER_API:
+		if actionType == "" {
+			actionType = e.CauseID
+		}
+	default:
+		return nil
+	}
+	switch actionType {
+	case DEBIT, DEBIT_RESET:
+		return invoiceLine(lines, DEBIT, utils.MONETARY, "", "")
+	case TOPUP, TOPUP_RESET:
+		return invoiceLine(lines, TOPUP, utils.MONETARY, "", "")
+	}
+	return nil
+}
+
+func invoiceLine(lines map[string]*InvoiceLine, lineType, tor, category, destination string) *InvoiceLine {
+	key := utils.ConcatKey(lineType, tor, category, destination)
+	line, found := lines[key]
+	if !found {
+		line = &InvoiceLine{Type: lineType, ToR: tor, Category: category, Destination: destination, Amount: dec.New()}
+		lines[key] = line
+	}
+	return line
+}
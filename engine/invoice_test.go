@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestInvoiceGenerate(t *testing.T) {
+	cdrDB, cleanup := newTestSQLStorage(t)
+	defer cleanup()
+	savedCdrStorage := cdrStorage
+	cdrStorage = cdrDB
+	defer func() { cdrStorage = savedCdrStorage }()
+
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	for i, cdr := range []*CDR{
+		&CDR{RunID: utils.META_DEFAULT, ToR: utils.VOICE, Category: "call", Destination: "0723", Usage: time.Minute, Cost: dec.NewFloat(1.5),
+			Taxes: []*CDRTax{&CDRTax{Name: "VAT", Type: TAX_PERCENT, Value: dec.NewFloat(20), Base: dec.NewFloat(1.5), Amount: dec.NewVal(3, 1)}}},
+		&CDR{RunID: utils.META_DEFAULT, ToR: utils.VOICE, Category: "call", Destination: "0723", Usage: 2 * time.Minute, Cost: dec.NewFloat(2.5),
+			Taxes: []*CDRTax{&CDRTax{Name: "VAT", Type: TAX_PERCENT, Value: dec.NewFloat(20), Base: dec.NewFloat(2.5), Amount: dec.NewVal(5, 1)}}},
+		&CDR{RunID: utils.MetaRaw, ToR: utils.VOICE, Category: "call", Destination: "0723", Usage: time.Minute, Cost: dec.NewFloat(-1)},
+		&CDR{RunID: utils.META_DEFAULT, ToR: utils.VOICE, Category: "call", Destination: "0723", Usage: time.Minute, Cost: dec.NewFloat(-1)},
+		&CDR{RunID: DEBIT, Source: CDRLOG, ToR: utils.MONETARY, Cost: dec.NewFloat(10)},
+	} {
+		cdr.UniqueID = utils.GenUUID()
+		cdr.Tenant, cdr.Account = "invoice", "acc1"
+		cdr.AnswerTime = start.Add(time.Duration(i+1) * time.Hour)
+		if err := cdrStorage.SetCDR(cdr, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// next period
+	if err := cdrStorage.SetCDR(&CDR{UniqueID: utils.GenUUID(), RunID: utils.META_DEFAULT, Tenant: "invoice", Account: "acc1", ToR: utils.VOICE,
+		Category: "call", Destination: "0723", AnswerTime: end.Add(time.Hour), Usage: time.Minute, Cost: dec.NewFloat(3)}, false); err != nil {
+		t.Fatal(err)
+	}
+	// the recurring charges are billed out of the ledger, the cdrlog cdr above is not counted again
+	entries := []*LedgerEntry{
+		&LedgerEntry{Cause: LEDGER_ACTION, CauseID: "MONTHLY_FEE", Action: DEBIT, Delta: dec.NewFloat(-10), Time: start.Add(time.Hour)},
+		&LedgerEntry{Cause: LEDGER_API, CauseID: TOPUP, Delta: dec.NewFloat(5), Time: start.Add(2 * time.Hour)},
+		&LedgerEntry{Cause: LEDGER_DEBIT, CauseID: "call1", Delta: dec.NewFloat(-4), Time: start.Add(3 * time.Hour)},
+		&LedgerEntry{Cause: LEDGER_ACTION, CauseID: "MONTHLY_FEE", Action: DEBIT, Delta: dec.NewFloat(-10), Time: end.Add(time.Hour)},
+	}
+	for _, e := range entries {
+		e.Tenant, e.Account, e.BalanceUUID, e.BalanceType = "invoice", "acc1", "inv1", utils.MONETARY
+	}
+	if err := accountingStorage.AddLedgerEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := (&InvoiceGenerator{Tenant: "invoice", Account: "acc1", Start: start, End: end}).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Number != 1 || len(inv.Lines) != 3 || len(inv.Taxes) != 1 {
+		t.Fatalf("bad invoice: %s", utils.ToIJSON(inv))
+	}
+	// topups are credited after the taxes, only the usage carries taxes
+	if inv.Subtotal.Cmp(dec.NewFloat(14)) != 0 || inv.Taxes[0].Amount.Cmp(dec.NewVal(8, 1)) != 0 || inv.Taxes[0].Base.Cmp(dec.NewFloat(4)) != 0 ||
+		inv.Credits.Cmp(dec.NewFloat(5)) != 0 || inv.Total.Cmp(dec.NewVal(98, 1)) != 0 {
+		t.Errorf("bad invoice totals: %s", utils.ToIJSON(inv))
+	}
+	for _, line := range inv.Lines {
+		if line.Type == INVOICE_USAGE && (line.Count != 2 || line.Usage != 3*time.Minute || line.Amount.Cmp(dec.NewFloat(4)) != 0) {
+			t.Errorf("bad usage line: %s", utils.ToIJSON(line))
+		}
+		if line.Type == DEBIT && (line.Count != 1 || line.Amount.Cmp(dec.NewFloat(10)) != 0) {
+			t.Errorf("bad debit line: %s", utils.ToIJSON(line))
+		}
+		if line.Type == TOPUP && line.Amount.Cmp(dec.NewFloat(5)) != 0 {
+			t.Errorf("bad topup line: %s", utils.ToIJSON(line))
+		}
+	}
+
+	if _, err := (&InvoiceGenerator{Tenant: "invoice", Account: "acc1", Start: start.Add(24 * time.Hour), End: end.Add(24 * time.Hour)}).Generate(); err != utils.ErrExists {
+		t.Error("expected overlapping period to be refused, got: ", err)
+	}
+	next, err := (&InvoiceGenerator{Tenant: "invoice", Account: "acc1", Start: end, End: end.AddDate(0, 1, 0)}).Generate()
+	if err != nil || next.Number != 2 || next.Total.Cmp(dec.NewFloat(13)) != 0 {
+		t.Errorf("bad next invoice: %s, %v", utils.ToIJSON(next), err)
+	}
+	if invs, err := accountingStorage.GetInvoices("invoice", "acc1", 0, 0); err != nil || len(invs) != 2 || invs[0].Number != 1 {
+		t.Errorf("bad stored invoices: %s, %v", utils.ToIJSON(invs), err)
+	}
+
+	stored, err := accountingStorage.GetInvoice("invoice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := stored.AsCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "Tax,VAT,*percent,20,,4.0,0.8") || !strings.Contains(string(out), "Credits,,,,,,5") || !strings.Contains(string(out), "Total,,,,,,9.8") {
+		t.Errorf("bad csv invoice: %s", out)
+	}
+}
@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// ledger causes of the balance changes
+const (
+	LEDGER_DEBIT    = "*debit"    // call debit, the cause id is the OriginID of the call
+	LEDGER_REFUND   = "*refund"   // call refund, the cause id is the OriginID of the call
+	LEDGER_ACTION   = "*action"   // action plan or trigger, the cause id is the action group
+	LEDGER_API      = "*api"      // api call, the cause id is the action type executed
+	LEDGER_TRANSFER = "*transfer" // balance transfer between accounts
+	LEDGER_EXPIRY   = "*expiry"   // expired balance removed
+	LEDGER_VOUCHER  = "*voucher"  // voucher redemption, the cause id is the voucher serial
+	LEDGER_OPENING  = "*opening"  // balance value found when the ledger was introduced
+)
+
+// LedgerEntry records a change of a balance value, the ledger of a balance is append only
+type LedgerEntry struct {
+	Tenant      string    `bson:"tenant"`
+	Account     string    `bson:"account"`
+	BalanceUUID string    `bson:"balance_uuid"`
+	BalanceID   string    `bson:"balance_id"`
+	BalanceType string    `bson:"balance_type"`
+	Delta       *dec.Dec  `bson:"delta"`
+	Value       *dec.Dec  `bson:"value"`    // the balance value after the change
+	Cause       string    `bson:"cause"`    // empty when the change was not attributed
+	CauseID     string    `bson:"cause_id"` // OriginID, action group id, api action type
+	Action      string    `bson:"action"`   // the action type for the action and api causes
+	Time        time.Time `bson:"time"`
+}
+
+// LedgerFilter selects the ledger entries of a tenant, the empty fields match all
+type LedgerFilter struct {
+	Tenant      string
+	Account     string
+	BalanceUUID string
+	BalanceType string
+	Cause       string
+	CauseID     string
+	TimeStart   time.Time // included
+	TimeEnd     time.Time // excluded
+}
+
+func (lf *LedgerFilter) query() map[string]interface{} {
+	q := map[string]interface{}{"tenant": lf.Tenant}
+	for field, value := range map[string]string{"account": lf.Account, "balance_uuid": lf.BalanceUUID, "balance_type": lf.BalanceType, "cause": lf.Cause, "cause_id": lf.CauseID} {
+		if value != "" {
+			q[field] = value
+		}
+	}
+	timeQ := make(map[string]interface{})
+	if !lf.TimeStart.IsZero() {
+		timeQ["$gte"] = lf.TimeStart
+	}
+	if !lf.TimeEnd.IsZero() {
+		timeQ["$lt"] = lf.TimeEnd
+	}
+	if len(timeQ) != 0 {
+		q["time"] = timeQ
+	}
+	return q
+}
+
+// ledgerBalance is the balance as last journaled
+type ledgerBalance struct {
+	id, balanceType string
+	value           *dec.Dec
+	expiration      time.Time
+}
+
+// accountLedger follows the balance changes of the account since it was loaded
+type accountLedger struct {
+	balances map[string]*ledgerBalance // by uuid, nil when the account was not loaded from storage
+	cause    string
+	causeID  string
+	action   string
+	pending  []*LedgerEntry
+	disabled bool
+}
+
+// SetLedgerCause attributes the next balance changes, the changes so far keep the previous cause
+func (acc *Account) SetLedgerCause(cause, causeID string) {
+	acc.collectLedger()
+	acc.ledger.cause, acc.ledger.causeID, acc.ledger.action = cause, causeID, ""
+}
+
+// pushLedgerCause attributes the next balance changes until the returned function restores the previous cause
+func (acc *Account) pushLedgerCause(cause, causeID string) func() {
+	acc.collectLedger()
+	prevCause, prevCauseID, prevAction := acc.ledger.cause, acc.ledger.causeID, acc.ledger.action
+	acc.ledger.cause, acc.ledger.causeID, acc.ledger.action = cause, causeID, ""
+	return func() {
+		acc.collectLedger()
+		acc.ledger.cause, acc.ledger.causeID, acc.ledger.action = prevCause, prevCauseID, prevAction
+	}
+}
+
+// setLedgerAction attributes the next balance changes to an action type within the current cause
+func (acc *Account) setLedgerAction(actionType string) {
+	acc.collectLedger()
+	acc.ledger.action = actionType
+}
+
+// ledgerCause returns the current cause of the balance changes
+func (acc *Account) ledgerCause() (string, string) {
+	if acc.ledger == nil {
+		return "", ""
+	}
+	return acc.ledger.cause, acc.ledger.causeID
+}
+
+// snapshotLedger marks the current balances as journaled
+func (acc *Account) snapshotLedger() {
+	if acc.ledger == nil {
+		acc.ledger = &accountLedger{}
+	}
+	acc.ledger.balances = make(map[string]*ledgerBalance)
+	for balanceType, balances := range acc.BalanceMap {
+		for _, b := range balances {
+			acc.ledger.balances[b.UUID] = &ledgerBalance{id: b.ID, balanceType: balanceType, value: dec.New().Set(b.GetValue()), expiration: b.ExpirationDate}
+		}
+	}
+}
+
+// disableLedger stops journaling the account, used when it is copied as it is
+func (acc *Account) disableLedger() {
+	if acc.ledger == nil {
+		acc.ledger = &accountLedger{}
+	}
+	acc.ledger.disabled = true
+}
+
+// collectLedger adds the balance changes since the last snapshot to the pending entries
+func (acc *Account) collectLedger() {
+	if acc.ledger == nil {
+		acc.ledger = &accountLedger{}
+	}
+	if acc.ledger.balances == nil || acc.ledger.disabled {
+		return
+	}
+	now := time.Now()
+	newEntry := func(uuid string, lb *ledgerBalance, delta, value *dec.Dec, cause, causeID string) {
+		action := acc.ledger.action
+		if cause == LEDGER_EXPIRY {
+			action = ""
+		}
+		acc.ledger.pending = append(acc.ledger.pending, &LedgerEntry{Tenant: acc.Tenant, Account: acc.Name,
+			BalanceUUID: uuid, BalanceID: lb.id, BalanceType: lb.balanceType,
+			Delta: delta, Value: value, Cause: cause, CauseID: causeID, Action: action, Time: now})
+	}
+	current := make(map[string]bool)
+	for balanceType, balances := range acc.BalanceMap {
+		for _, b := range balances {
+			current[b.UUID] = true
+			previous, found := acc.ledger.balances[b.UUID]
+			if !found {
+				previous = &ledgerBalance{value: dec.New()}
+			}
+			previous.id, previous.balanceType = b.ID, balanceType
+			if previous.value.Cmp(b.GetValue()) == 0 {
+				continue
+			}
+			newEntry(b.UUID, previous, dec.New().Sub(b.GetValue(), previous.value), dec.New().Set(b.GetValue()), acc.ledger.cause, acc.ledger.causeID)
+		}
+	}
+	for uuid, previous := range acc.ledger.balances {
+		if current[uuid] || previous.value.IsZero() {
+			continue
+		}
+		cause, causeID := acc.ledger.cause, acc.ledger.causeID
+		if !previous.expiration.IsZero() && previous.expiration.Before(now) {
+			cause, causeID = LEDGER_EXPIRY, ""
+		}
+		newEntry(uuid, previous, dec.New().Neg(previous.value), dec.New(), cause, causeID)
+	}
+	acc.snapshotLedger()
+}
+
+// ledgerEntries returns the balance changes to journal when saving the account.
+// The accounts not loaded from the storage are compared with the stored ones.
+func (acc *Account) ledgerEntries(getAccount func(tenant, name string) (*Account, error)) []*LedgerEntry {
+	if acc.ledger != nil && acc.ledger.disabled {
+		return nil
+	}
+	if acc.ledger == nil || acc.ledger.balances == nil {
+		cause, causeID := acc.ledgerCause()
+		stored, err := getAccount(acc.Tenant, acc.Name)
+		if err != nil || stored == nil {
+			stored = &Account{Tenant: acc.Tenant, Name: acc.Name}
+		}
+		stored.snapshotLedger()
+		if acc.ledger == nil {
+			acc.ledger = &accountLedger{}
+		}
+		acc.ledger.balances = stored.ledger.balances
+		acc.ledger.cause, acc.ledger.causeID = cause, causeID
+	}
+	acc.collectLedger()
+	entries := acc.ledger.pending
+	acc.ledger.pending = nil
+	return entries
+}
+
+// LedgerCheck is the outcome of recomputing a balance out of its ledger
+type LedgerCheck struct {
+	BalanceUUID string
+	Entries     int
+	Initial     *dec.Dec // the value before the first entry
+	Computed    *dec.Dec // the initial value with all the deltas applied
+	Current     *dec.Dec // the value in the account, nil if the balance is gone
+	Consistent  bool
+	Breaks      []*LedgerEntry // the entries not following from the previous ones
+}
+
+// CheckBalanceLedger recomputes the balance out of its ledger and compares it with the account one
+func CheckBalanceLedger(tenant, account, balanceUUID string) (*LedgerCheck, error) {
+	entries, err := accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: tenant, Account: account, BalanceUUID: balanceUUID}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := accountingStorage.GetAccount(tenant, account)
+	if err != nil {
+		return nil, err
+	}
+	return checkLedger(balanceUUID, entries, acc), nil
+}
+
+func checkLedger(balanceUUID string, entries []*LedgerEntry, acc *Account) *LedgerCheck {
+	lc := &LedgerCheck{BalanceUUID: balanceUUID, Entries: len(entries), Initial: dec.New(), Computed: dec.New()}
+	for i, e := range entries {
+		if i == 0 {
+			lc.Initial.Sub(e.Value, e.Delta)
+			lc.Computed.Set(lc.Initial)
+		} else if dec.New().Add(entries[i-1].Value, e.Delta).Cmp(e.Value) != 0 {
+			lc.Breaks = append(lc.Breaks, e)
+		}
+		lc.Computed.AddS(e.Delta)
+	}
+	if acc != nil {
+		for _, balances := range acc.BalanceMap {
+			for _, b := range balances {
+				if b.UUID == balanceUUID {
+					lc.Current = dec.New().Set(b.GetValue())
+				}
+			}
+		}
+	}
+	if lc.Current == nil {
+		lc.Consistent = len(lc.Breaks) == 0 && lc.Computed.IsZero()
+	} else {
+		lc.Consistent = len(lc.Breaks) == 0 && lc.Computed.Cmp(lc.Current) == 0
+	}
+	return lc
+}
+
+func init() {
+	RegisterMigration(&Migration{Field: "Accounts", FromVersion: "1", ToVersion: "2",
+		Description: "open the ledger of the balances stored before it existed", Migrate: openBalanceLedgers})
+}
+
+// openBalanceLedgers journals the current value of the balances without ledger entries, returns the number of accounts changed.
+// The journaled balances are collected in one pass over the ledger instead of a lookup per balance.
+func openBalanceLedgers(_ RatingStorage, accountDB AccountingStorage, _ CdrStorage, dryRun bool) (int, error) {
+	journaled := make(map[string]bool)
+	ledgerIter := accountDB.Iterator(ColBlg, "", nil)
+	e := &LedgerEntry{}
+	for ledgerIter.Next(e) {
+		journaled[utils.ConcatKey(e.Tenant, e.Account, e.BalanceUUID)] = true
+		e = &LedgerEntry{}
+	}
+	if err := ledgerIter.Close(); err != nil {
+		return 0, err
+	}
+	iter := accountDB.Iterator(ColAcc, "", nil)
+	now := time.Now()
+	count := 0
+	acc := &Account{}
+	for iter.Next(acc) {
+		var entries []*LedgerEntry
+		for balanceType, balances := range acc.BalanceMap {
+			for _, b := range balances {
+				if b.GetValue().IsZero() || journaled[utils.ConcatKey(acc.Tenant, acc.Name, b.UUID)] {
+					continue
+				}
+				entries = append(entries, &LedgerEntry{Tenant: acc.Tenant, Account: acc.Name, BalanceUUID: b.UUID, BalanceID: b.ID,
+					BalanceType: balanceType, Delta: dec.New().Set(b.GetValue()), Value: dec.New().Set(b.GetValue()), Cause: LEDGER_OPENING, Time: now})
+			}
+		}
+		if len(entries) != 0 {
+			count++
+			if !dryRun {
+				if err := accountDB.AddLedgerEntries(entries); err != nil {
+					iter.Close()
+					return count, err
+				}
+			}
+		}
+		acc = &Account{}
+	}
+	return count, iter.Close()
+}
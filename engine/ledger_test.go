@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestBalanceLedger(t *testing.T) {
+	if err := accountingStorage.SetAccount(&Account{Tenant: "ledger", Name: "acc", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+		&Balance{UUID: "l1", ID: utils.META_DEFAULT, Value: dec.NewFloat(10)},
+		&Balance{UUID: "l2", ID: "bonus", Value: dec.NewFloat(5), ExpirationDate: time.Now().Add(-time.Hour)},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := accountingStorage.GetAccount("ledger", "acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.SetLedgerCause(LEDGER_DEBIT, "call1")
+	acc.BalanceMap[utils.MONETARY].GetBalance("l1").SetValue(dec.NewFloat(7))
+	acc.CleanExpiredStuff()
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: "ledger", Account: "acc"}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got: %s", utils.ToIJSON(entries))
+	}
+	if entries, err = accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: "ledger", Account: "acc", Cause: LEDGER_DEBIT}, 0, 0); err != nil ||
+		len(entries) != 1 || entries[0].BalanceUUID != "l1" || entries[0].CauseID != "call1" || entries[0].Delta.String() != "-3" || entries[0].Value.String() != "7" {
+		t.Errorf("bad debit entries: %s, %v", utils.ToIJSON(entries), err)
+	}
+	if entries, err = accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: "ledger", Account: "acc", Cause: LEDGER_EXPIRY}, 0, 0); err != nil ||
+		len(entries) != 1 || entries[0].BalanceUUID != "l2" || entries[0].Delta.String() != "-5" || !entries[0].Value.IsZero() {
+		t.Errorf("bad expiry entries: %s, %v", utils.ToIJSON(entries), err)
+	}
+	if entries, err = accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: "ledger", Account: "acc", TimeStart: time.Now().Add(time.Minute)}, 0, 0); err != nil || len(entries) != 0 {
+		t.Errorf("no entries expected in the future: %s, %v", utils.ToIJSON(entries), err)
+	}
+	for _, uuid := range []string{"l1", "l2"} {
+		if lc, err := CheckBalanceLedger("ledger", "acc", uuid); err != nil || !lc.Consistent || lc.Entries != 2 {
+			t.Errorf("%s: bad ledger check: %s, %v", uuid, utils.ToIJSON(lc), err)
+		}
+	}
+	// a change not journaled is reported
+	acc.BalanceMap[utils.MONETARY].GetBalance("l1").SetValue(dec.NewFloat(100))
+	acc.disableLedger()
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if lc, err := CheckBalanceLedger("ledger", "acc", "l1"); err != nil || lc.Consistent || lc.Computed.String() != "7" || lc.Current.String() != "100" {
+		t.Errorf("bad ledger check: %s, %v", utils.ToIJSON(lc), err)
+	}
+}
+
+func TestCheckLedgerBreaks(t *testing.T) {
+	entries := []*LedgerEntry{
+		&LedgerEntry{BalanceUUID: "b", Delta: dec.NewFloat(10), Value: dec.NewFloat(10)},
+		&LedgerEntry{BalanceUUID: "b", Delta: dec.NewFloat(-2), Value: dec.NewFloat(8)},
+		&LedgerEntry{BalanceUUID: "b", Delta: dec.NewFloat(-2), Value: dec.NewFloat(5)},
+	}
+	lc := checkLedger("b", entries, nil)
+	if lc.Consistent || len(lc.Breaks) != 1 || lc.Breaks[0] != entries[2] || !lc.Initial.IsZero() || lc.Computed.String() != "6" {
+		t.Errorf("bad ledger check: %s", utils.ToIJSON(lc))
+	}
+}
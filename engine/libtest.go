@@ -13,11 +13,11 @@ import (
 )
 
 func InitDataDb(cfg *config.Config) error {
-	ratingDb, err := ConfigureRatingStorage(*cfg.TariffPlanDb.Host, *cfg.TariffPlanDb.Port, *cfg.TariffPlanDb.Name, *cfg.TariffPlanDb.User, *cfg.TariffPlanDb.Password, cfg.Cache, *cfg.DataDb.LoadHistorySize)
+	ratingDb, err := ConfigureRatingStorage(*cfg.TariffPlanDb.Type, *cfg.TariffPlanDb.Host, *cfg.TariffPlanDb.Port, *cfg.TariffPlanDb.Name, *cfg.TariffPlanDb.User, *cfg.TariffPlanDb.Password, cfg.Cache, *cfg.DataDb.LoadHistorySize)
 	if err != nil {
 		return err
 	}
-	accountDb, err := ConfigureAccountingStorage(*cfg.DataDb.Host, *cfg.DataDb.Port, *cfg.DataDb.Name,
+	accountDb, err := ConfigureAccountingStorage(*cfg.DataDb.Type, *cfg.DataDb.Host, *cfg.DataDb.Port, *cfg.DataDb.Name,
 		*cfg.DataDb.User, *cfg.DataDb.Password, cfg.Cache, *cfg.DataDb.LoadHistorySize)
 	if err != nil {
 		return err
@@ -33,7 +33,7 @@ func InitDataDb(cfg *config.Config) error {
 }
 
 func InitCdrDb(cfg *config.Config) error {
-	cdrDb, err := ConfigureCdrStorage(*cfg.CdrDb.Host, *cfg.CdrDb.Port, *cfg.CdrDb.Name, *cfg.CdrDb.User, *cfg.CdrDb.Password,
+	cdrDb, err := ConfigureCdrStorage(*cfg.CdrDb.Type, *cfg.CdrDb.Host, *cfg.CdrDb.Port, *cfg.CdrDb.Name, *cfg.CdrDb.User, *cfg.CdrDb.Password,
 		*cfg.CdrDb.MaxOpenConns, *cfg.CdrDb.MaxIdleConns, cfg.CdrDb.CdrsIndexes)
 	if err != nil {
 		return err
@@ -1023,6 +1023,12 @@ func TestLoadRatingPlans(t *testing.T) {
 				CodeName: "URG",
 			},
 		},
+		Bindings: []*RatingPlanBinding{
+			&RatingPlanBinding{DestinationRatesID: "RT_STANDARD", TimingID: "WORKDAYS_00", Weight: 10},
+			&RatingPlanBinding{DestinationRatesID: "RT_STD_WEEKEND", TimingID: "WORKDAYS_18", Weight: 10},
+			&RatingPlanBinding{DestinationRatesID: "RT_STD_WEEKEND", TimingID: "WEEKENDS", Weight: 10},
+			&RatingPlanBinding{DestinationRatesID: "RT_URG", TimingID: "*any", Weight: 20},
+		},
 	}
 	if !reflect.DeepEqual(rplan, expected) {
 		t.Errorf("Received: %s", utils.ToIJSON(rplan))
@@ -1560,43 +1566,51 @@ func TestLoadResourceLimits(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error getting count: %v", err)
 	}
-	if count != 0 {
+	if count != 2 {
 		t.Error("failed to res limits: ", count)
 	}
 
-	rl, err := accountingStorage.GetResourceLimit("", false, utils.CACHED)
-	if err != nil {
-		t.Fatalf("error getting res limit: %v", err)
-	}
 	at, _ := utils.ParseTimeDetectLayout("2014-07-29T15:00:00Z", *config.Get().General.DefaultTimezone)
 	eResLimits := map[string]*ResourceLimit{
 		"ResGroup1": &ResourceLimit{
-			ID: "ResGroup1",
+			Tenant: "test",
+			ID:     "ResGroup1",
 			Filters: []*RequestFilter{
-				&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1001", "1002"}},
-				&RequestFilter{Type: MetaStringPrefix, FieldName: "Destination", Values: []string{"10", "20"}},
-				&RequestFilter{Type: MetaCDRStats, Values: []string{"CDRST1:*min_ASR:34", "CDRST_1001:*min_ASR:20"}},
-				&RequestFilter{Type: MetaRSRFields, Values: []string{"Subject(~^1.*1$)", "Destination(1002)"}},
+				&RequestFilter{Tenant: "test", Type: MetaString, FieldName: "Account", Values: []string{"1001", "1002"}},
+				&RequestFilter{Tenant: "test", Type: MetaStringPrefix, FieldName: "Destination", Values: []string{"10", "20"}},
+				&RequestFilter{Tenant: "test", Type: MetaCDRStats, Values: []string{"CDRST1:*min_ASR:34", "CDRST_1001:*min_ASR:20"}},
+				&RequestFilter{Tenant: "test", Type: MetaRSRFields, Values: []string{"Subject(~^1.*1$)", "Destination(1002)"}},
 			},
 			ActivationTime: at,
 			Weight:         10,
 			Limit:          2,
+			ActionTriggers: ActionTriggers{},
+			Usage:          map[string]*ResourceUsage{},
 		},
 		"ResGroup2": &ResourceLimit{
-			ID: "ResGroup2",
+			Tenant: "test",
+			ID:     "ResGroup2",
 			Filters: []*RequestFilter{
-				&RequestFilter{Type: MetaDestinations, FieldName: "Destination", Values: []string{"DST_FS"}},
+				&RequestFilter{Tenant: "test", Type: MetaDestinations, FieldName: "Destination", Values: []string{"DST_FS"}},
 			},
 			ActivationTime: at,
 			Weight:         10,
 			Limit:          2,
+			ActionTriggers: ActionTriggers{},
+			Usage:          map[string]*ResourceUsage{},
 		},
 	}
-	if count == len(eResLimits) {
-		t.Error("Failed to load resourcelimits: ", count)
-	}
-	if reflect.DeepEqual(eResLimits, rl) {
-		t.Errorf("Expecting: %+v, received: %+v", eResLimits, rl)
+	for id, eRl := range eResLimits {
+		rl, err := accountingStorage.GetResourceLimit(id, false, utils.CACHED)
+		if err != nil {
+			t.Fatalf("error getting res limit %s: %v", id, err)
+		}
+		if !rl.ActivationTime.Equal(eRl.ActivationTime) {
+			t.Errorf("Expecting activation time: %v, received: %v", eRl.ActivationTime, rl.ActivationTime)
+		}
+		rl.ActivationTime = eRl.ActivationTime
+		if !reflect.DeepEqual(eRl, rl) {
+			t.Errorf("Expecting: %s, received: %s", utils.ToIJSON(eRl), utils.ToIJSON(rl))
+		}
 	}
-
 }
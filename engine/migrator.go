@@ -0,0 +1,505 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/accurateproject/accurate/utils"
+	"github.com/globalsign/mgo"
+	"go.uber.org/zap"
+)
+
+const (
+	migratorBatchSize = 100
+	// progress keys besides the collections
+	migratorQueueBase  = ":base"           // suffix of the destination queue size before the copy started
+	migratorCdrOrderID = "cdrs:order_id"   // order id of the last cdr copied
+	migratorCdrSameID  = "cdrs:same_order" // cdrs copied with that order id
+)
+
+// Migration upgrades the documents behind one StructVersion field from a version to the next one.
+// Migrate must be idempotent: an interrupted step is run again from the start on the next run.
+type Migration struct {
+	Field       string // StructVersion field, e.g. Accounts
+	FromVersion string
+	ToVersion   string
+	Description string
+	Migrate     func(ratingDB RatingStorage, accountDB AccountingStorage, cdrDB CdrStorage, dryRun bool) (int, error)
+}
+
+var migrations []*Migration
+
+// RegisterMigration adds a step migration, steps are chained on FromVersion/ToVersion
+func RegisterMigration(m *Migration) {
+	migrations = append(migrations, m)
+}
+
+func getMigration(field, fromVersion string) *Migration {
+	for _, m := range migrations {
+		if m.Field == field && m.FromVersion == fromVersion {
+			return m
+		}
+	}
+	return nil
+}
+
+// Fields returns the StructVersion field names in declaration order
+func (sv *StructVersion) Fields() []string {
+	t := reflect.TypeOf(sv).Elem()
+	fields := make([]string, t.NumField())
+	for i := range fields {
+		fields[i] = t.Field(i).Name
+	}
+	return fields
+}
+
+func (sv *StructVersion) Get(field string) string {
+	return reflect.ValueOf(sv).Elem().FieldByName(field).String()
+}
+
+func (sv *StructVersion) Set(field, version string) {
+	reflect.ValueOf(sv).Elem().FieldByName(field).SetString(version)
+}
+
+// baseVersion is assumed for data written before the versions collection existed
+func baseVersion() *StructVersion {
+	sv := &StructVersion{}
+	for _, field := range sv.Fields() {
+		sv.Set(field, "1")
+	}
+	return sv
+}
+
+type MigrationStep struct {
+	Field       string
+	FromVersion string
+	ToVersion   string
+	Description string
+	Documents   int
+}
+
+type MigrationReport struct {
+	DryRun  bool
+	Steps   []*MigrationStep
+	Copied  map[string]int // documents per collection
+	Skipped map[string]string
+}
+
+func (mr *MigrationReport) String() (s string) {
+	prefix := ""
+	if mr.DryRun {
+		prefix = "[dry-run] "
+	}
+	for _, step := range mr.Steps {
+		s += fmt.Sprintf("%smigrate %s %s -> %s (%s): %d documents\n", prefix, step.Field, step.FromVersion, step.ToVersion, step.Description, step.Documents)
+	}
+	var cols []string
+	for col := range mr.Copied {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		s += fmt.Sprintf("%scopy %s: %d documents\n", prefix, col, mr.Copied[col])
+	}
+	skipped := utils.MapKeys(mr.Skipped)
+	sort.Strings(skipped)
+	for _, col := range skipped {
+		s += fmt.Sprintf("%sskip %s: %s\n", prefix, col, mr.Skipped[col])
+	}
+	if s == "" {
+		s = prefix + "nothing to do\n"
+	}
+	return
+}
+
+// Migrator upgrades the data structures to CurrentVersion and copies data between storages
+type Migrator struct {
+	ratingDB  RatingStorage
+	accountDB AccountingStorage
+	cdrDB     CdrStorage
+	dryRun    bool
+	// Progress holds the number of documents already copied per collection, used to resume an interrupted copy
+	Progress map[string]int
+	// OnProgress is called after every copied batch, e.g. to persist the progress
+	OnProgress func(map[string]int) error
+	Report     *MigrationReport
+}
+
+func NewMigrator(ratingDB RatingStorage, accountDB AccountingStorage, cdrDB CdrStorage, dryRun bool) *Migrator {
+	return &Migrator{
+		ratingDB:  ratingDB,
+		accountDB: accountDB,
+		cdrDB:     cdrDB,
+		dryRun:    dryRun,
+		Progress:  make(map[string]int),
+		Report:    &MigrationReport{DryRun: dryRun, Copied: make(map[string]int), Skipped: make(map[string]string)},
+	}
+}
+
+// getStructVersion returns nil without error when no version was written yet
+func getStructVersion(accountDB AccountingStorage) (*StructVersion, error) {
+	dbVersion, err := accountDB.GetStructVersion()
+	if dbVersion == nil && (err == nil || err == utils.ErrNotFound || err == mgo.ErrNotFound) {
+		return nil, nil
+	}
+	return dbVersion, err
+}
+
+// Migrate runs the registered step migrations until the database reaches CurrentVersion.
+// The version is written after every step so an interrupted run continues with the failed step.
+func (m *Migrator) Migrate() error {
+	dbVersion, err := getStructVersion(m.accountDB)
+	if err != nil {
+		return err
+	}
+	if dbVersion == nil {
+		dbVersion = baseVersion()
+	}
+	for _, field := range dbVersion.Fields() {
+		for dbVersion.Get(field) != CurrentVersion.Get(field) {
+			fromVersion := dbVersion.Get(field)
+			mig := getMigration(field, fromVersion)
+			if mig == nil {
+				return fmt.Errorf("no migration registered for %s from version <%s> to <%s>", field, fromVersion, CurrentVersion.Get(field))
+			}
+			count, err := mig.Migrate(m.ratingDB, m.accountDB, m.cdrDB, m.dryRun)
+			if err != nil {
+				return fmt.Errorf("migrating %s from version <%s>: %v", field, fromVersion, err)
+			}
+			m.Report.Steps = append(m.Report.Steps, &MigrationStep{
+				Field:       field,
+				FromVersion: fromVersion,
+				ToVersion:   mig.ToVersion,
+				Description: mig.Description,
+				Documents:   count,
+			})
+			dbVersion.Set(field, mig.ToVersion)
+			if m.dryRun {
+				continue
+			}
+			if err := m.accountDB.SetStructVersion(dbVersion); err != nil {
+				return err
+			}
+			utils.Logger.Info("<Migrator> migrated", zap.String("field", field), zap.String("from", fromVersion), zap.String("to", mig.ToVersion), zap.Int("documents", count))
+		}
+	}
+	if !m.dryRun && len(m.Report.Steps) == 0 {
+		if current, err := getStructVersion(m.accountDB); err != nil {
+			return err
+		} else if current == nil { // make the version explicit for CheckVersion
+			return m.accountDB.SetStructVersion(CurrentVersion)
+		}
+	}
+	return nil
+}
+
+type collectionCopier struct {
+	col  string
+	item func() interface{}
+	set  func(ratingDB RatingStorage, accountDB AccountingStorage, item interface{}) error
+	// queues are not keyed so copying them twice duplicates the documents
+	queue bool
+}
+
+type pubSubDoc struct {
+	Key   string
+	Value *SubscriberData
+}
+
+var ratingCopiers = []*collectionCopier{
+	{col: ColTmg, item: func() interface{} { return &Timing{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error { return rs.SetTiming(x.(*Timing)) }},
+	{col: ColDst, item: func() interface{} { return &Destination{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetDestination(x.(*Destination))
+		}},
+	{col: ColRts, item: func() interface{} { return &Rate{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error { return rs.SetRate(x.(*Rate)) }},
+	{col: ColDrt, item: func() interface{} { return &DestinationRate{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetDestinationRate(x.(*DestinationRate))
+		}},
+	{col: ColRpl, item: func() interface{} { return &RatingPlan{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetRatingPlan(x.(*RatingPlan))
+		}},
+	{col: ColRpf, item: func() interface{} { return &RatingProfile{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetRatingProfile(x.(*RatingProfile))
+		}},
+	{col: ColLcr, item: func() interface{} { return &LCR{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error { return rs.SetLCR(x.(*LCR)) }},
+	{col: ColAct, item: func() interface{} { return &ActionGroup{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetActionGroup(x.(*ActionGroup))
+		}},
+	{col: ColApl, item: func() interface{} { return &ActionPlan{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetActionPlan(x.(*ActionPlan))
+		}},
+	{col: ColApb, item: func() interface{} { return &ActionPlanBinding{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetActionPlanBinding(x.(*ActionPlanBinding))
+		}},
+	{col: ColAtr, item: func() interface{} { return &ActionTriggerGroup{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetActionTriggers(x.(*ActionTriggerGroup))
+		}},
+	{col: ColShg, item: func() interface{} { return &SharedGroup{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetSharedGroup(x.(*SharedGroup))
+		}},
+	{col: ColDcs, item: func() interface{} { return &utils.DerivedChargerGroup{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetDerivedChargers(x.(*utils.DerivedChargerGroup))
+		}},
+	{col: ColCrs, item: func() interface{} { return &CdrStats{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error { return rs.SetCdrStats(x.(*CdrStats)) }},
+	{col: ColTax, item: func() interface{} { return &TaxRule{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error { return rs.SetTaxRule(x.(*TaxRule)) }},
+	{col: ColXch, item: func() interface{} { return &ExchangeRate{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error {
+			return rs.SetExchangeRate(x.(*ExchangeRate))
+		}},
+	{col: ColTsk, queue: true, item: func() interface{} { return &Task{} },
+		set: func(rs RatingStorage, _ AccountingStorage, x interface{}) error { return rs.PushTask(x.(*Task)) }},
+}
+
+var accountingCopiers = []*collectionCopier{
+	{col: ColAcc, item: func() interface{} { return &Account{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			acc := x.(*Account)
+			acc.disableLedger() // the ledger is copied as well
+			return as.SetAccount(acc)
+		}},
+	{col: ColSac, item: func() interface{} { return &SimpleAccount{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.SetSimpleAccount(x.(*SimpleAccount))
+		}},
+	{col: ColAls, item: func() interface{} { return &Alias{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error { return as.SetAlias(x.(*Alias)) }},
+	{col: ColUsr, item: func() interface{} { return &UserProfile{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error { return as.SetUser(x.(*UserProfile)) }},
+	{col: ColStq, item: func() interface{} { return &StatsQueue{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.SetCdrStatsQueue(x.(*StatsQueue))
+		}},
+	{col: ColPbs, item: func() interface{} { return &pubSubDoc{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			doc := x.(*pubSubDoc)
+			return as.SetSubscriber(doc.Key, doc.Value)
+		}},
+	{col: ColInv, item: func() interface{} { return &Invoice{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			if err := as.AddInvoice(x.(*Invoice)); err != nil && err != utils.ErrExists {
+				return err
+			}
+			return nil
+		}},
+	{col: ColBlg, queue: true, item: func() interface{} { return &LedgerEntry{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.AddLedgerEntries([]*LedgerEntry{x.(*LedgerEntry)})
+		}},
+	{col: ColVbt, item: func() interface{} { return &VoucherBatch{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.SetVoucherBatch(x.(*VoucherBatch))
+		}},
+	{col: ColVch, item: func() interface{} { return &Voucher{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error { return as.SetVoucher(x.(*Voucher)) }},
+	{col: ColRL, item: func() interface{} { return &ResourceLimit{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.SetResourceLimit(x.(*ResourceLimit), "")
+		}},
+	{col: ColTps, item: func() interface{} { return &StagedTpFile{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.SetStagedTpFile(x.(*StagedTpFile))
+		}},
+	{col: ColQcr, queue: true, item: func() interface{} { return &QCDR{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error { return as.PushQCDR(x.(*QCDR)) }},
+	{col: ColLht, queue: true, item: func() interface{} { return &utils.LoadInstance{} },
+		set: func(_ RatingStorage, as AccountingStorage, x interface{}) error {
+			return as.AddLoadHistory(x.(*utils.LoadInstance))
+		}},
+}
+
+// CopyTo copies all tenants from the migrator storages into the destination ones, nil storages are skipped.
+// Keyed documents are upserted so the copy can be repeated, queues and cdrs rely on Progress not to be duplicated.
+func (m *Migrator) CopyTo(ratingDB RatingStorage, accountDB AccountingStorage, cdrDB CdrStorage) error {
+	if m.ratingDB != nil && ratingDB != nil {
+		for _, cc := range ratingCopiers {
+			if err := m.copyCollection(m.ratingDB, cc, ratingDB, nil); err != nil {
+				return err
+			}
+		}
+	}
+	if m.accountDB != nil && accountDB != nil {
+		for _, cc := range accountingCopiers {
+			if err := m.copyCollection(m.accountDB, cc, nil, accountDB); err != nil {
+				return err
+			}
+		}
+		dbVersion, err := getStructVersion(m.accountDB)
+		if err != nil {
+			return err
+		}
+		if dbVersion != nil && !m.dryRun {
+			if err := accountDB.SetStructVersion(dbVersion); err != nil {
+				return err
+			}
+		}
+	}
+	if m.cdrDB != nil && cdrDB != nil {
+		if err := m.copyCDRs(cdrDB); err != nil {
+			return err
+		}
+		if err := m.copySMCosts(cdrDB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) checkpoint(col string, count int) error {
+	m.Progress[col] = count
+	if m.OnProgress == nil || m.dryRun {
+		return nil
+	}
+	return m.OnProgress(m.Progress)
+}
+
+// queueCopied returns the queue items already copied. The progress is saved every batch so the destination
+// may hold up to a batch more, they are counted against the destination size saved before the copy started.
+func (m *Migrator) queueCopied(col string, dst Storage) (int, error) {
+	done := m.Progress[col]
+	dstCount, err := dst.Count(col)
+	if err != nil {
+		return 0, fmt.Errorf("counting %s: %v", col, err)
+	}
+	base, started := m.Progress[col+migratorQueueBase]
+	if !started {
+		if done == 0 {
+			m.Progress[col+migratorQueueBase] = dstCount
+			return 0, m.checkpoint(col, 0)
+		}
+		return done, nil // progress saved without the destination size
+	}
+	if copied := dstCount - base; copied > done {
+		return copied, nil
+	}
+	return done, nil
+}
+
+func (m *Migrator) copyCollection(src Storage, cc *collectionCopier, ratingDB RatingStorage, accountDB AccountingStorage) error {
+	done := m.Progress[cc.col]
+	if cc.queue && !m.dryRun {
+		var dst Storage = accountDB
+		if ratingDB != nil {
+			dst = ratingDB
+		}
+		var err error
+		if done, err = m.queueCopied(cc.col, dst); err != nil {
+			return err
+		}
+	}
+	iter := src.Iterator(cc.col, "", nil)
+	count, copied := 0, 0
+	for {
+		item := cc.item()
+		if !iter.Next(item) {
+			break
+		}
+		count++
+		if count <= done { // copied by a previous run
+			continue
+		}
+		if !m.dryRun {
+			if err := cc.set(ratingDB, accountDB, item); err != nil {
+				iter.Close()
+				return fmt.Errorf("copying %s: %v", cc.col, err)
+			}
+		}
+		copied++
+		if !cc.queue || count%migratorBatchSize != 0 {
+			continue
+		}
+		if err := m.checkpoint(cc.col, count); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("reading %s: %v", cc.col, err)
+	}
+	m.Report.Copied[cc.col] = copied
+	return m.checkpoint(cc.col, count)
+}
+
+// copyCDRs pages the cdrs by order id from the last one copied, the cdrs sharing that order id are skipped
+// by their number so the cdrs added meanwhile do not shift the pages
+func (m *Migrator) copyCDRs(cdrDB CdrStorage) error {
+	count := m.Progress[ColCdr]
+	lastOrderID, sameOrderID := int64(m.Progress[migratorCdrOrderID]), m.Progress[migratorCdrSameID]
+	copied := 0
+	for {
+		limit := migratorBatchSize + sameOrderID
+		cdrs, _, err := m.cdrDB.GetCDRs(&utils.CDRsFilter{OrderIDStart: utils.Int64Pointer(lastOrderID),
+			Paginator: utils.Paginator{Limit: utils.IntPointer(limit)}}, false)
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", ColCdr, err)
+		}
+		page := cdrs
+		for skip := sameOrderID; skip > 0 && len(page) != 0 && page[0].OrderID == lastOrderID; skip-- {
+			page = page[1:]
+		}
+		for _, cdr := range page {
+			orderID := cdr.OrderID // the sql storages set their own
+			if !m.dryRun {
+				if err := cdrDB.SetCDR(cdr, true); err != nil {
+					return fmt.Errorf("copying %s: %v", ColCdr, err)
+				}
+			}
+			if orderID == lastOrderID {
+				sameOrderID++
+			} else {
+				lastOrderID, sameOrderID = orderID, 1
+			}
+		}
+		count += len(page)
+		copied += len(page)
+		m.Progress[migratorCdrOrderID], m.Progress[migratorCdrSameID] = int(lastOrderID), sameOrderID
+		if err := m.checkpoint(ColCdr, count); err != nil {
+			return err
+		}
+		if len(cdrs) < limit {
+			break
+		}
+	}
+	m.Report.Copied[ColCdr] = copied
+	return nil
+}
+
+func (m *Migrator) copySMCosts(cdrDB CdrStorage) error {
+	iter := m.cdrDB.Iterator(ColSmc, "", nil)
+	if err := iter.Err(); err == utils.ErrNotImplemented {
+		m.Report.Skipped[ColSmc] = "listing not supported by the source storage"
+		return nil
+	}
+	copied := 0
+	smc := &SMCost{}
+	for iter.Next(smc) {
+		if !m.dryRun {
+			if err := cdrDB.SetSMCost(smc); err != nil && err != utils.ErrExists && !mgo.IsDup(err) {
+				iter.Close()
+				return fmt.Errorf("copying %s: %v", ColSmc, err)
+			}
+		}
+		copied++
+		smc = &SMCost{}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("reading %s: %v", ColSmc, err)
+	}
+	m.Report.Copied[ColSmc] = copied
+	return nil
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestMigratorStepMigrations(t *testing.T) {
+	accountDB, cleanup := newTestBoltStorage(t, utils.DataDB)
+	defer cleanup()
+	savedMigrations, savedVersion := migrations, *CurrentVersion
+	defer func() {
+		migrations = savedMigrations
+		*CurrentVersion = savedVersion
+	}()
+	migrations = nil
+	CurrentVersion.Accounts = "3"
+	runs := 0
+	for _, step := range [][2]string{{"1", "2"}, {"2", "3"}} {
+		RegisterMigration(&Migration{Field: "Accounts", FromVersion: step[0], ToVersion: step[1], Description: "test",
+			Migrate: func(_ RatingStorage, _ AccountingStorage, _ CdrStorage, dryRun bool) (int, error) {
+				if !dryRun {
+					runs++
+				}
+				return 1, nil
+			}})
+	}
+	if err := accountDB.SetStructVersion(baseVersion()); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMigrator(nil, accountDB, nil, true)
+	if err := m.Migrate(); err != nil || len(m.Report.Steps) != 2 || runs != 0 {
+		t.Errorf("bad dry run: %s, %d, %v", m.Report, runs, err)
+	}
+	if dbVersion, err := accountDB.GetStructVersion(); err != nil || dbVersion.Accounts != "1" {
+		t.Errorf("dry run changed the version: %+v, %v", dbVersion, err)
+	}
+
+	m = NewMigrator(nil, accountDB, nil, false)
+	if err := m.Migrate(); err != nil || len(m.Report.Steps) != 2 || runs != 2 {
+		t.Errorf("bad migration: %s, %d, %v", m.Report, runs, err)
+	}
+	if dbVersion, err := accountDB.GetStructVersion(); err != nil || dbVersion.Accounts != "3" || dbVersion.Users != "1" {
+		t.Errorf("bad version after migration: %+v, %v", dbVersion, err)
+	}
+	if err := CheckVersion(accountDB); err != nil {
+		t.Error("version check failed: ", err)
+	}
+
+	m = NewMigrator(nil, accountDB, nil, false)
+	if err := m.Migrate(); err != nil || len(m.Report.Steps) != 0 || runs != 2 {
+		t.Errorf("migration not idempotent: %s, %d, %v", m.Report, runs, err)
+	}
+
+	CurrentVersion.Accounts = "4"
+	if err := NewMigrator(nil, accountDB, nil, false).Migrate(); err == nil {
+		t.Error("expected missing migration error")
+	}
+}
+
+func TestMigratorOpenBalanceLedgers(t *testing.T) {
+	accountDB, cleanup := newTestBoltStorage(t, utils.DataDB)
+	defer cleanup()
+	acc := &Account{Tenant: "migrate", Name: "old", BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{&Balance{UUID: "money", Value: dec.NewFloat(10)}, &Balance{UUID: "empty"}},
+	}}
+	acc.disableLedger() // stored before the ledger existed
+	if err := accountDB.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if err := accountDB.SetStructVersion(baseVersion()); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(nil, accountDB, nil, false)
+	if err := m.Migrate(); err != nil || len(m.Report.Steps) != 1 || m.Report.Steps[0].Documents != 1 {
+		t.Fatalf("bad migration: %s, %v", m.Report, err)
+	}
+	entries, err := accountDB.GetLedgerEntries(&LedgerFilter{Tenant: "migrate", Account: "old"}, 0, 0)
+	if err != nil || len(entries) != 1 || entries[0].BalanceUUID != "money" || entries[0].Cause != LEDGER_OPENING ||
+		!checkLedger("money", entries, acc).Consistent {
+		t.Errorf("bad opening entries: %s, %v", utils.ToIJSON(entries), err)
+	}
+	if err := accountDB.SetStructVersion(baseVersion()); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewMigrator(nil, accountDB, nil, false).Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = accountDB.GetLedgerEntries(&LedgerFilter{Tenant: "migrate", Account: "old"}, 0, 0); err != nil || len(entries) != 1 {
+		t.Errorf("migration not idempotent: %s, %v", utils.ToIJSON(entries), err)
+	}
+}
+
+func TestMigratorCopy(t *testing.T) {
+	srcRating, cleanupSrcRating := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanupSrcRating()
+	srcAccount, cleanupSrcAccount := newTestBoltStorage(t, utils.DataDB)
+	defer cleanupSrcAccount()
+	srcCdr, cleanupSrcCdr := newTestBoltStorage(t, utils.CdrDB)
+	defer cleanupSrcCdr()
+	dstRating, cleanupDstRating := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanupDstRating()
+	dstAccount, cleanupDstAccount := newTestBoltStorage(t, utils.DataDB)
+	defer cleanupDstAccount()
+	dstCdr, cleanupDstCdr := newTestSQLStorage(t)
+	defer cleanupDstCdr()
+
+	for _, tenant := range []string{"t1", "t2"} {
+		if err := srcRating.SetDestination(&Destination{Tenant: tenant, Name: "NAT", Code: "0256"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := srcAccount.SetAccount(&Account{Tenant: tenant, Name: "acc"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := srcCdr.SetCDR(&CDR{UniqueID: tenant, RunID: utils.META_DEFAULT, Tenant: tenant}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := srcRating.PushTask(&Task{UUID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcAccount.SetResourceLimit(&ResourceLimit{ID: "RL1", Limit: 2}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcAccount.SetStagedTpFile(&StagedTpFile{Tenant: "t1", LoadID: "L1", Name: "Rates.json", Content: []byte("[]")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcAccount.SetStructVersion(CurrentVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMigrator(srcRating, srcAccount, srcCdr, true)
+	if err := m.CopyTo(dstRating, dstAccount, dstCdr); err != nil || m.Report.Copied[ColDst] != 2 || m.Report.Copied[ColCdr] != 2 {
+		t.Errorf("bad dry run: %s, %v", m.Report, err)
+	}
+	if count, err := dstRating.Count(ColDst); err != nil || count != 0 {
+		t.Error("dry run copied destinations: ", count, err)
+	}
+
+	var saved map[string]int
+	m = NewMigrator(srcRating, srcAccount, srcCdr, false)
+	m.OnProgress = func(progress map[string]int) error {
+		saved = progress
+		return nil
+	}
+	if err := m.CopyTo(dstRating, dstAccount, dstCdr); err != nil {
+		t.Fatal(err)
+	}
+	if dests, err := dstRating.GetDestinations("t2", "", "NAT", utils.DestExact, utils.CACHE_SKIP); err != nil || len(dests) != 1 {
+		t.Errorf("bad copied destinations: %+v, %v", dests, err)
+	}
+	if _, err := dstAccount.GetAccount("t1", "acc"); err != nil {
+		t.Error("account not copied: ", err)
+	}
+	if dbVersion, err := dstAccount.GetStructVersion(); err != nil || dbVersion.Accounts != CurrentVersion.Accounts {
+		t.Errorf("version not copied: %+v, %v", dbVersion, err)
+	}
+	if _, count, err := dstCdr.GetCDRs(&utils.CDRsFilter{Count: true}, false); err != nil || count != 2 {
+		t.Error("bad copied cdrs: ", count, err)
+	}
+	if count, err := dstAccount.Count(ColRL); err != nil || count != 1 {
+		t.Error("resource limits not copied: ", count, err)
+	}
+	if files, err := dstAccount.GetStagedTpFiles("t1", "L1"); err != nil || len(files) != 1 || string(files[0].Content) != "[]" {
+		t.Errorf("staged files not copied: %+v, %v", files, err)
+	}
+
+	// resuming with the saved progress must not duplicate the queues
+	m = NewMigrator(srcRating, srcAccount, srcCdr, false)
+	m.Progress = saved
+	if err := m.CopyTo(dstRating, dstAccount, dstCdr); err != nil || m.Report.Copied[ColTsk] != 0 {
+		t.Errorf("bad resumed copy: %s, %v", m.Report, err)
+	}
+	if count, err := dstRating.Count(ColTsk); err != nil || count != 1 {
+		t.Error("tasks duplicated: ", count, err)
+	}
+	if count, err := dstRating.Count(ColDst); err != nil || count != 2 {
+		t.Error("destinations duplicated: ", count, err)
+	}
+}
+
+func TestMigratorCopyResume(t *testing.T) {
+	srcRating, cleanupSrcRating := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanupSrcRating()
+	srcCdr, cleanupSrcCdr := newTestBoltStorage(t, utils.CdrDB)
+	defer cleanupSrcCdr()
+	dstRating, cleanupDstRating := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanupDstRating()
+	dstCdr, cleanupDstCdr := newTestBoltStorage(t, utils.CdrDB)
+	defer cleanupDstCdr()
+
+	for _, uuid := range []string{"1", "2", "3"} {
+		if err := srcRating.PushTask(&Task{UUID: uuid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, orderID := range []int64{5, 5, 7} {
+		if err := srcCdr.SetCDR(&CDR{UniqueID: fmt.Sprint(i), RunID: utils.META_DEFAULT, OrderID: orderID}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a copy stopped after writing a task and a cdr past its last saved progress
+	if err := dstRating.PushTask(&Task{UUID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dstCdr.SetCDR(&CDR{UniqueID: "0", RunID: utils.META_DEFAULT, OrderID: 5}, false); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(srcRating, nil, srcCdr, false)
+	m.Progress = map[string]int{ColTsk: 0, ColTsk + migratorQueueBase: 0, ColCdr: 1, migratorCdrOrderID: 5, migratorCdrSameID: 1}
+	if err := m.CopyTo(dstRating, nil, dstCdr); err != nil || m.Report.Copied[ColTsk] != 2 || m.Report.Copied[ColCdr] != 2 {
+		t.Errorf("bad resumed copy: %s, %v", m.Report, err)
+	}
+	if count, err := dstRating.Count(ColTsk); err != nil || count != 3 {
+		t.Error("bad copied tasks: ", count, err)
+	}
+	if cdrs, _, err := dstCdr.GetCDRs(&utils.CDRsFilter{}, false); err != nil || len(cdrs) != 3 || cdrs[2].OrderID != 7 {
+		t.Errorf("bad copied cdrs: %s, %v", utils.ToIJSON(cdrs), err)
+	}
+}
@@ -0,0 +1,374 @@
+package engine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+// RateDeckFormat describes a carrier rate deck csv, each column is given by its header name or by its 1-based index
+type RateDeckFormat struct {
+	Tenant              string
+	Tag                 string // the generated elements are named after it: RP_<Tag>, DR_<Tag>, RT_<Tag>_...
+	Separator           string // defaults to comma
+	HasHeader           bool   // the first line holds the column names, implied when a column is given by name
+	PrefixColumn        string
+	DescriptionColumn   string // names the destinations, the prefix is used when missing
+	RateColumn          string
+	ConnectFeeColumn    string
+	IncrementColumn     string // seconds, or first/next seconds like 30/6
+	EffectiveDateColumn string // the rows with no date are effective from the import
+	DateLayout          string // time layout of the dates, detected when empty
+	Timezone            string // of the dates without one, defaults to UTC
+	RateUnit            string // defaults to 60s
+	DefaultIncrement    string // for the rows with no increment, defaults to 1
+	Currency            string
+	// the rating profile activating the plans, none when Subject is empty
+	Direction string // defaults to *out
+	Category  string
+	Subject   string
+}
+
+type rateDeckRow struct {
+	prefix    string
+	destTag   string
+	rate      *utils.TpRate
+	effective time.Time
+}
+
+// rateDeckVersion is the plan in effect from its activation time
+type rateDeckVersion struct {
+	activation time.Time
+	suffix     string
+	rows       map[string]*rateDeckRow // by prefix
+}
+
+// ImportRateDeck turns the rate deck into a tariff plan folder loadable with LoadTariffPlanFromFolder.
+// The rows effective before the import make the RP_<Tag> rating plan, each later effective date gets a RP_<Tag>_<date> plan
+// holding the rates in effect from that date, the rating profile activates them at the right time.
+// Replies with the number of elements written in each file.
+func ImportRateDeck(deckPath, tpPath string, format *RateDeckFormat) (map[string]int, error) {
+	if missing := utils.MissingStructFields(format, []string{"Tenant", "Tag", "PrefixColumn", "RateColumn"}); len(missing) != 0 {
+		return nil, utils.NewErrMandatoryIeMissing(missing...)
+	}
+	deck, err := os.Open(deckPath)
+	if err != nil {
+		return nil, err
+	}
+	defer deck.Close()
+	rows, err := format.read(deck, path.Base(deckPath))
+	if err != nil {
+		return nil, err
+	}
+	files := format.tariffPlan(format.versions(rows, time.Now()), rows)
+	if err := os.MkdirAll(tpPath, 0755); err != nil {
+		return nil, err
+	}
+	written := make(map[string]int)
+	for _, fileName := range []string{utils.DESTINATIONS_JSON, utils.RATES_JSON, utils.DESTINATION_RATES_JSON, utils.RATING_PLANS_JSON, utils.RATING_PROFILES_JSON} {
+		elements := files[fileName]
+		if len(elements) == 0 {
+			continue
+		}
+		writer, err := os.Create(path.Join(tpPath, fileName))
+		if err != nil {
+			return nil, err
+		}
+		err = utils.WriteJSON(writer, elements)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+		written[fileName] = len(elements)
+	}
+	return written, nil
+}
+
+// columns finds the record position of the configured columns, -1 for the missing ones
+func (rdf *RateDeckFormat) columns(header []string) (map[string]int, error) {
+	index := make(map[string]int)
+	for name, column := range map[string]string{
+		"PrefixColumn":        rdf.PrefixColumn,
+		"DescriptionColumn":   rdf.DescriptionColumn,
+		"RateColumn":          rdf.RateColumn,
+		"ConnectFeeColumn":    rdf.ConnectFeeColumn,
+		"IncrementColumn":     rdf.IncrementColumn,
+		"EffectiveDateColumn": rdf.EffectiveDateColumn,
+	} {
+		index[name] = -1
+		if column == "" {
+			continue
+		}
+		if pos, err := strconv.Atoi(column); err == nil {
+			if pos < 1 {
+				return nil, fmt.Errorf("invalid %s %s", name, column)
+			}
+			index[name] = pos - 1
+			continue
+		}
+		for i, title := range header {
+			if strings.EqualFold(strings.TrimSpace(title), column) {
+				index[name] = i
+			}
+		}
+		if index[name] == -1 {
+			return nil, fmt.Errorf("missing %s %s", name, column)
+		}
+	}
+	return index, nil
+}
+
+func (rdf *RateDeckFormat) hasHeader() bool {
+	for _, column := range []string{rdf.PrefixColumn, rdf.DescriptionColumn, rdf.RateColumn, rdf.ConnectFeeColumn, rdf.IncrementColumn, rdf.EffectiveDateColumn} {
+		if _, err := strconv.Atoi(column); column != "" && err != nil {
+			return true
+		}
+	}
+	return rdf.HasHeader
+}
+
+func (rdf *RateDeckFormat) read(r io.Reader, file string) ([]*rateDeckRow, error) {
+	csvReader := csv.NewReader(r)
+	if rdf.Separator != "" {
+		csvReader.Comma = []rune(rdf.Separator)[0]
+	}
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	var header []string
+	line := 0
+	if rdf.hasHeader() {
+		line++
+		var err error
+		if header, err = csvReader.Read(); err != nil {
+			return nil, &utils.CSVError{File: file, Line: 1, Err: err}
+		}
+	}
+	index, err := rdf.columns(header)
+	if err != nil {
+		return nil, &utils.CSVError{File: file, Line: 1, Err: err}
+	}
+	timezone := rdf.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	rateUnit := rdf.RateUnit
+	if rateUnit == "" {
+		rateUnit = "60s"
+	}
+	if _, err := utils.ParseDurationWithSecs(rateUnit); err != nil {
+		return nil, fmt.Errorf("invalid RateUnit %s", rateUnit)
+	}
+	var rows []*rateDeckRow
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, &utils.CSVError{File: file, Line: line, Err: err}
+		}
+		value := func(column string) string {
+			if i := index[column]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		fieldErr := func(column string, err error) error {
+			return &utils.CSVError{File: file, Line: line, Column: index[column] + 1, Err: fmt.Errorf("%s: %v", column, err)}
+		}
+		row := &rateDeckRow{prefix: value("PrefixColumn")}
+		if row.prefix == "" {
+			return nil, fieldErr("PrefixColumn", utils.ErrMandatoryIeMissing)
+		}
+		row.destTag = destinationTag(value("DescriptionColumn"))
+		if row.destTag == "" {
+			row.destTag = row.prefix
+		}
+		slot := &utils.TpRateSlot{RateUnit: rateUnit}
+		if slot.Rate, err = strconv.ParseFloat(value("RateColumn"), 64); err != nil {
+			return nil, fieldErr("RateColumn", err)
+		}
+		if connectFee := value("ConnectFeeColumn"); connectFee != "" {
+			if slot.ConnectFee, err = strconv.ParseFloat(connectFee, 64); err != nil {
+				return nil, fieldErr("ConnectFeeColumn", err)
+			}
+		}
+		increment := value("IncrementColumn")
+		if increment == "" {
+			increment = rdf.DefaultIncrement
+		}
+		if row.rate, err = rateDeckRate(slot, increment); err != nil {
+			return nil, fieldErr("IncrementColumn", err)
+		}
+		if date := value("EffectiveDateColumn"); date != "" {
+			if rdf.DateLayout != "" {
+				row.effective, err = time.ParseInLocation(rdf.DateLayout, date, loc)
+			} else {
+				row.effective, err = utils.ParseTimeDetectLayout(date, timezone)
+			}
+			if err != nil {
+				return nil, fieldErr("EffectiveDateColumn", err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// rateDeckRate builds the rate slots out of the first/next increments
+func rateDeckRate(slot *utils.TpRateSlot, increment string) (*utils.TpRate, error) {
+	if increment == "" {
+		increment = "1"
+	}
+	increments := strings.Split(increment, "/")
+	if len(increments) > 2 {
+		return nil, fmt.Errorf("invalid increment %s", increment)
+	}
+	var durations []time.Duration
+	for _, inc := range increments {
+		d, err := utils.ParseDurationWithSecs(strings.TrimSpace(inc))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid increment %s", increment)
+		}
+		durations = append(durations, d)
+	}
+	slot.RateIncrement = durations[0].String()
+	slot.GroupIntervalStart = "0s"
+	rate := &utils.TpRate{Slots: []*utils.TpRateSlot{slot}}
+	if len(durations) == 2 && durations[1] != durations[0] {
+		next := *slot
+		next.ConnectFee = 0
+		next.RateIncrement = durations[1].String()
+		next.GroupIntervalStart = durations[0].String()
+		rate.Slots = append(rate.Slots, &next)
+	}
+	return rate, nil
+}
+
+// destinationTag makes a tag out of the deck description
+func destinationTag(description string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, description), "_")
+}
+
+// versions splits the rows by their effective date, the first version holds the rows effective before now
+// and starts at the newest of their dates
+func (rdf *RateDeckFormat) versions(rows []*rateDeckRow, now time.Time) []*rateDeckVersion {
+	current := &rateDeckVersion{rows: make(map[string]*rateDeckRow)}
+	futureDates := make(map[time.Time]bool)
+	for _, row := range rows {
+		if row.effective.After(now) {
+			futureDates[row.effective] = true
+		} else if row.effective.After(current.activation) {
+			current.activation = row.effective
+		}
+	}
+	if current.activation.IsZero() {
+		current.activation = now
+	}
+	versions := []*rateDeckVersion{current}
+	var dates []time.Time
+	for date := range futureDates {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	for _, date := range dates {
+		versions = append(versions, &rateDeckVersion{activation: date, suffix: "_" + date.UTC().Format("20060102T150405"), rows: make(map[string]*rateDeckRow)})
+	}
+	// each version has the newest row of every prefix effective by then
+	for _, version := range versions {
+		until := version.activation
+		if version == current {
+			until = now
+		}
+		for _, row := range rows {
+			if row.effective.After(until) {
+				continue
+			}
+			if previous, found := version.rows[row.prefix]; !found || !row.effective.Before(previous.effective) {
+				version.rows[row.prefix] = row
+			}
+		}
+	}
+	return versions
+}
+
+// tariffPlan returns the tariff plan elements by file
+func (rdf *RateDeckFormat) tariffPlan(versions []*rateDeckVersion, rows []*rateDeckRow) map[string][]interface{} {
+	files := make(map[string][]interface{})
+	destinations := make(map[string]bool)
+	rateTags := make(map[string]string) // by slots
+	for _, row := range rows {
+		if destKey := utils.ConcatKey(row.destTag, row.prefix); !destinations[destKey] {
+			destinations[destKey] = true
+			files[utils.DESTINATIONS_JSON] = append(files[utils.DESTINATIONS_JSON], &utils.TpDestination{Tenant: rdf.Tenant, Tag: row.destTag, Code: row.prefix})
+		}
+		slotsKey := utils.ToJSON(row.rate.Slots)
+		if _, found := rateTags[slotsKey]; !found {
+			tag := fmt.Sprintf("RT_%s_%g", rdf.Tag, row.rate.Slots[0].Rate)
+			for _, slot := range row.rate.Slots {
+				increment, _ := time.ParseDuration(slot.RateIncrement)
+				tag += fmt.Sprintf("_%g", increment.Seconds())
+			}
+			if slot := row.rate.Slots[0]; slot.ConnectFee != 0 {
+				tag += fmt.Sprintf("_%g", slot.ConnectFee)
+			}
+			rateTags[slotsKey] = tag
+			files[utils.RATES_JSON] = append(files[utils.RATES_JSON], &utils.TpRate{Tenant: rdf.Tenant, Tag: tag, Slots: row.rate.Slots})
+		}
+	}
+	rpf := &utils.TpRatingProfile{Tenant: rdf.Tenant, Direction: rdf.Direction, Category: rdf.Category, Subject: rdf.Subject}
+	if rpf.Direction == "" {
+		rpf.Direction = utils.OUT
+	}
+	for _, version := range versions {
+		if len(version.rows) == 0 {
+			continue
+		}
+		drTag, rpTag := "DR_"+rdf.Tag+version.suffix, "RP_"+rdf.Tag+version.suffix
+		dr := &utils.TpDestinationRate{Tenant: rdf.Tenant, Tag: drTag}
+		var prefixes []string
+		for prefix := range version.rows {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		for _, prefix := range prefixes {
+			dr.Bindings = append(dr.Bindings, &utils.TpDestinationRateBinding{
+				DestinationCode: prefix,
+				RatesTag:        rateTags[utils.ToJSON(version.rows[prefix].rate.Slots)],
+			})
+		}
+		files[utils.DESTINATION_RATES_JSON] = append(files[utils.DESTINATION_RATES_JSON], dr)
+		files[utils.RATING_PLANS_JSON] = append(files[utils.RATING_PLANS_JSON], &utils.TpRatingPlan{
+			Tenant:   rdf.Tenant,
+			Tag:      rpTag,
+			Currency: rdf.Currency,
+			Bindings: []*utils.TpRatingPlanBinding{&utils.TpRatingPlanBinding{DestinationRatesTag: drTag, TimingTag: utils.ANY, Weight: 10}},
+		})
+		rpf.Activations = append(rpf.Activations, &utils.TpRatingProfileActivation{ActivationTime: formatTpDate(version.activation), RatingPlanTag: rpTag})
+	}
+	if rdf.Subject != "" && len(rpf.Activations) != 0 {
+		files[utils.RATING_PROFILES_JSON] = []interface{}{rpf}
+	}
+	return files
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestImportRateDeck(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "rate_deck_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	in7Days := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	in14Days := time.Now().UTC().AddDate(0, 0, 14).Format("2006-01-02")
+	deckPath := path.Join(tmpDir, "carrier.csv")
+	if err := ioutil.WriteFile(deckPath, []byte(`Prefix;Destination;Price;Billing;Effective Date
+49;Germany;0.02;60/60;2017-01-01
+4915;Germany Mobile;0.1;30/6;2017-01-01
+4915;Germany Mobile;0.12;30/6;`+in7Days+`
+4916;Germany Mobile;0.1;30/6;`+in14Days+`
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	format := &RateDeckFormat{
+		Tenant:              "deck",
+		Tag:                 "CARR",
+		Separator:           ";",
+		PrefixColumn:        "prefix",
+		DescriptionColumn:   "destination",
+		RateColumn:          "price",
+		IncrementColumn:     "billing",
+		EffectiveDateColumn: "effective date",
+		DateLayout:          "2006-01-02",
+		Category:            "call",
+		Subject:             "carr",
+	}
+	tpPath := path.Join(tmpDir, "tp")
+	written, err := ImportRateDeck(deckPath, tpPath, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written[utils.DESTINATIONS_JSON] != 3 || written[utils.RATES_JSON] != 3 || written[utils.DESTINATION_RATES_JSON] != 3 ||
+		written[utils.RATING_PLANS_JSON] != 3 || written[utils.RATING_PROFILES_JSON] != 1 {
+		t.Errorf("unexpected elements: %v", written)
+	}
+	report, err := ValidateTariffPlanFolder(tpPath, "UTC", utils.CSV_SEP, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsValid() || len(report.Warnings) != 0 {
+		t.Errorf("invalid tariff plan: %s", utils.ToIJSON(report))
+	}
+
+	ratingDb, err := NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ratingDb.Close()
+	accountingDb, err := NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accountingDb.Close()
+	if _, err := LoadTariffPlanFromFolder(tpPath, "UTC", utils.CSV_SEP, ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+	suffix7, suffix14 := "_"+in7Days[:4]+in7Days[5:7]+in7Days[8:]+"T000000", "_"+in14Days[:4]+in14Days[5:7]+in14Days[8:]+"T000000"
+	rps, err := ratingDb.GetRatingProfiles(utils.OUT, "deck", "call", "carr", utils.CACHE_SKIP)
+	if err != nil || len(rps) != 1 || len(rps[0].RatingPlanActivations) != 3 {
+		t.Fatalf("unexpected rating profiles: %s, %v", utils.ToIJSON(rps), err)
+	}
+	for i, expected := range []struct {
+		activation, ratingPlan string
+	}{
+		{"2017-01-01", "RP_CARR"},
+		{in7Days, "RP_CARR" + suffix7},
+		{in14Days, "RP_CARR" + suffix14},
+	} {
+		rpa := rps[0].RatingPlanActivations[i]
+		if rpa.ActivationTime.Format("2006-01-02") != expected.activation || rpa.RatingPlanID != expected.ratingPlan {
+			t.Errorf("unexpected activation %d: %s", i, utils.ToJSON(rpa))
+		}
+	}
+	for destRate, expected := range map[string]map[string]string{
+		"DR_CARR":            {"49": "RT_CARR_0.02_60", "4915": "RT_CARR_0.1_30_6"},
+		"DR_CARR" + suffix7:  {"49": "RT_CARR_0.02_60", "4915": "RT_CARR_0.12_30_6"},
+		"DR_CARR" + suffix14: {"49": "RT_CARR_0.02_60", "4915": "RT_CARR_0.12_30_6", "4916": "RT_CARR_0.1_30_6"},
+	} {
+		dr, err := ratingDb.GetDestinationRate("deck", destRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rates := make(map[string]string)
+		for _, binding := range dr.Bindings {
+			rates[binding.DestinationCode] = binding.RateID
+		}
+		if utils.ToJSON(rates) != utils.ToJSON(expected) {
+			t.Errorf("unexpected rates in %s: %v", destRate, rates)
+		}
+	}
+	rate, err := ratingDb.GetRate("deck", "RT_CARR_0.1_30_6")
+	if err != nil || len(rate.Slots) != 2 || rate.Slots[1].GroupIntervalStart != 30*time.Second || rate.Slots[1].RateIncrement != 6*time.Second {
+		t.Errorf("unexpected rate: %s, %v", utils.ToIJSON(rate), err)
+	}
+
+	if _, err := ImportRateDeck(deckPath, tpPath, &RateDeckFormat{Tenant: "deck", Tag: "CARR", PrefixColumn: "1", RateColumn: "cost"}); err == nil {
+		t.Error("imported with a missing column")
+	}
+}
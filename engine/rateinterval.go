@@ -190,11 +190,12 @@ type RIRate struct {
 	ConnectFee      *dec.Dec   `bson:"connect_fee,omitempty"`
 	MaxCost         *dec.Dec   `bson:"max_cost,omitempty"`
 	MaxCostStrategy string     `bson:"max_cost_strategy,omitempty"`
-	Rates           RateGroups `bson:"rates"` // GroupRateInterval (start time): Rate
+	Rates           RateGroups `bson:"rates"`              // GroupRateInterval (start time): Rate
+	Rounding        *Rounding  `bson:"rounding,omitempty"` // overrides the tenant rounding of the calls
 }
 
 func (rir *RIRate) hash() string {
-	str := fmt.Sprintf("%v %v %s", rir.ConnectFee, rir.MaxCost, rir.MaxCostStrategy)
+	str := fmt.Sprintf("%v %v %s", rir.ConnectFee, rir.MaxCost, rir.MaxCostStrategy) + rir.Rounding.hash()
 	for _, r := range rir.Rates {
 		str += r.hash()
 	}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 
+	"github.com/accurateproject/accurate/dec"
 	"github.com/accurateproject/accurate/history"
 	"github.com/accurateproject/accurate/utils"
 )
@@ -19,6 +21,28 @@ type RatingPlan struct {
 	Ratings          map[string]*RIRate      `bson:"ratings"`
 	DRates           map[string]*DRate       `bson:"d_rates"`
 	DestinationRates map[string]*DRateHelper `bson:"destination_rates"`
+	Currency         string                  `bson:"currency"` // currency of the rates, empty for the default one
+	Bindings         []*RatingPlanBinding    `bson:"bindings"` // the tariff plan bindings the plan was built from
+	Derivation       *RatingPlanDerivation   `bson:"derivation,omitempty"`
+	Rounding         *Rounding               `bson:"rounding,omitempty"` // default of the destination rates rounding
+}
+
+// RatingPlanDerivation builds the plan out of the parent one, the plan bindings override the parent destination codes
+type RatingPlanDerivation struct {
+	ParentID         string  `bson:"parent_id"`
+	MarkupPercent    float64 `bson:"markup_percent"`
+	MarkupAbsolute   float64 `bson:"markup_absolute"`
+	MarkupConnectFee bool    `bson:"markup_connect_fee"`
+	MinimumRate      float64 `bson:"minimum_rate"`
+	RoundingDecimals int     `bson:"rounding_decimals"`
+	RoundingMethod   string  `bson:"rounding_method"`
+	Currency         string  `bson:"currency"` // declared by the derived plan, empty to inherit the parent one
+}
+
+type RatingPlanBinding struct {
+	DestinationRatesID string  `bson:"destination_rates_id"`
+	TimingID           string  `bson:"timing_id"`
+	Weight             float64 `bson:"weight"`
 }
 
 type DRate struct {
@@ -103,8 +127,34 @@ func (rp *RatingPlan) GetHistoryRecord() history.Record {
 
 // IsValid determines if the rating plan covers a continous period of time
 func (rp *RatingPlan) isContinous() bool {
-	weekdays := make([]int, 7)
+	timings := make([]*RITiming, 0, len(rp.Timings))
 	for _, tm := range rp.Timings {
+		timings = append(timings, tm)
+	}
+	return areTimingsContinous(timings)
+}
+
+// getUncoveredDestinations returns the sorted destination codes not rated at all times of the week
+func (rp *RatingPlan) getUncoveredDestinations() []string {
+	var codes []string
+	for code, drHelper := range rp.DestinationRates {
+		var timings []*RITiming
+		for drateKey := range drHelper.DRateKeys {
+			if drate, found := rp.DRates[drateKey]; found && rp.Timings[drate.Timing] != nil {
+				timings = append(timings, rp.Timings[drate.Timing])
+			}
+		}
+		if !areTimingsContinous(timings) {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func areTimingsContinous(timings []*RITiming) bool {
+	weekdays := make([]int, 7)
+	for _, tm := range timings {
 		// if it is a blank timing than it will match all
 		if tm.IsBlank() {
 			return true
@@ -165,3 +215,84 @@ func (rp *RatingPlan) getFirstUnsaneTiming() string {
 	}
 	return ""
 }
+
+// checkSanity returns the first problem preventing the rating plan from rating
+func (rp *RatingPlan) checkSanity() error {
+	if !rp.isContinous() {
+		return fmt.Errorf("The rating plan %s is not covering all weekdays", rp.Name)
+	}
+	if crazyRate := rp.getFirstUnsaneRating(); crazyRate != "" {
+		return fmt.Errorf("The rate %s is invalid", crazyRate)
+	}
+	if crazyTiming := rp.getFirstUnsaneTiming(); crazyTiming != "" {
+		return fmt.Errorf("The timing %s is invalid", crazyTiming)
+	}
+	return nil
+}
+
+// derive adds the marked up rate intervals of the parent for the destination codes the plan does not bind itself,
+// the rates are not converted so the plan must rate in the parent currency
+func (rp *RatingPlan) derive(parent *RatingPlan) error {
+	if rp.Derivation.Currency != "" && rp.Derivation.Currency != parent.Currency {
+		return fmt.Errorf("rating plan %s in %s can not derive from %s in %s", rp.Name, rp.Derivation.Currency, parent.Name, parent.Currency)
+	}
+	rp.Currency = parent.Currency
+	own := make(map[string]bool, len(rp.DestinationRates))
+	for code := range rp.DestinationRates {
+		own[code] = true
+	}
+	for code, drHelper := range parent.DestinationRates {
+		if own[code] {
+			continue
+		}
+		for _, ri := range parent.RateIntervalList(code) {
+			ri.Rating = rp.Derivation.apply(ri.Rating)
+			if rp.Rounding != nil { // the plan rounding wins over the parent one
+				ri.Rating.Rounding = rp.Rounding
+			}
+			rp.AddRateInterval(code, drHelper.CodeName, ri)
+		}
+	}
+	return nil
+}
+
+// apply returns a marked up copy of the rating, the parent one is shared with its plan
+func (d *RatingPlanDerivation) apply(rating *RIRate) *RIRate {
+	derived := &RIRate{
+		ConnectFee:      rating.ConnectFee,
+		MaxCost:         rating.MaxCost,
+		MaxCostStrategy: rating.MaxCostStrategy,
+		Rounding:        rating.Rounding,
+	}
+	if d.MarkupConnectFee && rating.ConnectFee != nil && !rating.ConnectFee.IsZero() {
+		derived.ConnectFee = d.round(d.markup(rating.ConnectFee))
+	}
+	minimum := dec.NewFloat(d.MinimumRate)
+	for _, rate := range rating.Rates {
+		value := d.markup(rate.Value)
+		if value.Cmp(minimum) < 0 {
+			value.Set(minimum)
+		}
+		derived.Rates = append(derived.Rates, &RateInfo{
+			GroupIntervalStart: rate.GroupIntervalStart,
+			Value:              d.round(value),
+			RateIncrement:      rate.RateIncrement,
+			RateUnit:           rate.RateUnit,
+		})
+	}
+	return derived
+}
+
+// markup returns a new value with the percentage and then the absolute markup added
+func (d *RatingPlanDerivation) markup(value *dec.Dec) *dec.Dec {
+	hundred := dec.NewVal(100, 0)
+	factor := dec.New().Add(hundred, dec.NewFloat(d.MarkupPercent)).QuoS(hundred)
+	return dec.New().Mul(value, factor).AddS(dec.NewFloat(d.MarkupAbsolute))
+}
+
+func (d *RatingPlanDerivation) round(value *dec.Dec) *dec.Dec {
+	if d.RoundingMethod == "" {
+		return value
+	}
+	return value.RoundTo(int32(d.RoundingDecimals), d.RoundingMethod)
+}
@@ -416,3 +416,14 @@ func BenchmarkRPRestore(b *testing.B) {
 		ratingStorage.GetRatingPlan(rp.Tenant, rp.Name, utils.CACHE_SKIP)
 	}
 }
+
+func TestRPDerivationDecimalMarkup(t *testing.T) {
+	d := &RatingPlanDerivation{MarkupPercent: 15, MarkupConnectFee: true, MinimumRate: 0.05, RoundingDecimals: 2, RoundingMethod: dec.RoundMiddle}
+	derived := d.apply(&RIRate{ConnectFee: dec.NewVal(1, 1), Rates: RateGroups{
+		&RateInfo{Value: dec.NewVal(1, 1)}, // 0.115 is not exact in float64, rounds down there
+		&RateInfo{Value: dec.NewVal(1, 2)},
+	}})
+	if derived.ConnectFee.String() != "0.12" || derived.Rates[0].Value.String() != "0.12" || derived.Rates[1].Value.String() != "0.05" {
+		t.Errorf("bad derived rating: %s", utils.ToIJSON(derived))
+	}
+}
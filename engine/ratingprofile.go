@@ -65,6 +65,7 @@ type RatingInfo struct {
 	ActivationTime time.Time
 	RateIntervals  RateIntervalList
 	FallbackKeys   []string
+	Currency       string
 }
 
 // SelectRatingIntevalsForTimespan orders rate intervals in time preserving only those which aply to the specified timestamp
@@ -124,7 +125,7 @@ func (rpf *RatingProfile) GetRatingPlansForPrefix(cd *CallDescriptor) (err error
 	//log.Print("RPF: ", utils.ToIJSON(rpf))
 	var ris RatingInfos
 	for index, rpa := range rpf.RatingPlanActivations.GetActiveForCall(cd) {
-		rpl, err := ratingStorage.GetRatingPlan(rpf.Tenant, rpa.RatingPlanID, utils.CACHED)
+		rpl, err := cd.getRatingStorage().GetRatingPlan(rpf.Tenant, rpa.RatingPlanID, utils.CACHED)
 		//log.Print("RPL: ", utils.ToIJSON(rpl))
 		if err != nil || rpl == nil {
 			utils.Logger.Error("error checking destination", zap.Error(err))
@@ -174,6 +175,7 @@ func (rpf *RatingProfile) GetRatingPlansForPrefix(cd *CallDescriptor) (err error
 			ris = append(ris, &RatingInfo{
 				MatchedSubject: rpf.FullID(),
 				RatingPlanID:   rpl.Name,
+				Currency:       rpl.Currency,
 				MatchedPrefix:  destinationCode,
 				MatchedDestID:  destinationName,
 				ActivationTime: rpa.ActivationTime,
@@ -0,0 +1,376 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+	"go.uber.org/zap"
+)
+
+// Reservation holds part of the account balances for a prepaid session or a pending payment.
+// The held value cannot be used by other debits until it is captured, released or expired.
+type Reservation struct {
+	ID          string              `bson:"id"`
+	BalanceType string              `bson:"balance_type"`
+	Amounts     map[string]*dec.Dec `bson:"amounts"` // held value per balance uuid
+	ExpiresAt   time.Time           `bson:"expires_at"`
+}
+
+func (r *Reservation) IsExpired() bool {
+	return !r.ExpiresAt.IsZero() && r.ExpiresAt.Before(time.Now())
+}
+
+// GetValue returns the total held value
+func (r *Reservation) GetValue() *dec.Dec {
+	total := dec.New()
+	for _, amount := range r.Amounts {
+		total.AddS(amount)
+	}
+	return total
+}
+
+func (r *Reservation) Clone() *Reservation {
+	newR := &Reservation{
+		ID:          r.ID,
+		BalanceType: r.BalanceType,
+		Amounts:     make(map[string]*dec.Dec, len(r.Amounts)),
+		ExpiresAt:   r.ExpiresAt,
+	}
+	for uuid, amount := range r.Amounts {
+		newR.Amounts[uuid] = dec.New().Set(amount)
+	}
+	return newR
+}
+
+// purgeReservations drops the expired holds, returns true if any was removed
+func (acc *Account) purgeReservations() bool {
+	purged := false
+	for id, r := range acc.Reservations {
+		if r.IsExpired() {
+			delete(acc.Reservations, id)
+			purged = true
+		}
+	}
+	return purged
+}
+
+// getHeldValues sums the live holds per balance uuid, ignoring the one with excludeID
+func (acc *Account) getHeldValues(excludeID string) map[string]*dec.Dec {
+	held := make(map[string]*dec.Dec)
+	for id, r := range acc.Reservations {
+		if id == excludeID || r.IsExpired() {
+			continue
+		}
+		for uuid, amount := range r.Amounts {
+			if _, found := held[uuid]; !found {
+				held[uuid] = dec.New()
+			}
+			held[uuid].AddS(amount)
+		}
+	}
+	return held
+}
+
+// substractReservations takes the held values out of the balances so they are not seen as available.
+// Returns the held values, a debit on the real account puts them back with restoreReservations before saving.
+func (acc *Account) substractReservations(excludeID string) map[string]*dec.Dec {
+	held := acc.getHeldValues(excludeID)
+	for _, balances := range acc.BalanceMap {
+		for _, b := range balances {
+			if amount, found := held[b.UUID]; found {
+				b.SubstractValue(amount)
+			}
+		}
+	}
+	return held
+}
+
+// restoreReservations adds back the values taken out by substractReservations
+func (acc *Account) restoreReservations(held map[string]*dec.Dec) {
+	for _, balances := range acc.BalanceMap {
+		for _, b := range balances {
+			if amount, found := held[b.UUID]; found {
+				b.AddValue(amount)
+			}
+		}
+	}
+}
+
+// Reserve holds value from the balances of the given type matching the filter, in weight order.
+// A zero ttl keeps the hold until captured or released.
+func (acc *Account) Reserve(id, balanceType, filter string, value *dec.Dec, ttl time.Duration) (*Reservation, error) {
+	if value == nil || !value.GtZero() {
+		return nil, errors.New("reservation value must be positive")
+	}
+	acc.purgeReservations()
+	if _, found := acc.Reservations[id]; found {
+		return nil, utils.ErrExists
+	}
+	fltr, err := utils.NewStructQ(filter)
+	if err != nil {
+		return nil, err
+	}
+	var balances Balances
+	for _, b := range acc.BalanceMap[balanceType] {
+		if b.IsExpired() || b.Disabled {
+			continue
+		}
+		match, err := fltr.Query(b, false)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			balances = append(balances, b)
+		}
+	}
+	if len(balances) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	balances.Sort()
+	held := acc.getHeldValues("")
+	r := &Reservation{ID: id, BalanceType: balanceType, Amounts: make(map[string]*dec.Dec)}
+	if ttl > 0 {
+		r.ExpiresAt = time.Now().Add(ttl)
+	}
+	remaining := dec.New().Set(value)
+	for _, b := range balances {
+		amount := dec.New().Set(remaining)
+		if !b.Unlimited {
+			available := dec.New().Set(b.GetValue())
+			if h, found := held[b.UUID]; found {
+				available.SubS(h)
+			}
+			if !available.GtZero() {
+				continue
+			}
+			if available.Cmp(amount) < 0 {
+				amount.Set(available)
+			}
+		}
+		r.Amounts[b.UUID] = amount
+		remaining.SubS(amount)
+		if remaining.IsZero() {
+			break
+		}
+	}
+	if !remaining.IsZero() {
+		if balanceType == utils.MONETARY && acc.hasCreditLimit(balances[0]) {
+			// what the main balance can still hold within the credit limit
+			room := acc.creditLeft(balances[0])
+			if h, found := held[balances[0].UUID]; found {
+				room.SubS(h)
+			}
+			if amount, found := r.Amounts[balances[0].UUID]; found {
+				room.SubS(amount)
+			}
+			if room.Cmp(remaining) < 0 {
+				return nil, utils.ErrInsufficientCredit
+			}
+		} else if !acc.AllowNegative {
+			return nil, utils.ErrInsufficientCredit
+		}
+		// the rest is held on the main balance and can go negative
+		if amount, found := r.Amounts[balances[0].UUID]; found {
+			amount.AddS(remaining)
+		} else {
+			r.Amounts[balances[0].UUID] = remaining
+		}
+	}
+	if acc.Reservations == nil {
+		acc.Reservations = make(map[string]*Reservation)
+	}
+	acc.Reservations[id] = r
+	return r, nil
+}
+
+// Capture debits value from the held balances and releases the rest of the hold.
+// Returns the debited amount per balance uuid.
+func (acc *Account) Capture(id string, value *dec.Dec) (map[string]*dec.Dec, error) {
+	if value == nil || value.LtZero() {
+		return nil, errors.New("capture value must not be negative")
+	}
+	acc.purgeReservations()
+	r, found := acc.Reservations[id]
+	if !found {
+		return nil, utils.ErrNotFound
+	}
+	if r.GetValue().Cmp(value) < 0 {
+		return nil, utils.ErrInsufficientCredit
+	}
+	balances := acc.BalanceMap[r.BalanceType]
+	balances.Sort()
+	debited := make(map[string]*dec.Dec)
+	remaining := dec.New().Set(value)
+	for _, b := range balances {
+		if remaining.IsZero() {
+			break
+		}
+		amount, found := r.Amounts[b.UUID]
+		if !found {
+			continue
+		}
+		if amount.Cmp(remaining) > 0 {
+			amount = dec.New().Set(remaining)
+		}
+		b.SubstractValue(amount)
+		debited[b.UUID] = amount
+		remaining.SubS(amount)
+	}
+	if !remaining.IsZero() { // held balances were removed in the meantime
+		return nil, utils.ErrNotFound
+	}
+	delete(acc.Reservations, id)
+	return debited, nil
+}
+
+// Release drops the hold without debiting anything
+func (acc *Account) Release(id string) error {
+	acc.purgeReservations()
+	if _, found := acc.Reservations[id]; !found {
+		return utils.ErrNotFound
+	}
+	delete(acc.Reservations, id)
+	return nil
+}
+
+// consumeReservation lowers the hold with the value debited from each held balance since the before snapshot
+func (acc *Account) consumeReservation(id string, before map[string]*dec.Dec) {
+	r, found := acc.Reservations[id]
+	if !found {
+		return
+	}
+	for _, b := range acc.BalanceMap[r.BalanceType] {
+		amount, held := r.Amounts[b.UUID]
+		initial, ok := before[b.UUID]
+		if !held || !ok {
+			continue
+		}
+		used := dec.New().Sub(initial, b.GetValue())
+		if !used.GtZero() {
+			continue
+		}
+		if used.Cmp(amount) >= 0 {
+			delete(r.Amounts, b.UUID)
+			continue
+		}
+		amount.SubS(used)
+	}
+	if len(r.Amounts) == 0 {
+		delete(acc.Reservations, id)
+	}
+}
+
+// getBalanceValues snapshots the balance values of the given type by uuid
+func (acc *Account) getBalanceValues(balanceType string) map[string]*dec.Dec {
+	values := make(map[string]*dec.Dec)
+	for _, b := range acc.BalanceMap[balanceType] {
+		values[b.UUID] = dec.New().Set(b.GetValue())
+	}
+	return values
+}
+
+// guardReservation loads the account under its tenant:account lock, the one of the rating, the transfers and the api
+// account writers, and saves it back
+func guardReservation(tenant, account string, f func(*Account) error) error {
+	_, err := Guardian.Guard(func() (interface{}, error) {
+		acc, err := accountingStorage.GetAccount(tenant, account)
+		if err == utils.ErrNotFound {
+			return 0, utils.ErrAccountNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		if acc.Disabled {
+			return 0, utils.ErrAccountDisabled
+		}
+		if err := f(acc); err != nil {
+			return 0, err
+		}
+		return 0, accountingStorage.SetAccount(acc)
+	}, 0, utils.ConcatKey(tenant, account))
+	return err
+}
+
+// ReserveBalance creates a hold on the account, see Account.Reserve
+func ReserveBalance(tenant, account, id, balanceType, filter string, value *dec.Dec, ttl time.Duration) (r *Reservation, err error) {
+	err = guardReservation(tenant, account, func(acc *Account) error {
+		// a hold authorizes spending, the capture and the release always go through
+		if err := acc.authorizeCall(utils.OUT, ""); err != nil {
+			return err
+		}
+		r, err = acc.Reserve(id, balanceType, filter, value, ttl)
+		return err
+	})
+	return
+}
+
+// ReservationTTLMargin is how long a session hold outlives the usage it was made for
+var ReservationTTLMargin = time.Minute
+
+// ReserveSession holds the money the session can spend in the usage of the call descriptor, with its ReservationID,
+// so the parallel sessions on the account do not see it. A previous hold with the same id is replaced.
+// Only the monetary balances are held, the accounts without a credit limit are not.
+func ReserveSession(cd *CallDescriptor) (r *Reservation, err error) {
+	err = guardReservation(cd.Tenant, cd.getAccountName(), func(acc *Account) error {
+		if err := acc.authorizeCall(cd.Direction, cd.Destination); err != nil {
+			return err
+		}
+		delete(acc.Reservations, cd.ReservationID)
+		duration, err := cd.Clone().getMaxSessionDuration(acc)
+		if err != nil || duration <= 0 {
+			return err
+		}
+		// what the session would spend from each balance in the granted duration
+		dryCD := cd.Clone()
+		dryCD.TimeEnd = dryCD.TimeStart.Add(duration)
+		dryCD.DurationIndex = duration
+		dryAcc := acc.Clone()
+		dryAcc.substractReservations(cd.ReservationID)
+		before := dryAcc.getBalanceValues(utils.MONETARY)
+		if _, err := dryCD.debit(dryAcc, true, false); err != nil {
+			return err
+		}
+		amounts := make(map[string]*dec.Dec)
+		for _, b := range dryAcc.BalanceMap[utils.MONETARY] {
+			if initial, found := before[b.UUID]; found {
+				if used := dec.New().Sub(initial, b.GetValue()); used.GtZero() {
+					amounts[b.UUID] = used
+				}
+			}
+		}
+		if len(amounts) == 0 {
+			return nil
+		}
+		r = &Reservation{ID: cd.ReservationID, BalanceType: utils.MONETARY, Amounts: amounts, ExpiresAt: time.Now().Add(duration + ReservationTTLMargin)}
+		if acc.Reservations == nil {
+			acc.Reservations = make(map[string]*Reservation)
+		}
+		acc.Reservations[r.ID] = r
+		return nil
+	})
+	return
+}
+
+// CaptureReservation debits the account from the hold and releases the rest
+func CaptureReservation(tenant, account, id string, value *dec.Dec) error {
+	return guardReservation(tenant, account, func(acc *Account) error {
+		debited, err := acc.Capture(id, value)
+		if err != nil {
+			return err
+		}
+		acc.InitCounters()
+		acc.ExecuteActionTriggers(nil, false)
+		utils.Logger.Info("<Reservation> captured", zap.String("tenant", tenant), zap.String("account", account),
+			zap.String("id", id), zap.String("value", value.String()), zap.Int("balances", len(debited)))
+		return nil
+	})
+}
+
+// ReleaseReservation drops the hold from the account
+func ReleaseReservation(tenant, account, id string) error {
+	return guardReservation(tenant, account, func(acc *Account) error {
+		return acc.Release(id)
+	})
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func setReservationAccount(t *testing.T) {
+	if err := accountingStorage.SetAccount(&Account{Tenant: "test", Name: "reservation", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+		&Balance{UUID: "r1", ID: "bonus", Value: dec.NewFloat(3), Weight: 20},
+		&Balance{UUID: "r2", ID: utils.META_DEFAULT, Value: dec.NewFloat(10), Weight: 10},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getReservationAccount(t *testing.T) *Account {
+	acc, err := accountingStorage.GetAccount("test", "reservation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return acc
+}
+
+func reservationCD(reservationID string) *CallDescriptor {
+	return &CallDescriptor{
+		Direction:     "*out",
+		Category:      "call",
+		Tenant:        "test",
+		Subject:       "12345",
+		Account:       "reservation",
+		Destination:   "447956",
+		TimeStart:     time.Date(2014, 3, 4, 6, 0, 0, 0, time.UTC),
+		TimeEnd:       time.Date(2014, 3, 4, 7, 0, 0, 0, time.UTC),
+		ReservationID: reservationID,
+	}
+}
+
+func TestReservationCapture(t *testing.T) {
+	setReservationAccount(t)
+	r, err := ReserveBalance("test", "reservation", "s1", utils.MONETARY, "", dec.NewFloat(5), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the heavier balance is held first
+	if r.Amounts["r1"].Cmp(dec.NewFloat(3)) != 0 || r.Amounts["r2"].Cmp(dec.NewFloat(2)) != 0 {
+		t.Errorf("bad held amounts: %s", utils.ToIJSON(r))
+	}
+	if _, err := ReserveBalance("test", "reservation", "s1", utils.MONETARY, "", dec.NewFloat(1), 0); err != utils.ErrExists {
+		t.Error("expected duplicate reservation error, got: ", err)
+	}
+	if _, err := ReserveBalance("test", "reservation", "s2", utils.MONETARY, "", dec.NewFloat(9), 0); err != utils.ErrInsufficientCredit {
+		t.Error("expected insufficient credit for the second hold, got: ", err)
+	}
+	if err := CaptureReservation("test", "reservation", "s1", dec.NewFloat(4)); err != nil {
+		t.Fatal(err)
+	}
+	acc := getReservationAccount(t)
+	if len(acc.Reservations) != 0 {
+		t.Error("reservation not released after capture: ", utils.ToIJSON(acc.Reservations))
+	}
+	if v := acc.BalanceMap[utils.MONETARY].GetBalance("r1").GetValue(); !v.IsZero() {
+		t.Error("bad bonus balance: ", v)
+	}
+	if v := acc.BalanceMap[utils.MONETARY].GetBalance("r2").GetValue(); v.Cmp(dec.NewFloat(9)) != 0 {
+		t.Error("bad default balance: ", v)
+	}
+	if err := CaptureReservation("test", "reservation", "s1", dec.NewFloat(1)); err != utils.ErrNotFound {
+		t.Error("expected captured reservation to be gone, got: ", err)
+	}
+}
+
+func TestReservationReleaseAndExpiry(t *testing.T) {
+	setReservationAccount(t)
+	if _, err := ReserveBalance("test", "reservation", "s1", utils.MONETARY, `{"ID":"*default"}`, dec.NewFloat(10), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveBalance("test", "reservation", "s2", utils.MONETARY, `{"ID":"*default"}`, dec.NewFloat(1), 0); err != utils.ErrInsufficientCredit {
+		t.Error("expected the held balance to be unavailable, got: ", err)
+	}
+	if err := ReleaseReservation("test", "reservation", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveBalance("test", "reservation", "s2", utils.MONETARY, `{"ID":"*default"}`, dec.NewFloat(10), time.Millisecond); err != nil {
+		t.Fatal("released value not available: ", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := ReserveBalance("test", "reservation", "s3", utils.MONETARY, `{"ID":"*default"}`, dec.NewFloat(10), 0); err != nil {
+		t.Fatal("expired hold not released: ", err)
+	}
+	if acc := getReservationAccount(t); len(acc.Reservations) != 1 || acc.Reservations["s3"] == nil {
+		t.Error("bad reservations: ", utils.ToIJSON(acc.Reservations))
+	}
+	if err := ReleaseReservation("test", "reservation", "s2"); err != utils.ErrNotFound {
+		t.Error("expected expired reservation to be gone, got: ", err)
+	}
+}
+
+func TestReservationMaxSessionTime(t *testing.T) {
+	setReservationAccount(t)
+	full, err := reservationCD("").GetMaxSessionDuration()
+	if err != nil || full == 0 {
+		t.Fatal("bad max session time: ", full, err)
+	}
+	if _, err := ReserveBalance("test", "reservation", "s1", utils.MONETARY, "", dec.NewFloat(12), 0); err != nil {
+		t.Fatal(err)
+	}
+	// the hold is not available to other sessions
+	if other, err := reservationCD("s2").GetMaxSessionDuration(); err != nil || other >= full {
+		t.Errorf("hold ignored by other session: %v >= %v (%v)", other, full, err)
+	}
+	// but can be used by the session owning it
+	if own, err := reservationCD("s1").GetMaxSessionDuration(); err != nil || own != full {
+		t.Errorf("hold not usable by its session: %v != %v (%v)", own, full, err)
+	}
+}
+
+func TestReservationMaxDebitConsumesHold(t *testing.T) {
+	setReservationAccount(t)
+	if _, err := ReserveBalance("test", "reservation", "s1", utils.MONETARY, "", dec.NewFloat(13), 0); err != nil {
+		t.Fatal(err)
+	}
+	cd := reservationCD("s1")
+	cd.TimeEnd = cd.TimeStart.Add(time.Minute)
+	cc, err := cd.MaxDebit()
+	if err != nil || !cc.GetCost().GtZero() {
+		t.Fatal("bad max debit: ", cc, err)
+	}
+	acc := getReservationAccount(t)
+	r := acc.Reservations["s1"]
+	if r == nil {
+		t.Fatal("reservation removed before being used")
+	}
+	if held := dec.New().Sub(dec.NewFloat(13), r.GetValue()); held.Cmp(cc.GetCost()) != 0 {
+		t.Errorf("hold not lowered with the debited cost: %v != %v", held, cc.GetCost())
+	}
+}
+
+func TestReservationDebitKeepsHolds(t *testing.T) {
+	setReservationAccount(t)
+	if _, err := ReserveBalance("test", "reservation", "s1", utils.MONETARY, `{"ID":"bonus"}`, dec.NewFloat(3), 0); err != nil {
+		t.Fatal(err)
+	}
+	cd := reservationCD("s2")
+	cd.TimeEnd = cd.TimeStart.Add(time.Minute)
+	cc, err := cd.Debit()
+	if err != nil || !cc.GetCost().GtZero() {
+		t.Fatal("bad debit: ", cc, err)
+	}
+	acc := getReservationAccount(t)
+	if v := acc.BalanceMap[utils.MONETARY].GetBalance("r1").GetValue(); v.Cmp(dec.NewFloat(3)) != 0 {
+		t.Error("the held balance was spent: ", v)
+	}
+	if v := acc.BalanceMap[utils.MONETARY].GetBalance("r2").GetValue(); v.Cmp(dec.New().Sub(dec.NewFloat(10), cc.GetCost())) != 0 {
+		t.Error("bad default balance: ", v)
+	}
+	if err := CaptureReservation("test", "reservation", "s1", dec.NewFloat(3)); err != nil {
+		t.Error("the hold can not be captured after the debit: ", err)
+	}
+}
+
+func TestReservationSession(t *testing.T) {
+	setReservationAccount(t)
+	full, err := reservationCD("").GetMaxSessionDuration()
+	if err != nil || full == 0 {
+		t.Fatal("bad max session time: ", full, err)
+	}
+	cd := reservationCD("s1")
+	cd.TimeEnd = cd.TimeStart.Add(time.Minute)
+	r, err := ReserveSession(cd)
+	if err != nil || r == nil {
+		t.Fatal("no session hold: ", r, err)
+	}
+	cost, err := cd.Clone().GetCost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetValue().Cmp(cost.GetCost()) != 0 || r.ExpiresAt.IsZero() {
+		t.Errorf("bad session hold: %s, cost: %v", utils.ToIJSON(r), cost.GetCost())
+	}
+	if other, err := reservationCD("s2").GetMaxSessionDuration(); err != nil || other >= full {
+		t.Errorf("session hold ignored by other session: %v >= %v (%v)", other, full, err)
+	}
+	if _, err := ReserveSession(cd); err != nil || len(getReservationAccount(t).Reservations) != 1 {
+		t.Errorf("session hold not replaced: %v", err)
+	}
+}
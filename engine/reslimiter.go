@@ -21,16 +21,18 @@ type ResourceUsage struct {
 
 // ResourceLimit represents a limit imposed for accessing a resource (eg: new calls)
 type ResourceLimit struct {
-	ID             string           // Identifier of this limit
-	Filters        []*RequestFilter // Filters for the request
-	ActivationTime time.Time        // Time when this limit becomes active
-	ExpiryTime     time.Time
-	Weight         float64                   // Weight to sort the ResourceLimits
-	Limit          float64                   // Limit value
-	ActionTriggers ActionTriggers            // Thresholds to check after changing Limit
-	UsageTTL       time.Duration             // Expire usage after this duration
-	Usage          map[string]*ResourceUsage // Keep a record of usage, bounded with timestamps so we can expire too long records
-	usageCounter   float64                   // internal counter aggregating real usage of ResourceLimit
+	Tenant           string
+	ID               string           // Identifier of this limit
+	Filters          []*RequestFilter // Filters for the request
+	ActivationTime   time.Time        // Time when this limit becomes active
+	ExpiryTime       time.Time
+	Weight           float64                   // Weight to sort the ResourceLimits
+	Limit            float64                   // Limit value
+	ActionTriggers   ActionTriggers            // Thresholds to check after changing Limit
+	ActionTriggersID string                    // the tariff plan action triggers the thresholds come from
+	UsageTTL         time.Duration             // Expire usage after this duration
+	Usage            map[string]*ResourceUsage // Keep a record of usage, bounded with timestamps so we can expire too long records
+	usageCounter     float64                   // internal counter aggregating real usage of ResourceLimit
 }
 
 func (rl *ResourceLimit) removeExpiredUnits() {
@@ -264,6 +264,38 @@ func (rs *Responder) RefundRounding(arg *CallDescriptor, reply *float64) (err er
 	return
 }
 
+// ReserveSession holds the money the session can spend in its usage, replies with the held value
+func (rs *Responder) ReserveSession(arg *CallDescriptor, reply *float64) (err error) {
+	if arg.ReservationID == "" {
+		return utils.NewErrMandatoryIeMissing("ReservationID")
+	}
+	if rs.Bal != nil {
+		*reply, err = rs.callMethod(arg, "Responder.ReserveSession")
+		return
+	}
+	r, err := ReserveSession(arg)
+	if err != nil {
+		return err
+	}
+	*reply = 0
+	if r != nil {
+		*reply, _ = r.GetValue().Float64()
+	}
+	return nil
+}
+
+// ReleaseReservation drops the balance hold used by the session, if any
+func (rs *Responder) ReleaseReservation(arg *CallDescriptor, reply *float64) (err error) {
+	if arg.ReservationID == "" {
+		return utils.NewErrMandatoryIeMissing("ReservationID")
+	}
+	if rs.Bal != nil {
+		*reply, err = rs.callMethod(arg, "Responder.ReleaseReservation")
+		return
+	}
+	return ReleaseReservation(arg.Tenant, arg.getAccountName(), arg.ReservationID)
+}
+
 func (rs *Responder) GetMaxSessionTime(arg *CallDescriptor, reply *float64) (err error) {
 	if arg.Subject == "" {
 		arg.Subject = arg.Account
@@ -371,6 +403,7 @@ func (rs *Responder) GetDerivedMaxSessionTime(ev *CDR, reply *float64) error {
 		}
 		cd := &CallDescriptor{
 			UniqueID:    forkedEv.UniqueID,
+			OriginID:    forkedEv.OriginID,
 			RunID:       dc.RunID,
 			TOR:         forkedEv.ToR,
 			Direction:   forkedEv.Direction,
@@ -478,6 +511,7 @@ func (rs *Responder) GetSessionRuns(ev *CDR, sRuns *[]*SessionRun) error {
 		extraFields := ev.GetExtraFields()
 		cd := &CallDescriptor{
 			UniqueID:    forkedEv.UniqueID,
+			OriginID:    forkedEv.OriginID,
 			RunID:       dc.RunID,
 			TOR:         forkedEv.ToR,
 			Direction:   forkedEv.Direction,
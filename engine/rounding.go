@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+var (
+	globalRoundingMethod string
+	tenantRoundings      map[string]*config.Rounding
+)
+
+// SetRoundingMethod sets the system level rounding method, empty keeps the significant digits
+func SetRoundingMethod(method string) {
+	globalRoundingMethod = method
+}
+
+// SetTenantRoundings sets the tenant roundings overriding the system level one
+func SetTenantRoundings(roundings map[string]*config.Rounding) {
+	tenantRoundings = roundings
+}
+
+// Rounding of the costs to a number of decimals, an empty method keeps Decimals significant digits
+type Rounding struct {
+	Decimals int32  `bson:"decimals"`
+	Method   string `bson:"method"`
+}
+
+// Round rounds the value in place
+func (r *Rounding) Round(x *dec.Dec) *dec.Dec {
+	if r.Method == "" {
+		return x.Round(r.Decimals)
+	}
+	return x.RoundTo(r.Decimals, r.Method)
+}
+
+func (r *Rounding) hash() string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf(" %d%s", r.Decimals, r.Method)
+}
+
+// globalRounding returns the system level rounding
+func globalRounding() *Rounding {
+	return &Rounding{Decimals: globalRoundingDecimals, Method: globalRoundingMethod}
+}
+
+// tenantCallRounding returns the rounding of the tenant calls, the system level one for the tenants rounding only their invoices
+func tenantCallRounding(tenant string) *Rounding {
+	if tr, found := tenantRoundings[tenant]; found && tr.Scope != utils.ROUNDING_SCOPE_INVOICE {
+		return &Rounding{Decimals: int32(tr.Decimals), Method: tr.Method}
+	}
+	return globalRounding()
+}
+
+// tenantInvoiceRounding returns the rounding of the tenant invoice total, nil unless the tenant rounds only its invoices
+func tenantInvoiceRounding(tenant string) *Rounding {
+	if tr, found := tenantRoundings[tenant]; found && tr.Scope == utils.ROUNDING_SCOPE_INVOICE {
+		return &Rounding{Decimals: int32(tr.Decimals), Method: tr.Method}
+	}
+	return nil
+}
+
+// newRounding converts the tariff plan rounding
+func newRounding(tpr *utils.TpRounding) *Rounding {
+	if tpr == nil {
+		return nil
+	}
+	return &Rounding{Decimals: int32(tpr.Decimals), Method: tpr.Method}
+}
+
+// asTpRounding converts the rounding back for export
+func (r *Rounding) asTpRounding() *utils.TpRounding {
+	if r == nil {
+		return nil
+	}
+	return &utils.TpRounding{Decimals: int(r.Decimals), Method: r.Method}
+}
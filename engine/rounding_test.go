@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestLoadRatingPlanRounding(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "rounding_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	ratingDb, err := NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ratingDb.Close()
+	accountingDb, err := NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accountingDb.Close()
+	writeTpFolder(t, tmpDir, map[string]string{
+		utils.DESTINATIONS_CSV: "rnd,GERMANY,49\nrnd,FRANCE,33\n",
+		utils.RATES_CSV:        "rnd,RT_1,0,0.1,60s,1s,0s\n",
+		utils.DESTINATION_RATES_JSON: `{"Tenant":"rnd", "Tag":"DR_1", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_1"},
+  {"DestinationTag": "FRANCE", "RatesTag": "RT_1", "Rounding": {"Decimals": 4, "Method": "*half_even"}}]}`,
+		utils.RATING_PLANS_JSON: `{"Tenant":"rnd", "Tag":"RP_1", "Bindings":[{"DestinationRatesTag": "DR_1", "TimingTag": "*any", "Weight": 10}],
+  "Rounding": {"Decimals": 2, "Method": "*ceiling"}}`,
+	})
+	if _, err := LoadTariffPlanFromFolder(tmpDir, "UTC", utils.CSV_SEP, ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+	rp, err := ratingDb.GetRatingPlan("rnd", "RP_1", utils.CACHE_SKIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rp.RateIntervalList("49")[0].Rating.Rounding; r == nil || *r != (Rounding{Decimals: 2, Method: utils.ROUNDING_CEILING}) {
+		t.Errorf("the rating plan rounding should be the default: %+v", r)
+	}
+	if r := rp.RateIntervalList("33")[0].Rating.Rounding; r == nil || *r != (Rounding{Decimals: 4, Method: utils.ROUNDING_HALF_EVEN}) {
+		t.Errorf("the destination rate rounding should override the plan: %+v", r)
+	}
+	drs, err := NewTpExporter(ratingDb, accountingDb, "rnd").ExportDestinationRates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bindings := drs[0].(*utils.TpDestinationRate).Bindings; len(bindings) != 2 || bindings[0].Rounding == nil || bindings[1].Rounding != nil {
+		t.Errorf("unexpected exported bindings: %s", utils.ToIJSON(bindings))
+	}
+}
+
+func TestCallCostRounding(t *testing.T) {
+	defer SetTenantRoundings(nil)
+	SetTenantRoundings(map[string]*config.Rounding{
+		"cent":    &config.Rounding{Decimals: 2, Method: utils.ROUNDING_CEILING, Scope: utils.ROUNDING_SCOPE_CALL},
+		"invoice": &config.Rounding{Decimals: 2, Method: utils.ROUNDING_HALF_EVEN, Scope: utils.ROUNDING_SCOPE_INVOICE},
+	})
+	newCC := func(tenant string, rounding *Rounding) *CallCost {
+		start := time.Date(2017, 1, 1, 10, 0, 0, 0, time.UTC)
+		return &CallCost{Tenant: tenant, Timespans: TimeSpans{&TimeSpan{
+			TimeStart: start,
+			TimeEnd:   start.Add(61 * time.Second),
+			RateInterval: &RateInterval{Rating: &RIRate{
+				Rates:    RateGroups{&RateInfo{Value: dec.NewFloat(0.1), RateIncrement: time.Second, RateUnit: time.Minute}},
+				Rounding: rounding,
+			}},
+		}}}
+	}
+	for _, test := range []struct {
+		tenant   string
+		rounding *Rounding
+		cost     string
+		applied  Rounding
+	}{
+		{"cent", nil, "0.11", Rounding{Decimals: 2, Method: utils.ROUNDING_CEILING}},
+		{"cent", &Rounding{Decimals: 3, Method: utils.ROUNDING_DOWN}, "0.101", Rounding{Decimals: 3, Method: utils.ROUNDING_DOWN}},
+		{"invoice", nil, "0.101666667", Rounding{Decimals: globalRoundingDecimals, Method: globalRoundingMethod}},
+	} {
+		cc := newCC(test.tenant, test.rounding)
+		cc.UpdateCost()
+		if cc.GetCost().String() != test.cost || cc.Rounding == nil || *cc.Rounding != test.applied {
+			t.Errorf("%s: expected %s rounded with %+v, got %s with %+v", test.tenant, test.cost, test.applied, cc.GetCost(), cc.Rounding)
+		}
+	}
+	if r := tenantInvoiceRounding("invoice"); r == nil || r.Round(dec.NewFloat(0.125)).String() != "0.12" {
+		t.Errorf("bad invoice rounding: %+v", r)
+	}
+	if tenantInvoiceRounding("cent") != nil {
+		t.Error("the calls are already rounded")
+	}
+}
@@ -83,6 +83,7 @@ func (sg *SharedGroup) GetBalances(destination, category, direction, balanceType
 			if nUb == nil || nUb.Disabled {
 				continue
 			}
+			nUb.SetLedgerCause(ub.ledgerCause())
 		}
 		//sg.members = append(sg.members, nUb)
 		sb := nUb.getBalancesForPrefix(destination, category, direction, balanceType, sg.Name)
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// SimulationTotal aggregates the previous and the simulated cost of a group of cdrs
+type SimulationTotal struct {
+	CDRs    int
+	Usage   time.Duration
+	OldCost *dec.Dec
+	NewCost *dec.Dec
+	Delta   *dec.Dec // NewCost - OldCost
+}
+
+func newSimulationTotal() *SimulationTotal {
+	return &SimulationTotal{OldCost: dec.New(), NewCost: dec.New(), Delta: dec.New()}
+}
+
+func (st *SimulationTotal) add(usage time.Duration, oldCost, newCost *dec.Dec) {
+	st.CDRs++
+	st.Usage += usage
+	st.OldCost.AddS(oldCost)
+	st.NewCost.AddS(newCost)
+	st.Delta.Sub(st.NewCost, st.OldCost)
+}
+
+// RatingSimulation is the revenue impact of a candidate tariff plan over the historical cdrs
+type RatingSimulation struct {
+	Destinations map[string]*SimulationTotal // keyed by tenant and matched destination id
+	Subjects     map[string]*SimulationTotal // keyed by tenant and subject
+	Total        *SimulationTotal
+	Unrated      int               // cdrs without a previous cost, left out of the totals
+	Errors       map[string]string // keyed by cdr unique id and run id
+}
+
+func newRatingSimulation() *RatingSimulation {
+	return &RatingSimulation{
+		Destinations: make(map[string]*SimulationTotal),
+		Subjects:     make(map[string]*SimulationTotal),
+		Total:        newSimulationTotal(),
+		Errors:       make(map[string]string),
+	}
+}
+
+func (rs *RatingSimulation) add(cdr *CDR, cc *CallCost) {
+	destID := ""
+	if len(cc.Timespans) > 0 {
+		destID = cc.Timespans[0].MatchedDestID
+	}
+	if destID == "" {
+		destID = cdr.Destination
+	}
+	subject := cdr.Subject
+	if subject == "" {
+		subject = cdr.Account
+	}
+	for key, totals := range map[string]map[string]*SimulationTotal{
+		utils.ConcatKey(cdr.Tenant, destID):  rs.Destinations,
+		utils.ConcatKey(cdr.Tenant, subject): rs.Subjects,
+	} {
+		if _, found := totals[key]; !found {
+			totals[key] = newSimulationTotal()
+		}
+		totals[key].add(cdr.Usage, cdr.GetCost(), cc.GetCost())
+	}
+	rs.Total.add(cdr.Usage, cdr.GetCost(), cc.GetCost())
+}
+
+// SimulateRating re-rates the cdrs matching the filter with the tariff plan found in tpPath, accounts are never touched.
+// The plan is loaded in a temporary storage with its own cache, the live rating is not affected.
+func SimulateRating(tpPath, timezone string, csvSep rune, cdrDb CdrStorage, filter *utils.CDRsFilter) (*RatingSimulation, error) {
+	cdrs, _, err := cdrDb.GetCDRs(filter, false)
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir("", "rating_simulation")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	simRatingDb, err := NewIsolatedBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB)
+	if err != nil {
+		return nil, err
+	}
+	defer simRatingDb.Close()
+	simAccountingDb, err := NewIsolatedBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB)
+	if err != nil {
+		return nil, err
+	}
+	defer simAccountingDb.Close()
+	if _, err := LoadTariffPlanFromFolder(tpPath, timezone, csvSep, simRatingDb, simAccountingDb); err != nil {
+		return nil, err
+	}
+
+	sim := newRatingSimulation()
+	for _, cdr := range cdrs {
+		if cdr.RunID == utils.MetaRaw || cdr.GetCost().LtZero() {
+			sim.Unrated++
+			continue
+		}
+		timeStart := cdr.AnswerTime
+		if timeStart.IsZero() {
+			timeStart = cdr.SetupTime
+		}
+		cd := &CallDescriptor{
+			TOR:             cdr.ToR,
+			Direction:       cdr.Direction,
+			Tenant:          cdr.Tenant,
+			Category:        cdr.Category,
+			Subject:         cdr.Subject,
+			Account:         cdr.Account,
+			Destination:     cdr.Destination,
+			TimeStart:       timeStart,
+			TimeEnd:         timeStart.Add(cdr.Usage),
+			DurationIndex:   cdr.Usage,
+			PerformRounding: true,
+			ratingDB:        simRatingDb,
+		}
+		cc, err := cd.GetCost()
+		if err != nil {
+			sim.Errors[utils.ConcatKey(cdr.UniqueID, cdr.RunID)] = err.Error()
+			continue
+		}
+		sim.add(cdr, cc)
+	}
+	return sim, nil
+}
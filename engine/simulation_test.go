@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestSimulateRating(t *testing.T) {
+	cdrDB, cleanup := newTestSQLStorage(t)
+	defer cleanup()
+	tpDir, err := ioutil.TempDir("", "simulation_tp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tpDir)
+	for fileName, content := range map[string]string{
+		utils.DESTINATIONS_JSON: `{"Tenant":"sim", "Code": "0723", "Tag": "RO_MOBILE"}
+{"Tenant":"sim", "Code": "0256", "Tag": "RO_FIXED"}`,
+		utils.RATES_JSON: `{"Tenant":"sim", "Tag":"R_MOBILE", "Slots":[{"ConnectFee":0,"Rate":2,"RateUnit":"60s","RateIncrement":"60s", "GroupIntervalStart":"0s"}]}
+{"Tenant":"sim", "Tag":"R_FIXED", "Slots":[{"ConnectFee":0,"Rate":1,"RateUnit":"60s","RateIncrement":"60s", "GroupIntervalStart":"0s"}]}`,
+		utils.DESTINATION_RATES_JSON: `{"Tenant":"sim", "Tag":"DR_SIM", "Bindings":[{"DestinationTag": "RO_MOBILE", "RatesTag": "R_MOBILE"}, {"DestinationTag": "RO_FIXED", "RatesTag": "R_FIXED"}]}`,
+		utils.RATING_PLANS_JSON:      `{"Tenant":"sim", "Tag":"RP_SIM", "Bindings":[{"DestinationRatesTag": "DR_SIM", "TimingTag": "*any", "Weight": 10}]}`,
+		utils.RATING_PROFILES_JSON: `{"Direction":"*out", "Tenant":"sim", "Category":"call", "Subject":"*any", "Activations":[
+        {"ActivationTime":"2012-01-01T00:00:00Z", "RatingPlanTag":"RP_SIM"}]}`,
+	} {
+		if err := ioutil.WriteFile(path.Join(tpDir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, cdr := range []*CDR{
+		&CDR{Account: "acc1", Destination: "0723045326", Usage: 2 * time.Minute, Cost: dec.NewVal(3, 0)},
+		&CDR{Account: "acc1", Destination: "0256045326", Usage: time.Minute, Cost: dec.NewVal(1, 0)},
+		&CDR{Account: "acc2", Destination: "0723045327", Usage: time.Minute, Cost: dec.NewVal(1, 0)},
+		&CDR{Account: "acc2", Destination: "0723045327", Usage: time.Minute, Cost: dec.NewVal(-1, 0)},
+	} {
+		cdr.UniqueID = utils.GenUUID()
+		cdr.RunID, cdr.ToR, cdr.Direction, cdr.Tenant, cdr.Category = utils.META_DEFAULT, utils.VOICE, utils.OUT, "sim", "call"
+		cdr.AnswerTime = start
+		if err := cdrDB.SetCDR(cdr, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sim, err := SimulateRating(tpDir, "UTC", utils.CSV_SEP, cdrDB, &utils.CDRsFilter{Tenants: []string{"sim"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpf, err := ratingStorage.GetRatingProfile(utils.OUT, "sim", "call", utils.ANY, false, utils.CACHED); err == nil {
+		t.Errorf("simulated plan visible to the live rating: %s", utils.ToIJSON(rpf))
+	}
+	if sim.Unrated != 1 || len(sim.Errors) != 0 {
+		t.Errorf("bad simulation counters: %s", utils.ToIJSON(sim))
+	}
+	// old 5, new 4 + 1 + 2
+	if sim.Total.CDRs != 3 || sim.Total.OldCost.Cmp(dec.NewVal(5, 0)) != 0 || sim.Total.NewCost.Cmp(dec.NewVal(7, 0)) != 0 ||
+		sim.Total.Delta.Cmp(dec.NewVal(2, 0)) != 0 {
+		t.Errorf("bad simulation total: %s", utils.ToIJSON(sim.Total))
+	}
+	if mobile := sim.Destinations[utils.ConcatKey("sim", "RO_MOBILE")]; mobile == nil || mobile.CDRs != 2 || mobile.Delta.Cmp(dec.NewVal(2, 0)) != 0 {
+		t.Errorf("bad destination totals: %s", utils.ToIJSON(sim.Destinations))
+	}
+	if acc1 := sim.Subjects[utils.ConcatKey("sim", "acc1")]; acc1 == nil || acc1.CDRs != 2 || acc1.NewCost.Cmp(dec.NewVal(5, 0)) != 0 {
+		t.Errorf("bad subject totals: %s", utils.ToIJSON(sim.Subjects))
+	}
+}
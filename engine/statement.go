@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// statement line types besides the action types
+const (
+	STATEMENT_USAGE   = "*usage"
+	STATEMENT_OPENING = "*opening"
+	STATEMENT_CLOSING = "*closing"
+	STATEMENT         = "*statement"
+)
+
+// AccountStatement lists the balance changes of an account in a period, out of the balance ledger
+type AccountStatement struct {
+	Tenant  string
+	Account string
+	From    time.Time           // included
+	To      time.Time           // excluded
+	Opening map[string]*dec.Dec // balance type totals at From
+	Closing map[string]*dec.Dec // balance type totals at To
+	Lines   []*StatementLine
+}
+
+// StatementLine is a balance change of the statement
+type StatementLine struct {
+	Time        time.Time
+	Type        string // *usage, *refund, *expiry, *transfer or the action type (*topup, *debit, *topup_reset...)
+	BalanceType string
+	BalanceUUID string
+	BalanceID   string
+	Amount      *dec.Dec // the balance change
+	Balance     *dec.Dec // the balance type total after the change
+	OriginID    string   // the call of the usage lines
+	Destination string
+	Usage       time.Duration
+	ActionsID   string // the action group of the action lines
+}
+
+// NewAccountStatement builds the statement going back from the current account balances through the ledger
+func NewAccountStatement(tenant, account string, from, to time.Time) (*AccountStatement, error) {
+	acc, err := accountingStorage.GetAccount(tenant, account)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := accountingStorage.GetLedgerEntries(&LedgerFilter{Tenant: tenant, Account: account, TimeStart: from}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	st := &AccountStatement{Tenant: tenant, Account: account, From: from, To: to,
+		Opening: make(map[string]*dec.Dec), Closing: make(map[string]*dec.Dec)}
+	total := func(totals map[string]*dec.Dec, balanceType string) *dec.Dec {
+		if _, found := totals[balanceType]; !found {
+			totals[balanceType] = dec.New()
+		}
+		return totals[balanceType]
+	}
+	for balanceType, balances := range acc.BalanceMap {
+		for _, b := range balances {
+			total(st.Closing, balanceType).AddS(b.GetValue())
+		}
+	}
+	var inPeriod []*LedgerEntry
+	for _, e := range entries {
+		if e.Time.Before(to) {
+			inPeriod = append(inPeriod, e)
+			continue
+		}
+		total(st.Closing, e.BalanceType).SubS(e.Delta)
+	}
+	for balanceType, value := range st.Closing {
+		st.Opening[balanceType] = dec.New().Set(value)
+	}
+	for _, e := range inPeriod {
+		total(st.Opening, e.BalanceType).SubS(e.Delta)
+		total(st.Closing, e.BalanceType) // the balance types gone in the period
+	}
+	running := make(map[string]*dec.Dec, len(st.Opening))
+	for balanceType, value := range st.Opening {
+		running[balanceType] = dec.New().Set(value)
+	}
+	for _, e := range inPeriod {
+		line := &StatementLine{
+			Time:        e.Time,
+			Type:        e.Cause,
+			BalanceType: e.BalanceType,
+			BalanceUUID: e.BalanceUUID,
+			BalanceID:   e.BalanceID,
+			Amount:      e.Delta,
+			Balance:     dec.New().Set(running[e.BalanceType].AddS(e.Delta)),
+		}
+		switch e.Cause {
+		case LEDGER_DEBIT:
+			line.Type, line.OriginID = STATEMENT_USAGE, e.CauseID
+		case LEDGER_REFUND:
+			line.OriginID = e.CauseID
+		case LEDGER_ACTION:
+			line.ActionsID = e.CauseID
+			if e.Action != "" {
+				line.Type = e.Action
+			}
+		case LEDGER_API:
+			line.Type = e.CauseID
+			if e.Action != "" {
+				line.Type = e.Action
+			}
+		}
+		st.Lines = append(st.Lines, line)
+	}
+	if err := st.addUsageDetails(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// addUsageDetails completes the usage lines with the rated cdrs of the calls set up in the period,
+// the other usage lines keep the ledger details only
+func (st *AccountStatement) addUsageDetails() error {
+	if cdrStorage == nil {
+		return nil
+	}
+	var originIDs []string
+	for _, l := range st.Lines {
+		if l.OriginID != "" {
+			originIDs = append(originIDs, l.OriginID)
+		}
+	}
+	if len(originIDs) == 0 {
+		return nil
+	}
+	cdrs := make(map[string]*CDR)
+	found, _, err := cdrStorage.GetCDRs(&utils.CDRsFilter{Tenants: []string{st.Tenant}, Accounts: []string{st.Account},
+		SetupTimeStart: &st.From, SetupTimeEnd: &st.To}, false)
+	if err != nil {
+		return err
+	}
+	for _, cdr := range found {
+		if _, exists := cdrs[cdr.OriginID]; !exists || cdr.RunID == utils.META_DEFAULT {
+			cdrs[cdr.OriginID] = cdr
+		}
+	}
+	for _, l := range st.Lines {
+		if l.OriginID == "" {
+			continue
+		}
+		if cdr, found := cdrs[l.OriginID]; found {
+			l.Destination, l.Usage = cdr.Destination, cdr.Usage
+		}
+	}
+	return nil
+}
+
+// AsCDRs renders the statement as records for the cdr exporter: the opening totals, the lines and the closing totals.
+// The cost is the balance change, the Balance extra field is the balance type total.
+func (st *AccountStatement) AsCDRs() []*CDR {
+	var cdrs []*CDR
+	newCDR := func(lineType, balanceType string, t time.Time, amount, balance *dec.Dec) *CDR {
+		cdr := &CDR{
+			RunID:       STATEMENT,
+			Source:      lineType,
+			OriginHost:  "127.0.0.1",
+			ToR:         balanceType,
+			Direction:   utils.OUT,
+			Tenant:      st.Tenant,
+			Account:     st.Account,
+			Subject:     st.Account,
+			SetupTime:   t,
+			AnswerTime:  t,
+			Cost:        amount,
+			ExtraFields: map[string]string{"Balance": balance.String()},
+		}
+		cdr.UniqueID = utils.Sha1(st.Tenant, st.Account, t.String(), lineType, balanceType, strconv.Itoa(len(cdrs)))
+		cdrs = append(cdrs, cdr)
+		return cdr
+	}
+	for _, balanceType := range balanceTypes(st.Opening) {
+		newCDR(STATEMENT_OPENING, balanceType, st.From, dec.New(), st.Opening[balanceType])
+	}
+	for _, l := range st.Lines {
+		cdr := newCDR(l.Type, l.BalanceType, l.Time, l.Amount, l.Balance)
+		cdr.OriginID, cdr.Destination, cdr.Usage = l.OriginID, l.Destination, l.Usage
+		cdr.ExtraFields["BalanceUUID"], cdr.ExtraFields["BalanceID"], cdr.ExtraFields["ActionsID"] = l.BalanceUUID, l.BalanceID, l.ActionsID
+	}
+	for _, balanceType := range balanceTypes(st.Closing) {
+		newCDR(STATEMENT_CLOSING, balanceType, st.To, dec.New(), st.Closing[balanceType])
+	}
+	return cdrs
+}
+
+func balanceTypes(totals map[string]*dec.Dec) []string {
+	types := make([]string, 0, len(totals))
+	for balanceType := range totals {
+		types = append(types, balanceType)
+	}
+	sort.Strings(types)
+	return types
+}
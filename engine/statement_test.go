@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestAccountStatement(t *testing.T) {
+	acc := &Account{Tenant: "statement", Name: "acc", BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{&Balance{UUID: "s1", ID: utils.META_DEFAULT, Value: dec.NewFloat(5)}},
+		utils.VOICE:    Balances{&Balance{UUID: "s2", ID: "minutes", Value: dec.NewFloat(60)}},
+	}}
+	acc.disableLedger()
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	day := func(month time.Month, d int) time.Time { return time.Date(2017, month, d, 12, 0, 0, 0, time.UTC) }
+	entry := func(uuid, balanceType string, delta, value float64, cause, causeID, action string, t time.Time) *LedgerEntry {
+		return &LedgerEntry{Tenant: "statement", Account: "acc", BalanceUUID: uuid, BalanceType: balanceType,
+			Delta: dec.NewFloat(delta), Value: dec.NewFloat(value), Cause: cause, CauseID: causeID, Action: action, Time: t}
+	}
+	if err := accountingStorage.AddLedgerEntries([]*LedgerEntry{
+		entry("s1", utils.MONETARY, 10, 10, LEDGER_API, TOPUP, TOPUP, day(time.January, 1)),
+		entry("s1", utils.MONETARY, -3, 7, LEDGER_DEBIT, "call1", "", day(time.January, 5)),
+		entry("s2", utils.VOICE, 60, 60, LEDGER_ACTION, "AG1", TOPUP, day(time.January, 10)),
+		entry("s1", utils.MONETARY, -2, 5, LEDGER_DEBIT, "call2", "", day(time.February, 2)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := NewAccountStatement("statement", "acc", day(time.January, 2), day(time.February, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Opening[utils.MONETARY].String() != "10" || st.Opening[utils.VOICE].String() != "0" ||
+		st.Closing[utils.MONETARY].String() != "7" || st.Closing[utils.VOICE].String() != "60" {
+		t.Errorf("bad totals: %s %s", utils.ToIJSON(st.Opening), utils.ToIJSON(st.Closing))
+	}
+	if len(st.Lines) != 2 {
+		t.Fatalf("bad lines: %s", utils.ToIJSON(st.Lines))
+	}
+	if l := st.Lines[0]; l.Type != STATEMENT_USAGE || l.OriginID != "call1" || l.Amount.String() != "-3" || l.Balance.String() != "7" {
+		t.Errorf("bad usage line: %s", utils.ToIJSON(l))
+	}
+	if l := st.Lines[1]; l.Type != TOPUP || l.ActionsID != "AG1" || l.Balance.String() != "60" {
+		t.Errorf("bad action line: %s", utils.ToIJSON(l))
+	}
+	cdrs := st.AsCDRs()
+	if len(cdrs) != 6 || cdrs[0].Source != STATEMENT_OPENING || cdrs[2].OriginID != "call1" || cdrs[5].Source != STATEMENT_CLOSING ||
+		cdrs[5].ExtraFields["Balance"] != "60" {
+		t.Errorf("bad statement records: %s", utils.ToIJSON(cdrs))
+	}
+}
+
+func TestAccountStatementUsageDetails(t *testing.T) {
+	cdrDB, cleanup := newTestBoltStorage(t, utils.CdrDB)
+	defer cleanup()
+	savedCdrStorage := cdrStorage
+	cdrStorage = cdrDB
+	defer func() { cdrStorage = savedCdrStorage }()
+
+	acc := &Account{Tenant: "statement", Name: "usage", BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{&Balance{UUID: "u1", ID: utils.META_DEFAULT, Value: dec.NewFloat(5)}}}}
+	acc.disableLedger()
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2017, time.March, d, 12, 0, 0, 0, time.UTC) }
+	if err := accountingStorage.AddLedgerEntries([]*LedgerEntry{
+		&LedgerEntry{Tenant: "statement", Account: "usage", BalanceUUID: "u1", BalanceType: utils.MONETARY,
+			Delta: dec.NewFloat(-1), Value: dec.NewFloat(6), Cause: LEDGER_DEBIT, CauseID: "call1", Time: day(5)},
+		&LedgerEntry{Tenant: "statement", Account: "usage", BalanceUUID: "u1", BalanceType: utils.MONETARY,
+			Delta: dec.NewFloat(-1), Value: dec.NewFloat(5), Cause: LEDGER_DEBIT, CauseID: "call2", Time: day(6)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cdr := range []*CDR{
+		// the same origin id reused long before the period
+		&CDR{UniqueID: "old", RunID: utils.META_DEFAULT, OriginID: "call1", Destination: "0999", SetupTime: day(1).AddDate(0, -6, 0), Usage: time.Hour},
+		&CDR{UniqueID: "new", RunID: "run2", OriginID: "call1", Destination: "0723", SetupTime: day(5), Usage: time.Minute},
+	} {
+		cdr.Tenant, cdr.Account = "statement", "usage"
+		if err := cdrDB.SetCDR(cdr, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// session costs are not matched on the origin id alone
+	if err := cdrDB.SetSMCost(&SMCost{UniqueID: "sm", RunID: utils.META_DEFAULT, OriginHost: "127.0.0.1", OriginID: "call2",
+		Usage: 30, CostDetails: &CallCost{Destination: "0744"}}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := NewAccountStatement("statement", "usage", day(2), day(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Lines) != 2 {
+		t.Fatalf("bad lines: %s", utils.ToIJSON(st.Lines))
+	}
+	if l := st.Lines[0]; l.Destination != "0723" || l.Usage != time.Minute {
+		t.Errorf("bad cdr details: %s", utils.ToIJSON(l))
+	}
+	if l := st.Lines[1]; l.OriginID != "call2" || l.Destination != "" || l.Usage != 0 {
+		t.Errorf("bad details without cdr: %s", utils.ToIJSON(l))
+	}
+}
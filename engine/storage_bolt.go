@@ -0,0 +1,1652 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/accurateproject/accurate/cache2go"
+	"github.com/accurateproject/accurate/config"
+	"github.com/accurateproject/accurate/utils"
+	"github.com/boltdb/bolt"
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	boltFiles   = make(map[string]*boltFile) // the same file can back more than one storage type
+	boltFilesMu sync.Mutex
+)
+
+type boltFile struct {
+	db   *bolt.DB
+	refs int
+}
+
+func openBoltFile(path string) (*bolt.DB, error) {
+	boltFilesMu.Lock()
+	defer boltFilesMu.Unlock()
+	if bf, found := boltFiles[path]; found {
+		bf.refs++
+		return bf.db, nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	boltFiles[path] = &boltFile{db: db, refs: 1}
+	return db, nil
+}
+
+func closeBoltFile(path string) {
+	boltFilesMu.Lock()
+	defer boltFilesMu.Unlock()
+	bf, found := boltFiles[path]
+	if !found {
+		return
+	}
+	if bf.refs--; bf.refs == 0 {
+		bf.db.Close()
+		delete(boltFiles, path)
+	}
+}
+
+// NewBoltStorage opens (or creates) the embedded database file found at path
+func NewBoltStorage(path, storageType string, cdrsIndexes []string, cacheCfg *config.Cache, loadHistorySize int) (bs *BoltStorage, err error) {
+	db, err := openBoltFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bs = &BoltStorage{db: db, path: path, cacheCfg: cacheCfg, loadHistorySize: loadHistorySize, cdrsIndexes: cdrsIndexes, storageType: storageType}
+	if err = bs.EnsureIndexes(); err != nil {
+		bs.Close()
+		return nil, err
+	}
+	return
+}
+
+// NewIsolatedBoltStorage opens a storage whose cached items are kept apart from the engine ones,
+// used to load and query tariff plans without changing the live rating
+func NewIsolatedBoltStorage(path, storageType string) (*BoltStorage, error) {
+	bs, err := NewBoltStorage(path, storageType, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	bs.cacheNS = utils.GenUUID() + utils.CONCATENATED_KEY_SEP
+	return bs, nil
+}
+
+// BoltStorage keeps every collection in a bucket of an embedded key/value file,
+// documents are bson encoded so they can be queried with the same filters as in mongo
+type BoltStorage struct {
+	db              *bolt.DB
+	path            string
+	cacheCfg        *config.Cache
+	loadHistorySize int
+	cdrsIndexes     []string
+	storageType     string
+	cacheNS         string // prefixes the cache tenants of an isolated storage
+}
+
+// cacheTenant returns the cache partition of the tenant
+func (bs *BoltStorage) cacheTenant(tenant string) string {
+	return bs.cacheNS + tenant
+}
+
+// boltKey builds the unique key of a document out of its identity fields
+func boltKey(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00"))
+}
+
+func boltSeqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// documents are stored under a sequence key to keep the insertion (natural) order,
+// the keys bucket maps the unique key of the document to that sequence
+func boltKeysBucket(col string) []byte {
+	return []byte(col + "_keys")
+}
+
+// the seqs bucket maps the sequence key back to the unique key of the document
+func boltSeqsBucket(col string) []byte {
+	return []byte(col + "_seqs")
+}
+
+// boltIndexes are the fields kept in index buckets, the queries on all the fields of an index
+// read the indexed documents instead of the whole bucket
+var boltIndexes = map[string][][]string{
+	ColApb: {{"tenant", "account"}},
+	ColInv: {{"tenant", "account"}},
+	ColBlg: {{"tenant", "account"}, {"tenant", "cause"}},
+}
+
+// the index bucket keys are the field values followed by the sequence key of the document
+func boltIndexBucket(col string, fields []string) []byte {
+	return []byte(col + "_idx_" + strings.Join(fields, "_"))
+}
+
+func boltIndexPrefix(values []string) []byte {
+	return append(boltKey(values...), 0)
+}
+
+// docIndexValues returns the index values of the document, the fields that are not strings are indexed as empty
+func docIndexValues(doc bson.M, fields []string) []string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i], _ = doc[field].(string)
+	}
+	return values
+}
+
+// ensureSeqsBucket returns the seqs bucket, filled out of the keys bucket for the files written before it
+func ensureSeqsBucket(tx *bolt.Tx, col string) (*bolt.Bucket, error) {
+	if sb := tx.Bucket(boltSeqsBucket(col)); sb != nil {
+		return sb, nil
+	}
+	sb, err := tx.CreateBucket(boltSeqsBucket(col))
+	if err != nil {
+		return nil, err
+	}
+	if kb := tx.Bucket(boltKeysBucket(col)); kb != nil {
+		err = kb.ForEach(func(k, v []byte) error {
+			return sb.Put(v, k)
+		})
+	}
+	return sb, err
+}
+
+// ensureIndexBucket returns the index bucket, built out of the stored documents when missing
+func ensureIndexBucket(tx *bolt.Tx, col string, fields []string) (*bolt.Bucket, error) {
+	if ib := tx.Bucket(boltIndexBucket(col, fields)); ib != nil {
+		return ib, nil
+	}
+	ib, err := tx.CreateBucket(boltIndexBucket(col, fields))
+	if err != nil {
+		return nil, err
+	}
+	if b := tx.Bucket([]byte(col)); b != nil {
+		err = b.ForEach(func(k, v []byte) error {
+			doc := bson.M{}
+			if err := bson.Unmarshal(v, &doc); err != nil {
+				return err
+			}
+			return ib.Put(append(boltIndexPrefix(docIndexValues(doc, fields)), k...), []byte{})
+		})
+	}
+	return ib, err
+}
+
+// indexDoc adds or removes the index entries of the document
+func indexDoc(tx *bolt.Tx, col string, seqKey, data []byte, add bool) error {
+	indexes := boltIndexes[col]
+	if len(indexes) == 0 || data == nil {
+		return nil
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	for _, fields := range indexes {
+		key := append(boltIndexPrefix(docIndexValues(doc, fields)), seqKey...)
+		if !add {
+			if ib := tx.Bucket(boltIndexBucket(col, fields)); ib != nil {
+				if err := ib.Delete(key); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		ib, err := ensureIndexBucket(tx, col, fields)
+		if err != nil {
+			return err
+		}
+		if err := ib.Put(key, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexedSeqKeys returns in natural order the sequence keys indexed under the filter values,
+// false when no index covers the filter
+func indexedSeqKeys(tx *bolt.Tx, col string, fltr bson.M) ([][]byte, bool) {
+	var fields, values []string
+	for _, index := range boltIndexes[col] {
+		indexValues := make([]string, len(index))
+		covered := true
+		for i, field := range index {
+			if indexValues[i], covered = fltr[field].(string); !covered {
+				break
+			}
+		}
+		if covered && len(index) > len(fields) {
+			fields, values = index, indexValues
+		}
+	}
+	if fields == nil {
+		return nil, false
+	}
+	ib := tx.Bucket(boltIndexBucket(col, fields))
+	if ib == nil {
+		return nil, false
+	}
+	prefix := boltIndexPrefix(values)
+	var seqKeys [][]byte
+	c := ib.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if len(k)-len(prefix) == 8 { // a longer value sharing the prefix otherwise
+			seqKeys = append(seqKeys, append([]byte{}, k[len(prefix):]...))
+		}
+	}
+	return seqKeys, true
+}
+
+// putDoc stores the document, a nil key always appends a new one
+func putDoc(tx *bolt.Tx, col string, key, data []byte, overwrite bool) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(col))
+	if err != nil {
+		return err
+	}
+	var seqKey []byte
+	if key != nil {
+		kb, err := tx.CreateBucketIfNotExists(boltKeysBucket(col))
+		if err != nil {
+			return err
+		}
+		sb, err := ensureSeqsBucket(tx, col)
+		if err != nil {
+			return err
+		}
+		if existing := kb.Get(key); existing != nil {
+			if !overwrite {
+				return utils.ErrExists
+			}
+			seqKey = append([]byte{}, existing...)
+			if err := indexDoc(tx, col, seqKey, b.Get(seqKey), false); err != nil {
+				return err
+			}
+		}
+		if seqKey == nil {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			seqKey = boltSeqKey(seq)
+			if err := kb.Put(key, seqKey); err != nil {
+				return err
+			}
+			if err := sb.Put(seqKey, key); err != nil {
+				return err
+			}
+		}
+	} else {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		seqKey = boltSeqKey(seq)
+	}
+	if err := b.Put(seqKey, data); err != nil {
+		return err
+	}
+	return indexDoc(tx, col, seqKey, data, true)
+}
+
+// deleteDocs removes the documents with the given sequence keys together with their unique keys and index entries
+func deleteDocs(tx *bolt.Tx, col string, seqKeys [][]byte) error {
+	b := tx.Bucket([]byte(col))
+	if b == nil || len(seqKeys) == 0 {
+		return nil
+	}
+	kb := tx.Bucket(boltKeysBucket(col))
+	var sb *bolt.Bucket
+	if kb != nil {
+		var err error
+		if sb, err = ensureSeqsBucket(tx, col); err != nil {
+			return err
+		}
+	}
+	for _, seqKey := range seqKeys {
+		if err := indexDoc(tx, col, seqKey, b.Get(seqKey), false); err != nil {
+			return err
+		}
+		if err := b.Delete(seqKey); err != nil {
+			return err
+		}
+		if sb == nil {
+			continue
+		}
+		if key := sb.Get(seqKey); key != nil {
+			if err := kb.Delete(append([]byte{}, key...)); err != nil {
+				return err
+			}
+			if err := sb.Delete(seqKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (bs *BoltStorage) upsert(col string, key []byte, doc interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return putDoc(tx, col, key, data, true)
+	})
+}
+
+func (bs *BoltStorage) insert(col string, doc interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return putDoc(tx, col, nil, data, false)
+	})
+}
+
+// getDoc reads the document of the key inside the transaction
+func getDoc(tx *bolt.Tx, col string, key []byte, out interface{}) error {
+	kb, b := tx.Bucket(boltKeysBucket(col)), tx.Bucket([]byte(col))
+	if kb == nil || b == nil {
+		return utils.ErrNotFound
+	}
+	seqKey := kb.Get(key)
+	if seqKey == nil {
+		return utils.ErrNotFound
+	}
+	data := b.Get(seqKey)
+	if data == nil {
+		return utils.ErrNotFound
+	}
+	return bson.Unmarshal(data, out)
+}
+
+func (bs *BoltStorage) getOne(col string, key []byte, out interface{}) error {
+	var data []byte
+	bs.db.View(func(tx *bolt.Tx) error {
+		kb, b := tx.Bucket(boltKeysBucket(col)), tx.Bucket([]byte(col))
+		if kb == nil || b == nil {
+			return nil
+		}
+		if seqKey := kb.Get(key); seqKey != nil {
+			if v := b.Get(seqKey); v != nil {
+				data = append([]byte{}, v...)
+			}
+		}
+		return nil
+	})
+	if data == nil {
+		return utils.ErrNotFound
+	}
+	return bson.Unmarshal(data, out)
+}
+
+func (bs *BoltStorage) remove(col string, key []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		kb := tx.Bucket(boltKeysBucket(col))
+		if kb == nil {
+			return nil
+		}
+		seqKey := kb.Get(key)
+		if seqKey == nil {
+			return nil
+		}
+		return deleteDocs(tx, col, [][]byte{append([]byte{}, seqKey...)})
+	})
+}
+
+// scan returns the documents of the bucket matching the filter, in key order
+func scan(tx *bolt.Tx, col string, fltr map[string]interface{}) ([]*boltDoc, error) {
+	b := tx.Bucket([]byte(col))
+	if b == nil {
+		return nil, nil
+	}
+	normalized, err := normalizeFilter(fltr)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*boltDoc
+	match := func(k, v []byte) error {
+		doc := bson.M{}
+		if err := bson.Unmarshal(v, &doc); err != nil {
+			return err
+		}
+		if matchFilter(doc, normalized) {
+			docs = append(docs, &boltDoc{key: append([]byte{}, k...), raw: append([]byte{}, v...), doc: doc})
+		}
+		return nil
+	}
+	if seqKeys, indexed := indexedSeqKeys(tx, col, normalized); indexed {
+		for _, k := range seqKeys {
+			if v := b.Get(k); v != nil {
+				if err := match(k, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return docs, nil
+	}
+	err = b.ForEach(match)
+	return docs, err
+}
+
+func paginateDocs(docs []*boltDoc, offset, limit int) []*boltDoc {
+	if offset > 0 {
+		if offset >= len(docs) {
+			return nil
+		}
+		docs = docs[offset:]
+	}
+	if limit > 0 && limit < len(docs) {
+		docs = docs[:limit]
+	}
+	return docs
+}
+
+func (bs *BoltStorage) find(col string, fltr map[string]interface{}, sort string, offset, limit int) (docs []*boltDoc, err error) {
+	err = bs.db.View(func(tx *bolt.Tx) error {
+		docs, err = scan(tx, col, fltr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDocs(docs, sort)
+	return paginateDocs(docs, offset, limit), nil
+}
+
+func (bs *BoltStorage) findAll(col string, fltr map[string]interface{}, sort string, offset, limit int, out interface{}) error {
+	docs, err := bs.find(col, fltr, sort, offset, limit)
+	if err != nil {
+		return err
+	}
+	return unmarshalDocs(docs, out)
+}
+
+func (bs *BoltStorage) findOne(col string, fltr map[string]interface{}, out interface{}) error {
+	docs, err := bs.find(col, fltr, "", 0, 1)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return utils.ErrNotFound
+	}
+	return bson.Unmarshal(docs[0].raw, out)
+}
+
+func (bs *BoltStorage) removeAll(col string, fltr map[string]interface{}) (removed int, err error) {
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		docs, err := scan(tx, col, fltr)
+		if err != nil {
+			return err
+		}
+		removed = len(docs)
+		return deleteDocs(tx, col, docKeys(docs))
+	})
+	return
+}
+
+func (bs *BoltStorage) RemoveTenant(tenant string, collections ...string) error {
+	for _, collName := range expandCollections(collections) {
+		if _, err := bs.removeAll(collName, map[string]interface{}{"tenant": tenant}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bs *BoltStorage) GetTenantSnapshot(tenant string, collections ...string) (*TenantSnapshot, error) {
+	snapshot := &TenantSnapshot{Tenant: tenant, Collections: make(map[string][]*SnapshotDoc)}
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		for _, col := range expandCollections(collections) {
+			docs, err := scan(tx, col, map[string]interface{}{"tenant": tenant})
+			if err != nil {
+				return err
+			}
+			uniqueKeys := make(map[string][]byte) // by sequence key
+			if kb := tx.Bucket(boltKeysBucket(col)); kb != nil {
+				kb.ForEach(func(k, v []byte) error {
+					uniqueKeys[string(v)] = append([]byte{}, k...)
+					return nil
+				})
+			}
+			snapDocs := make([]*SnapshotDoc, len(docs))
+			for i, doc := range docs {
+				snapDocs[i] = &SnapshotDoc{Key: uniqueKeys[string(doc.key)], Data: doc.raw}
+			}
+			snapshot.Collections[col] = snapDocs
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RestoreTenantSnapshot swaps the tenant documents in one transaction, the readers find either the old or the new ones
+func (bs *BoltStorage) RestoreTenantSnapshot(snapshot *TenantSnapshot) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for col, snapDocs := range snapshot.Collections {
+			docs, err := scan(tx, col, map[string]interface{}{"tenant": snapshot.Tenant})
+			if err != nil {
+				return err
+			}
+			if err := deleteDocs(tx, col, docKeys(docs)); err != nil {
+				return err
+			}
+			for _, doc := range snapDocs {
+				if err := putDoc(tx, col, doc.Key, doc.Data, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (bs *BoltStorage) Count(collection string) (count int, err error) {
+	err = bs.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(collection)); b != nil {
+			count = b.Stats().KeyN
+		}
+		return nil
+	})
+	return
+}
+
+func (bs *BoltStorage) Iterator(collection, sort string, fltr map[string]interface{}) Iterator {
+	docs, err := bs.find(collection, fltr, sort, 0, 0)
+	return &boltIterator{docs: docs, err: err}
+}
+
+func (bs *BoltStorage) GetAllPaged(tenant string, out interface{}, collection string, limit, offset int) error {
+	return bs.findAll(collection, map[string]interface{}{"tenant": tenant}, "", offset, limit, out)
+}
+
+func (bs *BoltStorage) GetByNames(tenant string, names []string, out interface{}, collection string) error {
+	flt := map[string]interface{}{"tenant": tenant}
+	if len(names) > 0 {
+		flt["name"] = bson.M{"$in": names}
+	}
+	return bs.findAll(collection, flt, "", 0, 0, out)
+}
+
+// EnsureIndexes creates the buckets and builds the seqs and index buckets missing from the older files,
+// uniqueness is given by the document keys
+func (bs *BoltStorage) EnsureIndexes() error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for _, col := range expandCollections([]string{bs.storageType}) {
+			if _, err := tx.CreateBucketIfNotExists([]byte(col)); err != nil {
+				return err
+			}
+			if tx.Bucket(boltKeysBucket(col)) != nil {
+				if _, err := ensureSeqsBucket(tx, col); err != nil {
+					return err
+				}
+			}
+			for _, fields := range boltIndexes[col] {
+				if _, err := ensureIndexBucket(tx, col, fields); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (bs *BoltStorage) Close() {
+	if bs.cacheNS != "" {
+		cache2go.FlushPrefix(bs.cacheNS)
+	}
+	closeBoltFile(bs.path)
+}
+
+func (bs *BoltStorage) Ping() error {
+	return bs.db.View(func(tx *bolt.Tx) error { return nil })
+}
+
+func (bs *BoltStorage) Flush() (err error) {
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		var names [][]byte
+		tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, append([]byte{}, name...))
+			return nil
+		})
+		for _, name := range names {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bs.EnsureIndexes()
+}
+
+func (bs *BoltStorage) PreloadRatingCache() error {
+	return preloadRatingCache(bs.cacheCfg, bs)
+}
+
+func (bs *BoltStorage) PreloadAccountingCache() error {
+	return preloadAccountingCache(bs.cacheCfg, bs)
+}
+
+func (bs *BoltStorage) PreloadCacheForPrefix(prefix string) error {
+	transID := cache2go.BeginTransaction()
+	switch prefix {
+	case utils.RATING_PLAN_PREFIX:
+		iter := bs.Iterator(ColRpl, "", nil)
+		var rpl RatingPlan
+		for iter.Next(&rpl) {
+			if _, err := bs.GetRatingPlan(rpl.Tenant, rpl.Name, transID); err != nil {
+				cache2go.RollbackTransaction(transID)
+				return err
+			}
+		}
+		if err := iter.Close(); err != nil {
+			cache2go.RollbackTransaction(transID)
+			return err
+		}
+	default:
+		return utils.ErrInvalidKey
+	}
+	cache2go.CommitTransaction(transID)
+	return nil
+}
+
+func (bs *BoltStorage) GetRatingPlan(tenant, name, cacheParam string) (rp *RatingPlan, err error) {
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.RATING_PLAN_PREFIX+name); ok {
+			if x != nil {
+				return x.(*RatingPlan), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	rp = new(RatingPlan)
+	if err = bs.getOne(ColRpl, boltKey(tenant, name), rp); err != nil {
+		return nil, err
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.RATING_PLAN_PREFIX+name, rp, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetRatingPlan(rp *RatingPlan) (err error) {
+	err = bs.upsert(ColRpl, boltKey(rp.Tenant, rp.Name), rp)
+	if err == nil && historyScribe != nil {
+		var response int
+		historyScribe.Call("HistoryV1.Record", rp.GetHistoryRecord(), &response)
+	}
+	cache2go.Set(bs.cacheTenant(rp.Tenant), utils.RATING_PLAN_PREFIX+rp.Name, rp, utils.CACHE_SKIP)
+	return err
+}
+
+func (bs *BoltStorage) GetRatingProfiles(direction, tenant, category, subject, cacheParam string) (rps []*RatingProfile, err error) {
+	key := utils.ConcatKey(direction, category, subject)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.RATING_PROFILE_PREFIX+key); ok {
+			if x != nil {
+				return x.([]*RatingProfile), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	rps = make([]*RatingProfile, 0)
+	err = bs.findAll(ColRpf, map[string]interface{}{
+		"direction": direction,
+		"tenant":    tenant,
+		"category":  category,
+		"subject":   subject,
+	}, "", 0, 0, &rps)
+	if err != nil {
+		rps = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.RATING_PROFILE_PREFIX+key, rps, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) GetRatingProfile(direction, tenant, category, subject string, prefixMatching bool, cacheParam string) (rp *RatingProfile, err error) {
+	prefix := ""
+	if prefixMatching {
+		prefix = "prefix"
+	}
+	key := utils.ConcatKey(direction, category, subject, prefix)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.RATING_PROFILE_PREFIX+key); ok {
+			if x != nil {
+				return x.(*RatingProfile), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	m := map[string]interface{}{
+		"direction": direction,
+		"tenant":    tenant,
+		"category":  category,
+		"subject":   subject,
+	}
+	if prefixMatching && subject != "" && subject != utils.ANY {
+		x := make([]*RatingProfile, 0)
+		m["subject"] = bson.M{"$in": utils.SplitPrefix(subject, MIN_PREFIX_MATCH)}
+		err = bs.findAll(ColRpf, m, "-subject", 0, 1, &x)
+		if err == nil && len(x) == 0 {
+			err = utils.ErrNotFound
+		}
+		if len(x) > 0 {
+			rp = x[0]
+		}
+	} else {
+		rp = new(RatingProfile)
+		err = bs.findOne(ColRpf, m, rp)
+	}
+
+	if err != nil {
+		rp = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.RATING_PROFILE_PREFIX+key, rp, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetRatingProfile(rp *RatingProfile) error {
+	err := bs.upsert(ColRpf, boltKey(rp.Direction, rp.Tenant, rp.Category, rp.Subject), rp)
+	if err == nil && historyScribe != nil {
+		var response int
+		historyScribe.Call("HistoryV1.Record", rp.GetHistoryRecord(false), &response)
+	}
+	cache2go.RemPrefixKey(bs.cacheTenant(rp.Tenant), utils.RATING_PROFILE_PREFIX, "")
+	return err
+}
+
+func (bs *BoltStorage) RemoveRatingProfile(direction, tenant, category, subject string) error {
+	if _, err := bs.removeAll(ColRpf, map[string]interface{}{"direction": direction, "tenant": tenant, "category": category, "subject": subject}); err != nil {
+		return err
+	}
+	rpf := &RatingProfile{
+		Direction: direction,
+		Tenant:    tenant,
+		Category:  category,
+		Subject:   subject,
+	}
+	cache2go.RemPrefixKey(bs.cacheTenant(tenant), utils.RATING_PROFILE_PREFIX, "")
+	if historyScribe != nil {
+		var response int
+		go historyScribe.Call("HistoryV1.Record", rpf.GetHistoryRecord(true), &response)
+	}
+	return nil
+}
+
+func (bs *BoltStorage) GetLCR(direction, tenant, category, account, subject string, prefixMatching bool, cacheParam string) (lcr *LCR, err error) {
+	prefix := ""
+	if prefixMatching {
+		prefix = "prefix"
+	}
+	key := utils.ConcatKey(direction, category, account, subject, prefix)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.LCR_PREFIX+key); ok {
+			if x != nil {
+				return x.(*LCR), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	m := map[string]interface{}{
+		"direction": bson.M{"$in": []string{direction, utils.ANY}},
+		"tenant":    bson.M{"$in": []string{tenant, utils.ANY}},
+		"category":  bson.M{"$in": []string{category, utils.ANY}},
+		"account":   bson.M{"$in": []string{account, utils.ANY}},
+		"subject":   bson.M{"$in": []string{subject, utils.ANY}},
+	}
+	if prefixMatching {
+		lcrl := LCRList{}
+		subjectList := utils.SplitPrefix(subject, MIN_PREFIX_MATCH)
+		subjectList = append(subjectList, utils.ANY)
+		m["subject"] = bson.M{"$in": subjectList}
+		err = bs.findAll(ColLcr, m, "-subject", 0, 1, &lcrl)
+		if err == nil && len(lcrl) == 0 {
+			err = utils.ErrNotFound
+		}
+		if len(lcrl) > 0 {
+			lcrl.Sort()
+			lcr = lcrl[0] // best match
+		}
+	} else {
+		lcr = &LCR{}
+		err = bs.findOne(ColLcr, m, lcr)
+	}
+
+	if err != nil {
+		lcr = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.LCR_PREFIX+key, lcr, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetLCR(lcr *LCR) error {
+	err := bs.upsert(ColLcr, boltKey(lcr.Direction, lcr.Tenant, lcr.Category, lcr.Account, lcr.Subject), lcr)
+	cache2go.RemKey(bs.cacheTenant(lcr.Tenant), utils.LCR_PREFIX+lcr.FullID(), "")
+	return err
+}
+
+func (bs *BoltStorage) GetDestinations(tenant, code, name, strategy string, cacheParam string) (result Destinations, err error) {
+	key := utils.ConcatKey(code, name, strategy)
+	if name == "" { // if search by name do not use cache (should be rare)
+		if cacheParam == utils.CACHED {
+			if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.DESTINATION_PREFIX+key); ok {
+				if x != nil {
+					return x.(Destinations), nil
+				}
+				return nil, utils.ErrNotFound
+			}
+			cacheParam = utils.CACHE_SKIP
+		}
+	}
+	result = make([]*Destination, 0)
+	switch strategy {
+	case utils.DestExact:
+		err = bs.findAll(ColDst, map[string]interface{}{"tenant": tenant, "code": code, "name": name}, "", 0, 0, &result)
+	case utils.DestMatching:
+		err = bs.findAll(ColDst, map[string]interface{}{"tenant": tenant, "code": bson.M{"$in": utils.SplitPrefix(code, MIN_PREFIX_MATCH)}}, "-code", 0, 0, &result)
+	default:
+		err = utils.ErrInvalidKey
+	}
+	if err != nil {
+		result = nil
+	}
+	if name == "" { // if search by name do not use cache (should be rare)
+		cache2go.Set(bs.cacheTenant(tenant), utils.DESTINATION_PREFIX+key, result, cacheParam)
+	}
+	return
+}
+
+func (bs *BoltStorage) SetDestination(dest *Destination) (err error) {
+	err = bs.upsert(ColDst, boltKey(dest.Tenant, dest.Code, dest.Name), dest)
+	cache2go.RemPrefixKey(bs.cacheTenant(dest.Tenant), utils.DESTINATION_PREFIX, "")
+	if err == nil && historyScribe != nil {
+		var response int
+		historyScribe.Call("HistoryV1.Record", dest.GetHistoryRecord(false), &response)
+	}
+	return
+}
+
+func (bs *BoltStorage) RemoveDestination(dest *Destination) (err error) {
+	if err = bs.remove(ColDst, boltKey(dest.Tenant, dest.Code, dest.Name)); err != nil {
+		return err
+	}
+	cache2go.RemPrefixKey(bs.cacheTenant(dest.Tenant), utils.DESTINATION_PREFIX, "") // remove all destinations because we don't know all the combinations'
+	return
+}
+
+func (bs *BoltStorage) RemoveDestinations(tenant, code, name string) (err error) {
+	if _, err = bs.removeAll(ColDst, map[string]interface{}{"tenant": tenant, "code": code, "name": name}); err != nil {
+		return err
+	}
+	cache2go.RemPrefixKey(bs.cacheTenant(tenant), utils.DESTINATION_PREFIX, "") // remove all destinations because we don't know all the combinations'
+	return
+}
+
+func (bs *BoltStorage) GetTiming(tenant, name string) (result *Timing, err error) {
+	if tmg := getMetaTiming(tenant, name); tmg != nil {
+		return tmg, nil
+	}
+	result = &Timing{}
+	if err = bs.getOne(ColTmg, boltKey(tenant, name), result); err != nil {
+		result = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) SetTiming(tmg *Timing) (err error) {
+	return bs.upsert(ColTmg, boltKey(tmg.Tenant, tmg.Name), tmg)
+}
+
+func (bs *BoltStorage) GetRate(tenant, name string) (result *Rate, err error) {
+	result = &Rate{}
+	if err = bs.getOne(ColRts, boltKey(tenant, name), result); err != nil {
+		result = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) SetRate(rt *Rate) (err error) {
+	return bs.upsert(ColRts, boltKey(rt.Tenant, rt.Name), rt)
+}
+
+func (bs *BoltStorage) GetDestinationRate(tenant, name string) (result *DestinationRate, err error) {
+	result = &DestinationRate{}
+	if err = bs.getOne(ColDrt, boltKey(tenant, name), result); err != nil {
+		result = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) SetDestinationRate(drt *DestinationRate) (err error) {
+	return bs.upsert(ColDrt, boltKey(drt.Tenant, drt.Name), drt)
+}
+
+func (bs *BoltStorage) GetActionGroup(tenant, name, cacheParam string) (ag *ActionGroup, err error) {
+	key := utils.ConcatKey(tenant, name)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.ACTION_PREFIX+key); ok {
+			if x != nil {
+				return x.(*ActionGroup), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	ag = &ActionGroup{}
+	if err = bs.getOne(ColAct, boltKey(tenant, name), ag); err != nil {
+		ag = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.ACTION_PREFIX+key, ag, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetActionGroup(ag *ActionGroup) error {
+	err := bs.upsert(ColAct, boltKey(ag.Tenant, ag.Name), ag)
+	cache2go.RemKey(bs.cacheTenant(ag.Tenant), utils.ACTION_PREFIX+ag.Name, "")
+	return err
+}
+
+func (bs *BoltStorage) RemoveActionGroup(tenant, name string) error {
+	cache2go.RemKey(bs.cacheTenant(tenant), utils.ACTION_PREFIX+name, "")
+	return bs.remove(ColAct, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) GetSharedGroup(tenant, name, cacheParam string) (sg *SharedGroup, err error) {
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.SHARED_GROUP_PREFIX+name); ok {
+			if x != nil {
+				return x.(*SharedGroup), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	sg = &SharedGroup{}
+	if err = bs.getOne(ColShg, boltKey(tenant, name), sg); err != nil {
+		sg = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.SHARED_GROUP_PREFIX+name, sg, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetSharedGroup(sg *SharedGroup) (err error) {
+	err = bs.upsert(ColShg, boltKey(sg.Tenant, sg.Name), sg)
+	cache2go.RemKey(bs.cacheTenant(sg.Tenant), utils.SHARED_GROUP_PREFIX+sg.Name, "")
+	return err
+}
+
+func (bs *BoltStorage) GetAccount(tenant, name string) (result *Account, err error) {
+	result = new(Account)
+	if err = bs.getOne(ColAcc, boltKey(tenant, name), result); err != nil {
+		result = nil
+	} else {
+		result.snapshotLedger()
+	}
+	return
+}
+
+// SetAccount stores the account and appends its ledger entries in the same transaction
+func (bs *BoltStorage) SetAccount(acc *Account) error {
+	// never override existing account with an empty one
+	// if all balances expired and were cleaned it makes
+	// sense to write empty balance map
+	entries := acc.ledgerEntries(bs.GetAccount)
+	saved := acc // the state changes are published from the caller account
+	if len(acc.BalanceMap) == 0 {
+		if ac, err := bs.GetAccount(acc.Tenant, acc.Name); err == nil && !ac.allBalancesExpired() {
+			entries = nil
+			ac.TriggerIDs = acc.TriggerIDs
+			ac.TriggerRecords = acc.TriggerRecords
+			ac.UnitCounters = acc.UnitCounters
+			ac.AllowNegative = acc.AllowNegative
+			ac.CreditLimit = acc.CreditLimit
+			ac.Disabled = acc.Disabled
+			ac.State = acc.State
+			ac.StateChanges = acc.StateChanges
+			acc = ac
+		}
+	}
+	data, err := bson.Marshal(acc)
+	if err != nil {
+		return err
+	}
+	if err := bs.db.Update(func(tx *bolt.Tx) error {
+		if err := putDoc(tx, ColAcc, boltKey(acc.Tenant, acc.Name), data, true); err != nil {
+			return err
+		}
+		return putLedgerEntries(tx, entries)
+	}); err != nil {
+		return err
+	}
+	saved.publishStateChanges()
+	return nil
+}
+
+func (bs *BoltStorage) RemoveAccount(tenant, name string) error {
+	return bs.remove(ColAcc, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) SetSimpleAccount(sa *SimpleAccount) error {
+	return bs.upsert(ColSac, boltKey(sa.Tenant, sa.Name), sa)
+}
+
+func (bs *BoltStorage) RemoveSimpleAccount(tenant, name string) error {
+	return bs.remove(ColSac, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) GetCdrStatsQueue(tenant, name string) (sq *StatsQueue, err error) {
+	sq = &StatsQueue{}
+	if err = bs.getOne(ColStq, boltKey(tenant, name), sq); err != nil {
+		sq = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) SetCdrStatsQueue(sq *StatsQueue) (err error) {
+	return bs.upsert(ColStq, boltKey(sq.Tenant, sq.Name), sq)
+}
+
+func (bs *BoltStorage) RemoveCdrStatsQueue(tenant, name string) (err error) {
+	return bs.remove(ColStq, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) PushQCDR(qcdr *QCDR) error {
+	qcdr.ID = bson.NewObjectId()
+	return bs.insert(ColQcr, qcdr)
+}
+
+// PopQCDR selects and removes the queued cdrs in the same transaction
+func (bs *BoltStorage) PopQCDR(tenant, name string, fltr map[string]interface{}, limit int) (qcdrs []*QCDR, err error) {
+	if fltr == nil {
+		fltr = make(map[string]interface{})
+	}
+	fltr["tenant"] = tenant
+	fltr["name"] = name
+	qcdrs = make([]*QCDR, 0)
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		docs, err := scan(tx, ColQcr, fltr)
+		if err != nil {
+			return err
+		}
+		sortDocs(docs, "event_time")
+		docs = paginateDocs(docs, 0, limit)
+		if err := unmarshalDocs(docs, &qcdrs); err != nil {
+			return err
+		}
+		return deleteDocs(tx, ColQcr, docKeys(docs))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return qcdrs, nil
+}
+
+func (bs *BoltStorage) RemoveQCDRs(tenant, name string) error {
+	_, err := bs.removeAll(ColQcr, map[string]interface{}{"tenant": tenant, "name": name})
+	return err
+}
+
+func (bs *BoltStorage) GetSubscribers() (result map[string]*SubscriberData, err error) {
+	iter := bs.Iterator(ColPbs, "", nil)
+	result = make(map[string]*SubscriberData)
+	var kv struct {
+		Key   string
+		Value *SubscriberData
+	}
+	for iter.Next(&kv) {
+		result[kv.Key] = kv.Value
+	}
+	err = iter.Close()
+	return
+}
+
+func (bs *BoltStorage) SetSubscriber(key string, sub *SubscriberData) (err error) {
+	return bs.upsert(ColPbs, boltKey(key), &struct {
+		Key   string
+		Value *SubscriberData
+	}{Key: key, Value: sub})
+}
+
+func (bs *BoltStorage) RemoveSubscriber(key string) (err error) {
+	return bs.remove(ColPbs, boltKey(key))
+}
+
+func (bs *BoltStorage) SetUser(up *UserProfile) (err error) {
+	return bs.upsert(ColUsr, boltKey(up.Tenant, up.Name), up)
+}
+
+func (bs *BoltStorage) GetUser(tenant, name string) (up *UserProfile, err error) {
+	up = &UserProfile{}
+	if err = bs.getOne(ColUsr, boltKey(tenant, name), up); err != nil {
+		up = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) RemoveUser(tenant, name string) (err error) {
+	return bs.remove(ColUsr, boltKey(tenant, name))
+}
+
+// AddInvoice numbers and stores the invoice in the same transaction
+func (bs *BoltStorage) AddInvoice(inv *Invoice) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		if inv.Number == 0 {
+			docs, err := scan(tx, ColInv, map[string]interface{}{"tenant": inv.Tenant})
+			if err != nil {
+				return err
+			}
+			sortDocs(docs, "-number")
+			last := &Invoice{}
+			if len(docs) > 0 {
+				if err := bson.Unmarshal(docs[0].raw, last); err != nil {
+					return err
+				}
+			}
+			inv.Number = last.Number + 1
+		}
+		data, err := bson.Marshal(inv)
+		if err != nil {
+			return err
+		}
+		return putDoc(tx, ColInv, boltKey(inv.Tenant, strconv.FormatInt(inv.Number, 10)), data, false)
+	})
+}
+
+func (bs *BoltStorage) GetInvoice(tenant string, number int64) (inv *Invoice, err error) {
+	inv = &Invoice{}
+	if err = bs.getOne(ColInv, boltKey(tenant, strconv.FormatInt(number, 10)), inv); err != nil {
+		inv = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) GetInvoices(tenant, account string, offset, limit int) (invs []*Invoice, err error) {
+	fltr := map[string]interface{}{"tenant": tenant}
+	if account != "" {
+		fltr["account"] = account
+	}
+	err = bs.findAll(ColInv, fltr, "number", offset, limit, &invs)
+	return
+}
+
+// AddLedgerEntries appends the entries in the same transaction
+func (bs *BoltStorage) AddLedgerEntries(entries []*LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return putLedgerEntries(tx, entries)
+	})
+}
+
+func putLedgerEntries(tx *bolt.Tx, entries []*LedgerEntry) error {
+	for _, e := range entries {
+		data, err := bson.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := putDoc(tx, ColBlg, nil, data, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddVoucherBatch stores the batch with its vouchers in the same transaction
+func (bs *BoltStorage) AddVoucherBatch(batch *VoucherBatch, vouchers []*Voucher) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		data, err := bson.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		if err := putDoc(tx, ColVbt, boltKey(batch.Tenant, batch.ID), data, false); err != nil {
+			return err
+		}
+		for _, v := range vouchers {
+			data, err := bson.Marshal(v)
+			if err != nil {
+				return err
+			}
+			if err := putDoc(tx, ColVch, boltKey(v.Tenant, v.PINHash), data, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bs *BoltStorage) SetVoucherBatch(batch *VoucherBatch) error {
+	return bs.upsert(ColVbt, boltKey(batch.Tenant, batch.ID), batch)
+}
+
+func (bs *BoltStorage) GetVoucherBatch(tenant, id string) (batch *VoucherBatch, err error) {
+	batch = &VoucherBatch{}
+	if err = bs.getOne(ColVbt, boltKey(tenant, id), batch); err != nil {
+		batch = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) GetVoucherBatches(tenant string, offset, limit int) (batches []*VoucherBatch, err error) {
+	err = bs.findAll(ColVbt, map[string]interface{}{"tenant": tenant}, "id", offset, limit, &batches)
+	return
+}
+
+func (bs *BoltStorage) SetVoucher(v *Voucher) error {
+	return bs.upsert(ColVch, boltKey(v.Tenant, v.PINHash), v)
+}
+
+// MarkVoucherRedeemed marks the voucher redeemed only if it was not and counts it on the batch in the same transaction
+func (bs *BoltStorage) MarkVoucherRedeemed(v *Voucher) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		stored, batch := &Voucher{}, &VoucherBatch{}
+		if err := getDoc(tx, ColVch, boltKey(v.Tenant, v.PINHash), stored); err != nil {
+			return err
+		}
+		if stored.Redeemed {
+			return utils.ErrVoucherNotRedeemable
+		}
+		if err := getDoc(tx, ColVbt, boltKey(v.Tenant, v.BatchID), batch); err != nil {
+			return err
+		}
+		stored.Redeemed, stored.Account, stored.RedeemedAt = true, v.Account, v.RedeemedAt
+		batch.countRedeemed()
+		vData, err := bson.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		bData, err := bson.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		if err := putDoc(tx, ColVch, boltKey(v.Tenant, v.PINHash), vData, true); err != nil {
+			return err
+		}
+		return putDoc(tx, ColVbt, boltKey(v.Tenant, v.BatchID), bData, true)
+	})
+}
+
+// AddVoucherAttempts adds delta to the attempts of the key in the window in the same transaction,
+// only the last window of the key is kept
+func (bs *BoltStorage) AddVoucherAttempts(tenant, key string, window time.Time, delta int) (count int, err error) {
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		va := &voucherAttempts{}
+		if err := getDoc(tx, ColVat, boltKey(tenant, key), va); err != nil && err != utils.ErrNotFound {
+			return err
+		}
+		if va.Window.After(window) { // a late update of a past window
+			count = va.Count
+			return nil
+		}
+		if !va.Window.Equal(window) {
+			va = &voucherAttempts{Tenant: tenant, Key: key, Window: window}
+		}
+		if va.Count += delta; va.Count < 0 {
+			va.Count = 0
+		}
+		count = va.Count
+		data, err := bson.Marshal(va)
+		if err != nil {
+			return err
+		}
+		return putDoc(tx, ColVat, boltKey(tenant, key), data, true)
+	})
+	return
+}
+
+func (bs *BoltStorage) GetVoucher(tenant, pinHash string) (v *Voucher, err error) {
+	v = &Voucher{}
+	if err = bs.getOne(ColVch, boltKey(tenant, pinHash), v); err != nil {
+		v = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) GetVouchers(tenant, batchID string, offset, limit int) (vouchers []*Voucher, err error) {
+	err = bs.findAll(ColVch, map[string]interface{}{"tenant": tenant, "batch_id": batchID}, "serial", offset, limit, &vouchers)
+	return
+}
+
+func (bs *BoltStorage) GetLedgerEntries(fltr *LedgerFilter, offset, limit int) (entries []*LedgerEntry, err error) {
+	err = bs.findAll(ColBlg, fltr.query(), "$natural", offset, limit, &entries)
+	return
+}
+
+func (bs *BoltStorage) GetAlias(direction, tenant, category, account, subject, context, cacheParam string) (al *Alias, err error) {
+	key := utils.ConcatKey(direction, category, account, subject, context)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.ALIASES_PREFIX+key); ok {
+			if x != nil {
+				return x.(*Alias), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	all := AliasList{}
+	err = bs.findAll(ColAls, map[string]interface{}{
+		"direction": bson.M{"$in": []string{direction, utils.ANY}},
+		"tenant":    bson.M{"$in": []string{tenant, utils.ANY}},
+		"category":  bson.M{"$in": []string{category, utils.ANY}},
+		"account":   bson.M{"$in": []string{account, utils.ANY}},
+		"subject":   bson.M{"$in": []string{subject, utils.ANY}},
+		"context":   context,
+	}, "", 0, 0, &all)
+	if err == nil && len(all) > 0 {
+		all.Sort() // sort by precision
+		al = all[0]
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.ALIASES_PREFIX+key, al, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetAlias(al *Alias) (err error) {
+	err = bs.upsert(ColAls, boltKey(al.Direction, al.Tenant, al.Category, al.Account, al.Subject, al.Context), al)
+	cache2go.RemKey(bs.cacheTenant(al.Tenant), utils.ALIASES_PREFIX+al.FullID(), "")
+	return err
+}
+
+func (bs *BoltStorage) RemoveAlias(direction, tenant, category, account, subject, context string) (err error) {
+	key := utils.ALIASES_PREFIX + utils.ConcatKey(direction, category, account, subject, context)
+	if err = bs.remove(ColAls, boltKey(direction, tenant, category, account, subject, context)); err != nil {
+		return err
+	}
+	cache2go.RemKey(bs.cacheTenant(tenant), key, "")
+	return
+}
+
+func (bs *BoltStorage) GetReverseAlias(tenant, context, target, alias, cacheParam string) (als []*Alias, err error) {
+	key := utils.ConcatKey(context, target, alias)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.ALIASES_PREFIX+key); ok {
+			if x != nil {
+				return x.([]*Alias), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	als = make([]*Alias, 0)
+	if err = bs.findAll(ColAls, map[string]interface{}{"tenant": tenant, "context": context, "index.target": target, "index.alias": alias}, "", 0, 0, &als); err != nil {
+		return nil, err
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.ALIASES_PREFIX+key, als, cacheParam)
+	return
+}
+
+// Adds a single load instance to load history
+func (bs *BoltStorage) AddLoadHistory(ldInst *utils.LoadInstance) error {
+	return bs.insert(ColLht, ldInst)
+}
+
+// Replaces the tenant load instance having the same LoadID
+func (bs *BoltStorage) SetLoadInstance(ldInst *utils.LoadInstance) error {
+	return bs.upsert(ColLht, boltKey(ldInst.Tenant, ldInst.LoadID), ldInst)
+}
+
+// Returns the tenant load instances, the newest first, zero limit for all of them
+func (bs *BoltStorage) GetLoadHistory(tenant string, limit int) (loads []*utils.LoadInstance, err error) {
+	loads = make([]*utils.LoadInstance, 0)
+	err = bs.findAll(ColLht, map[string]interface{}{"tenant": tenant}, "-load_time", 0, limit, &loads)
+	return
+}
+
+func (bs *BoltStorage) SetStagedTpFile(file *StagedTpFile) error {
+	return bs.upsert(ColTps, boltKey(file.Tenant, file.LoadID, file.Name), file)
+}
+
+func (bs *BoltStorage) GetStagedTpFiles(tenant, loadID string) (files []*StagedTpFile, err error) {
+	files = make([]*StagedTpFile, 0)
+	err = bs.findAll(ColTps, map[string]interface{}{"tenant": tenant, "load_id": loadID}, "", 0, 0, &files)
+	return
+}
+
+func (bs *BoltStorage) GetActionTriggers(tenant, name, cacheParam string) (atrg *ActionTriggerGroup, err error) {
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.ACTION_TRIGGER_PREFIX+name); ok {
+			if x != nil {
+				return x.(*ActionTriggerGroup), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	atrg = &ActionTriggerGroup{}
+	if err = bs.getOne(ColAtr, boltKey(tenant, name), atrg); err != nil {
+		atrg = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.ACTION_TRIGGER_PREFIX+name, atrg, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetActionTriggers(atrg *ActionTriggerGroup) (err error) {
+	if len(atrg.ActionTriggers) == 0 {
+		return bs.remove(ColAtr, boltKey(atrg.Tenant, atrg.Name)) // delete the atrg
+	}
+	err = bs.upsert(ColAtr, boltKey(atrg.Tenant, atrg.Name), atrg)
+	cache2go.RemKey(bs.cacheTenant(atrg.Tenant), utils.ACTION_TRIGGER_PREFIX+atrg.Name, "")
+	return err
+}
+
+func (bs *BoltStorage) RemoveActionTriggers(tenant, name string) error {
+	cache2go.RemKey(bs.cacheTenant(tenant), utils.ACTION_TRIGGER_PREFIX+name, "")
+	return bs.remove(ColAtr, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) GetActionPlan(tenant, name, cacheParam string) (apl *ActionPlan, err error) {
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.ACTION_PLAN_PREFIX+name); ok {
+			if x != nil {
+				return x.(*ActionPlan), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	apl = &ActionPlan{}
+	if err = bs.getOne(ColApl, boltKey(tenant, name), apl); err != nil {
+		return nil, err
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.ACTION_PLAN_PREFIX+name, apl, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetActionPlan(apl *ActionPlan) (err error) {
+	cache2go.RemKey(bs.cacheTenant(apl.Tenant), utils.ACTION_PLAN_PREFIX+apl.Name, "")
+	if len(apl.ActionTimings) == 0 {
+		return bs.remove(ColApl, boltKey(apl.Tenant, apl.Name))
+	}
+	return bs.upsert(ColApl, boltKey(apl.Tenant, apl.Name), apl)
+}
+
+func (bs *BoltStorage) GetActionPlanBinding(tenant, account, actionPlan string) (*ActionPlanBinding, error) {
+	apb := &ActionPlanBinding{}
+	if err := bs.getOne(ColApb, boltKey(tenant, account, actionPlan), apb); err != nil {
+		return nil, err
+	}
+	return apb, nil
+}
+
+func (bs *BoltStorage) SetActionPlanBinding(apb *ActionPlanBinding) error {
+	return bs.upsert(ColApb, boltKey(apb.Tenant, apb.Account, apb.ActionPlan), apb)
+}
+
+func (bs *BoltStorage) RemoveActionPlanBindings(tenant, account, actionPlan string) error {
+	_, err := bs.removeAll(ColApb, map[string]interface{}{"tenant": tenant, "account": account, "action_plan": actionPlan})
+	return err
+}
+
+func (bs *BoltStorage) PushTask(t *Task) error {
+	return bs.insert(ColTsk, t)
+}
+
+// PopTask removes and returns the oldest task
+func (bs *BoltStorage) PopTask() (t *Task, err error) {
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ColTsk))
+		if b == nil {
+			return utils.ErrNotFound
+		}
+		k, v := b.Cursor().First()
+		if k == nil {
+			return utils.ErrNotFound
+		}
+		t = &Task{}
+		if err := bson.Unmarshal(v, t); err != nil {
+			return err
+		}
+		return deleteDocs(tx, ColTsk, [][]byte{append([]byte{}, k...)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (bs *BoltStorage) GetDerivedChargers(direction, tenant, category, account, subject, cacheParam string) (dcs utils.DerivedChargers, err error) {
+	key := utils.ConcatKey(direction, category, account, subject)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.DERIVEDCHARGERS_PREFIX+key); ok {
+			if x != nil {
+				return x.(utils.DerivedChargers), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	dcs = make(utils.DerivedChargers, 0)
+	err = bs.findAll(ColDcs, map[string]interface{}{
+		"direction": direction,
+		"tenant":    bson.M{"$in": []string{tenant, utils.ANY}},
+		"category":  bson.M{"$in": []string{category, utils.ANY}},
+		"account":   bson.M{"$in": []string{account, utils.ANY}},
+		"subject":   bson.M{"$in": []string{subject, utils.ANY}},
+	}, "", 0, 0, &dcs)
+	if err != nil {
+		dcs = nil
+	} else {
+		dcs.Sort() // sort by precision
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.DERIVEDCHARGERS_PREFIX+key, dcs, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetDerivedChargers(dcs *utils.DerivedChargerGroup) (err error) {
+	key := utils.ConcatKey(dcs.Direction, dcs.Category, dcs.Account, dcs.Subject)
+	cache2go.RemKey(bs.cacheTenant(dcs.Tenant), utils.DERIVEDCHARGERS_PREFIX+key, "")
+	dbKey := boltKey(dcs.Direction, dcs.Tenant, dcs.Category, dcs.Account, dcs.Subject)
+	if len(dcs.Chargers) == 0 {
+		return bs.remove(ColDcs, dbKey)
+	}
+	return bs.upsert(ColDcs, dbKey, dcs)
+}
+
+func (bs *BoltStorage) SetCdrStats(cs *CdrStats) error {
+	return bs.upsert(ColCrs, boltKey(cs.Tenant, cs.Name), cs)
+}
+
+func (bs *BoltStorage) GetCdrStats(tenant, name string) (cs *CdrStats, err error) {
+	cs = &CdrStats{}
+	err = bs.getOne(ColCrs, boltKey(tenant, name), cs)
+	return
+}
+
+func (bs *BoltStorage) RemoveCdrStats(tenant, name string) (err error) {
+	return bs.remove(ColCrs, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) SetTaxRule(tr *TaxRule) error {
+	return bs.upsert(ColTax, boltKey(tr.Tenant, tr.Name), tr)
+}
+
+// GetTaxRules returns the tenant tax rules, heavier first
+func (bs *BoltStorage) GetTaxRules(tenant string) (trs []*TaxRule, err error) {
+	err = bs.findAll(ColTax, map[string]interface{}{"tenant": tenant}, "-weight,name", 0, 0, &trs)
+	return
+}
+
+func (bs *BoltStorage) GetExchangeRate(tenant, from, to, cacheParam string) (er *ExchangeRate, err error) {
+	key := utils.ConcatKey(from, to)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(bs.cacheTenant(tenant), utils.EXCHANGE_RATE_PREFIX+key); ok {
+			if x != nil {
+				return x.(*ExchangeRate), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	er = &ExchangeRate{}
+	if err = bs.getOne(ColXch, boltKey(tenant, from, to), er); err != nil {
+		er = nil
+	}
+	cache2go.Set(bs.cacheTenant(tenant), utils.EXCHANGE_RATE_PREFIX+key, er, cacheParam)
+	return
+}
+
+func (bs *BoltStorage) SetExchangeRate(er *ExchangeRate) error {
+	err := bs.upsert(ColXch, boltKey(er.Tenant, er.From, er.To), er)
+	cache2go.RemKey(bs.cacheTenant(er.Tenant), utils.EXCHANGE_RATE_PREFIX+utils.ConcatKey(er.From, er.To), "")
+	return err
+}
+
+func (bs *BoltStorage) RemoveTaxRule(tenant, name string) (err error) {
+	return bs.remove(ColTax, boltKey(tenant, name))
+}
+
+func (bs *BoltStorage) SetStructVersion(v *StructVersion) (err error) {
+	return bs.insert(ColVer, v)
+}
+
+func (bs *BoltStorage) GetStructVersion() (rsv *StructVersion, err error) {
+	rsv = &StructVersion{}
+	err = bs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ColVer))
+		if b == nil {
+			return utils.ErrNotFound
+		}
+		_, v := b.Cursor().Last() // latest document
+		if v == nil {
+			return utils.ErrNotFound
+		}
+		return bson.Unmarshal(v, rsv)
+	})
+	if err != nil {
+		rsv = nil
+	}
+	return
+}
+
+func (bs *BoltStorage) GetResourceLimit(id string, skipCache bool, transactionID string) (rl *ResourceLimit, err error) {
+	rl = &ResourceLimit{}
+	if err = bs.getOne(ColRL, boltKey(id), rl); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (bs *BoltStorage) SetResourceLimit(rl *ResourceLimit, transactionID string) (err error) {
+	return bs.upsert(ColRL, boltKey(rl.ID), rl)
+}
+
+func (bs *BoltStorage) RemoveResourceLimit(id string, transactionID string) error {
+	return nil
+}
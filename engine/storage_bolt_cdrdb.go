@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+	"github.com/boltdb/bolt"
+	"github.com/globalsign/mgo/bson"
+)
+
+// SetSMCost appends the cost like the mongo insert, the duplicates are checked by the cdr server
+func (bs *BoltStorage) SetSMCost(smc *SMCost) error {
+	data, err := bson.Marshal(smc)
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return putDoc(tx, utils.TBLSMCosts, nil, data, false)
+	})
+}
+
+func (bs *BoltStorage) GetSMCosts(uniqueid, runid, originHost, originIDPrefix string) (smcs []*SMCost, err error) {
+	var all []*SMCost
+	if err = bs.findAll(utils.TBLSMCosts, nil, "", 0, 0, &all); err != nil {
+		return nil, err
+	}
+	for _, smc := range all {
+		if smc.RunID != runid {
+			continue
+		}
+		if originIDPrefix != "" {
+			if smc.OriginHost != originHost || !strings.HasPrefix(smc.OriginID, originIDPrefix) {
+				continue
+			}
+		} else if smc.UniqueID != uniqueid {
+			continue
+		}
+		smcs = append(smcs, smc)
+	}
+	return smcs, nil
+}
+
+func (bs *BoltStorage) SetCDR(cdr *CDR, update bool) (err error) {
+	if cdr.OrderID == 0 {
+		cdr.OrderID = time.Now().UnixNano()
+	}
+	data, err := bson.Marshal(cdr)
+	if err != nil {
+		return err
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return putDoc(tx, utils.TBLCDRS, boltKey(cdr.UniqueID, cdr.RunID), data, update)
+	})
+}
+
+// GetCDRs applies the filter on the decoded cdrs, semantics follow the mongo implementation
+func (bs *BoltStorage) GetCDRs(qryFltr *utils.CDRsFilter, remove bool) ([]*CDR, int64, error) {
+	matcher, err := newCdrMatcher(qryFltr)
+	if err != nil {
+		return nil, 0, err
+	}
+	var cdrs []*CDR
+	var keys [][]byte
+	collect := func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utils.TBLCDRS))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			cdr := &CDR{}
+			if err := bson.Unmarshal(v, cdr); err != nil {
+				return err
+			}
+			if matcher.match(cdr) && matcher.matchTimestamps(v) {
+				cdrs = append(cdrs, cdr)
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+	}
+	if remove {
+		err = bs.db.Update(func(tx *bolt.Tx) error {
+			if err := collect(tx); err != nil {
+				return err
+			}
+			return deleteDocs(tx, utils.TBLCDRS, keys)
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, int64(len(keys)), nil
+	}
+	if err = bs.db.View(collect); err != nil {
+		return nil, 0, err
+	}
+	// pages follow the order id like in the other storages
+	sort.SliceStable(cdrs, func(i, j int) bool { return cdrs[i].OrderID < cdrs[j].OrderID })
+	if qryFltr.Paginator.Offset != nil {
+		if *qryFltr.Paginator.Offset >= len(cdrs) {
+			cdrs = cdrs[:0]
+		} else {
+			cdrs = cdrs[*qryFltr.Paginator.Offset:]
+		}
+	}
+	if qryFltr.Paginator.Limit != nil && *qryFltr.Paginator.Limit < len(cdrs) {
+		cdrs = cdrs[:*qryFltr.Paginator.Limit]
+	}
+	if qryFltr.Count {
+		return nil, int64(len(cdrs)), nil
+	}
+	if cdrs == nil {
+		cdrs = make([]*CDR, 0)
+	}
+	return cdrs, 0, nil
+}
+
+type cdrMatcher struct {
+	fltr                         *utils.CDRsFilter
+	minPDD, maxPDD               *time.Duration
+	minUsage, maxUsage           *time.Duration
+	minCost, maxCost, equalsCost *dec.Dec
+}
+
+func newCdrMatcher(qryFltr *utils.CDRsFilter) (*cdrMatcher, error) {
+	cm := &cdrMatcher{fltr: qryFltr}
+	for _, d := range []struct {
+		value string
+		dest  **time.Duration
+	}{
+		{qryFltr.MinPDD, &cm.minPDD},
+		{qryFltr.MaxPDD, &cm.maxPDD},
+		{qryFltr.MinUsage, &cm.minUsage},
+		{qryFltr.MaxUsage, &cm.maxUsage},
+	} {
+		if len(d.value) == 0 {
+			continue
+		}
+		parsed, err := utils.ParseDurationWithSecs(d.value)
+		if err != nil {
+			return nil, err
+		}
+		*d.dest = &parsed
+	}
+	if qryFltr.MinCost != nil {
+		cm.minCost = dec.NewFloat(*qryFltr.MinCost)
+		if qryFltr.MaxCost != nil && !(*qryFltr.MinCost == 0.0 && *qryFltr.MaxCost == -1.0) { // Special case when we want to skip errors
+			cm.maxCost = dec.NewFloat(*qryFltr.MaxCost)
+		}
+	} else if qryFltr.MaxCost != nil {
+		if *qryFltr.MaxCost == -1.0 { // Non-rated CDRs
+			cm.equalsCost = dec.New()
+		} else {
+			cm.maxCost = dec.NewFloat(*qryFltr.MaxCost)
+		}
+	}
+	return cm, nil
+}
+
+func inFilterList(value string, in, notIn []string) bool {
+	if len(in) != 0 && !utils.IsSliceMember(in, value) {
+		return false
+	}
+	return len(notIn) == 0 || !utils.IsSliceMember(notIn, value)
+}
+
+func inTimeInterval(value time.Time, start, end *time.Time) bool {
+	if start != nil && value.Before(*start) {
+		return false
+	}
+	return end == nil || value.Before(*end)
+}
+
+func inDurationInterval(value time.Duration, min, max *time.Duration) bool {
+	if min != nil && value < *min {
+		return false
+	}
+	return max == nil || value < *max
+}
+
+func (cm *cdrMatcher) match(cdr *CDR) bool {
+	f := cm.fltr
+	if !inFilterList(cdr.UniqueID, f.UniqueIDs, f.NotUniqueIDs) ||
+		!inFilterList(cdr.RunID, f.RunIDs, f.NotRunIDs) ||
+		!inFilterList(cdr.ToR, f.ToRs, f.NotToRs) ||
+		!inFilterList(cdr.OriginHost, f.OriginHosts, f.NotOriginHosts) ||
+		!inFilterList(cdr.Source, f.Sources, f.NotSources) ||
+		!inFilterList(cdr.RequestType, f.RequestTypes, f.NotRequestTypes) ||
+		!inFilterList(cdr.Direction, f.Directions, f.NotDirections) ||
+		!inFilterList(cdr.Tenant, f.Tenants, f.NotTenants) ||
+		!inFilterList(cdr.Category, f.Categories, f.NotCategories) ||
+		!inFilterList(cdr.Account, f.Accounts, f.NotAccounts) ||
+		!inFilterList(cdr.Subject, f.Subjects, f.NotSubjects) ||
+		!inFilterList(cdr.Supplier, f.Suppliers, f.NotSuppliers) ||
+		!inFilterList(cdr.DisconnectCause, f.DisconnectCauses, f.NotDisconnectCauses) {
+		return false
+	}
+	if f.OrderIDStart != nil && cdr.OrderID < *f.OrderIDStart {
+		return false
+	}
+	if f.OrderIDEnd != nil && cdr.OrderID >= *f.OrderIDEnd {
+		return false
+	}
+	if !inTimeInterval(cdr.SetupTime, f.SetupTimeStart, f.SetupTimeEnd) ||
+		!inTimeInterval(cdr.AnswerTime, f.AnswerTimeStart, f.AnswerTimeEnd) ||
+		!inDurationInterval(cdr.Usage, cm.minUsage, cm.maxUsage) ||
+		!inDurationInterval(cdr.PDD, cm.minPDD, cm.maxPDD) {
+		return false
+	}
+	if !cm.matchDestination(cdr.Destination) || !cm.matchExtraFields(cdr.ExtraFields) {
+		return false
+	}
+	return cm.matchCost(cdr.Cost)
+}
+
+// matchTimestamps filters on the createdat and updatedat fields of the stored document, missing fields do not match the interval like in mongo
+func (cm *cdrMatcher) matchTimestamps(raw []byte) bool {
+	f := cm.fltr
+	if f.CreatedAtStart == nil && f.CreatedAtEnd == nil && f.UpdatedAtStart == nil && f.UpdatedAtEnd == nil {
+		return true
+	}
+	var ts struct {
+		CreatedAt *time.Time `bson:"createdat"`
+		UpdatedAt *time.Time `bson:"updatedat"`
+	}
+	if err := bson.Unmarshal(raw, &ts); err != nil {
+		return false
+	}
+	for _, interval := range []struct {
+		value      *time.Time
+		start, end *time.Time
+	}{
+		{ts.CreatedAt, f.CreatedAtStart, f.CreatedAtEnd},
+		{ts.UpdatedAt, f.UpdatedAtStart, f.UpdatedAtEnd},
+	} {
+		if interval.start == nil && interval.end == nil {
+			continue
+		}
+		if interval.value == nil || !inTimeInterval(*interval.value, interval.start, interval.end) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cm *cdrMatcher) matchDestination(destination string) bool {
+	if len(cm.fltr.DestinationPrefixes) != 0 {
+		found := false
+		for _, prefix := range cm.fltr.DestinationPrefixes {
+			if len(prefix) != 0 && strings.HasPrefix(destination, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, prefix := range cm.fltr.NotDestinationPrefixes {
+		if len(prefix) != 0 && strings.HasPrefix(destination, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cm *cdrMatcher) matchExtraFields(extraFields map[string]string) bool {
+	if len(cm.fltr.ExtraFields) != 0 {
+		found := false
+		for field, value := range cm.fltr.ExtraFields {
+			if fv, has := extraFields[field]; has && fv == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for field, value := range cm.fltr.NotExtraFields {
+		if fv, has := extraFields[field]; has && fv == value {
+			return false
+		}
+	}
+	return true
+}
+
+func (cm *cdrMatcher) matchCost(cost *dec.Dec) bool {
+	if cm.minCost == nil && cm.maxCost == nil && cm.equalsCost == nil {
+		return true
+	}
+	if cost == nil {
+		return false
+	}
+	if cm.equalsCost != nil {
+		return cost.Cmp(cm.equalsCost) == 0
+	}
+	if cm.minCost != nil && cost.Cmp(cm.minCost) < 0 {
+		return false
+	}
+	return cm.maxCost == nil || cost.Cmp(cm.maxCost) < 0
+}
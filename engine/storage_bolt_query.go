@@ -0,0 +1,347 @@
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// boltDoc is a stored document together with its bucket key
+type boltDoc struct {
+	key []byte
+	raw []byte
+	doc bson.M
+}
+
+func docKeys(docs []*boltDoc) [][]byte {
+	keys := make([][]byte, len(docs))
+	for i, d := range docs {
+		keys[i] = d.key
+	}
+	return keys
+}
+
+// normalizeFilter drops empty string values (see filter) and passes the rest
+// through bson so the values compare with the ones decoded from storage
+func normalizeFilter(fltr map[string]interface{}) (bson.M, error) {
+	if len(fltr) == 0 {
+		return bson.M{}, nil
+	}
+	data, err := bson.Marshal(filter(bson.M(fltr)))
+	if err != nil {
+		return nil, err
+	}
+	normalized := bson.M{}
+	if err := bson.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// lookupField returns the value(s) found at the dotted path inside the document
+func lookupField(doc bson.M, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case bson.M:
+			var found bool
+			if current, found = value[part]; !found {
+				return nil, false
+			}
+		case []interface{}: // collect the field from every array element
+			var values []interface{}
+			for _, item := range value {
+				if m, ok := item.(bson.M); ok {
+					if v, found := m[part]; found {
+						values = append(values, v)
+					}
+				}
+			}
+			if len(values) == 0 {
+				return nil, false
+			}
+			current = values
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// matchFilter checks the document against a mongo like filter
+// supporting plain equality and the $in, $nin, $ne, $gt, $gte, $lt and $lte operators
+func matchFilter(doc bson.M, fltr bson.M) bool {
+	for field, cond := range fltr {
+		value, found := lookupField(doc, field)
+		if ops, isOps := cond.(bson.M); isOps && isOperatorDoc(ops) {
+			for op, arg := range ops {
+				if !matchOperator(value, found, op, arg) {
+					return false
+				}
+			}
+			continue
+		}
+		if !found {
+			if cond != nil {
+				return false
+			}
+			continue
+		}
+		if !matchEqual(value, cond) {
+			return false
+		}
+	}
+	return true
+}
+
+func isOperatorDoc(m bson.M) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchEqual follows mongo semantics where an array field matches if any of its elements does
+func matchEqual(value, cond interface{}) bool {
+	if values, isArray := value.([]interface{}); isArray {
+		if _, condIsArray := cond.([]interface{}); !condIsArray {
+			for _, v := range values {
+				if valuesEqual(v, cond) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return valuesEqual(value, cond)
+}
+
+func matchOperator(value interface{}, found bool, op string, arg interface{}) bool {
+	switch op {
+	case "$in":
+		if !found {
+			return false
+		}
+		for _, a := range toSlice(arg) {
+			if matchEqual(value, a) {
+				return true
+			}
+		}
+		return false
+	case "$nin":
+		return !matchOperator(value, found, "$in", arg)
+	case "$ne":
+		return !found || !matchEqual(value, arg)
+	case "$gt", "$gte", "$lt", "$lte":
+		if !found {
+			return false
+		}
+		cmp, ok := compareValues(value, arg)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "$gt":
+			return cmp > 0
+		case "$gte":
+			return cmp >= 0
+		case "$lt":
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	}
+	return false
+}
+
+func toSlice(arg interface{}) []interface{} {
+	if s, ok := arg.([]interface{}); ok {
+		return s
+	}
+	return []interface{}{arg}
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if cmp, ok := compareValues(a, b); ok {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(x interface{}) (float64, bool) {
+	switch v := x.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// compareValues compares two bson scalar values, returns false if they are not comparable
+func compareValues(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(va, vb), true
+	case time.Time:
+		vb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case va.Before(vb):
+			return -1, true
+		case va.After(vb):
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		vb, ok := b.(bool)
+		if !ok || va != vb {
+			return 0, false
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sortDocs orders the documents using a mongo like sort spec: $natural, -$natural or comma separated field and -field keys
+func sortDocs(docs []*boltDoc, spec string) {
+	if spec == "" || spec == "$natural" {
+		return // bucket keys are already in natural order
+	}
+	if spec == "-$natural" {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+		return
+	}
+	keys := strings.Split(spec, ",")
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, key := range keys {
+			desc := strings.HasPrefix(key, "-")
+			field := strings.TrimPrefix(key, "-")
+			vi, foundi := lookupField(docs[i].doc, field)
+			vj, foundj := lookupField(docs[j].doc, field)
+			if !foundi || !foundj {
+				if foundi == foundj {
+					continue
+				}
+				if desc {
+					return foundi
+				}
+				return foundj
+			}
+			cmp, _ := compareValues(vi, vj)
+			if cmp == 0 {
+				continue
+			}
+			if desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+// unmarshalDocs decodes the raw documents into the slice pointed by out
+func unmarshalDocs(docs []*boltDoc, out interface{}) error {
+	slicev := reflect.ValueOf(out)
+	if slicev.Kind() != reflect.Ptr || slicev.Elem().Kind() != reflect.Slice {
+		panic("out argument must be a slice address")
+	}
+	slicev = slicev.Elem()
+	slicev.Set(slicev.Slice(0, 0))
+	elemt := slicev.Type().Elem()
+	for _, d := range docs {
+		var elemp reflect.Value
+		if elemt.Kind() == reflect.Ptr {
+			elemp = reflect.New(elemt.Elem())
+		} else {
+			elemp = reflect.New(elemt)
+		}
+		if err := bson.Unmarshal(d.raw, elemp.Interface()); err != nil {
+			return err
+		}
+		if elemt.Kind() == reflect.Ptr {
+			slicev = reflect.Append(slicev, elemp)
+		} else {
+			slicev = reflect.Append(slicev, elemp.Elem())
+		}
+	}
+	reflect.ValueOf(out).Elem().Set(slicev)
+	return nil
+}
+
+// boltIterator walks over a snapshot of the documents matched by a query
+type boltIterator struct {
+	docs  []*boltDoc
+	index int
+	err   error
+}
+
+func (bi *boltIterator) All(result interface{}) error {
+	if bi.err != nil {
+		return bi.err
+	}
+	bi.err = unmarshalDocs(bi.docs[bi.index:], result)
+	bi.index = len(bi.docs)
+	return bi.err
+}
+
+func (bi *boltIterator) Close() error {
+	return bi.err
+}
+
+func (bi *boltIterator) Done() bool {
+	return bi.err != nil || bi.index >= len(bi.docs)
+}
+
+func (bi *boltIterator) Err() error {
+	return bi.err
+}
+
+func (bi *boltIterator) Next(result interface{}) bool {
+	if bi.Done() {
+		return false
+	}
+	if bi.err = bson.Unmarshal(bi.docs[bi.index].raw, result); bi.err != nil {
+		return false
+	}
+	bi.index++
+	return true
+}
+
+func (bi *boltIterator) Timeout() bool {
+	return false
+}
@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+	"github.com/boltdb/bolt"
+	"github.com/globalsign/mgo/bson"
+)
+
+func newTestBoltStorage(t *testing.T, storageType string) (*BoltStorage, func()) {
+	dir, err := ioutil.TempDir("", "accurate_bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := NewBoltStorage(path.Join(dir, "test.db"), storageType, nil, nil, 10)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return bs, func() {
+		bs.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltStorageDestinations(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanup()
+	for _, code := range []string{"0257", "0256", "0723"} {
+		if err := bs.SetDestination(&Destination{Tenant: "bolt", Name: "NAT", Code: code}); err != nil {
+			t.Fatal("error storing destination: ", err)
+		}
+	}
+	// overwrite should keep the original position
+	if err := bs.SetDestination(&Destination{Tenant: "bolt", Name: "NAT", Code: "0257"}); err != nil {
+		t.Fatal("error storing destination: ", err)
+	}
+	dests, err := bs.GetDestinations("bolt", "", "NAT", utils.DestExact, utils.CACHE_SKIP)
+	if err != nil || len(dests) != 3 ||
+		dests[0].Code != "0257" || dests[1].Code != "0256" || dests[2].Code != "0723" {
+		t.Errorf("bad destinations back: %+v, %v", dests, err)
+	}
+	dests, err = bs.GetDestinations("bolt", "0256789", "", utils.DestMatching, utils.CACHE_SKIP)
+	if err != nil || len(dests) != 1 || dests[0].Code != "0256" {
+		t.Errorf("bad matching destinations: %+v, %v", dests, err)
+	}
+	if err := bs.RemoveDestination(&Destination{Tenant: "bolt", Name: "NAT", Code: "0256"}); err != nil {
+		t.Error("error removing destination: ", err)
+	}
+	if count, err := bs.Count(ColDst); err != nil || count != 2 {
+		t.Error("wrong destination count: ", count, err)
+	}
+}
+
+func TestBoltStorageRatingProfilePrefix(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanup()
+	for _, subject := range []string{"10", "1001", "100"} {
+		if err := bs.SetRatingProfile(&RatingProfile{Direction: utils.OUT, Tenant: "bolt", Category: "call", Subject: subject}); err != nil {
+			t.Fatal("error storing rating profile: ", err)
+		}
+	}
+	rp, err := bs.GetRatingProfile(utils.OUT, "bolt", "call", "100234", true, utils.CACHE_SKIP)
+	if err != nil || rp.Subject != "100" {
+		t.Errorf("bad prefix match: %+v, %v", rp, err)
+	}
+	if _, err := bs.GetRatingProfile(utils.OUT, "bolt", "call", "2", false, utils.CACHE_SKIP); err != utils.ErrNotFound {
+		t.Error("expected not found, got: ", err)
+	}
+}
+
+func TestBoltStorageAccountRemoveTenant(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.DataDB)
+	defer cleanup()
+	if err := bs.SetAccount(&Account{Tenant: "bolt", Name: "acc1", BalanceMap: map[string]Balances{utils.MONETARY: Balances{&Balance{Value: dec.NewFloat(10)}}}}); err != nil {
+		t.Fatal("error storing account: ", err)
+	}
+	if err := bs.SetAccount(&Account{Tenant: "other", Name: "acc1"}); err != nil {
+		t.Fatal("error storing account: ", err)
+	}
+	if acc, err := bs.GetAccount("bolt", "acc1"); err != nil || len(acc.BalanceMap[utils.MONETARY]) != 1 {
+		t.Errorf("bad account: %+v, %v", acc, err)
+	}
+	if err := bs.RemoveTenant("bolt", utils.DataDB); err != nil {
+		t.Error("error removing tenant: ", err)
+	}
+	if _, err := bs.GetAccount("bolt", "acc1"); err != utils.ErrNotFound {
+		t.Error("account not removed: ", err)
+	}
+	if _, err := bs.GetAccount("other", "acc1"); err != nil {
+		t.Error("wrong account removed: ", err)
+	}
+}
+
+func TestBoltStorageIndexes(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.DataDB)
+	defer cleanup()
+	if err := bs.AddLedgerEntries([]*LedgerEntry{
+		&LedgerEntry{Tenant: "bolt", Account: "acc1", Cause: LEDGER_DEBIT, Delta: dec.NewFloat(-1)},
+		&LedgerEntry{Tenant: "bolt", Account: "acc2", Cause: LEDGER_ACTION, Delta: dec.NewFloat(2)},
+		&LedgerEntry{Tenant: "bolt", Account: "acc1", Cause: LEDGER_ACTION, Delta: dec.NewFloat(3)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := bs.GetLedgerEntries(&LedgerFilter{Tenant: "bolt", Account: "acc1"}, 0, 0); err != nil || len(entries) != 2 || entries[0].Cause != LEDGER_DEBIT {
+		t.Errorf("bad account entries: %s, %v", utils.ToIJSON(entries), err)
+	}
+	if entries, err := bs.GetLedgerEntries(&LedgerFilter{Tenant: "bolt", Cause: LEDGER_ACTION}, 0, 0); err != nil || len(entries) != 2 || entries[0].Account != "acc2" {
+		t.Errorf("bad cause entries: %s, %v", utils.ToIJSON(entries), err)
+	}
+
+	for _, apb := range []*ActionPlanBinding{{"bolt", "acc1", "AP1"}, {"bolt", "acc1", "AP2"}, {"bolt", "acc2", "AP1"}} {
+		if err := bs.SetActionPlanBinding(apb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bs.RemoveActionPlanBindings("bolt", "acc1", "AP1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.GetActionPlanBinding("bolt", "acc1", "AP1"); err != utils.ErrNotFound {
+		t.Error("binding not removed: ", err)
+	}
+	bs.db.View(func(tx *bolt.Tx) error {
+		if seqKeys, indexed := indexedSeqKeys(tx, ColApb, bson.M{"tenant": "bolt", "account": "acc1"}); !indexed || len(seqKeys) != 1 {
+			t.Errorf("bad indexed bindings: %d, %v", len(seqKeys), indexed)
+		}
+		if keys := tx.Bucket(boltKeysBucket(ColApb)).Stats().KeyN; keys != 2 {
+			t.Error("unique key not removed: ", keys)
+		}
+		return nil
+	})
+
+	// the files written before the seqs and index buckets get them when opened
+	if err := bs.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltSeqsBucket(ColApb)); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(boltIndexBucket(ColBlg, []string{"tenant", "cause"}))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.EnsureIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := bs.GetLedgerEntries(&LedgerFilter{Tenant: "bolt", Cause: LEDGER_ACTION}, 0, 0); err != nil || len(entries) != 2 {
+		t.Errorf("bad rebuilt cause entries: %s, %v", utils.ToIJSON(entries), err)
+	}
+	if err := bs.RemoveActionPlanBindings("bolt", "acc1", "AP2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.SetActionPlanBinding(&ActionPlanBinding{"bolt", "acc1", "AP2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.GetActionPlanBinding("bolt", "acc1", "AP2"); err != nil {
+		t.Error("binding not stored again: ", err)
+	}
+}
+
+func TestBoltStorageTasks(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanup()
+	for _, uuid := range []string{"1", "2", "3"} {
+		if err := bs.PushTask(&Task{UUID: uuid}); err != nil {
+			t.Fatal("error pushing task: ", err)
+		}
+	}
+	for _, uuid := range []string{"1", "2", "3"} {
+		if task, err := bs.PopTask(); err != nil || task.UUID != uuid {
+			t.Errorf("expected task %s, got %+v, %v", uuid, task, err)
+		}
+	}
+	if _, err := bs.PopTask(); err != utils.ErrNotFound {
+		t.Error("expected empty queue: ", err)
+	}
+}
+
+func TestBoltStorageTaxRulesOrder(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.TariffPlanDB)
+	defer cleanup()
+	for _, tr := range []*TaxRule{
+		&TaxRule{Tenant: "bolt", Name: "C", Weight: 10},
+		&TaxRule{Tenant: "bolt", Name: "B", Weight: 20},
+		&TaxRule{Tenant: "bolt", Name: "A", Weight: 10},
+	} {
+		if err := bs.SetTaxRule(tr); err != nil {
+			t.Fatal("error storing tax rule: ", err)
+		}
+	}
+	trs, err := bs.GetTaxRules("bolt")
+	if err != nil || len(trs) != 3 || trs[0].Name != "B" || trs[1].Name != "A" || trs[2].Name != "C" {
+		t.Errorf("expected weight then name order, got: %s, %v", utils.ToIJSON(trs), err)
+	}
+}
+
+func TestBoltStorageQCDRs(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.DataDB)
+	defer cleanup()
+	now := time.Now()
+	for i := 3; i > 0; i-- {
+		if err := bs.PushQCDR(&QCDR{Tenant: "bolt", Name: "q1", EventTime: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal("error pushing qcdr: ", err)
+		}
+	}
+	qcdrs, err := bs.PopQCDR("bolt", "q1", map[string]interface{}{"event_time": map[string]interface{}{"$lte": now.Add(2 * time.Second)}}, 10)
+	if err != nil || len(qcdrs) != 2 || qcdrs[0].EventTime.After(qcdrs[1].EventTime) {
+		t.Errorf("bad qcdrs: %+v, %v", qcdrs, err)
+	}
+	if count, err := bs.Count(ColQcr); err != nil || count != 1 {
+		t.Error("wrong qcdr count: ", count, err)
+	}
+}
+
+func TestBoltStorageCDRs(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.CdrDB)
+	defer cleanup()
+	cdrs := []*CDR{
+		&CDR{UniqueID: "1", RunID: utils.META_DEFAULT, Tenant: "bolt", Destination: "4986517174963", Usage: 10 * time.Second, Cost: dec.NewFloat(1)},
+		&CDR{UniqueID: "2", RunID: utils.META_DEFAULT, Tenant: "bolt", Destination: "4986517174964", Usage: 20 * time.Second, Cost: dec.NewFloat(2)},
+		&CDR{UniqueID: "3", RunID: utils.META_DEFAULT, Tenant: "other", Destination: "0723", Usage: 30 * time.Second},
+	}
+	for _, cdr := range cdrs {
+		if err := bs.SetCDR(cdr, false); err != nil {
+			t.Fatal("error storing cdr: ", err)
+		}
+	}
+	if err := bs.SetCDR(cdrs[0], false); err != utils.ErrExists {
+		t.Error("expected duplicate error, got: ", err)
+	}
+	if err := bs.SetCDR(cdrs[0], true); err != nil {
+		t.Error("error updating cdr: ", err)
+	}
+	if result, _, err := bs.GetCDRs(&utils.CDRsFilter{Tenants: []string{"bolt"}}, false); err != nil || len(result) != 2 {
+		t.Errorf("bad cdrs: %+v, %v", result, err)
+	}
+	if result, _, err := bs.GetCDRs(&utils.CDRsFilter{DestinationPrefixes: []string{"49"}, MinUsage: "15s"}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "2" {
+		t.Errorf("bad cdrs: %+v, %v", result, err)
+	}
+	if _, count, err := bs.GetCDRs(&utils.CDRsFilter{MinCost: utils.Float64Pointer(1.5), Count: true}, false); err != nil || count != 1 {
+		t.Error("bad cdrs count: ", count, err)
+	}
+	if _, count, err := bs.GetCDRs(&utils.CDRsFilter{NotTenants: []string{"bolt"}}, true); err != nil || count != 1 {
+		t.Error("bad removed count: ", count, err)
+	}
+	if count, err := bs.Count(ColCdr); err != nil || count != 2 {
+		t.Error("wrong cdr count: ", count, err)
+	}
+	// the cdrs carry no creation time so the interval matches nothing, same as mongo
+	createdAt := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	if result, _, err := bs.GetCDRs(&utils.CDRsFilter{CreatedAtStart: &createdAt}, false); err != nil || len(result) != 0 {
+		t.Errorf("bad cdrs: %+v, %v", result, err)
+	}
+}
+
+func TestBoltStorageSMCosts(t *testing.T) {
+	bs, cleanup := newTestBoltStorage(t, utils.CdrDB)
+	defer cleanup()
+	if err := bs.SetSMCost(&SMCost{UniqueID: "1", RunID: utils.META_DEFAULT, OriginHost: "127.0.0.1", OriginID: "abc_1"}); err != nil {
+		t.Fatal("error storing sm cost: ", err)
+	}
+	if smcs, err := bs.GetSMCosts("", utils.META_DEFAULT, "127.0.0.1", "abc"); err != nil || len(smcs) != 1 {
+		t.Errorf("bad sm costs: %+v, %v", smcs, err)
+	}
+	if smcs, err := bs.GetSMCosts("1", utils.META_DEFAULT, "", ""); err != nil || len(smcs) != 1 {
+		t.Errorf("bad sm costs: %+v, %v", smcs, err)
+	}
+	if err := bs.SetSMCost(&SMCost{UniqueID: "1", RunID: utils.META_DEFAULT}); err != nil {
+		t.Error("duplicate sm cost rejected: ", err)
+	}
+	if smcs, err := bs.GetSMCosts("1", utils.META_DEFAULT, "", ""); err != nil || len(smcs) != 2 {
+		t.Errorf("bad sm costs: %+v, %v", smcs, err)
+	}
+}
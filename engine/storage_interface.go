@@ -1,6 +1,10 @@
 package engine
 
-import "github.com/accurateproject/accurate/utils"
+import (
+	"time"
+
+	"github.com/accurateproject/accurate/utils"
+)
 
 type Storage interface {
 	Close()
@@ -13,6 +17,20 @@ type Storage interface {
 	GetByNames(tenant string, names []string, out interface{}, collection string) error
 	PreloadCacheForPrefix(string) error
 	RemoveTenant(tenant string, collections ...string) error
+	GetTenantSnapshot(tenant string, collections ...string) (*TenantSnapshot, error)
+	RestoreTenantSnapshot(*TenantSnapshot) error // replaces the tenant documents of the snapshot collections
+}
+
+// TenantSnapshot holds the raw documents of a tenant by collection, in their natural order
+type TenantSnapshot struct {
+	Tenant      string
+	Collections map[string][]*SnapshotDoc
+}
+
+// SnapshotDoc is a bson document together with the unique key the bolt storage files it under, mongo ignores the key
+type SnapshotDoc struct {
+	Key  []byte
+	Data []byte
 }
 
 // Interface for storage providers.
@@ -40,6 +58,11 @@ type RatingStorage interface {
 	SetCdrStats(*CdrStats) error
 	GetCdrStats(tenant, name string) (*CdrStats, error)
 	RemoveCdrStats(tenant, name string) error
+	SetTaxRule(*TaxRule) error
+	GetTaxRules(tenant string) ([]*TaxRule, error)
+	RemoveTaxRule(tenant, name string) error
+	GetExchangeRate(tenant, from, to, cacheParam string) (*ExchangeRate, error)
+	SetExchangeRate(*ExchangeRate) error
 	GetDerivedChargers(direction, tenant, category, account, subject, cacheParam string) (utils.DerivedChargers, error)
 	SetDerivedChargers(*utils.DerivedChargerGroup) error
 	GetActionGroup(tenant, name, cacheParam string) (*ActionGroup, error)
@@ -87,6 +110,24 @@ type AccountingStorage interface {
 	SetResourceLimit(*ResourceLimit, string) error
 	RemoveResourceLimit(string, string) error
 	AddLoadHistory(*utils.LoadInstance) error
+	SetLoadInstance(*utils.LoadInstance) error
+	GetLoadHistory(tenant string, limit int) ([]*utils.LoadInstance, error)
+	SetStagedTpFile(*StagedTpFile) error
+	GetStagedTpFiles(tenant, loadID string) ([]*StagedTpFile, error)
+	AddInvoice(*Invoice) error // assigns the next tenant number if missing, never overwrites
+	GetInvoice(tenant string, number int64) (*Invoice, error)
+	GetInvoices(tenant, account string, offset, limit int) ([]*Invoice, error) // in number order, empty account for all
+	AddLedgerEntries([]*LedgerEntry) error
+	GetLedgerEntries(fltr *LedgerFilter, offset, limit int) ([]*LedgerEntry, error) // in insertion order
+	AddVoucherBatch(*VoucherBatch, []*Voucher) error
+	SetVoucherBatch(*VoucherBatch) error
+	GetVoucherBatch(tenant, id string) (*VoucherBatch, error)
+	GetVoucherBatches(tenant string, offset, limit int) ([]*VoucherBatch, error)
+	SetVoucher(*Voucher) error
+	MarkVoucherRedeemed(*Voucher) error                                              // ErrVoucherNotRedeemable if already redeemed, counts the voucher on its batch
+	AddVoucherAttempts(tenant, key string, window time.Time, delta int) (int, error) // returns the attempts of the key in the window
+	GetVoucher(tenant, pinHash string) (*Voucher, error)
+	GetVouchers(tenant, batchID string, offset, limit int) ([]*Voucher, error) // in serial order
 	GetStructVersion() (*StructVersion, error)
 	SetStructVersion(*StructVersion) error
 }
@@ -210,7 +210,7 @@ func (ms *MongoStorage) GetCDRs(qryFltr *utils.CDRsFilter, remove bool) ([]*CDR,
 		}
 		return nil, int64(chgd.Removed), nil
 	}
-	q := col.Find(filters)
+	q := col.Find(filters).Sort(OrderIDLow)
 	if qryFltr.Paginator.Limit != nil {
 		q = q.Limit(*qryFltr.Paginator.Limit)
 	}
@@ -3,6 +3,8 @@ package engine
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/accurateproject/accurate/cache2go"
 	"github.com/accurateproject/accurate/config"
@@ -32,8 +34,16 @@ const (
 	ColQcr = "stat_qcdrs"
 	ColPbs = "pubsub"
 	ColUsr = "users"
+	ColInv = "invoices"
+	ColBlg = "balance_ledger"
+	ColVbt = "voucher_batches"
+	ColVch = "vouchers"
+	ColVat = "voucher_attempts"
 	ColCrs = "cdr_stats"
+	ColTax = "tax_rules"
+	ColXch = "exchange_rates"
 	ColLht = "load_history"
+	ColTps = "tp_staged_files"
 	ColVer = "versions"
 	ColRL  = "resource_limits"
 	ColCdr = "cdrs"
@@ -68,6 +78,12 @@ var (
 	DestinationLow     = strings.ToLower(utils.DESTINATION)
 	CostLow            = strings.ToLower(utils.COST)
 
+	storageCollections = map[string][]string{
+		utils.TariffPlanDB: []string{ColTmg, ColDst, ColRts, ColDrt, ColAct, ColApl, ColTsk, ColApb, ColAtr, ColRpl, ColRpf, ColShg, ColLcr, ColDcs, ColCrs, ColTax, ColXch},
+		utils.DataDB:       []string{ColAcc, ColSac, ColAls, ColStq, ColQcr, ColPbs, ColUsr, ColRL, ColInv, ColBlg, ColVbt, ColVch, ColVat, ColTps},
+		utils.CdrDB:        []string{ColCdr, ColSmc},
+	}
+
 	indexes = map[string]map[string][]mgo.Index{
 		utils.TariffPlanDB: map[string][]mgo.Index{
 			ColDst: []mgo.Index{
@@ -102,6 +118,12 @@ var (
 				mgo.Index{Key: []string{"tenant", "name"}, Unique: true},
 				mgo.Index{Key: []string{"tenant"}},
 			},
+			ColTax: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "name"}, Unique: true},
+			},
+			ColXch: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "from", "to"}, Unique: true},
+			},
 			ColRpf: []mgo.Index{
 				mgo.Index{Key: []string{"direction", "tenant", "category", "subject"}, Unique: true},
 				mgo.Index{Key: []string{"direction", "tenant", "category"}, Unique: false}, // for lcr
@@ -130,6 +152,29 @@ var (
 			ColUsr: []mgo.Index{
 				mgo.Index{Key: []string{"tenant", "name"}, Unique: true},
 			},
+			ColInv: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "number"}, Unique: true},
+				mgo.Index{Key: []string{"tenant", "account"}, Unique: false},
+			},
+			ColBlg: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "account", "balance_uuid"}, Unique: false},
+				mgo.Index{Key: []string{"tenant", "account", "time"}, Unique: false},
+				mgo.Index{Key: []string{"tenant", "cause", "cause_id"}, Unique: false},
+			},
+			ColVbt: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "id"}, Unique: true},
+			},
+			ColVch: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "pin_hash"}, Unique: true},
+				mgo.Index{Key: []string{"tenant", "batch_id", "serial"}, Unique: false},
+			},
+			ColVat: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "key", "window"}, Unique: true},
+				mgo.Index{Key: []string{"window"}, ExpireAfter: 24 * time.Hour}, // the past windows are not needed
+			},
+			ColTps: []mgo.Index{
+				mgo.Index{Key: []string{"tenant", "load_id", "name"}, Unique: true},
+			},
 			ColAls: []mgo.Index{
 				mgo.Index{Key: []string{"direction", "tenant", "category", "account", "subject", "context"}, Unique: true},
 				mgo.Index{Key: []string{"tenant", "context", "index.target", "index.alias"}, Unique: false},
@@ -178,43 +223,101 @@ type MongoStorage struct {
 	loadHistorySize int
 	cdrsIndexes     []string
 	storageType     string
+	swapMux         sync.RWMutex // held by the readers of the swapped collections, see RestoreTenantSnapshot
+}
+
+// swappedCollections are replaced by the tariff plan activation, their readers wait for a swap to finish
+var swappedCollections = utils.NewStringMap(append(append([]string{}, stagedRatingCollections...), stagedAccountingCollections...)...)
+
+// mongoSession releases the swap read lock together with the session
+type mongoSession struct {
+	*mgo.Session
+	release func()
+}
+
+func (s *mongoSession) Close() {
+	s.Session.Close()
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
 }
 
-func (ms *MongoStorage) conn(col string) (*mgo.Session, *mgo.Collection) {
-	sessionCopy := ms.session.Copy()
+// conn returns a session copy and the collection, the session must be closed before opening another one
+func (ms *MongoStorage) conn(col string) (*mongoSession, *mgo.Collection) {
+	sessionCopy := &mongoSession{Session: ms.session.Copy()}
+	if swappedCollections[col] {
+		ms.swapMux.RLock()
+		sessionCopy.release = ms.swapMux.RUnlock
+	}
 	return sessionCopy, sessionCopy.DB(ms.db).C(col)
 }
 
 func (ms *MongoStorage) RemoveTenant(tenant string, collections ...string) error {
-	tpCollections := []string{ColTmg, ColDst, ColRts, ColDrt, ColAct, ColApl, ColTsk, ColApb, ColAtr, ColRpl, ColRpf, ColShg, ColLcr, ColDcs, ColCrs}
-	dataCollections := []string{ColAcc, ColSac, ColAls, ColStq, ColQcr, ColPbs, ColUsr, ColRL}
-	cdrCollections := []string{ColCdr, ColSmc}
-	var colls []string
-	for _, col := range collections {
-		if col == utils.TariffPlanDB {
-			colls = append(colls, tpCollections...)
-			continue
+	for _, collName := range expandCollections(collections) {
+		session, col := ms.conn(collName)
+		defer session.Close()
+		if _, err := col.RemoveAll(bson.M{"tenant": tenant}); err != nil && err != mgo.ErrNotFound {
+			return err
 		}
-		if col == utils.DataDB {
-			colls = append(colls, dataCollections...)
-			continue
+	}
+	return nil
+}
+
+func (ms *MongoStorage) GetTenantSnapshot(tenant string, collections ...string) (*TenantSnapshot, error) {
+	snapshot := &TenantSnapshot{Tenant: tenant, Collections: make(map[string][]*SnapshotDoc)}
+	for _, collName := range expandCollections(collections) {
+		session, col := ms.conn(collName)
+		iter := col.Find(bson.M{"tenant": tenant}).Iter()
+		snapDocs := make([]*SnapshotDoc, 0)
+		var raw bson.Raw
+		for iter.Next(&raw) {
+			snapDocs = append(snapDocs, &SnapshotDoc{Data: append([]byte{}, raw.Data...)})
 		}
-		if col == utils.CdrDB {
-			colls = append(colls, cdrCollections...)
-			continue
+		err := iter.Close()
+		session.Close()
+		if err != nil {
+			return nil, err
 		}
-		colls = append(colls, col)
+		snapshot.Collections[collName] = snapDocs
 	}
-	for _, collName := range colls {
-		session, col := ms.conn(collName)
-		defer session.Close()
-		if _, err := col.RemoveAll(bson.M{"tenant": tenant}); err != nil && err != mgo.ErrNotFound {
+	return snapshot, nil
+}
+
+// RestoreTenantSnapshot replaces the tenant documents collection by collection. There are no transactions so the
+// swap holds the lock taken by the readers of the swapped collections, they never see a half written tenant.
+// A failure leaves the collections half written, the caller restores the previous snapshot.
+func (ms *MongoStorage) RestoreTenantSnapshot(snapshot *TenantSnapshot) error {
+	ms.swapMux.Lock()
+	defer ms.swapMux.Unlock()
+	session := ms.session.Copy()
+	defer session.Close()
+	for collName, snapDocs := range snapshot.Collections {
+		col := session.DB(ms.db).C(collName)
+		if _, err := col.RemoveAll(bson.M{"tenant": snapshot.Tenant}); err != nil && err != mgo.ErrNotFound {
 			return err
 		}
+		for _, doc := range snapDocs {
+			if err := col.Insert(bson.Raw{Kind: 0x03, Data: doc.Data}); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
+// expandCollections replaces the storage type names with the collections they hold
+func expandCollections(collections []string) (colls []string) {
+	for _, col := range collections {
+		if storageColls, found := storageCollections[col]; found {
+			colls = append(colls, storageColls...)
+			continue
+		}
+		colls = append(colls, col)
+	}
+	return
+}
+
 func filter(params bson.M) bson.M {
 	filteredParams := bson.M{}
 	for k, v := range params {
@@ -313,71 +416,11 @@ func (ms *MongoStorage) Flush() (err error) {
 }
 
 func (ms *MongoStorage) PreloadRatingCache() error {
-	if ms.cacheCfg == nil {
-		return nil
-	}
-	if ms.cacheCfg.Destinations != nil && ms.cacheCfg.Destinations.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.DESTINATION_PREFIX); err != nil {
-			return err
-		}
-	}
-
-	if ms.cacheCfg.RatingPlans != nil && ms.cacheCfg.RatingPlans.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.RATING_PLAN_PREFIX); err != nil {
-			return err
-		}
-	}
-
-	if ms.cacheCfg.RatingProfiles != nil && ms.cacheCfg.RatingProfiles.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.RATING_PROFILE_PREFIX); err != nil {
-			return err
-		}
-	}
-	if ms.cacheCfg.Lcr != nil && ms.cacheCfg.Lcr.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.LCR_PREFIX); err != nil {
-			return err
-		}
-	}
-	if ms.cacheCfg.CdrStats != nil && ms.cacheCfg.CdrStats.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.CDR_STATS_PREFIX); err != nil {
-			return err
-		}
-	}
-	if ms.cacheCfg.Actions != nil && ms.cacheCfg.Actions.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.ACTION_PREFIX); err != nil {
-			return err
-		}
-	}
-	if ms.cacheCfg.ActionPlans != nil && ms.cacheCfg.ActionPlans.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.ACTION_PLAN_PREFIX); err != nil {
-			return err
-		}
-	}
-	if ms.cacheCfg.ActionTriggers != nil && ms.cacheCfg.ActionTriggers.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.ACTION_TRIGGER_PREFIX); err != nil {
-			return err
-		}
-	}
-	if ms.cacheCfg.SharedGroups != nil && ms.cacheCfg.SharedGroups.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.SHARED_GROUP_PREFIX); err != nil {
-			return err
-		}
-	}
-	// add more prefixes if needed
-	return nil
+	return preloadRatingCache(ms.cacheCfg, ms)
 }
 
 func (ms *MongoStorage) PreloadAccountingCache() error {
-	if ms.cacheCfg == nil {
-		return nil
-	}
-	if ms.cacheCfg.Aliases != nil && ms.cacheCfg.Aliases.Precache {
-		if err := ms.PreloadCacheForPrefix(utils.ALIASES_PREFIX); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return preloadAccountingCache(ms.cacheCfg, ms)
 }
 
 func (ms *MongoStorage) PreloadCacheForPrefix(prefix string) error {
@@ -385,11 +428,21 @@ func (ms *MongoStorage) PreloadCacheForPrefix(prefix string) error {
 	//FIXME: cache2go.RemPrefixKey(prefix, transID)
 	switch prefix {
 	case utils.RATING_PLAN_PREFIX:
+		// collect the keys first, the getter opens its own session
 		session, col := ms.conn(ColRpl)
-		defer session.Close()
-		iter := col.Find(bson.M{}).Iter()
+		iter := col.Find(bson.M{}).Select(bson.M{"tenant": 1, "name": 1}).Iter()
+		var rpls []RatingPlan
 		var rpl RatingPlan
 		for iter.Next(&rpl) {
+			rpls = append(rpls, rpl)
+		}
+		err := iter.Close()
+		session.Close()
+		if err != nil {
+			cache2go.RollbackTransaction(transID)
+			return err
+		}
+		for _, rpl := range rpls {
 			_, err := ms.GetRatingPlan(rpl.Tenant, rpl.Name, transID)
 			if err != nil {
 				cache2go.RollbackTransaction(transID)
@@ -673,29 +726,9 @@ func (ms *MongoStorage) RemoveDestinations(tenant, code, name string) (err error
 }
 
 func (ms *MongoStorage) GetTiming(tenant, name string) (result *Timing, err error) {
-	if name == utils.ANY {
-		return &Timing{
-			Tenant:    tenant,
-			Name:      utils.ANY,
-			Years:     utils.Years{},
-			Months:    utils.Months{},
-			MonthDays: utils.MonthDays{},
-			WeekDays:  utils.WeekDays{},
-			Time:      "00:00:00",
-		}, nil
-	}
-	if name == utils.ASAP {
-		return &Timing{
-			Tenant:    tenant,
-			Name:      utils.ASAP,
-			Years:     utils.Years{},
-			Months:    utils.Months{},
-			MonthDays: utils.MonthDays{},
-			WeekDays:  utils.WeekDays{},
-			Time:      utils.ASAP,
-		}, nil
+	if tmg := getMetaTiming(tenant, name); tmg != nil {
+		return tmg, nil
 	}
-
 	session, col := ms.conn(ColTmg)
 	defer session.Close()
 	result = &Timing{}
@@ -829,28 +862,45 @@ func (ms *MongoStorage) GetAccount(tenant, name string) (result *Account, err er
 		err = utils.ErrNotFound
 		result = nil
 	}
+	if err == nil {
+		result.snapshotLedger()
+	}
 	return
 }
 
+// SetAccount upserts the account and then inserts its ledger entries, mongo has no transaction over both:
+// if the insert fails the account is saved without the entries and CheckBalanceLedger reports the balances inconsistent
 func (ms *MongoStorage) SetAccount(acc *Account) error {
 	//utils.Logger.Info("Saving acount: ", zap.String("account", utils.ToJSON(acc)))
 	// never override existing account with an empty one
 	// UPDATE: if all balances expired and were cleaned it makes
 	// sense to write empty balance map
+	entries := acc.ledgerEntries(ms.GetAccount)
+	saved := acc // the state changes are published from the caller account
 	if len(acc.BalanceMap) == 0 {
 		if ac, err := ms.GetAccount(acc.Tenant, acc.Name); err == nil && !ac.allBalancesExpired() {
+			entries = nil
 			ac.TriggerIDs = acc.TriggerIDs
 			ac.TriggerRecords = acc.TriggerRecords
 			ac.UnitCounters = acc.UnitCounters
 			ac.AllowNegative = acc.AllowNegative
+			ac.CreditLimit = acc.CreditLimit
 			ac.Disabled = acc.Disabled
+			ac.State = acc.State
+			ac.StateChanges = acc.StateChanges
 			acc = ac
 		}
 	}
 	session, col := ms.conn(ColAcc)
 	defer session.Close()
-	_, err := col.Upsert(bson.M{"tenant": acc.Tenant, "name": acc.Name}, acc)
-	return err
+	if _, err := col.Upsert(bson.M{"tenant": acc.Tenant, "name": acc.Name}, acc); err != nil {
+		return err
+	}
+	if err := ms.AddLedgerEntries(entries); err != nil {
+		return err
+	}
+	saved.publishStateChanges()
+	return nil
 }
 
 func (ms *MongoStorage) RemoveAccount(tenant, name string) error {
@@ -1023,6 +1073,221 @@ func (ms *MongoStorage) RemoveUser(tenant, name string) (err error) {
 	return err
 }
 
+func (ms *MongoStorage) AddInvoice(inv *Invoice) error {
+	session, col := ms.conn(ColInv)
+	defer session.Close()
+	autoNumber := inv.Number == 0
+	for {
+		if autoNumber {
+			last := &Invoice{}
+			if err := col.Find(bson.M{"tenant": inv.Tenant}).Sort("-number").One(last); err != nil && err != mgo.ErrNotFound {
+				return err
+			}
+			inv.Number = last.Number + 1
+		}
+		err := col.Insert(inv)
+		if !mgo.IsDup(err) {
+			return err
+		}
+		if !autoNumber {
+			return utils.ErrExists
+		}
+		// another invoice took the number in the meantime
+	}
+}
+
+func (ms *MongoStorage) GetInvoice(tenant string, number int64) (inv *Invoice, err error) {
+	session, col := ms.conn(ColInv)
+	defer session.Close()
+	inv = &Invoice{}
+	if err = col.Find(bson.M{"tenant": tenant, "number": number}).One(inv); err == mgo.ErrNotFound {
+		err = utils.ErrNotFound
+	}
+	if err != nil {
+		inv = nil
+	}
+	return
+}
+
+func (ms *MongoStorage) GetInvoices(tenant, account string, offset, limit int) (invs []*Invoice, err error) {
+	session, col := ms.conn(ColInv)
+	defer session.Close()
+	fltr := bson.M{"tenant": tenant}
+	if account != "" {
+		fltr["account"] = account
+	}
+	q := col.Find(fltr).Sort("number")
+	if offset > 0 {
+		q = q.Skip(offset)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err = q.All(&invs)
+	return
+}
+
+func (ms *MongoStorage) AddLedgerEntries(entries []*LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	session, col := ms.conn(ColBlg)
+	defer session.Close()
+	docs := make([]interface{}, len(entries))
+	for i, e := range entries {
+		docs[i] = e
+	}
+	return col.Insert(docs...)
+}
+
+func (ms *MongoStorage) GetLedgerEntries(fltr *LedgerFilter, offset, limit int) (entries []*LedgerEntry, err error) {
+	session, col := ms.conn(ColBlg)
+	defer session.Close()
+	q := col.Find(fltr.query()).Sort("_id")
+	if offset > 0 {
+		q = q.Skip(offset)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err = q.All(&entries)
+	return
+}
+
+// AddVoucherBatch inserts the batch with its vouchers, nothing is kept if the batch or a pin exists
+func (ms *MongoStorage) AddVoucherBatch(batch *VoucherBatch, vouchers []*Voucher) error {
+	session, col := ms.conn(ColVbt)
+	defer session.Close()
+	if err := col.Insert(batch); err != nil {
+		if mgo.IsDup(err) {
+			return utils.ErrExists
+		}
+		return err
+	}
+	docs := make([]interface{}, len(vouchers))
+	for i, v := range vouchers {
+		docs[i] = v
+	}
+	vchCol := session.DB(ms.db).C(ColVch)
+	if err := vchCol.Insert(docs...); err != nil {
+		vchCol.RemoveAll(bson.M{"tenant": batch.Tenant, "batch_id": batch.ID})
+		col.Remove(bson.M{"tenant": batch.Tenant, "id": batch.ID})
+		if mgo.IsDup(err) {
+			return utils.ErrExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (ms *MongoStorage) SetVoucherBatch(batch *VoucherBatch) error {
+	session, col := ms.conn(ColVbt)
+	defer session.Close()
+	_, err := col.Upsert(bson.M{"tenant": batch.Tenant, "id": batch.ID}, batch)
+	return err
+}
+
+func (ms *MongoStorage) GetVoucherBatch(tenant, id string) (batch *VoucherBatch, err error) {
+	session, col := ms.conn(ColVbt)
+	defer session.Close()
+	batch = &VoucherBatch{}
+	if err = col.Find(bson.M{"tenant": tenant, "id": id}).One(batch); err == mgo.ErrNotFound {
+		err = utils.ErrNotFound
+	}
+	if err != nil {
+		batch = nil
+	}
+	return
+}
+
+func (ms *MongoStorage) GetVoucherBatches(tenant string, offset, limit int) (batches []*VoucherBatch, err error) {
+	session, col := ms.conn(ColVbt)
+	defer session.Close()
+	q := col.Find(bson.M{"tenant": tenant}).Sort("id")
+	if offset > 0 {
+		q = q.Skip(offset)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err = q.All(&batches)
+	return
+}
+
+func (ms *MongoStorage) SetVoucher(v *Voucher) error {
+	session, col := ms.conn(ColVch)
+	defer session.Close()
+	_, err := col.Upsert(bson.M{"tenant": v.Tenant, "pin_hash": v.PINHash}, v)
+	return err
+}
+
+// MarkVoucherRedeemed marks the voucher redeemed only if it was not, then counts it on the batch.
+// A failure after the voucher was marked leaves the batch count short, the voucher is never redeemed twice.
+func (ms *MongoStorage) MarkVoucherRedeemed(v *Voucher) error {
+	session, col := ms.conn(ColVch)
+	defer session.Close()
+	err := col.Update(bson.M{"tenant": v.Tenant, "pin_hash": v.PINHash, "redeemed": false},
+		bson.M{"$set": bson.M{"redeemed": true, "account": v.Account, "redeemed_at": v.RedeemedAt}})
+	if err == mgo.ErrNotFound {
+		return utils.ErrVoucherNotRedeemable
+	}
+	if err != nil {
+		return err
+	}
+	batchCol := session.DB(ms.db).C(ColVbt)
+	batch := &VoucherBatch{}
+	if _, err := batchCol.Find(bson.M{"tenant": v.Tenant, "id": v.BatchID}).Apply(mgo.Change{
+		Update: bson.M{"$inc": bson.M{"redeemed": 1}}, ReturnNew: true}, batch); err != nil {
+		return err
+	}
+	if batch.Redeemed < batch.Size {
+		return nil
+	}
+	batch.setState(VOUCHER_REDEEMED)
+	return batchCol.Update(bson.M{"tenant": v.Tenant, "id": v.BatchID}, bson.M{"$set": bson.M{"state": batch.State, "state_times": batch.StateTimes}})
+}
+
+// AddVoucherAttempts adds delta to the attempts of the key in the window, in one atomic update
+func (ms *MongoStorage) AddVoucherAttempts(tenant, key string, window time.Time, delta int) (int, error) {
+	session, col := ms.conn(ColVat)
+	defer session.Close()
+	va := &voucherAttempts{}
+	change := mgo.Change{Update: bson.M{"$inc": bson.M{"count": delta}}, Upsert: true, ReturnNew: true}
+	q := col.Find(bson.M{"tenant": tenant, "key": key, "window": window})
+	_, err := q.Apply(change, va)
+	if mgo.IsDup(err) { // concurrent first attempts, the other one inserted the window
+		_, err = q.Apply(change, va)
+	}
+	return va.Count, err
+}
+
+func (ms *MongoStorage) GetVoucher(tenant, pinHash string) (v *Voucher, err error) {
+	session, col := ms.conn(ColVch)
+	defer session.Close()
+	v = &Voucher{}
+	if err = col.Find(bson.M{"tenant": tenant, "pin_hash": pinHash}).One(v); err == mgo.ErrNotFound {
+		err = utils.ErrNotFound
+	}
+	if err != nil {
+		v = nil
+	}
+	return
+}
+
+func (ms *MongoStorage) GetVouchers(tenant, batchID string, offset, limit int) (vouchers []*Voucher, err error) {
+	session, col := ms.conn(ColVch)
+	defer session.Close()
+	q := col.Find(bson.M{"tenant": tenant, "batch_id": batchID}).Sort("serial")
+	if offset > 0 {
+		q = q.Skip(offset)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err = q.All(&vouchers)
+	return
+}
+
 func (ms *MongoStorage) GetAlias(direction, tenant, category, account, subject, context, cacheParam string) (al *Alias, err error) {
 	key := utils.ConcatKey(direction, category, account, subject, context)
 	if cacheParam == utils.CACHED {
@@ -1116,6 +1381,41 @@ func (ms *MongoStorage) AddLoadHistory(ldInst *utils.LoadInstance) error {
 	return col.Insert(ldInst)
 }
 
+// Replaces the tenant load instance having the same LoadID
+func (ms *MongoStorage) SetLoadInstance(ldInst *utils.LoadInstance) error {
+	session, col := ms.conn(ColLht)
+	defer session.Close()
+	_, err := col.Upsert(bson.M{"tenant": ldInst.Tenant, "load_id": ldInst.LoadID}, ldInst)
+	return err
+}
+
+// Returns the tenant load instances, the newest first, zero limit for all of them
+func (ms *MongoStorage) GetLoadHistory(tenant string, limit int) (loads []*utils.LoadInstance, err error) {
+	session, col := ms.conn(ColLht)
+	defer session.Close()
+	q := col.Find(bson.M{"tenant": tenant}).Sort("-load_time")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err = q.All(&loads)
+	return
+}
+
+func (ms *MongoStorage) SetStagedTpFile(file *StagedTpFile) error {
+	session, col := ms.conn(ColTps)
+	defer session.Close()
+	_, err := col.Upsert(bson.M{"tenant": file.Tenant, "load_id": file.LoadID, "name": file.Name}, file)
+	return err
+}
+
+func (ms *MongoStorage) GetStagedTpFiles(tenant, loadID string) (files []*StagedTpFile, err error) {
+	session, col := ms.conn(ColTps)
+	defer session.Close()
+	files = make([]*StagedTpFile, 0)
+	err = col.Find(bson.M{"tenant": tenant, "load_id": loadID}).All(&files)
+	return
+}
+
 func (ms *MongoStorage) GetActionTriggers(tenant, name, cacheParam string) (atrg *ActionTriggerGroup, err error) {
 	if cacheParam == utils.CACHED {
 		if x, ok := cache2go.Get(tenant, utils.ACTION_TRIGGER_PREFIX+name); ok {
@@ -1330,6 +1630,62 @@ func (ms *MongoStorage) RemoveCdrStats(tenant, name string) (err error) {
 	return err
 }
 
+func (ms *MongoStorage) SetTaxRule(tr *TaxRule) error {
+	session, col := ms.conn(ColTax)
+	defer session.Close()
+	_, err := col.Upsert(bson.M{"tenant": tr.Tenant, "name": tr.Name}, tr)
+	return err
+}
+
+// GetTaxRules returns the tenant tax rules, heavier first
+func (ms *MongoStorage) GetTaxRules(tenant string) (trs []*TaxRule, err error) {
+	session, col := ms.conn(ColTax)
+	defer session.Close()
+	err = col.Find(bson.M{"tenant": tenant}).Sort("-weight", "name").All(&trs)
+	return
+}
+
+func (ms *MongoStorage) GetExchangeRate(tenant, from, to, cacheParam string) (er *ExchangeRate, err error) {
+	key := utils.ConcatKey(from, to)
+	if cacheParam == utils.CACHED {
+		if x, ok := cache2go.Get(tenant, utils.EXCHANGE_RATE_PREFIX+key); ok {
+			if x != nil {
+				return x.(*ExchangeRate), nil
+			}
+			return nil, utils.ErrNotFound
+		}
+		cacheParam = utils.CACHE_SKIP
+	}
+	session, col := ms.conn(ColXch)
+	defer session.Close()
+	er = &ExchangeRate{}
+	err = col.Find(bson.M{"tenant": tenant, "from": from, "to": to}).One(er)
+	if err == mgo.ErrNotFound {
+		err = utils.ErrNotFound
+		er = nil
+	}
+	cache2go.Set(tenant, utils.EXCHANGE_RATE_PREFIX+key, er, cacheParam)
+	return
+}
+
+func (ms *MongoStorage) SetExchangeRate(er *ExchangeRate) error {
+	session, col := ms.conn(ColXch)
+	defer session.Close()
+	_, err := col.Upsert(bson.M{"tenant": er.Tenant, "from": er.From, "to": er.To}, er)
+	cache2go.RemKey(er.Tenant, utils.EXCHANGE_RATE_PREFIX+utils.ConcatKey(er.From, er.To), "")
+	return err
+}
+
+func (ms *MongoStorage) RemoveTaxRule(tenant, name string) (err error) {
+	session, col := ms.conn(ColTax)
+	defer session.Close()
+	err = col.Remove(bson.M{"tenant": tenant, "name": name})
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
 func (ms *MongoStorage) SetStructVersion(v *StructVersion) (err error) {
 	session, col := ms.conn(ColVer)
 	defer session.Close()
@@ -1348,6 +1704,15 @@ func (ms *MongoStorage) GetStructVersion() (rsv *StructVersion, err error) {
 }
 
 func (ms *MongoStorage) GetResourceLimit(id string, skipCache bool, transactionID string) (rl *ResourceLimit, err error) {
+	session, col := ms.conn(ColRL)
+	defer session.Close()
+	rl = &ResourceLimit{}
+	if err = col.Find(bson.M{"id": id}).One(rl); err != nil {
+		if err == mgo.ErrNotFound {
+			err = utils.ErrNotFound
+		}
+		return nil, err
+	}
 	/*key := utils.ResourceLimitsPrefix + id
 	if !skipCache {
 		if x, ok := cache.Get(key); ok {
@@ -1371,9 +1736,9 @@ func (ms *MongoStorage) GetResourceLimit(id string, skipCache bool, transactionI
 }
 
 func (ms *MongoStorage) SetResourceLimit(rl *ResourceLimit, transactionID string) (err error) {
-	/*session, col := ms.conn(ColRL)
+	session, col := ms.conn(ColRL)
 	defer session.Close()
-	_, err = col.Upsert(bson.M{"id": rl.ID}, rl)*/
+	_, err = col.Upsert(bson.M{"id": rl.ID}, rl)
 	return err
 }
 
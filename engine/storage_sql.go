@@ -0,0 +1,608 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/accurateproject/accurate/utils"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	sqlCdrColumns    = "id, uniqueid, run_id, origin_host, source, origin_id, tor, request_type, direction, tenant, category, account, subject, destination, setup_time, pdd, answer_time, usage, supplier, disconnect_cause, extra_fields, cost_source, cost, cost_details, account_summary, taxes, extra_info"
+	sqlSMCostColumns = "uniqueid, run_id, origin_host, origin_id, cost_source, usage, cost_details"
+	sqlMaxLimit      = "9223372036854775807" // mysql and sqlite do not accept OFFSET without LIMIT
+
+	// sqlite schema mirrors the ones shipped for mysql and postgres in data/storage
+	sqliteCdrsSchema = `
+CREATE TABLE IF NOT EXISTS cdrs (
+ id INTEGER PRIMARY KEY AUTOINCREMENT,
+ uniqueid CHAR(40) NOT NULL,
+ run_id VARCHAR(64) NOT NULL,
+ origin_host VARCHAR(64) NOT NULL,
+ source VARCHAR(64) NOT NULL,
+ origin_id VARCHAR(64) NOT NULL,
+ tor VARCHAR(16) NOT NULL,
+ request_type VARCHAR(24) NOT NULL,
+ direction VARCHAR(8) NOT NULL,
+ tenant VARCHAR(64) NOT NULL,
+ category VARCHAR(32) NOT NULL,
+ account VARCHAR(128) NOT NULL,
+ subject VARCHAR(128) NOT NULL,
+ destination VARCHAR(128) NOT NULL,
+ setup_time TIMESTAMP NOT NULL,
+ pdd NUMERIC(12,9) NOT NULL,
+ answer_time TIMESTAMP NOT NULL,
+ usage NUMERIC(30,9) NOT NULL,
+ supplier VARCHAR(128) NOT NULL,
+ disconnect_cause VARCHAR(64) NOT NULL,
+ extra_fields TEXT NOT NULL,
+ cost_source VARCHAR(64) NOT NULL,
+ cost NUMERIC(20,4) NOT NULL,
+ cost_details TEXT,
+ account_summary TEXT,
+ taxes TEXT,
+ extra_info TEXT,
+ created_at TIMESTAMP NULL,
+ updated_at TIMESTAMP NULL,
+ deleted_at TIMESTAMP NULL,
+ UNIQUE (uniqueid, run_id, origin_id)
+);
+CREATE TABLE IF NOT EXISTS sm_costs (
+ id INTEGER PRIMARY KEY AUTOINCREMENT,
+ uniqueid CHAR(40) NOT NULL,
+ run_id VARCHAR(64) NOT NULL,
+ origin_host VARCHAR(64) NOT NULL,
+ origin_id VARCHAR(64) NOT NULL,
+ cost_source VARCHAR(64) NOT NULL,
+ usage NUMERIC(30,9) NOT NULL,
+ cost_details TEXT,
+ created_at TIMESTAMP NULL,
+ deleted_at TIMESTAMP NULL,
+ UNIQUE (uniqueid, run_id)
+);
+CREATE INDEX IF NOT EXISTS origin_smcost_idx ON sm_costs (origin_host, origin_id);
+`
+)
+
+// NewSQLStorage connects to a mysql, postgres or sqlite cdr database,
+// for sqlite the name is the path of the database file
+func NewSQLStorage(dbType, host, port, name, user, pass string, maxConn, maxIdleConn int) (*SQLStorage, error) {
+	var dsn string
+	switch dbType {
+	case utils.MYSQL:
+		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=true&loc=UTC", user, pass, host, port, name)
+	case utils.POSTGRES:
+		dsn = fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=disable", host, port, name, user, pass)
+	case utils.SQLITE:
+		dsn = name
+		maxConn, maxIdleConn = 1, 1 // writes are serialized anyway, also keeps :memory: databases on a single connection
+	default:
+		return nil, fmt.Errorf("unsupported sql db_type <%s>", dbType)
+	}
+	db, err := sql.Open(dbType, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.SetMaxOpenConns(maxConn)
+	db.SetMaxIdleConns(maxIdleConn)
+	ss := &SQLStorage{db: db, dbType: dbType}
+	if err = ss.EnsureIndexes(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ss, nil
+}
+
+// SQLStorage implements CdrStorage on top of the cdrs and sm_costs tables
+type SQLStorage struct {
+	db     *sql.DB
+	dbType string
+}
+
+func (ss *SQLStorage) Close() {
+	ss.db.Close()
+}
+
+func (ss *SQLStorage) Ping() error {
+	return ss.db.Ping()
+}
+
+func (ss *SQLStorage) Flush() error {
+	for _, tbl := range []string{utils.TBLCDRS, utils.TBLSMCosts} {
+		if _, err := ss.db.Exec("DELETE FROM " + tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates the sqlite tables, mysql and postgres ones are created with the scripts from data/storage
+func (ss *SQLStorage) EnsureIndexes() error {
+	if ss.dbType != utils.SQLITE {
+		return nil
+	}
+	_, err := ss.db.Exec(sqliteCdrsSchema)
+	return err
+}
+
+func (ss *SQLStorage) Count(col string) (count int, err error) {
+	err = ss.db.QueryRow("SELECT COUNT(*) FROM " + col).Scan(&count)
+	return
+}
+
+func (ss *SQLStorage) Iterator(col, sort string, filter map[string]interface{}) Iterator {
+	return sqlIterator{}
+}
+
+// sqlIterator is returned for the document collections the sql storage does not hold
+type sqlIterator struct{}
+
+func (sqlIterator) All(result interface{}) error { return utils.ErrNotImplemented }
+func (sqlIterator) Close() error                 { return nil }
+func (sqlIterator) Done() bool                   { return true }
+func (sqlIterator) Err() error                   { return utils.ErrNotImplemented }
+func (sqlIterator) Next(result interface{}) bool { return false }
+func (sqlIterator) Timeout() bool                { return false }
+
+func (ss *SQLStorage) GetAllPaged(tenant string, out interface{}, collection string, limit, offset int) error {
+	return utils.ErrNotImplemented
+}
+
+func (ss *SQLStorage) GetByNames(tenant string, names []string, out interface{}, collection string) error {
+	return utils.ErrNotImplemented
+}
+
+func (ss *SQLStorage) PreloadCacheForPrefix(string) error {
+	return utils.ErrNotImplemented
+}
+
+// RemoveTenant removes the tenant cdrs, sm_costs have no tenant information
+func (ss *SQLStorage) RemoveTenant(tenant string, collections ...string) error {
+	for _, col := range expandCollections(collections) {
+		if col != utils.TBLCDRS {
+			continue
+		}
+		if _, err := ss.db.Exec("DELETE FROM cdrs WHERE tenant = "+ss.placeholder(1), tenant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ss *SQLStorage) GetTenantSnapshot(tenant string, collections ...string) (*TenantSnapshot, error) {
+	return nil, utils.ErrNotImplemented
+}
+
+func (ss *SQLStorage) RestoreTenantSnapshot(*TenantSnapshot) error {
+	return utils.ErrNotImplemented
+}
+
+// placeholder returns the bind parameter for the n-th (1 based) argument
+func (ss *SQLStorage) placeholder(n int) string {
+	if ss.dbType == utils.POSTGRES {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// column quotes usage, a reserved word in mysql
+func (ss *SQLStorage) column(name string) string {
+	if name == "usage" && ss.dbType == utils.MYSQL {
+		return "`usage`"
+	}
+	return name
+}
+
+func (ss *SQLStorage) columns(names string) string {
+	cols := strings.Split(names, ", ")
+	for i, col := range cols {
+		cols[i] = ss.column(col)
+	}
+	return strings.Join(cols, ", ")
+}
+
+func (ss *SQLStorage) SetSMCost(smc *SMCost) error {
+	costDetails, err := json.Marshal(smc.CostDetails)
+	if err != nil {
+		return err
+	}
+	q := &sqlQuery{ss: ss}
+	_, err = ss.db.Exec(fmt.Sprintf("INSERT INTO sm_costs (%s, created_at) VALUES (%s)", ss.columns(sqlSMCostColumns), q.placeholders(8)),
+		smc.UniqueID, smc.RunID, smc.OriginHost, smc.OriginID, smc.CostSource, smc.Usage, string(costDetails), time.Now().UTC())
+	return sqlDuplicateError(err)
+}
+
+func (ss *SQLStorage) GetSMCosts(uniqueid, runid, originHost, originIDPrefix string) ([]*SMCost, error) {
+	q := &sqlQuery{ss: ss}
+	if originIDPrefix != "" {
+		q.where("origin_id LIKE " + q.arg(likePrefix(originIDPrefix)) + " ESCAPE '!'").
+			where("origin_host = " + q.arg(originHost)).
+			where("run_id = " + q.arg(runid))
+	} else {
+		q.where("uniqueid = " + q.arg(uniqueid)).
+			where("run_id = " + q.arg(runid))
+	}
+	q.where("deleted_at IS NULL")
+	rows, err := ss.db.Query(fmt.Sprintf("SELECT %s FROM sm_costs%s ORDER BY id", ss.columns(sqlSMCostColumns), q.whereClause()), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var smcs []*SMCost
+	for rows.Next() {
+		smc := &SMCost{}
+		var costDetails sql.NullString
+		if err := rows.Scan(&smc.UniqueID, &smc.RunID, &smc.OriginHost, &smc.OriginID, &smc.CostSource, &smc.Usage, &costDetails); err != nil {
+			return nil, err
+		}
+		if costDetails.Valid && costDetails.String != "" {
+			if err := json.Unmarshal([]byte(costDetails.String), &smc.CostDetails); err != nil {
+				return nil, err
+			}
+		}
+		smcs = append(smcs, smc)
+	}
+	return smcs, rows.Err()
+}
+
+func (ss *SQLStorage) SetCDR(cdr *CDR, update bool) error {
+	extraFields := cdr.ExtraFields
+	if extraFields == nil {
+		extraFields = make(map[string]string)
+	}
+	extraFieldsJSON, err := json.Marshal(extraFields)
+	if err != nil {
+		return err
+	}
+	costDetails, err := json.Marshal(cdr.CostDetails)
+	if err != nil {
+		return err
+	}
+	accountSummary, err := json.Marshal(cdr.AccountSummary)
+	if err != nil {
+		return err
+	}
+	taxes, err := json.Marshal(cdr.Taxes)
+	if err != nil {
+		return err
+	}
+	cost := "0" // same as unrated in the filters
+	if cdr.Cost != nil {
+		cost = fmt.Sprintf("%f", cdr.Cost.Big)
+	}
+	now := time.Now().UTC()
+	values := []interface{}{cdr.OriginHost, cdr.Source, cdr.OriginID, cdr.ToR, cdr.RequestType, cdr.Direction, cdr.Tenant, cdr.Category,
+		cdr.Account, cdr.Subject, cdr.Destination, cdr.SetupTime.UTC(), cdr.PDD.Seconds(), cdr.AnswerTime.UTC(), cdr.Usage.Seconds(),
+		cdr.Supplier, cdr.DisconnectCause, string(extraFieldsJSON), cdr.CostSource, cost, string(costDetails), string(accountSummary), string(taxes), cdr.ExtraInfo}
+	if update {
+		q := &sqlQuery{ss: ss}
+		var sets []string
+		for i, col := range strings.Split(sqlCdrColumns, ", ")[3:] { // id, uniqueid and run_id are the key
+			sets = append(sets, ss.column(col)+" = "+q.arg(values[i]))
+		}
+		sets = append(sets, "updated_at = "+q.arg(now))
+		q.where("uniqueid = " + q.arg(cdr.UniqueID)).where("run_id = " + q.arg(cdr.RunID))
+		res, err := ss.db.Exec("UPDATE cdrs SET "+strings.Join(sets, ", ")+q.whereClause(), q.args...)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected > 0 {
+			return err
+		}
+	}
+	q := &sqlQuery{ss: ss}
+	query := fmt.Sprintf("INSERT INTO cdrs (%s, created_at, updated_at) VALUES (%s)", ss.columns(strings.TrimPrefix(sqlCdrColumns, "id, ")), q.placeholders(len(values)+4))
+	args := append(append([]interface{}{cdr.UniqueID, cdr.RunID}, values...), now, now)
+	// the auto increment id is the order id
+	if ss.dbType == utils.POSTGRES { // no LastInsertId support in pq
+		return sqlDuplicateError(ss.db.QueryRow(query+" RETURNING id", args...).Scan(&cdr.OrderID))
+	}
+	res, err := ss.db.Exec(query, args...)
+	if err != nil {
+		return sqlDuplicateError(err)
+	}
+	cdr.OrderID, err = res.LastInsertId()
+	return err
+}
+
+// sqlDuplicateError translates the unique constraint violations of the drivers into utils.ErrExists
+func sqlDuplicateError(err error) error {
+	switch e := err.(type) {
+	case *mysql.MySQLError:
+		if e.Number == 1062 { // ER_DUP_ENTRY
+			return utils.ErrExists
+		}
+	case *pq.Error:
+		if e.Code == "23505" { // unique_violation
+			return utils.ErrExists
+		}
+	case sqlite3.Error:
+		if e.ExtendedCode == sqlite3.ErrConstraintUnique || e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return utils.ErrExists
+		}
+	}
+	return err
+}
+
+// GetCDRs translates the filter into sql, semantics follow the mongo implementation
+func (ss *SQLStorage) GetCDRs(qryFltr *utils.CDRsFilter, remove bool) ([]*CDR, int64, error) {
+	q := &sqlQuery{ss: ss}
+	for _, f := range []struct {
+		column    string
+		in, notIn []string
+	}{
+		{"uniqueid", qryFltr.UniqueIDs, qryFltr.NotUniqueIDs},
+		{"run_id", qryFltr.RunIDs, qryFltr.NotRunIDs},
+		{"origin_host", qryFltr.OriginHosts, qryFltr.NotOriginHosts},
+		{"source", qryFltr.Sources, qryFltr.NotSources},
+		{"tor", qryFltr.ToRs, qryFltr.NotToRs},
+		{"request_type", qryFltr.RequestTypes, qryFltr.NotRequestTypes},
+		{"direction", qryFltr.Directions, qryFltr.NotDirections},
+		{"tenant", qryFltr.Tenants, qryFltr.NotTenants},
+		{"category", qryFltr.Categories, qryFltr.NotCategories},
+		{"account", qryFltr.Accounts, qryFltr.NotAccounts},
+		{"subject", qryFltr.Subjects, qryFltr.NotSubjects},
+		{"supplier", qryFltr.Suppliers, qryFltr.NotSuppliers},
+		{"disconnect_cause", qryFltr.DisconnectCauses, qryFltr.NotDisconnectCauses},
+	} {
+		q.in(f.column, f.in, false).in(f.column, f.notIn, true)
+	}
+	var costs, notCosts []interface{}
+	for _, c := range qryFltr.Costs {
+		costs = append(costs, c)
+	}
+	for _, c := range qryFltr.NotCosts {
+		notCosts = append(notCosts, c)
+	}
+	q.inValues("cost", costs, false).inValues("cost", notCosts, true)
+	if qryFltr.OrderIDStart != nil {
+		q.where("id >= " + q.arg(*qryFltr.OrderIDStart))
+	}
+	if qryFltr.OrderIDEnd != nil {
+		q.where("id < " + q.arg(*qryFltr.OrderIDEnd))
+	}
+	for _, f := range []struct {
+		column     string
+		start, end *time.Time
+	}{
+		{"setup_time", qryFltr.SetupTimeStart, qryFltr.SetupTimeEnd},
+		{"answer_time", qryFltr.AnswerTimeStart, qryFltr.AnswerTimeEnd},
+		{"created_at", qryFltr.CreatedAtStart, qryFltr.CreatedAtEnd},
+		{"updated_at", qryFltr.UpdatedAtStart, qryFltr.UpdatedAtEnd},
+	} {
+		if f.start != nil {
+			q.where(f.column + " >= " + q.arg(f.start.UTC()))
+		}
+		if f.end != nil {
+			q.where(f.column + " < " + q.arg(f.end.UTC()))
+		}
+	}
+	for _, f := range []struct {
+		column, value, op string
+	}{
+		{ss.column("usage"), qryFltr.MinUsage, ">="},
+		{ss.column("usage"), qryFltr.MaxUsage, "<"},
+		{"pdd", qryFltr.MinPDD, ">="},
+		{"pdd", qryFltr.MaxPDD, "<"},
+	} {
+		if len(f.value) == 0 {
+			continue
+		}
+		parsed, err := utils.ParseDurationWithSecs(f.value)
+		if err != nil {
+			return nil, 0, err
+		}
+		q.where(f.column + " " + f.op + " " + q.arg(parsed.Seconds()))
+	}
+	var prefixConds []string
+	for _, prefix := range qryFltr.DestinationPrefixes {
+		if len(prefix) != 0 {
+			prefixConds = append(prefixConds, "destination LIKE "+q.arg(likePrefix(prefix))+" ESCAPE '!'")
+		}
+	}
+	if len(prefixConds) != 0 {
+		q.where("(" + strings.Join(prefixConds, " OR ") + ")")
+	}
+	for _, prefix := range qryFltr.NotDestinationPrefixes {
+		if len(prefix) != 0 {
+			q.where("destination NOT LIKE " + q.arg(likePrefix(prefix)) + " ESCAPE '!'")
+		}
+	}
+	if len(qryFltr.ExtraFields) != 0 {
+		var conds []string
+		for field, value := range qryFltr.ExtraFields {
+			conds = append(conds, q.extraField(field, value))
+		}
+		q.where("(" + strings.Join(conds, " OR ") + ")")
+	}
+	if len(qryFltr.NotExtraFields) != 0 {
+		var conds []string
+		for field, value := range qryFltr.NotExtraFields {
+			conds = append(conds, q.extraField(field, value))
+		}
+		q.where("NOT (" + strings.Join(conds, " OR ") + ")")
+	}
+	if qryFltr.MinCost != nil {
+		if qryFltr.MaxCost == nil {
+			q.where("cost >= " + q.arg(*qryFltr.MinCost))
+		} else if *qryFltr.MinCost == 0.0 && *qryFltr.MaxCost == -1.0 { // Special case when we want to skip errors
+			q.where("cost >= " + q.arg(0.0))
+		} else {
+			q.where("cost >= " + q.arg(*qryFltr.MinCost)).where("cost < " + q.arg(*qryFltr.MaxCost))
+		}
+	} else if qryFltr.MaxCost != nil {
+		if *qryFltr.MaxCost == -1.0 { // Non-rated CDRs
+			q.where("cost = " + q.arg(0.0))
+		} else {
+			q.where("cost < " + q.arg(*qryFltr.MaxCost))
+		}
+	}
+	if !qryFltr.Unscoped {
+		q.where("deleted_at IS NULL")
+	}
+	if remove {
+		res, err := ss.db.Exec("DELETE FROM cdrs"+q.whereClause(), q.args...)
+		if err != nil {
+			return nil, 0, err
+		}
+		removed, err := res.RowsAffected()
+		return nil, removed, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM cdrs%s ORDER BY id", ss.columns(sqlCdrColumns), q.whereClause())
+	if qryFltr.Paginator.Limit != nil {
+		query += " LIMIT " + q.arg(*qryFltr.Paginator.Limit)
+	}
+	if qryFltr.Paginator.Offset != nil {
+		if qryFltr.Paginator.Limit == nil && ss.dbType != utils.POSTGRES {
+			query += " LIMIT " + sqlMaxLimit
+		}
+		query += " OFFSET " + q.arg(*qryFltr.Paginator.Offset)
+	}
+	if qryFltr.Count {
+		var cnt int64
+		if err := ss.db.QueryRow("SELECT COUNT(*) FROM ("+query+") AS paged", q.args...).Scan(&cnt); err != nil {
+			return nil, 0, err
+		}
+		return nil, cnt, nil
+	}
+	rows, err := ss.db.Query(query, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	cdrs := make([]*CDR, 0)
+	for rows.Next() {
+		cdr, err := scanSQLCdr(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		cdrs = append(cdrs, cdr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return cdrs, 0, nil
+}
+
+func scanSQLCdr(rows *sql.Rows) (*CDR, error) {
+	cdr := &CDR{}
+	var pdd, usage float64
+	var extraFields, cost, costDetails, accountSummary, taxes, extraInfo sql.NullString
+	if err := rows.Scan(&cdr.OrderID, &cdr.UniqueID, &cdr.RunID, &cdr.OriginHost, &cdr.Source, &cdr.OriginID, &cdr.ToR, &cdr.RequestType,
+		&cdr.Direction, &cdr.Tenant, &cdr.Category, &cdr.Account, &cdr.Subject, &cdr.Destination, &cdr.SetupTime, &pdd, &cdr.AnswerTime,
+		&usage, &cdr.Supplier, &cdr.DisconnectCause, &extraFields, &cdr.CostSource, &cost, &costDetails, &accountSummary, &taxes, &extraInfo); err != nil {
+		return nil, err
+	}
+	cdr.PDD = time.Duration(pdd * float64(time.Second))
+	cdr.Usage = time.Duration(usage * float64(time.Second))
+	cdr.ExtraInfo = extraInfo.String
+	cdr.ExtraFields = make(map[string]string)
+	if extraFields.Valid && extraFields.String != "" {
+		if err := json.Unmarshal([]byte(extraFields.String), &cdr.ExtraFields); err != nil {
+			return nil, err
+		}
+	}
+	if cost.Valid {
+		if _, err := cdr.GetCost().SetString(cost.String); err != nil {
+			return nil, err
+		}
+	}
+	if costDetails.Valid && costDetails.String != "" {
+		if err := json.Unmarshal([]byte(costDetails.String), &cdr.CostDetails); err != nil {
+			return nil, err
+		}
+	}
+	if accountSummary.Valid && accountSummary.String != "" {
+		if err := json.Unmarshal([]byte(accountSummary.String), &cdr.AccountSummary); err != nil {
+			return nil, err
+		}
+	}
+	if taxes.Valid && taxes.String != "" {
+		if err := json.Unmarshal([]byte(taxes.String), &cdr.Taxes); err != nil {
+			return nil, err
+		}
+	}
+	return cdr, nil
+}
+
+// likePrefix escapes the LIKE wildcards using ! as escape character
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
+
+// sqlQuery collects the where conditions together with their arguments
+type sqlQuery struct {
+	ss    *SQLStorage
+	conds []string
+	args  []interface{}
+}
+
+func (q *sqlQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return q.ss.placeholder(len(q.args))
+}
+
+func (q *sqlQuery) placeholders(n int) string {
+	phs := make([]string, n)
+	for i := range phs {
+		phs[i] = q.ss.placeholder(i + 1)
+	}
+	return strings.Join(phs, ", ")
+}
+
+func (q *sqlQuery) where(cond string) *sqlQuery {
+	q.conds = append(q.conds, cond)
+	return q
+}
+
+func (q *sqlQuery) in(column string, values []string, not bool) *sqlQuery {
+	vals := make([]interface{}, len(values))
+	for i, v := range values {
+		vals[i] = v
+	}
+	return q.inValues(column, vals, not)
+}
+
+func (q *sqlQuery) inValues(column string, values []interface{}, not bool) *sqlQuery {
+	if len(values) == 0 {
+		return q
+	}
+	phs := make([]string, len(values))
+	for i, v := range values {
+		phs[i] = q.arg(v)
+	}
+	op := " IN ("
+	if not {
+		op = " NOT IN ("
+	}
+	return q.where(column + op + strings.Join(phs, ", ") + ")")
+}
+
+// extraField matches a key inside the json encoded extra fields
+func (q *sqlQuery) extraField(field, value string) string {
+	if q.ss.dbType == utils.POSTGRES {
+		content, _ := json.Marshal(map[string]string{field: value})
+		return "CAST(extra_fields AS jsonb) @> CAST(" + q.arg(string(content)) + " AS jsonb)"
+	}
+	// extra fields are written by SetCDR as compact json so the pair can be searched as text
+	key, _ := json.Marshal(field)
+	val, _ := json.Marshal(value)
+	return "extra_fields LIKE " + q.arg("%"+strings.TrimSuffix(likePrefix(string(key)+":"+string(val)), "%")+"%") + " ESCAPE '!'"
+}
+
+func (q *sqlQuery) whereClause() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conds, " AND ")
+}
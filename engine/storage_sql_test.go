@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func newTestSQLStorage(t *testing.T) (*SQLStorage, func()) {
+	dir, err := ioutil.TempDir("", "accurate_sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := NewSQLStorage(utils.SQLITE, "", "", path.Join(dir, "cdrs.db"), "", "", 1, 1)
+	if err == nil {
+		err = ss.EnsureIndexes()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return ss, func() {
+		ss.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQLStorageCDRs(t *testing.T) {
+	ss, cleanup := newTestSQLStorage(t)
+	defer cleanup()
+	answer := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	cdrs := []*CDR{
+		&CDR{UniqueID: "1", RunID: utils.META_DEFAULT, Tenant: "sql", Destination: "4986517174963", AnswerTime: answer, Usage: 10 * time.Second, Cost: dec.NewFloat(1), ExtraFields: map[string]string{"field1": "val1"}},
+		&CDR{UniqueID: "2", RunID: utils.META_DEFAULT, Tenant: "sql", Destination: "4986517174964", AnswerTime: answer.Add(time.Hour), Usage: 20 * time.Second, Cost: dec.NewFloat(2)},
+		&CDR{UniqueID: "3", RunID: utils.META_DEFAULT, Tenant: "other", Destination: "0723_100%", Usage: 30 * time.Second},
+	}
+	for _, cdr := range cdrs {
+		if err := ss.SetCDR(cdr, false); err != nil {
+			t.Fatal("error storing cdr: ", err)
+		}
+	}
+	if err := ss.SetCDR(cdrs[0], false); err != utils.ErrExists {
+		t.Error("expected duplicate error, got: ", err)
+	}
+	cdrs[0].Subject = "updated"
+	if err := ss.SetCDR(cdrs[0], true); err != nil {
+		t.Error("error updating cdr: ", err)
+	}
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{Subjects: []string{"updated"}}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "1" || result[0].Usage != 10*time.Second ||
+		result[0].ExtraFields["field1"] != "val1" || !result[0].AnswerTime.Equal(answer) {
+		t.Errorf("bad updated cdr: %+v, %v", result, err)
+	}
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{Tenants: []string{"sql"}}, false); err != nil || len(result) != 2 {
+		t.Errorf("bad cdrs: %+v, %v", result, err)
+	}
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{DestinationPrefixes: []string{"49"}, MinUsage: "15s"}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "2" {
+		t.Errorf("bad cdrs: %+v, %v", result, err)
+	}
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{DestinationPrefixes: []string{"0723_100%"}}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "3" {
+		t.Errorf("bad escaped prefix: %+v, %v", result, err)
+	}
+	answerEnd := answer.Add(time.Minute)
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{AnswerTimeStart: &answer, AnswerTimeEnd: &answerEnd}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "1" {
+		t.Errorf("bad answer time interval: %+v, %v", result, err)
+	}
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{ExtraFields: map[string]string{"field1": "val1"}}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "1" {
+		t.Errorf("bad extra fields: %+v, %v", result, err)
+	}
+	if result, _, err := ss.GetCDRs(&utils.CDRsFilter{Paginator: utils.Paginator{Limit: utils.IntPointer(1), Offset: utils.IntPointer(1)}}, false); err != nil ||
+		len(result) != 1 || result[0].UniqueID != "2" {
+		t.Errorf("bad pagination: %+v, %v", result, err)
+	}
+	if _, count, err := ss.GetCDRs(&utils.CDRsFilter{MinCost: utils.Float64Pointer(1.5), Count: true}, false); err != nil || count != 1 {
+		t.Error("bad cdrs count: ", count, err)
+	}
+	if _, count, err := ss.GetCDRs(&utils.CDRsFilter{MaxCost: utils.Float64Pointer(-1), Count: true}, false); err != nil || count != 1 {
+		t.Error("bad unrated cdrs count: ", count, err)
+	}
+	if _, count, err := ss.GetCDRs(&utils.CDRsFilter{NotTenants: []string{"sql"}}, true); err != nil || count != 1 {
+		t.Error("bad removed count: ", count, err)
+	}
+	if count, err := ss.Count(utils.TBLCDRS); err != nil || count != 2 {
+		t.Error("wrong cdr count: ", count, err)
+	}
+}
+
+func TestSQLStorageSMCosts(t *testing.T) {
+	ss, cleanup := newTestSQLStorage(t)
+	defer cleanup()
+	if err := ss.SetSMCost(&SMCost{UniqueID: "1", RunID: utils.META_DEFAULT, OriginHost: "127.0.0.1", OriginID: "abc_1", Usage: 10}); err != nil {
+		t.Fatal("error storing sm cost: ", err)
+	}
+	if smcs, err := ss.GetSMCosts("", utils.META_DEFAULT, "127.0.0.1", "abc"); err != nil || len(smcs) != 1 {
+		t.Errorf("bad sm costs: %+v, %v", smcs, err)
+	}
+	if smcs, err := ss.GetSMCosts("1", utils.META_DEFAULT, "", ""); err != nil || len(smcs) != 1 || smcs[0].OriginID != "abc_1" {
+		t.Errorf("bad sm costs: %+v, %v", smcs, err)
+	}
+}
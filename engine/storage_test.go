@@ -123,6 +123,11 @@ func TestStoreInterfaces(t *testing.T) {
 	var _ RatingStorage = mdb
 	var _ AccountingStorage = mdb
 	var _ CdrStorage = mdb
+	bdb := new(BoltStorage)
+	var _ RatingStorage = bdb
+	var _ AccountingStorage = bdb
+	var _ CdrStorage = bdb
+	var _ CdrStorage = new(SQLStorage)
 }
 
 func TestDifferentUuid(t *testing.T) {
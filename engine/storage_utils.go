@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"os"
 	"path"
 
@@ -12,207 +13,281 @@ import (
 
 // Various helpers to deal with database
 
-func ConfigureRatingStorage(host, port, name, user, pass string, cacheCfg *config.Cache, loadHistorySize int) (db RatingStorage, err error) {
-	utils.Logger.Info("Connecting to ratingDB...", zap.String("host", host), zap.String("port", port), zap.String("db", name), zap.String("user", user))
-	db, err = NewMongoStorage(host, port, name, user, pass, utils.TariffPlanDB, nil, cacheCfg, loadHistorySize)
+func ConfigureRatingStorage(dbType, host, port, name, user, pass string, cacheCfg *config.Cache, loadHistorySize int) (db RatingStorage, err error) {
+	utils.Logger.Info("Connecting to ratingDB...", zap.String("type", dbType), zap.String("host", host), zap.String("port", port), zap.String("db", name), zap.String("user", user))
+	switch dbType {
+	case utils.MONGO:
+		db, err = NewMongoStorage(host, port, name, user, pass, utils.TariffPlanDB, nil, cacheCfg, loadHistorySize)
+	case utils.BOLT:
+		db, err = NewBoltStorage(name, utils.TariffPlanDB, nil, cacheCfg, loadHistorySize)
+	default:
+		err = fmt.Errorf("unsupported db_type <%s> for %s", dbType, utils.TariffPlanDB)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return
 }
 
-func ConfigureAccountingStorage(host, port, name, user, pass string, cacheCfg *config.Cache, loadHistorySize int) (db AccountingStorage, err error) {
-	utils.Logger.Info("Connecting to dataDB...", zap.String("host", host), zap.String("port", port), zap.String("db", name), zap.String("user", user))
-	db, err = NewMongoStorage(host, port, name, user, pass, utils.DataDB, nil, cacheCfg, loadHistorySize)
+func ConfigureAccountingStorage(dbType, host, port, name, user, pass string, cacheCfg *config.Cache, loadHistorySize int) (db AccountingStorage, err error) {
+	utils.Logger.Info("Connecting to dataDB...", zap.String("type", dbType), zap.String("host", host), zap.String("port", port), zap.String("db", name), zap.String("user", user))
+	switch dbType {
+	case utils.MONGO:
+		db, err = NewMongoStorage(host, port, name, user, pass, utils.DataDB, nil, cacheCfg, loadHistorySize)
+	case utils.BOLT:
+		db, err = NewBoltStorage(name, utils.DataDB, nil, cacheCfg, loadHistorySize)
+	default:
+		err = fmt.Errorf("unsupported db_type <%s> for %s", dbType, utils.DataDB)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return
 }
 
-func ConfigureCdrStorage(host, port, name, user, pass string, maxConn, maxIdleConn int, cdrsIndexes []string) (db CdrStorage, err error) {
-	utils.Logger.Info("Connecting to cdrDB...", zap.String("host", host), zap.String("port", port), zap.String("db", name), zap.String("user", user))
-	db, err = NewMongoStorage(host, port, name, user, pass, utils.CdrDB, cdrsIndexes, nil, 1)
+func ConfigureCdrStorage(dbType, host, port, name, user, pass string, maxConn, maxIdleConn int, cdrsIndexes []string) (db CdrStorage, err error) {
+	utils.Logger.Info("Connecting to cdrDB...", zap.String("type", dbType), zap.String("host", host), zap.String("port", port), zap.String("db", name), zap.String("user", user))
+	switch dbType {
+	case utils.MONGO:
+		db, err = NewMongoStorage(host, port, name, user, pass, utils.CdrDB, cdrsIndexes, nil, 1)
+	case utils.BOLT:
+		db, err = NewBoltStorage(name, utils.CdrDB, cdrsIndexes, nil, 1)
+	case utils.MYSQL, utils.POSTGRES, utils.SQLITE:
+		db, err = NewSQLStorage(dbType, host, port, name, user, pass, maxConn, maxIdleConn)
+	default:
+		err = fmt.Errorf("unsupported db_type <%s> for %s", dbType, utils.CdrDB)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return
 }
 
-func LoadTariffPlanFromFolder(tpPath, timezone string, ratingDb RatingStorage, accountingDb AccountingStorage) (*TpReader, error) {
-	tpr := NewTpReader(ratingDb, accountingDb, timezone)
+// tpFile is one kind of tariff plan file, the files are loaded in the order of tpFiles
+type tpFile struct {
+	jsonName   string
+	csvName    string
+	newElement func() interface{}
+	load       func(*TpReader, interface{}) error
+	export     func(*TpExporter) ([]interface{}, error) // nil for the data not exported
+}
 
-	if reader, err := os.Open(path.Join(tpPath, utils.DESTINATIONS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpDestination{} }, tpr.LoadDestination); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
+var tpFiles = []*tpFile{
+	&tpFile{utils.DESTINATIONS_JSON, utils.DESTINATIONS_CSV, func() interface{} { return &utils.TpDestination{} }, (*TpReader).LoadDestination, (*TpExporter).ExportDestinations},
+	&tpFile{utils.TIMINGS_JSON, utils.TIMINGS_CSV, func() interface{} { return &utils.TpTiming{} }, (*TpReader).LoadTiming, (*TpExporter).ExportTimings},
+	&tpFile{utils.RATES_JSON, utils.RATES_CSV, func() interface{} { return &utils.TpRate{} }, (*TpReader).LoadRate, (*TpExporter).ExportRates},
+	&tpFile{utils.DESTINATION_RATES_JSON, utils.DESTINATION_RATES_CSV, func() interface{} { return &utils.TpDestinationRate{} }, (*TpReader).LoadDestinationRate, (*TpExporter).ExportDestinationRates},
+	&tpFile{utils.EXCHANGE_RATES_JSON, utils.EXCHANGE_RATES_CSV, func() interface{} { return &utils.TpExchangeRate{} }, (*TpReader).LoadExchangeRate, (*TpExporter).ExportExchangeRates},
+	&tpFile{utils.RATING_PLANS_JSON, utils.RATING_PLANS_CSV, func() interface{} { return &utils.TpRatingPlan{} }, (*TpReader).LoadRatingPlan, (*TpExporter).ExportRatingPlans},
+	&tpFile{utils.RATING_PROFILES_JSON, utils.RATING_PROFILES_CSV, func() interface{} { return &utils.TpRatingProfile{} }, (*TpReader).LoadRatingProfile, (*TpExporter).ExportRatingProfiles},
+	&tpFile{utils.SHARED_GROUPS_JSON, utils.SHARED_GROUPS_CSV, func() interface{} { return &utils.TpSharedGroup{} }, (*TpReader).LoadSharedGroup, (*TpExporter).ExportSharedGroups},
+	&tpFile{utils.LCRS_JSON, utils.LCRS_CSV, func() interface{} { return &utils.TpLcrRule{} }, (*TpReader).LoadLCR, (*TpExporter).ExportLcrs},
+	&tpFile{utils.ACTIONS_JSON, utils.ACTIONS_CSV, func() interface{} { return &utils.TpActionGroup{} }, (*TpReader).LoadActionGroup, (*TpExporter).ExportActionGroups},
+	&tpFile{utils.ACTION_PLANS_JSON, utils.ACTION_PLANS_CSV, func() interface{} { return &utils.TpActionPlan{} }, (*TpReader).LoadActionPlan, (*TpExporter).ExportActionPlans},
+	&tpFile{utils.ACTION_TRIGGERS_JSON, utils.ACTION_TRIGGERS_CSV, func() interface{} { return &utils.TpActionTrigger{} }, (*TpReader).LoadActionTrigger, (*TpExporter).ExportActionTriggers},
+	&tpFile{utils.ACCOUNT_ACTIONS_JSON, utils.ACCOUNT_ACTIONS_CSV, func() interface{} { return &utils.TpAccountAction{} }, (*TpReader).LoadAccountAction, nil},
+	&tpFile{utils.DERIVED_CHARGERS_JSON, utils.DERIVED_CHARGERS_CSV, func() interface{} { return &utils.TpDerivedCharger{} }, (*TpReader).LoadDerivedCharger, (*TpExporter).ExportDerivedChargers},
+	&tpFile{utils.CDR_STATS_JSON, utils.CDR_STATS_CSV, func() interface{} { return &utils.TpCdrStats{} }, (*TpReader).LoadCdrStats, (*TpExporter).ExportCdrStats},
+	&tpFile{utils.TAX_RULES_JSON, utils.TAX_RULES_CSV, func() interface{} { return &utils.TpTaxRule{} }, (*TpReader).LoadTaxRule, (*TpExporter).ExportTaxRules},
+	&tpFile{utils.USERS_JSON, utils.USERS_CSV, func() interface{} { return &utils.TpUser{} }, (*TpReader).LoadUser, (*TpExporter).ExportUsers},
+	&tpFile{utils.ALIASES_JSON, utils.ALIASES_CSV, func() interface{} { return &utils.TpAlias{} }, (*TpReader).LoadAlias, (*TpExporter).ExportAliases},
+	&tpFile{utils.RESOURCE_LIMITS_JSON, utils.RESOURCE_LIMITS_CSV, func() interface{} { return &utils.TpResourceLimit{} }, (*TpReader).LoadResourceLimit, (*TpExporter).ExportResourceLimits},
+}
+
+// LoadTariffPlanFromFolder loads each kind of tariff plan file out of its JSON-lines file, falling back to the csv one
+func LoadTariffPlanFromFolder(tpPath, timezone string, csvSep rune, ratingDb RatingStorage, accountingDb AccountingStorage) (*TpReader, error) {
+	tpr := NewTpReader(ratingDb, accountingDb, timezone)
+	for _, tpf := range tpFiles {
+		load := func(el interface{}) error { return tpf.load(tpr, el) }
+		if fileName, err := readTpFile(tpPath, tpf, csvSep, load); fileName == "" {
+			utils.Logger.Warn(tpf.jsonName, zap.Error(err))
+		} else if err != nil {
 			return nil, err
 		}
-	} else {
-		utils.Logger.Warn(utils.DESTINATIONS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.TIMINGS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpTiming{} }, tpr.LoadTiming); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.TIMINGS_JSON, zap.Error(err))
+	return tpr, nil
+}
+
+// readTpFile passes the elements of the JSON-lines file, or else of the csv one, to the callback.
+// Returns the name of the file read, empty if the folder has none of them.
+func readTpFile(tpPath string, tpf *tpFile, csvSep rune, callback func(interface{}) error) (string, error) {
+	reader, err := os.Open(path.Join(tpPath, tpf.jsonName))
+	if err == nil {
+		defer reader.Close()
+		if err = utils.LoadJSON(reader, tpf.newElement, callback); err != nil {
+			err = fmt.Errorf("%s: %v", tpf.jsonName, err)
+		}
+		return tpf.jsonName, err
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.RATES_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpRate{} }, tpr.LoadRate); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.RATES_JSON, zap.Error(err))
+	if reader, err = os.Open(path.Join(tpPath, tpf.csvName)); err != nil {
+		return "", err
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.DESTINATION_RATES_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpDestinationRate{} }, tpr.LoadDestinationRate); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.DESTINATION_RATES_JSON, zap.Error(err))
+	defer reader.Close()
+	return tpf.csvName, utils.LoadCSV(reader, tpf.csvName, csvSep, tpf.newElement, callback)
+}
+
+// ExportTariffPlanToFolder writes the tariff plan of the tenant in the files read by LoadTariffPlanFromFolder.
+// The format is utils.JSON or utils.CSV, the files of the other format and the ones with no data are removed so the folder loads back the same data.
+// The accounts and the resource limits are not exported.
+func ExportTariffPlanToFolder(tenant, tpPath, format string, csvSep rune, ratingDb RatingStorage, accountingDb AccountingStorage) (map[string]int, error) {
+	if format != utils.JSON && format != utils.CSV {
+		return nil, fmt.Errorf("unsupported tariff plan format %s", format)
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.RATING_PLANS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpRatingPlan{} }, tpr.LoadRatingPlan); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.RATING_PLANS_JSON, zap.Error(err))
+	if err := os.MkdirAll(tpPath, 0755); err != nil {
+		return nil, err
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.RATING_PROFILES_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpRatingProfile{} }, tpr.LoadRatingProfile); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.RATING_PROFILES_JSON, zap.Error(err))
+	tpe := NewTpExporter(ratingDb, accountingDb, tenant)
+	exported := make(map[string]int)
+	for _, tpf := range tpFiles {
+		if tpf.export == nil {
+			continue
+		}
+		elements, err := tpf.export(tpe)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", tpf.jsonName, err)
+		}
+		fileName, otherFileName := tpf.jsonName, tpf.csvName
+		if format == utils.CSV {
+			fileName, otherFileName = tpf.csvName, tpf.jsonName
+		}
+		for _, name := range []string{fileName, otherFileName} {
+			if err := os.Remove(path.Join(tpPath, name)); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		if len(elements) == 0 {
+			continue
+		}
+		if format == utils.CSV {
+			for _, el := range elements {
+				if rp, isRp := el.(*utils.TpRatingPlan); isRp && rp.Derivation != nil {
+					return nil, fmt.Errorf("%s: derived rating plan %s can only be exported as JSON", fileName, rp.Tag)
+				}
+				if rp, isRp := el.(*utils.TpRatingPlan); isRp && rp.Rounding != nil {
+					return nil, fmt.Errorf("%s: rating plan %s with rounding can only be exported as JSON", fileName, rp.Tag)
+				}
+				if dr, isDr := el.(*utils.TpDestinationRate); isDr {
+					for _, b := range dr.Bindings {
+						if b.Rounding != nil {
+							return nil, fmt.Errorf("%s: destination rate %s with rounding can only be exported as JSON", fileName, dr.Tag)
+						}
+					}
+				}
+			}
+		}
+		writer, err := os.Create(path.Join(tpPath, fileName))
+		if err != nil {
+			return nil, err
+		}
+		if format == utils.CSV {
+			err = utils.WriteCSV(writer, fileName, csvSep, elements)
+		} else {
+			err = utils.WriteJSON(writer, elements)
+		}
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+		exported[fileName] = len(elements)
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.SHARED_GROUPS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpSharedGroup{} }, tpr.LoadSharedGroup); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
+	return exported, nil
+}
+
+// getMetaTiming returns the built in timings (*any and *asap) or nil for the others
+func getMetaTiming(tenant, name string) *Timing {
+	if name == utils.ANY {
+		return &Timing{
+			Tenant:    tenant,
+			Name:      utils.ANY,
+			Years:     utils.Years{},
+			Months:    utils.Months{},
+			MonthDays: utils.MonthDays{},
+			WeekDays:  utils.WeekDays{},
+			Time:      "00:00:00",
 		}
-	} else {
-		utils.Logger.Warn(utils.SHARED_GROUPS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.LCRS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpLcrRule{} }, tpr.LoadLCR); err != nil {
-			return nil, err
+	if name == utils.ASAP {
+		return &Timing{
+			Tenant:    tenant,
+			Name:      utils.ASAP,
+			Years:     utils.Years{},
+			Months:    utils.Months{},
+			MonthDays: utils.MonthDays{},
+			WeekDays:  utils.WeekDays{},
+			Time:      utils.ASAP,
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.LCRS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.ACTIONS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpActionGroup{} }, tpr.LoadActionGroup); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.ACTIONS_JSON, zap.Error(err))
+	return nil
+}
+
+func preloadRatingCache(cacheCfg *config.Cache, storage Storage) error {
+	if cacheCfg == nil {
+		return nil
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.ACTION_PLANS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpActionPlan{} }, tpr.LoadActionPlan); err != nil {
-			return nil, err
+	if cacheCfg.Destinations != nil && cacheCfg.Destinations.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.DESTINATION_PREFIX); err != nil {
+			return err
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.ACTION_PLANS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.ACTION_TRIGGERS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpActionTrigger{} }, tpr.LoadActionTrigger); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
+
+	if cacheCfg.RatingPlans != nil && cacheCfg.RatingPlans.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.RATING_PLAN_PREFIX); err != nil {
+			return err
 		}
-	} else {
-		utils.Logger.Warn(utils.ACTION_TRIGGERS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.ACCOUNT_ACTIONS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpAccountAction{} }, tpr.LoadAccountAction); err != nil {
-			return nil, err
-		}
-		if err := reader.Close(); err != nil {
-			return nil, err
+
+	if cacheCfg.RatingProfiles != nil && cacheCfg.RatingProfiles.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.RATING_PROFILE_PREFIX); err != nil {
+			return err
 		}
-	} else {
-		utils.Logger.Warn(utils.ACCOUNT_ACTIONS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.DERIVED_CHARGERS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpDerivedCharger{} }, tpr.LoadDerivedCharger); err != nil {
-			return nil, err
+	if cacheCfg.Lcr != nil && cacheCfg.Lcr.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.LCR_PREFIX); err != nil {
+			return err
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.DERIVED_CHARGERS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.CDR_STATS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpCdrStats{} }, tpr.LoadCdrStats); err != nil {
-			return nil, err
+	if cacheCfg.CdrStats != nil && cacheCfg.CdrStats.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.CDR_STATS_PREFIX); err != nil {
+			return err
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.CDR_STATS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.USERS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpUser{} }, tpr.LoadUser); err != nil {
-			return nil, err
+	if cacheCfg.Actions != nil && cacheCfg.Actions.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.ACTION_PREFIX); err != nil {
+			return err
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
-		}
-	} else {
-		utils.Logger.Warn(utils.USERS_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.ALIASES_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpAlias{} }, tpr.LoadAlias); err != nil {
-			return nil, err
+	if cacheCfg.ActionPlans != nil && cacheCfg.ActionPlans.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.ACTION_PLAN_PREFIX); err != nil {
+			return err
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
+	}
+	if cacheCfg.ActionTriggers != nil && cacheCfg.ActionTriggers.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.ACTION_TRIGGER_PREFIX); err != nil {
+			return err
 		}
-	} else {
-		utils.Logger.Warn(utils.ALIASES_JSON, zap.Error(err))
 	}
-	if reader, err := os.Open(path.Join(tpPath, utils.RESOURCE_LIMITS_JSON)); err == nil {
-		if err := utils.LoadJSON(reader, func() interface{} { return &utils.TpResourceLimit{} }, tpr.LoadResourceLimit); err != nil {
-			return nil, err
+	if cacheCfg.SharedGroups != nil && cacheCfg.SharedGroups.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.SHARED_GROUP_PREFIX); err != nil {
+			return err
 		}
-		if err := reader.Close(); err != nil {
-			return nil, err
+	}
+	// add more prefixes if needed
+	return nil
+}
+
+func preloadAccountingCache(cacheCfg *config.Cache, storage Storage) error {
+	if cacheCfg == nil {
+		return nil
+	}
+	if cacheCfg.Aliases != nil && cacheCfg.Aliases.Precache {
+		if err := storage.PreloadCacheForPrefix(utils.ALIASES_PREFIX); err != nil {
+			return err
 		}
-	} else {
-		utils.Logger.Warn(utils.RESOURCE_LIMITS_JSON, zap.Error(err))
 	}
-	return tpr, nil
+
+	return nil
 }
 
 // Stores one Cost coming from SM
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestLoadTariffPlanFromCSVFolder(t *testing.T) {
+	tpDir, err := ioutil.TempDir("", "csv_tp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tpDir)
+	for fileName, content := range map[string]string{
+		utils.DESTINATIONS_CSV: `#Tenant;Tag;Code
+csvtp;CSV_MOBILE;0723
+csvtp;CSV_MOBILE;0724`,
+		utils.RATES_CSV: `#Tenant;Tag;ConnectFee;Rate;RateUnit;RateIncrement;GroupIntervalStart
+csvtp;R_CSV;0.1;2;60s;60s;0s
+csvtp;R_CSV;0;1;60s;1s;60s`,
+		utils.DESTINATION_RATES_CSV: `csvtp;DR_CSV;;CSV_MOBILE;R_CSV;;`,
+		// the json file is preferred over the csv one
+		utils.RATING_PLANS_JSON: `{"Tenant":"csvtp", "Tag":"RP_CSV", "Bindings":[{"DestinationRatesTag": "DR_CSV", "TimingTag": "*any", "Weight": 10}]}`,
+		utils.RATING_PLANS_CSV:  `csvtp;RP_OTHER;;DR_CSV;*any;10`,
+	} {
+		if err := ioutil.WriteFile(path.Join(tpDir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadTariffPlanFromFolder(tpDir, "UTC", ';', ratingStorage, accountingStorage); err != nil {
+		t.Fatal(err)
+	}
+	rp, err := ratingStorage.GetRatingPlan("csvtp", "RP_CSV", utils.CACHE_SKIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.DRates) != 1 {
+		t.Errorf("bad rating plan: %s", utils.ToIJSON(rp))
+	}
+	if _, err := ratingStorage.GetRatingPlan("csvtp", "RP_OTHER", utils.CACHE_SKIP); err != utils.ErrNotFound {
+		t.Error("csv rating plan should not be loaded: ", err)
+	}
+	if dests, err := ratingStorage.GetDestinations("csvtp", "0724", "CSV_MOBILE", utils.DestExact, utils.CACHE_SKIP); err != nil || len(dests) != 1 {
+		t.Errorf("bad destinations: %v %s", err, utils.ToIJSON(dests))
+	}
+	ioutil.WriteFile(path.Join(tpDir, utils.RATES_CSV), []byte("csvtp;R_CSV;x;2;60s;60s;0s"), 0644)
+	if _, err = LoadTariffPlanFromFolder(tpDir, "UTC", ';', ratingStorage, accountingStorage); err == nil || !strings.HasPrefix(err.Error(), "Rates.csv:1:3: ConnectFee") {
+		t.Error("expected csv position error, got: ", err)
+	}
+}
+
+func TestLoadTutorialTariffPlan(t *testing.T) {
+	tpPath := path.Join("..", "data", "tariffplans", "tutorial")
+	// every csv file is valid, also the ones shadowed by a json file
+	for _, tpf := range tpFiles {
+		reader, err := os.Open(path.Join(tpPath, tpf.csvName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		err = utils.LoadCSV(reader, tpf.csvName, utils.CSV_SEP, tpf.newElement, func(interface{}) error { count++; return nil })
+		reader.Close()
+		if err != nil || count == 0 {
+			t.Errorf("%s: %d elements, %v", tpf.csvName, count, err)
+		}
+	}
+	tmpDir, err := ioutil.TempDir("", "tutorial_tp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tutRatingDb, err := NewIsolatedBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tutRatingDb.Close()
+	tutAccountingDb, err := NewIsolatedBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tutAccountingDb.Close()
+	if _, err := LoadTariffPlanFromFolder(tpPath, "UTC", utils.CSV_SEP, tutRatingDb, tutAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	if rp, err := tutRatingDb.GetRatingPlan("cgrates.org", "RP_RETAIL2", utils.CACHE_SKIP); err != nil || len(rp.Bindings) != 13 || len(rp.Timings) != 5 {
+		t.Errorf("bad rating plan: %v %s", err, utils.ToIJSON(rp))
+	}
+	if _, err := tutAccountingDb.GetAccount("cgrates.org", "1001"); err != nil {
+		t.Error("error getting the tutorial account: ", err)
+	}
+}
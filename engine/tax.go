@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+const (
+	TAX_PERCENT  = "*percent"  // percent of the cdr cost
+	TAX_PER_UNIT = "*per_unit" // fixed amount for each usage unit
+	TAX_COMPOUND = "*compound" // percent of the cdr cost plus the taxes applied before it
+)
+
+// TaxRule is a tariff plan tax applied on the rated cdrs matching its destinations and categories
+type TaxRule struct {
+	Tenant         string          `bson:"tenant"`
+	Name           string          `bson:"name"`
+	DestinationIDs utils.StringMap `bson:"destination_ids"` // empty for all destinations
+	Categories     utils.StringMap `bson:"categories"`      // empty for all categories
+	Type           string          `bson:"type"`            // *percent, *per_unit or *compound
+	Value          *dec.Dec        `bson:"value"`           // the percent or the amount per unit
+	Unit           time.Duration   `bson:"unit"`            // usage unit of the *per_unit taxes
+	ActivationTime time.Time       `bson:"activation_time"`
+	ExpirationTime time.Time       `bson:"expiration_time"` // zero for no expiration
+	ExemptAccounts utils.StringMap `bson:"exempt_accounts"`
+	Weight         float64         `bson:"weight"` // heavier rules are applied first
+}
+
+// CDRTax is one entry of the cdr tax breakdown
+type CDRTax struct {
+	Name   string
+	Type   string
+	Value  *dec.Dec // the percent or the amount per unit of the rule
+	Base   *dec.Dec // the value the percent was applied on or the number of units
+	Amount *dec.Dec
+}
+
+func (tr *TaxRule) isActive(t time.Time) bool {
+	return !t.Before(tr.ActivationTime) && (tr.ExpirationTime.IsZero() || t.Before(tr.ExpirationTime))
+}
+
+func (tr *TaxRule) matches(cdr *CDR, destinationIDs utils.StringMap) bool {
+	if tr.ExemptAccounts[cdr.Account] {
+		return false
+	}
+	if len(tr.Categories) != 0 && !tr.Categories[cdr.Category] {
+		return false
+	}
+	if len(tr.DestinationIDs) == 0 {
+		return true
+	}
+	for destID := range destinationIDs {
+		if tr.DestinationIDs[destID] {
+			return true
+		}
+	}
+	return false
+}
+
+// apply computes the tax for the cdr, taxed holds the amounts of the taxes applied before
+func (tr *TaxRule) apply(cdr *CDR, taxed *dec.Dec) (*CDRTax, error) {
+	tax := &CDRTax{Name: tr.Name, Type: tr.Type, Value: dec.New().Set(tr.Value)}
+	switch tr.Type {
+	case TAX_PERCENT:
+		tax.Base = dec.New().Set(cdr.GetCost())
+	case TAX_COMPOUND:
+		tax.Base = dec.New().Add(cdr.GetCost(), taxed)
+	case TAX_PER_UNIT:
+		if tr.Unit <= 0 {
+			return nil, fmt.Errorf("tax rule %s has no unit", tr.Name)
+		}
+		tax.Base = dec.NewFloat(cdr.Usage.Seconds()).QuoS(dec.NewFloat(tr.Unit.Seconds()))
+		tax.Amount = dec.New().Mul(tax.Base, tr.Value).Round(globalRoundingDecimals)
+		return tax, nil
+	default:
+		return nil, fmt.Errorf("unsupported tax type %s for %s", tr.Type, tr.Name)
+	}
+	tax.Amount = dec.New().Mul(tax.Base, tr.Value)
+	tax.Amount.QuoS(dec.NewVal(100, 0)).Round(globalRoundingDecimals)
+	return tax, nil
+}
+
+// TaxProcessCdr fills the cdr tax breakdown out of the tenant tax rules active at the answer time.
+// Unrated cdrs are left untouched.
+func TaxProcessCdr(cdr *CDR) error {
+	if cdr.GetCost().LtZero() {
+		return nil
+	}
+	rules, err := ratingStorage.GetTaxRules(cdr.Tenant)
+	if err != nil && err != utils.ErrNotFound {
+		return err
+	}
+	cdr.Taxes = nil
+	if len(rules) == 0 {
+		return nil
+	}
+	taxTime := cdr.AnswerTime
+	if taxTime.IsZero() {
+		taxTime = cdr.SetupTime
+	}
+	destinationIDs := make(utils.StringMap)
+	if dests, err := ratingStorage.GetDestinations(cdr.Tenant, cdr.Destination, "", utils.DestMatching, utils.CACHED); err == nil {
+		for _, dest := range dests {
+			destinationIDs[dest.Name] = true
+		}
+	}
+	taxed := dec.New()
+	for _, rule := range rules {
+		if !rule.isActive(taxTime) || !rule.matches(cdr, destinationIDs) {
+			continue
+		}
+		tax, err := rule.apply(cdr, taxed)
+		if err != nil {
+			cdr.Taxes = nil
+			return err
+		}
+		taxed.AddS(tax.Amount)
+		cdr.Taxes = append(cdr.Taxes, tax)
+	}
+	return nil
+}
+
+// GetTaxTotal returns the sum of the cdr tax breakdown
+func (cdr *CDR) GetTaxTotal() *dec.Dec {
+	total := dec.New()
+	for _, tax := range cdr.Taxes {
+		total.AddS(tax.Amount)
+	}
+	return total
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestTaxProcessCdr(t *testing.T) {
+	if err := ratingStorage.SetDestination(&Destination{Tenant: "tax", Code: "4420", Name: "UK_LONDON"}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tr := range []*TaxRule{
+		&TaxRule{Tenant: "tax", Name: "VAT", Type: TAX_PERCENT, Value: dec.NewFloat(20), ActivationTime: start, Weight: 20},
+		&TaxRule{Tenant: "tax", Name: "FEE", Type: TAX_PER_UNIT, Value: dec.NewVal(1, 2), Unit: time.Minute,
+			DestinationIDs: utils.NewStringMap("UK_LONDON"), ActivationTime: start, Weight: 10},
+		&TaxRule{Tenant: "tax", Name: "LOCAL", Type: TAX_COMPOUND, Value: dec.NewFloat(10), Categories: utils.NewStringMap("call"),
+			ExemptAccounts: utils.NewStringMap("exempt"), ActivationTime: start, Weight: 5},
+		&TaxRule{Tenant: "tax", Name: "OLD", Type: TAX_PERCENT, Value: dec.NewFloat(50), ExpirationTime: start},
+	} {
+		if err := ratingStorage.SetTaxRule(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cdr := &CDR{Tenant: "tax", Account: "acc1", Category: "call", Destination: "442012345", AnswerTime: start.Add(time.Hour),
+		Usage: 2 * time.Minute, Cost: dec.NewFloat(10)}
+	if err := TaxProcessCdr(cdr); err != nil {
+		t.Fatal(err)
+	}
+	if len(cdr.Taxes) != 3 || cdr.Taxes[0].Name != "VAT" || cdr.Taxes[1].Name != "FEE" || cdr.Taxes[2].Name != "LOCAL" {
+		t.Fatalf("bad taxes: %s", utils.ToIJSON(cdr.Taxes))
+	}
+	// 20% of 10, 2 minutes at 0.01, 10% of 10 + 2.02
+	if cdr.Taxes[0].Amount.Cmp(dec.NewFloat(2)) != 0 || cdr.Taxes[1].Amount.Cmp(dec.NewVal(2, 2)) != 0 ||
+		cdr.Taxes[2].Amount.Cmp(dec.NewVal(1202, 3)) != 0 {
+		t.Errorf("bad tax amounts: %s", utils.ToIJSON(cdr.Taxes))
+	}
+	if cdr.GetCost().Cmp(dec.NewFloat(10)) != 0 {
+		t.Error("cost changed by taxes: ", cdr.GetCost())
+	}
+
+	exempt := &CDR{Tenant: "tax", Account: "exempt", Category: "call", Destination: "0723", AnswerTime: start.Add(time.Hour),
+		Usage: time.Minute, Cost: dec.NewFloat(5)}
+	if err := TaxProcessCdr(exempt); err != nil {
+		t.Fatal(err)
+	}
+	if len(exempt.Taxes) != 1 || exempt.Taxes[0].Name != "VAT" || exempt.GetTaxTotal().Cmp(dec.NewFloat(1)) != 0 {
+		t.Errorf("bad exempt taxes: %s", utils.ToIJSON(exempt.Taxes))
+	}
+
+	unrated := &CDR{Tenant: "tax", Account: "acc1", Category: "call", AnswerTime: start.Add(time.Hour), Cost: dec.NewVal(-1, 0)}
+	if err := TaxProcessCdr(unrated); err != nil || len(unrated.Taxes) != 0 {
+		t.Errorf("unrated cdr taxed: %s, %v", utils.ToIJSON(unrated.Taxes), err)
+	}
+}
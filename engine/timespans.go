@@ -19,6 +19,7 @@ type TimeSpan struct {
 	DurationIndex                                              time.Duration // the call duration so far till TimeEnd
 	Increments                                                 *Increments
 	MatchedSubject, MatchedPrefix, MatchedDestID, RatingPlanID string
+	Currency                                                   string // currency of the rating plan
 	CompressFactor                                             int
 	ratingInfo                                                 *RatingInfo
 }
@@ -153,6 +154,7 @@ func (ts *TimeSpan) setRatingInfo(rp *RatingInfo) {
 	ts.MatchedPrefix = rp.MatchedPrefix
 	ts.MatchedDestID = rp.MatchedDestID
 	ts.RatingPlanID = rp.RatingPlanID
+	ts.Currency = rp.Currency
 }
 
 func (ts *TimeSpan) createIncrementsSlice() {
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+// MaxTpArchiveSize limits the unpacked size of an uploaded tariff plan archive
+var MaxTpArchiveSize int64 = 256 << 20
+
+// UnpackTariffPlanArchive extracts the zip or tar.gz archive in the folder, the format is told by the file name.
+// Returns the folder holding the tariff plan files: the folder itself or the single directory the archive was made of.
+func UnpackTariffPlanArchive(archive []byte, fileName, folder string) (string, error) {
+	var err error
+	size := MaxTpArchiveSize
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		err = unpackZip(archive, folder, &size)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		err = unpackTarGz(archive, folder, &size)
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s", fileName)
+	}
+	if err != nil {
+		return "", err
+	}
+	tpPath := folder
+	for {
+		entries, err := ioutil.ReadDir(tpPath)
+		if err != nil {
+			return "", err
+		}
+		var dirs []os.FileInfo
+		files := 0
+		for _, entry := range entries {
+			if entry.Name() == "__MACOSX" {
+				continue
+			}
+			if entry.IsDir() {
+				dirs = append(dirs, entry)
+			} else {
+				files++
+			}
+		}
+		if files != 0 || len(dirs) != 1 {
+			return tpPath, nil
+		}
+		tpPath = path.Join(tpPath, dirs[0].Name())
+	}
+}
+
+func unpackZip(archive []byte, folder string, size *int64) error {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() && !zf.FileInfo().IsDir() { // no links
+			continue
+		}
+		dest, err := archiveEntryPath(folder, zf.Name)
+		if err != nil {
+			return err
+		}
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveEntry(dest, r, size)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpackTarGz(archive []byte, folder string, size *int64) error {
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA && hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		dest, err := archiveEntryPath(folder, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeArchiveEntry(dest, tr, size); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveEntryPath keeps the entry inside the folder, the absolute and the parent paths are refused
+func archiveEntryPath(folder, name string) (string, error) {
+	name = filepath.ToSlash(name)
+	clean := path.Clean(name)
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid archive entry: %s", name)
+	}
+	return filepath.Join(folder, filepath.FromSlash(clean)), nil
+}
+
+// writeArchiveEntry copies the entry in the file, taking its size out of the remaining one
+func writeArchiveEntry(dest string, r io.Reader, size *int64) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, *size+1))
+	if err != nil {
+		return err
+	}
+	if *size -= n; *size < 0 {
+		return utils.ErrTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestUnpackTariffPlanArchive(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_archive_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	files := map[string]string{
+		"tp/" + utils.DESTINATIONS_CSV: "arc,GERMANY,49\n",
+		"tp/" + utils.RATES_CSV:        "arc,RT_1,0,0.1,60s,1s,0s\n",
+	}
+	zipArchive := func(files map[string]string) []byte {
+		var b bytes.Buffer
+		zw := zip.NewWriter(&b)
+		for name, content := range files {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(content))
+		}
+		zw.Close()
+		return b.Bytes()
+	}
+	var tgz bytes.Buffer
+	gzw := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gzw.Close()
+
+	for i, archive := range []struct {
+		data     []byte
+		fileName string
+	}{{zipArchive(files), "tp.zip"}, {tgz.Bytes(), "tp.tar.gz"}, {tgz.Bytes(), "TP.TGZ"}} {
+		folder := path.Join(tmpDir, archive.fileName)
+		tpPath, err := UnpackTariffPlanArchive(archive.data, archive.fileName, folder)
+		if err != nil {
+			t.Fatalf("%s: %v", archive.fileName, err)
+		}
+		if tpPath != path.Join(folder, "tp") {
+			t.Errorf("%d: the tariff plan should be in the single top directory: %s", i, tpPath)
+		}
+		if content, err := ioutil.ReadFile(path.Join(tpPath, utils.RATES_CSV)); err != nil || string(content) != files["tp/"+utils.RATES_CSV] {
+			t.Errorf("%d: bad unpacked file: %q, %v", i, content, err)
+		}
+	}
+
+	if _, err := UnpackTariffPlanArchive(zipArchive(map[string]string{"../evil.csv": "x"}), "evil.zip", path.Join(tmpDir, "evil")); err == nil {
+		t.Error("unpacked an entry outside the folder")
+	}
+	if _, err := os.Stat(path.Join(tmpDir, "evil.csv")); !os.IsNotExist(err) {
+		t.Error("the entry escaped the folder: ", err)
+	}
+	if _, err := UnpackTariffPlanArchive(tgz.Bytes(), "tp.rar", path.Join(tmpDir, "rar")); err == nil {
+		t.Error("unpacked an unknown format")
+	}
+	defer func(size int64) { MaxTpArchiveSize = size }(MaxTpArchiveSize)
+	MaxTpArchiveSize = 20
+	if _, err := UnpackTariffPlanArchive(zipArchive(files), "big.zip", path.Join(tmpDir, "big")); err != utils.ErrTooLarge {
+		t.Error("expected too large error: ", err)
+	}
+}
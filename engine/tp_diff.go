@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+// TpDiffItem is a tariff plan element found on one side of the diff only or changed, Old is nil for the added ones and New for the removed ones
+type TpDiffItem struct {
+	File string // the file holding the element, in JSON-lines name
+	ID   string
+	Old  interface{} `json:",omitempty"`
+	New  interface{} `json:",omitempty"`
+}
+
+// TpDiff lists the tenant elements added, removed or changed by the new tariff plan, in the loading order of the files and by id
+type TpDiff struct {
+	Tenant  string
+	Added   []*TpDiffItem
+	Removed []*TpDiffItem
+	Changed []*TpDiffItem
+}
+
+func (diff *TpDiff) IsEmpty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+// String is the human readable report, one line for each element and the old and new values of the changed ones
+func (diff *TpDiff) String() string {
+	var b bytes.Buffer
+	for _, item := range diff.Added {
+		fmt.Fprintf(&b, "+ %s %s\n", item.File, item.ID)
+	}
+	for _, item := range diff.Removed {
+		fmt.Fprintf(&b, "- %s %s\n", item.File, item.ID)
+	}
+	for _, item := range diff.Changed {
+		fmt.Fprintf(&b, "~ %s %s\n    - %s\n    + %s\n", item.File, item.ID, utils.ToJSON(item.Old), utils.ToJSON(item.New))
+	}
+	fmt.Fprintf(&b, "%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+	return b.String()
+}
+
+// DiffTariffPlanFolder compares the tenant tariff plan in the storages with the one in the folder.
+// The folder is loaded in temporary storages first so both sides are compared in the exported form.
+func DiffTariffPlanFolder(tenant, tpPath, timezone string, csvSep rune, ratingDb RatingStorage, accountingDb AccountingStorage) (*TpDiff, error) {
+	oldElements, err := exportTpElements(tenant, ratingDb, accountingDb)
+	if err != nil {
+		return nil, err
+	}
+	newElements, err := exportTpFolderElements(tenant, tpPath, timezone, csvSep)
+	if err != nil {
+		return nil, err
+	}
+	return diffTpElements(tenant, oldElements, newElements), nil
+}
+
+// DiffTariffPlanLoads compares two staged loads of the tenant
+func DiffTariffPlanLoads(tenant, oldLoadID, newLoadID, timezone string, accountingDb AccountingStorage) (*TpDiff, error) {
+	var elements []map[string]map[string]interface{}
+	for _, loadID := range []string{oldLoadID, newLoadID} {
+		load, err := getLoadInstance(accountingDb, tenant, loadID)
+		if err != nil {
+			return nil, err
+		}
+		tpPath, err := stagedTpFolder(accountingDb, load)
+		if err != nil {
+			return nil, err
+		}
+		loadElements, err := exportTpFolderElements(tenant, tpPath, timezone, utils.CSV_SEP)
+		os.RemoveAll(tpPath)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, loadElements)
+	}
+	return diffTpElements(tenant, elements[0], elements[1]), nil
+}
+
+// exportTpElements returns the exported elements of the tenant by file and id
+func exportTpElements(tenant string, ratingDb RatingStorage, accountingDb AccountingStorage) (map[string]map[string]interface{}, error) {
+	tpe := NewTpExporter(ratingDb, accountingDb, tenant)
+	result := make(map[string]map[string]interface{})
+	for _, tpf := range tpFiles {
+		if tpf.export == nil {
+			continue
+		}
+		elements, err := tpf.export(tpe)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", tpf.jsonName, err)
+		}
+		byID := make(map[string]interface{}, len(elements))
+		for _, el := range elements {
+			_, id := tpElementKey(el)
+			if dest, isDest := el.(*utils.TpDestination); isDest { // exported code by code
+				id = utils.ConcatKey(dest.Tag, dest.Code)
+			}
+			byID[id] = el
+		}
+		result[tpf.jsonName] = byID
+	}
+	return result, nil
+}
+
+func exportTpFolderElements(tenant, tpPath, timezone string, csvSep rune) (result map[string]map[string]interface{}, err error) {
+	tpStagingMux.Lock()
+	defer tpStagingMux.Unlock()
+	_, err = loadTariffPlanAside(tpPath, timezone, csvSep, func(tenants []string, ratingDb RatingStorage, accountingDb AccountingStorage) (err error) {
+		result, err = exportTpElements(tenant, ratingDb, accountingDb)
+		return
+	})
+	return
+}
+
+func diffTpElements(tenant string, oldElements, newElements map[string]map[string]interface{}) *TpDiff {
+	diff := &TpDiff{Tenant: tenant, Added: []*TpDiffItem{}, Removed: []*TpDiffItem{}, Changed: []*TpDiffItem{}}
+	for _, tpf := range tpFiles {
+		oldByID, newByID := oldElements[tpf.jsonName], newElements[tpf.jsonName]
+		var ids []string
+		for id := range oldByID {
+			ids = append(ids, id)
+		}
+		for id := range newByID {
+			if _, found := oldByID[id]; !found {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			oldEl, inOld := oldByID[id]
+			newEl, inNew := newByID[id]
+			item := &TpDiffItem{File: tpf.jsonName, ID: id, Old: oldEl, New: newEl}
+			switch {
+			case !inOld:
+				diff.Added = append(diff.Added, item)
+			case !inNew:
+				diff.Removed = append(diff.Removed, item)
+			case utils.ToJSON(oldEl) != utils.ToJSON(newEl):
+				diff.Changed = append(diff.Changed, item)
+			}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestDiffTariffPlan(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_diff_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	ratingDb, err := NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ratingDb.Close()
+	accountingDb, err := NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accountingDb.Close()
+	writeTp := func(name, destinations, rates, ratingPlan string) string {
+		tpPath := path.Join(tmpDir, name)
+		writeTpFolder(t, tpPath, map[string]string{
+			utils.DESTINATIONS_CSV:       destinations,
+			utils.RATES_CSV:              rates,
+			utils.DESTINATION_RATES_JSON: `{"Tenant":"dif", "Tag":"DR_1", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_1"}]}`,
+			utils.RATING_PLANS_JSON:      `{"Tenant":"dif", "Tag":"` + ratingPlan + `", "Bindings":[{"DestinationRatesTag": "DR_1", "TimingTag": "*any", "Weight": 10}]}`,
+		})
+		return tpPath
+	}
+	oldPath := writeTp("old", "dif,GERMANY,49\n", "dif,RT_1,0,0.1,60s,1s,0s\n", "RP_1")
+	newPath := writeTp("new", "dif,GERMANY,49\ndif,GERMANY,4915\n", "dif,RT_1,0,0.2,60s,1s,0s\n", "RP_2")
+	if _, err := LoadTariffPlanFromFolder(oldPath, "UTC", utils.CSV_SEP, ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := DiffTariffPlanFolder("dif", oldPath, "UTC", utils.CSV_SEP, ratingDb, accountingDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.IsEmpty() {
+		t.Errorf("the loaded folder should not differ: %s", diff)
+	}
+	diff, err = DiffTariffPlanFolder("dif", newPath, "UTC", utils.CSV_SEP, ratingDb, accountingDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 2 || diff.Added[0].File != utils.DESTINATIONS_JSON || diff.Added[0].ID != "GERMANY:4915" ||
+		diff.Added[1].File != utils.RATING_PLANS_JSON || diff.Added[1].ID != "RP_2" || diff.Added[1].Old != nil ||
+		len(diff.Removed) != 1 || diff.Removed[0].ID != "RP_1" || diff.Removed[0].New != nil ||
+		len(diff.Changed) != 1 || diff.Changed[0].ID != "RT_1" ||
+		diff.Changed[0].Old.(*utils.TpRate).Slots[0].Rate != 0.1 || diff.Changed[0].New.(*utils.TpRate).Slots[0].Rate != 0.2 {
+		t.Errorf("unexpected diff: %s", utils.ToIJSON(diff))
+	}
+
+	stagingDir := path.Join(tmpDir, "staging")
+	oldLoads, err := StageTariffPlan(oldPath, stagingDir, "UTC", utils.CSV_SEP, accountingDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newLoads, err := StageTariffPlan(newPath, stagingDir, "UTC", utils.CSV_SEP, accountingDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadsDiff, err := DiffTariffPlanLoads("dif", oldLoads[0].LoadID, newLoads[0].LoadID, "UTC", accountingDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadsDiff.String() != diff.String() {
+		t.Errorf("expected loads diff:\n%s\ngot:\n%s", diff, loadsDiff)
+	}
+	if _, err := DiffTariffPlanLoads("dif", oldLoads[0].LoadID, "UNKNOWN", "UTC", accountingDb); err != utils.ErrNotFound {
+		t.Error("compared unknown load: ", err)
+	}
+}
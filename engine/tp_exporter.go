@@ -0,0 +1,576 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// TpExporter converts the tariff plan data of a tenant back to the loader models, the reverse of TpReader
+type TpExporter struct {
+	ratingStorage     RatingStorage
+	accountingStorage AccountingStorage
+	tenant            string
+	timings           []*Timing           // tenant timings, lazily loaded
+	destinationCodes  map[string][]string // destination name to sorted codes, lazily loaded
+}
+
+func NewTpExporter(ratingStorage RatingStorage, accountingStorage AccountingStorage, tenant string) *TpExporter {
+	return &TpExporter{
+		ratingStorage:     ratingStorage,
+		accountingStorage: accountingStorage,
+		tenant:            tenant,
+	}
+}
+
+func (tpe *TpExporter) filter() map[string]interface{} {
+	return map[string]interface{}{"tenant": tpe.tenant}
+}
+
+func (tpe *TpExporter) ExportDestinations() ([]interface{}, error) {
+	var dests []*Destination
+	if err := tpe.ratingStorage.Iterator(ColDst, "", tpe.filter()).All(&dests); err != nil {
+		return nil, err
+	}
+	sort.Slice(dests, func(i, j int) bool {
+		if dests[i].Name != dests[j].Name {
+			return dests[i].Name < dests[j].Name
+		}
+		return dests[i].Code < dests[j].Code
+	})
+	result := make([]interface{}, len(dests))
+	for i, d := range dests {
+		result[i] = &utils.TpDestination{Tenant: d.Tenant, Code: d.Code, Tag: d.Name}
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportTimings() ([]interface{}, error) {
+	timings, err := tpe.loadTimings()
+	if err != nil {
+		return nil, err
+	}
+	// the action plans keep only the timing definition, the ones not matching a stored timing get a generated one
+	actionPlans, err := tpe.loadActionPlans()
+	if err != nil {
+		return nil, err
+	}
+	extra := make(map[string]*Timing)
+	for _, apl := range actionPlans {
+		for _, at := range apl.ActionTimings {
+			if at.Timing == nil || at.Timing.Timing == nil {
+				continue
+			}
+			if tag, found := tpe.timingTag(at.Timing.Timing); !found {
+				rit := at.Timing.Timing
+				extra[tag] = &Timing{Tenant: tpe.tenant, Name: tag, Years: rit.Years, Months: rit.Months,
+					MonthDays: rit.MonthDays, WeekDays: rit.WeekDays, Time: rit.StartTime}
+			}
+		}
+	}
+	for _, tm := range extra {
+		timings = append(timings, tm)
+	}
+	sort.Slice(timings, func(i, j int) bool { return timings[i].Name < timings[j].Name })
+	result := make([]interface{}, len(timings))
+	for i, tm := range timings {
+		result[i] = &utils.TpTiming{Tenant: tm.Tenant, Tag: tm.Name, Years: tm.Years, Months: tm.Months,
+			MonthDays: tm.MonthDays, WeekDays: tm.WeekDays, Time: tm.Time}
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportRates() ([]interface{}, error) {
+	var rates []*Rate
+	if err := tpe.ratingStorage.Iterator(ColRts, "", tpe.filter()).All(&rates); err != nil {
+		return nil, err
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Name < rates[j].Name })
+	result := make([]interface{}, len(rates))
+	for i, r := range rates {
+		tpRate := &utils.TpRate{Tenant: r.Tenant, Tag: r.Name}
+		for _, slot := range r.Slots {
+			tpRate.Slots = append(tpRate.Slots, &utils.TpRateSlot{
+				ConnectFee:         slot.ConnectFee,
+				Rate:               slot.Rate,
+				RateUnit:           slot.RateUnit.String(),
+				RateIncrement:      slot.RateIncrement.String(),
+				GroupIntervalStart: slot.GroupIntervalStart.String(),
+			})
+		}
+		result[i] = tpRate
+	}
+	return result, nil
+}
+
+// ExportDestinationRates binds the whole destinations when all their codes are present, the single codes otherwise
+func (tpe *TpExporter) ExportDestinationRates() ([]interface{}, error) {
+	var drs []*DestinationRate
+	if err := tpe.ratingStorage.Iterator(ColDrt, "", tpe.filter()).All(&drs); err != nil {
+		return nil, err
+	}
+	destinationCodes, err := tpe.loadDestinationCodes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(drs, func(i, j int) bool { return drs[i].Name < drs[j].Name })
+	result := make([]interface{}, len(drs))
+	for i, dr := range drs {
+		groups := make(map[utils.TpDestinationRateBinding][]string)
+		roundings := make(map[string]*utils.TpRounding) // the same rounding shares the pointer in the group keys
+		for _, b := range dr.Bindings {
+			if _, found := roundings[b.Rounding.hash()]; !found {
+				roundings[b.Rounding.hash()] = b.Rounding.asTpRounding()
+			}
+			key := utils.TpDestinationRateBinding{DestinationTag: b.DestinationName, RatesTag: b.RateID, MaxCost: b.MaxCost, MaxCostStrategy: b.MaxCostStrategy,
+				Rounding: roundings[b.Rounding.hash()]}
+			groups[key] = append(groups[key], b.DestinationCode)
+		}
+		var bindings []*utils.TpDestinationRateBinding
+		for key, codes := range groups {
+			sort.Strings(codes)
+			if key.DestinationTag == utils.ANY || (key.DestinationTag != "" && fmt.Sprint(codes) == fmt.Sprint(destinationCodes[key.DestinationTag])) {
+				b := key
+				bindings = append(bindings, &b)
+				continue
+			}
+			for _, code := range codes {
+				b := key
+				b.DestinationTag, b.DestinationCode = "", code
+				bindings = append(bindings, &b)
+			}
+		}
+		sort.Slice(bindings, func(i, j int) bool {
+			return utils.ConcatKey(bindings[i].DestinationTag, bindings[i].DestinationCode, bindings[i].RatesTag) <
+				utils.ConcatKey(bindings[j].DestinationTag, bindings[j].DestinationCode, bindings[j].RatesTag)
+		})
+		result[i] = &utils.TpDestinationRate{Tenant: dr.Tenant, Tag: dr.Name, Bindings: bindings}
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportExchangeRates() ([]interface{}, error) {
+	var ers []*ExchangeRate
+	if err := tpe.ratingStorage.Iterator(ColXch, "", tpe.filter()).All(&ers); err != nil {
+		return nil, err
+	}
+	sort.Slice(ers, func(i, j int) bool {
+		return utils.ConcatKey(ers[i].From, ers[i].To) < utils.ConcatKey(ers[j].From, ers[j].To)
+	})
+	result := make([]interface{}, len(ers))
+	for i, er := range ers {
+		tpEr := &utils.TpExchangeRate{Tenant: er.Tenant, From: er.From, To: er.To}
+		for _, activation := range er.Activations {
+			tpEr.Activations = append(tpEr.Activations, &utils.TpExchangeRateActivation{
+				ActivationTime: formatTpDate(activation.ActivationTime),
+				Rate:           decFloat(activation.Rate),
+			})
+		}
+		result[i] = tpEr
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportRatingPlans() ([]interface{}, error) {
+	var rps []*RatingPlan
+	if err := tpe.ratingStorage.Iterator(ColRpl, "", tpe.filter()).All(&rps); err != nil {
+		return nil, err
+	}
+	sort.Slice(rps, func(i, j int) bool { return rps[i].Name < rps[j].Name })
+	rps = parentsFirst(rps)
+	result := make([]interface{}, len(rps))
+	for i, rp := range rps {
+		if len(rp.Bindings) == 0 && rp.Derivation == nil {
+			return nil, fmt.Errorf("rating plan %s has no tariff plan bindings, reload it before exporting", rp.Name)
+		}
+		tpRp := &utils.TpRatingPlan{Tenant: rp.Tenant, Tag: rp.Name, Currency: rp.Currency, Rounding: rp.Rounding.asTpRounding()}
+		for _, b := range rp.Bindings {
+			tpRp.Bindings = append(tpRp.Bindings, &utils.TpRatingPlanBinding{DestinationRatesTag: b.DestinationRatesID, TimingTag: b.TimingID, Weight: b.Weight})
+		}
+		if d := rp.Derivation; d != nil {
+			tpRp.Derivation = &utils.TpRatingPlanDerivation{
+				ParentTag:        d.ParentID,
+				MarkupPercent:    d.MarkupPercent,
+				MarkupAbsolute:   d.MarkupAbsolute,
+				MarkupConnectFee: d.MarkupConnectFee,
+				MinimumRate:      d.MinimumRate,
+				RoundingDecimals: d.RoundingDecimals,
+				RoundingMethod:   d.RoundingMethod,
+			}
+			tpRp.Currency = d.Currency // the plan currency is the inherited one
+		}
+		result[i] = tpRp
+	}
+	return result, nil
+}
+
+// parentsFirst moves the derived rating plans after their parents, the loader needs the parent stored
+func parentsFirst(rps []*RatingPlan) []*RatingPlan {
+	names := make(map[string]bool, len(rps))
+	for _, rp := range rps {
+		names[rp.Name] = true
+	}
+	result := make([]*RatingPlan, 0, len(rps))
+	added := make(map[string]bool, len(rps))
+	for len(result) < len(rps) {
+		progress := false
+		for _, rp := range rps {
+			if added[rp.Name] {
+				continue
+			}
+			if rp.Derivation != nil && names[rp.Derivation.ParentID] && !added[rp.Derivation.ParentID] {
+				continue
+			}
+			result = append(result, rp)
+			added[rp.Name] = true
+			progress = true
+		}
+		if !progress { // derivation loop, keep the name order
+			for _, rp := range rps {
+				if !added[rp.Name] {
+					result = append(result, rp)
+					added[rp.Name] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (tpe *TpExporter) ExportRatingProfiles() ([]interface{}, error) {
+	var rpfs []*RatingProfile
+	if err := tpe.ratingStorage.Iterator(ColRpf, "", tpe.filter()).All(&rpfs); err != nil {
+		return nil, err
+	}
+	sort.Slice(rpfs, func(i, j int) bool { return rpfs[i].FullID() < rpfs[j].FullID() })
+	result := make([]interface{}, len(rpfs))
+	for i, rpf := range rpfs {
+		tpRpf := &utils.TpRatingProfile{Tenant: rpf.Tenant, Direction: rpf.Direction, Category: rpf.Category, Subject: rpf.Subject}
+		for _, rpa := range rpf.RatingPlanActivations {
+			tpRpf.Activations = append(tpRpf.Activations, &utils.TpRatingProfileActivation{
+				ActivationTime:   formatTpDate(rpa.ActivationTime),
+				RatingPlanTag:    rpa.RatingPlanID,
+				FallbackSubjects: rpa.FallbackKeys,
+				CdrStatQueueIDs:  rpa.CdrStatQueueIDs,
+			})
+		}
+		result[i] = tpRpf
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportSharedGroups() ([]interface{}, error) {
+	var sgs []*SharedGroup
+	if err := tpe.ratingStorage.Iterator(ColShg, "", tpe.filter()).All(&sgs); err != nil {
+		return nil, err
+	}
+	sort.Slice(sgs, func(i, j int) bool { return sgs[i].Name < sgs[j].Name })
+	result := make([]interface{}, len(sgs))
+	for i, sg := range sgs {
+		tpSg := &utils.TpSharedGroup{Tenant: sg.Tenant, Tag: sg.Name, MemberIDs: stringMapTags(sg.MemberIDs),
+			AccountParameters: make(map[string]*utils.TpSharingParameters)}
+		for account, param := range sg.AccountParameters {
+			tpSg.AccountParameters[account] = &utils.TpSharingParameters{Strategy: param.Strategy, RatingSubject: param.RatingSubject}
+		}
+		result[i] = tpSg
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportLcrs() ([]interface{}, error) {
+	var lcrs []*LCR
+	if err := tpe.ratingStorage.Iterator(ColLcr, "", tpe.filter()).All(&lcrs); err != nil {
+		return nil, err
+	}
+	sort.Slice(lcrs, func(i, j int) bool {
+		return utils.ConcatKey(lcrs[i].Direction, lcrs[i].Category, lcrs[i].Account, lcrs[i].Subject) <
+			utils.ConcatKey(lcrs[j].Direction, lcrs[j].Category, lcrs[j].Account, lcrs[j].Subject)
+	})
+	result := make([]interface{}, len(lcrs))
+	for i, lcr := range lcrs {
+		tpLcr := &utils.TpLcrRule{Tenant: lcr.Tenant, Direction: lcr.Direction, Category: lcr.Category, Account: lcr.Account, Subject: lcr.Subject}
+		for _, activation := range lcr.Activations {
+			tpActivation := &utils.TpLcrActivation{ActivationTime: formatTpDate(activation.ActivationTime)}
+			for _, entry := range activation.Entries {
+				tpActivation.Entries = append(tpActivation.Entries, &utils.TpLcrEntry{DestinationTag: entry.DestinationID, RPCategory: entry.RPCategory,
+					Strategy: entry.Strategy, StrategyParams: entry.StrategyParams, Weight: entry.Weight})
+			}
+			tpLcr.Activations = append(tpLcr.Activations, tpActivation)
+		}
+		result[i] = tpLcr
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportActionGroups() ([]interface{}, error) {
+	var ags []*ActionGroup
+	if err := tpe.ratingStorage.Iterator(ColAct, "", tpe.filter()).All(&ags); err != nil {
+		return nil, err
+	}
+	sort.Slice(ags, func(i, j int) bool { return ags[i].Name < ags[j].Name })
+	result := make([]interface{}, len(ags))
+	for i, ag := range ags {
+		tpAg := &utils.TpActionGroup{Tenant: ag.Tenant, Tag: ag.Name}
+		for _, a := range ag.Actions {
+			tpAg.Actions = append(tpAg.Actions, &utils.TpActionBody{Action: a.ActionType, TOR: a.TOR, Params: a.Params,
+				ExecFilter: a.ExecFilter, Filter: a.Filter1, Weight: a.Weight})
+		}
+		result[i] = tpAg
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportActionPlans() ([]interface{}, error) {
+	apls, err := tpe.loadActionPlans()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tpe.loadTimings(); err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(apls))
+	for i, apl := range apls {
+		tpApl := &utils.TpActionPlan{Tenant: apl.Tenant, Tag: apl.Name}
+		for _, at := range apl.ActionTimings {
+			timingTag := utils.ANY
+			if at.Timing != nil && at.Timing.Timing != nil {
+				timingTag, _ = tpe.timingTag(at.Timing.Timing)
+			}
+			tpApl.ActionTimings = append(tpApl.ActionTimings, &utils.TpActionTiming{TimingTag: timingTag, ActionsTag: at.ActionsID, Weight: at.Weight})
+		}
+		result[i] = tpApl
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportActionTriggers() ([]interface{}, error) {
+	var atrgs []*ActionTriggerGroup
+	if err := tpe.ratingStorage.Iterator(ColAtr, "", tpe.filter()).All(&atrgs); err != nil {
+		return nil, err
+	}
+	sort.Slice(atrgs, func(i, j int) bool { return atrgs[i].Name < atrgs[j].Name })
+	result := make([]interface{}, len(atrgs))
+	for i, atrg := range atrgs {
+		tpAtr := &utils.TpActionTrigger{Tenant: atrg.Tenant, Tag: atrg.Name}
+		for _, atr := range atrg.ActionTriggers {
+			tpAtr.Triggers = append(tpAtr.Triggers, &utils.TpTriggerBody{
+				UniqueID:       atr.UniqueID,
+				ThresholdType:  atr.ThresholdType,
+				ThresholdValue: decFloat(atr.ThresholdValue),
+				Recurrent:      atr.Recurrent,
+				MinSleep:       atr.MinSleep.String(),
+				ExpiryTime:     formatTpDate(atr.ExpirationDate),
+				ActivationTime: formatTpDate(atr.ActivationDate),
+				TOR:            atr.TOR,
+				Filter:         atr.Filter,
+				MinQueuedItems: atr.MinQueuedItems,
+				ActionsTag:     atr.ActionsID,
+				Weight:         atr.Weight,
+			})
+		}
+		result[i] = tpAtr
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportDerivedChargers() ([]interface{}, error) {
+	var dcgs []*utils.DerivedChargerGroup
+	if err := tpe.ratingStorage.Iterator(ColDcs, "", tpe.filter()).All(&dcgs); err != nil {
+		return nil, err
+	}
+	sort.Slice(dcgs, func(i, j int) bool {
+		return utils.ConcatKey(dcgs[i].Direction, dcgs[i].Category, dcgs[i].Account, dcgs[i].Subject) <
+			utils.ConcatKey(dcgs[j].Direction, dcgs[j].Category, dcgs[j].Account, dcgs[j].Subject)
+	})
+	result := make([]interface{}, len(dcgs))
+	for i, dcg := range dcgs {
+		tpDc := &utils.TpDerivedCharger{Tenant: dcg.Tenant, Direction: dcg.Direction, Category: dcg.Category, Account: dcg.Account,
+			Subject: dcg.Subject, DestinationIDs: stringMapTags(dcg.DestinationIDs)}
+		for _, dc := range dcg.Chargers {
+			tpDc.Chargers = append(tpDc.Chargers, &utils.TpDerivedChargerRun{RunID: dc.RunID, Filter: dc.RunFilter, Fields: dc.Fields})
+		}
+		result[i] = tpDc
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportCdrStats() ([]interface{}, error) {
+	var css []*CdrStats
+	if err := tpe.ratingStorage.Iterator(ColCrs, "", tpe.filter()).All(&css); err != nil {
+		return nil, err
+	}
+	sort.Slice(css, func(i, j int) bool { return css[i].Name < css[j].Name })
+	result := make([]interface{}, len(css))
+	for i, cs := range css {
+		result[i] = &utils.TpCdrStats{Tenant: cs.Tenant, Tag: cs.Name, QueueLength: cs.QueueLength, TimeWindow: cs.TimeWindow.String(),
+			Metrics: cs.Metrics, Filter: cs.Filter, ActionTriggerTags: stringMapTags(cs.TriggerIDs), Disabled: cs.Disabled}
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportTaxRules() ([]interface{}, error) {
+	trs, err := tpe.ratingStorage.GetTaxRules(tpe.tenant)
+	if err != nil && err != utils.ErrNotFound {
+		return nil, err
+	}
+	sort.Slice(trs, func(i, j int) bool { return trs[i].Name < trs[j].Name })
+	result := make([]interface{}, len(trs))
+	for i, tr := range trs {
+		tpTr := &utils.TpTaxRule{Tenant: tr.Tenant, Tag: tr.Name, DestinationTags: stringMapTags(tr.DestinationIDs), Categories: stringMapTags(tr.Categories),
+			Type: tr.Type, Value: decFloat(tr.Value), ActivationTime: formatTpDate(tr.ActivationTime), ExpirationTime: formatTpDate(tr.ExpirationTime),
+			ExemptAccounts: stringMapTags(tr.ExemptAccounts), Weight: tr.Weight}
+		if tr.Unit != 0 {
+			tpTr.Unit = tr.Unit.String()
+		}
+		result[i] = tpTr
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportUsers() ([]interface{}, error) {
+	var ups []*UserProfile
+	if err := tpe.accountingStorage.Iterator(ColUsr, "", tpe.filter()).All(&ups); err != nil {
+		return nil, err
+	}
+	sort.Slice(ups, func(i, j int) bool { return ups[i].Name < ups[j].Name })
+	result := make([]interface{}, len(ups))
+	for i, up := range ups {
+		result[i] = &utils.TpUser{Tenant: up.Tenant, Name: up.Name, Masked: up.Masked, Index: up.Index, Query: up.Query, Weight: up.Weight}
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) ExportAliases() ([]interface{}, error) {
+	var als []*Alias
+	if err := tpe.accountingStorage.Iterator(ColAls, "", tpe.filter()).All(&als); err != nil {
+		return nil, err
+	}
+	sort.Slice(als, func(i, j int) bool {
+		return utils.ConcatKey(als[i].Direction, als[i].Category, als[i].Account, als[i].Subject, als[i].Context) <
+			utils.ConcatKey(als[j].Direction, als[j].Category, als[j].Account, als[j].Subject, als[j].Context)
+	})
+	result := make([]interface{}, len(als))
+	for i, al := range als {
+		tpAl := &utils.TpAlias{Tenant: al.Tenant, Direction: al.Direction, Category: al.Category, Account: al.Account, Subject: al.Subject, Context: al.Context}
+		for _, ai := range al.Index {
+			tpAl.Indexes = append(tpAl.Indexes, &utils.TpAliasIndex{Target: ai.Target, Alias: ai.Alias})
+		}
+		for _, av := range al.Values {
+			tpAl.Values = append(tpAl.Values, &utils.TpAliasValue{DestinationTag: av.DestinationID, Fields: av.Fields, Weight: av.Weight})
+		}
+		result[i] = tpAl
+	}
+	return result, nil
+}
+
+// ExportResourceLimits puts the limit attributes on the first filter, the one LoadResourceLimit takes them from
+func (tpe *TpExporter) ExportResourceLimits() ([]interface{}, error) {
+	var rls []*ResourceLimit
+	if err := tpe.accountingStorage.Iterator(ColRL, "", tpe.filter()).All(&rls); err != nil {
+		return nil, err
+	}
+	sort.Slice(rls, func(i, j int) bool { return rls[i].ID < rls[j].ID })
+	result := make([]interface{}, len(rls))
+	for i, rl := range rls {
+		if len(rl.Filters) == 0 {
+			return nil, fmt.Errorf("resource limit %s has no filters", rl.ID)
+		}
+		tpRl := &utils.TpResourceLimit{Tenant: rl.Tenant, Tag: rl.ID}
+		for j, rf := range rl.Filters {
+			tpRf := &utils.TpResourceFilter{Type: rf.Type, FieldName: rf.FieldName, Values: rf.Values}
+			if j == 0 {
+				tpRf.ActivationTime = formatTpDate(rl.ActivationTime)
+				tpRf.Weight = rl.Weight
+				tpRf.Limit = rl.Limit
+				tpRf.ActionTriggersTag = rl.ActionTriggersID
+			}
+			tpRl.Filters = append(tpRl.Filters, tpRf)
+		}
+		result[i] = tpRl
+	}
+	return result, nil
+}
+
+func (tpe *TpExporter) loadTimings() ([]*Timing, error) {
+	if tpe.timings == nil {
+		tpe.timings = make([]*Timing, 0)
+		if err := tpe.ratingStorage.Iterator(ColTmg, "", tpe.filter()).All(&tpe.timings); err != nil {
+			return nil, err
+		}
+		sort.Slice(tpe.timings, func(i, j int) bool { return tpe.timings[i].Name < tpe.timings[j].Name })
+	}
+	return append([]*Timing{}, tpe.timings...), nil
+}
+
+func (tpe *TpExporter) loadActionPlans() ([]*ActionPlan, error) {
+	var apls []*ActionPlan
+	if err := tpe.ratingStorage.Iterator(ColApl, "", tpe.filter()).All(&apls); err != nil {
+		return nil, err
+	}
+	sort.Slice(apls, func(i, j int) bool { return apls[i].Name < apls[j].Name })
+	return apls, nil
+}
+
+func (tpe *TpExporter) loadDestinationCodes() (map[string][]string, error) {
+	if tpe.destinationCodes == nil {
+		var dests []*Destination
+		if err := tpe.ratingStorage.Iterator(ColDst, "", tpe.filter()).All(&dests); err != nil {
+			return nil, err
+		}
+		tpe.destinationCodes = make(map[string][]string)
+		for _, d := range dests {
+			tpe.destinationCodes[d.Name] = append(tpe.destinationCodes[d.Name], d.Code)
+		}
+		for _, codes := range tpe.destinationCodes {
+			sort.Strings(codes)
+		}
+	}
+	return tpe.destinationCodes, nil
+}
+
+// timingTag finds the timing with the rit definition, the second value is false for a generated tag.
+// The timings must be already loaded.
+func (tpe *TpExporter) timingTag(rit *RITiming) (string, bool) {
+	for _, tm := range append([]*Timing{getMetaTiming(tpe.tenant, utils.ASAP), getMetaTiming(tpe.tenant, utils.ANY)}, tpe.timings...) {
+		if fmt.Sprint(tm.Years, tm.Months, tm.MonthDays, tm.WeekDays, tm.Time) ==
+			fmt.Sprint(rit.Years, rit.Months, rit.MonthDays, rit.WeekDays, rit.StartTime) {
+			return tm.Name, true
+		}
+	}
+	return "TM_" + rit.hash(), false
+}
+
+// formatTpDate is the reverse of utils.ParseDate, the zero time is left empty
+func formatTpDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func decFloat(d *dec.Dec) float64 {
+	if d == nil {
+		return 0
+	}
+	f, _ := d.Float64()
+	return f
+}
+
+// stringMapTags lists the map keys sorted, the false ones with the ! prefix used by utils.NewStringMap
+func stringMapTags(sm utils.StringMap) []string {
+	if len(sm) == 0 {
+		return nil
+	}
+	tags := make([]string, 0, len(sm))
+	for k, v := range sm {
+		if !v {
+			k = "!" + k
+		}
+		tags = append(tags, k)
+	}
+	sort.Strings(tags)
+	return tags
+}
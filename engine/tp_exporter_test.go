@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+	"github.com/globalsign/mgo/bson"
+)
+
+// exportReload exports the tenant from the given storages and loads the folder into new ones
+func exportReload(t *testing.T, tmpDir, name, format string, ratingDb RatingStorage, accountingDb AccountingStorage) (RatingStorage, AccountingStorage, string) {
+	tpPath := path.Join(tmpDir, name)
+	if _, err := ExportTariffPlanToFolder("test", tpPath, format, ';', ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+	newRatingDb, err := NewBoltStorage(path.Join(tmpDir, name+"_tp.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newAccountingDb, err := NewBoltStorage(path.Join(tmpDir, name+"_data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTariffPlanFromFolder(tpPath, "UTC", ';', newRatingDb, newAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	return newRatingDb, newAccountingDb, tpPath
+}
+
+// tenantDocs lists the tenant documents of the collection as sorted json, the storage ids and the action timing uuids left out
+func tenantDocs(t *testing.T, it Iterator) []string {
+	var docs []bson.M
+	if err := it.All(&docs); err != nil {
+		t.Fatal(err)
+	}
+	result := make([]string, len(docs))
+	for i, doc := range docs {
+		delete(doc, "_id")
+		if ats, ok := doc["action_timings"].([]interface{}); ok {
+			for _, at := range ats {
+				delete(at.(bson.M), "uuid")
+			}
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[i] = string(b)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestExportTariffPlanRoundTrip(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	// the loader test data, in storages of its own
+	origPath := path.Join(tmpDir, "orig")
+	os.Mkdir(origPath, 0755)
+	for fileName, content := range map[string]string{
+		utils.DESTINATIONS_JSON:      destinations,
+		utils.TIMINGS_JSON:           timings,
+		utils.RATES_JSON:             rates,
+		utils.DESTINATION_RATES_JSON: destinationRates,
+		utils.RATING_PLANS_JSON:      ratingPlans,
+		utils.RATING_PROFILES_JSON:   ratingProfiles,
+		utils.SHARED_GROUPS_JSON:     sharedGroups,
+		utils.LCRS_JSON:              lcrs,
+		utils.ACTIONS_JSON:           actions,
+		utils.ACTION_PLANS_JSON:      actionPlans,
+		utils.ACTION_TRIGGERS_JSON:   actionTriggers,
+		utils.DERIVED_CHARGERS_JSON:  derivedChargers,
+		utils.CDR_STATS_JSON:         cdrStats,
+		utils.USERS_JSON:             users,
+		utils.ALIASES_JSON:           aliases,
+		utils.RESOURCE_LIMITS_JSON:   resLimits,
+	} {
+		if err := ioutil.WriteFile(path.Join(origPath, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origRatingDb, err := NewBoltStorage(path.Join(tmpDir, "orig_tp.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origRatingDb.Close()
+	origAccountingDb, err := NewBoltStorage(path.Join(tmpDir, "orig_data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origAccountingDb.Close()
+	if _, err := LoadTariffPlanFromFolder(origPath, "UTC", utils.CSV_SEP, origRatingDb, origAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonRatingDb, jsonAccountingDb, firstPath := exportReload(t, tmpDir, "json", utils.JSON, origRatingDb, origAccountingDb)
+	defer jsonRatingDb.Close()
+	defer jsonAccountingDb.Close()
+	csvRatingDb, csvAccountingDb, _ := exportReload(t, tmpDir, "csv", utils.CSV, jsonRatingDb, jsonAccountingDb)
+	defer csvRatingDb.Close()
+	defer csvAccountingDb.Close()
+	lastPath := path.Join(tmpDir, "last")
+	if _, err := ExportTariffPlanToFolder("test", lastPath, utils.JSON, ';', csvRatingDb, csvAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(firstPath)
+	if err != nil || len(files) < 10 {
+		t.Fatalf("missing exported files: %v %v", files, err)
+	}
+	for _, f := range files {
+		first, _ := ioutil.ReadFile(path.Join(firstPath, f.Name()))
+		last, err := ioutil.ReadFile(path.Join(lastPath, f.Name()))
+		if err != nil || string(first) != string(last) {
+			t.Errorf("%s differs after the round trip (%v):\n%s\n%s", f.Name(), err, first, last)
+		}
+	}
+	for _, col := range stagedRatingCollections {
+		orig := tenantDocs(t, origRatingDb.Iterator(col, "", map[string]interface{}{"tenant": "test"}))
+		reloaded := tenantDocs(t, csvRatingDb.Iterator(col, "", map[string]interface{}{"tenant": "test"}))
+		if !reflect.DeepEqual(orig, reloaded) {
+			t.Errorf("%s differs after the round trip:\n%v\n%v", col, orig, reloaded)
+		}
+	}
+	for _, col := range stagedAccountingCollections {
+		orig := tenantDocs(t, origAccountingDb.Iterator(col, "", map[string]interface{}{"tenant": "test"}))
+		reloaded := tenantDocs(t, csvAccountingDb.Iterator(col, "", map[string]interface{}{"tenant": "test"}))
+		if len(orig) == 0 || !reflect.DeepEqual(orig, reloaded) {
+			t.Errorf("%s differs after the round trip:\n%v\n%v", col, orig, reloaded)
+		}
+	}
+	for _, name := range []string{"STANDARD", "PREMIUM"} {
+		rp, err := origRatingDb.GetRatingPlan("test", name, utils.CACHE_SKIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reloaded, err := csvRatingDb.GetRatingPlan("test", name, utils.CACHE_SKIP)
+		if err != nil || !reflect.DeepEqual(rp, reloaded) {
+			t.Errorf("rating plan %s differs after the round trip: %v %s", name, err, utils.ToIJSON(reloaded))
+		}
+	}
+	drs, err := origRatingDb.GetDestinationRate("test", "RT_STANDARD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := csvRatingDb.GetDestinationRate("test", "RT_STANDARD"); err != nil || !reflect.DeepEqual(drs, reloaded) {
+		t.Errorf("destination rate differs after the round trip: %v %s", err, utils.ToIJSON(reloaded))
+	}
+}
@@ -66,6 +66,15 @@ func (tpr *TpReader) LoadTiming(el interface{}) error {
 func (tpr *TpReader) LoadRate(el interface{}) error {
 	element := el.(*utils.TpRate)
 	tpr.loadStats.Tenants[element.Tenant] = true
+	r, err := newRate(element)
+	if err != nil {
+		return err
+	}
+	return tpr.ratingStorage.SetRate(r)
+}
+
+// newRate parses the durations of the rate slots
+func newRate(element *utils.TpRate) (*Rate, error) {
 	r := &Rate{
 		Tenant: element.Tenant,
 		Name:   element.Tag,
@@ -76,7 +85,7 @@ func (tpr *TpReader) LoadRate(el interface{}) error {
 		if slot.RateUnit != "" {
 			ru, err = utils.ParseDurationWithSecs(slot.RateUnit)
 			if err != nil {
-				return fmt.Errorf("error parsing rate unit %s: %s", slot.RateUnit, err.Error())
+				return nil, fmt.Errorf("error parsing rate unit %s: %s", slot.RateUnit, err.Error())
 			}
 		} else {
 			ru = time.Minute
@@ -85,7 +94,7 @@ func (tpr *TpReader) LoadRate(el interface{}) error {
 		if slot.RateIncrement != "" {
 			ri, err = utils.ParseDurationWithSecs(slot.RateIncrement)
 			if err != nil {
-				return fmt.Errorf("error parsing rate increment %s: %s", slot.RateIncrement, err.Error())
+				return nil, fmt.Errorf("error parsing rate increment %s: %s", slot.RateIncrement, err.Error())
 			}
 		} else {
 			ri = 1 * time.Second
@@ -94,7 +103,7 @@ func (tpr *TpReader) LoadRate(el interface{}) error {
 		if slot.GroupIntervalStart != "" {
 			gis, err = utils.ParseDurationWithSecs(slot.GroupIntervalStart)
 			if err != nil {
-				return fmt.Errorf("error parsing group interval start %s: %s", slot.GroupIntervalStart, err.Error())
+				return nil, fmt.Errorf("error parsing group interval start %s: %s", slot.GroupIntervalStart, err.Error())
 			}
 		} else {
 			gis = 0 * time.Second
@@ -107,8 +116,7 @@ func (tpr *TpReader) LoadRate(el interface{}) error {
 			GroupIntervalStart: gis,
 		})
 	}
-
-	return tpr.ratingStorage.SetRate(r)
+	return r, nil
 }
 
 func (tpr *TpReader) LoadDestinationRate(el interface{}) error {
@@ -160,6 +168,7 @@ func (tpr *TpReader) LoadDestinationRate(el interface{}) error {
 				RateID:          binding.RatesTag,
 				MaxCost:         binding.MaxCost,
 				MaxCostStrategy: binding.MaxCostStrategy,
+				Rounding:        newRounding(binding.Rounding),
 			}
 		}
 	}
@@ -170,65 +179,144 @@ func (tpr *TpReader) LoadRatingPlan(el interface{}) error {
 	element := el.(*utils.TpRatingPlan)
 	tpr.loadStats.Tenants[element.Tenant] = true
 	rp := &RatingPlan{
-		Tenant: element.Tenant,
-		Name:   element.Tag,
+		Tenant:   element.Tenant,
+		Name:     element.Tag,
+		Currency: element.Currency,
+		Rounding: newRounding(element.Rounding),
 	}
 
 	for _, rpBinding := range element.Bindings {
-		timing, err := tpr.ratingStorage.GetTiming(rp.Tenant, rpBinding.TimingTag)
+		rp.Bindings = append(rp.Bindings, &RatingPlanBinding{
+			DestinationRatesID: rpBinding.DestinationRatesTag,
+			TimingID:           rpBinding.TimingTag,
+			Weight:             rpBinding.Weight,
+		})
+	}
+	if d := element.Derivation; d != nil {
+		rp.Derivation = &RatingPlanDerivation{
+			ParentID:         d.ParentTag,
+			MarkupPercent:    d.MarkupPercent,
+			MarkupAbsolute:   d.MarkupAbsolute,
+			MarkupConnectFee: d.MarkupConnectFee,
+			MinimumRate:      d.MinimumRate,
+			RoundingDecimals: d.RoundingDecimals,
+			RoundingMethod:   d.RoundingMethod,
+			Currency:         element.Currency,
+		}
+	}
+	if err := tpr.setRatingPlan(rp); err != nil {
+		return err
+	}
+	return tpr.rederiveRatingPlans(rp, utils.StringMap{rp.Name: true})
+}
+
+// setRatingPlan builds the rate intervals out of the plan bindings and its parent, then stores the plan
+func (tpr *TpReader) setRatingPlan(rp *RatingPlan) error {
+	for _, rpBinding := range rp.Bindings {
+		timing, err := tpr.ratingStorage.GetTiming(rp.Tenant, rpBinding.TimingID)
 		if err != nil || timing == nil {
-			return fmt.Errorf("could not get timing for tag %s (%v)", rpBinding.TimingTag, err)
+			return fmt.Errorf("could not get timing for tag %s (%v)", rpBinding.TimingID, err)
 		}
 
-		drate, err := tpr.ratingStorage.GetDestinationRate(rp.Tenant, rpBinding.DestinationRatesTag)
+		drate, err := tpr.ratingStorage.GetDestinationRate(rp.Tenant, rpBinding.DestinationRatesID)
 		if err != nil || drate == nil {
-			return fmt.Errorf("could not find destination rate for tag %s (%s)", rpBinding.DestinationRatesTag, err.Error())
+			return fmt.Errorf("could not find destination rate for tag %s (%v)", rpBinding.DestinationRatesID, err)
 		}
-		for _, drBinding := range drate.Bindings {
-			rate, err := tpr.ratingStorage.GetRate(rp.Tenant, drBinding.RateID)
-			if err != nil || rate == nil {
-				return fmt.Errorf("could not find rate for tag %s (%v)", drBinding.RateID, err)
-			}
-
-			ri := &RateInterval{
-				Timing: &RITiming{
-					Years:     timing.Years,
-					Months:    timing.Months,
-					MonthDays: timing.MonthDays,
-					WeekDays:  timing.WeekDays,
-					StartTime: timing.Time,
-				},
-				Weight: rpBinding.Weight,
-				Rating: &RIRate{
-					ConnectFee:      dec.NewFloat(rate.Slots[0].ConnectFee),
-					MaxCost:         dec.NewFloat(drBinding.MaxCost),
-					MaxCostStrategy: drBinding.MaxCostStrategy,
-				},
-			}
-			for _, rs := range rate.Slots {
-				ri.Rating.Rates = append(ri.Rating.Rates, &RateInfo{
-					GroupIntervalStart: rs.GroupIntervalStart,
-					Value:              dec.NewFloat(rs.Rate),
-					RateIncrement:      rs.RateIncrement,
-					RateUnit:           rs.RateUnit,
-				})
-			}
-			rp.AddRateInterval(drBinding.DestinationCode, drBinding.DestinationName, ri)
-
+		if err := rp.addDestinationRate(drate, timing, rpBinding.Weight, func(rateID string) (*Rate, error) {
+			return tpr.ratingStorage.GetRate(rp.Tenant, rateID)
+		}); err != nil {
+			return err
 		}
 	}
-	if !rp.isContinous() {
-		return fmt.Errorf("The rating plan %s is not covering all weekdays", rp.Name)
-	}
-	if crazyRate := rp.getFirstUnsaneRating(); crazyRate != "" {
-		return fmt.Errorf("The rate %s is invalid", crazyRate)
+	if rp.Derivation != nil {
+		if rp.Derivation.ParentID == rp.Name {
+			return fmt.Errorf("rating plan %s derived from itself", rp.Name)
+		}
+		parent, err := tpr.ratingStorage.GetRatingPlan(rp.Tenant, rp.Derivation.ParentID, utils.CACHE_SKIP)
+		if err != nil || parent == nil {
+			return fmt.Errorf("could not get parent rating plan for tag %s (%v)", rp.Derivation.ParentID, err)
+		}
+		if err := rp.derive(parent); err != nil {
+			return err
+		}
 	}
-	if crazyTiming := rp.getFirstUnsaneTiming(); crazyTiming != "" {
-		return fmt.Errorf("The timing %s is invalid", crazyTiming)
+	if err := rp.checkSanity(); err != nil {
+		return err
 	}
 	return tpr.ratingStorage.SetRatingPlan(rp)
 }
 
+// rederiveRatingPlans rebuilds the stored plans derived from the reloaded one, and the ones derived from them
+func (tpr *TpReader) rederiveRatingPlans(parent *RatingPlan, done utils.StringMap) error {
+	var rps []*RatingPlan
+	if err := tpr.ratingStorage.Iterator(ColRpl, "", map[string]interface{}{"tenant": parent.Tenant}).All(&rps); err != nil {
+		return err
+	}
+	for _, stored := range rps {
+		if stored.Derivation == nil || stored.Derivation.ParentID != parent.Name || done[stored.Name] {
+			continue
+		}
+		done[stored.Name] = true
+		rp := &RatingPlan{
+			Tenant:     stored.Tenant,
+			Name:       stored.Name,
+			Bindings:   stored.Bindings,
+			Derivation: stored.Derivation,
+			Rounding:   stored.Rounding,
+		}
+		if err := tpr.setRatingPlan(rp); err != nil {
+			return fmt.Errorf("could not derive rating plan %s (%v)", rp.Name, err)
+		}
+		if err := tpr.rederiveRatingPlans(rp, done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDestinationRate adds the rate intervals of the destination rate bindings active in the timing
+func (rp *RatingPlan) addDestinationRate(drate *DestinationRate, timing *Timing, weight float64, getRate func(rateID string) (*Rate, error)) error {
+	for _, drBinding := range drate.Bindings {
+		rate, err := getRate(drBinding.RateID)
+		if err != nil || rate == nil {
+			return fmt.Errorf("could not find rate for tag %s (%v)", drBinding.RateID, err)
+		}
+		if len(rate.Slots) == 0 {
+			return fmt.Errorf("the rate %s has no slots", drBinding.RateID)
+		}
+
+		ri := &RateInterval{
+			Timing: &RITiming{
+				Years:     timing.Years,
+				Months:    timing.Months,
+				MonthDays: timing.MonthDays,
+				WeekDays:  timing.WeekDays,
+				StartTime: timing.Time,
+			},
+			Weight: weight,
+			Rating: &RIRate{
+				ConnectFee:      dec.NewFloat(rate.Slots[0].ConnectFee),
+				MaxCost:         dec.NewFloat(drBinding.MaxCost),
+				MaxCostStrategy: drBinding.MaxCostStrategy,
+				Rounding:        drBinding.Rounding,
+			},
+		}
+		for _, rs := range rate.Slots {
+			ri.Rating.Rates = append(ri.Rating.Rates, &RateInfo{
+				GroupIntervalStart: rs.GroupIntervalStart,
+				Value:              dec.NewFloat(rs.Rate),
+				RateIncrement:      rs.RateIncrement,
+				RateUnit:           rs.RateUnit,
+			})
+		}
+		if ri.Rating.Rounding == nil {
+			ri.Rating.Rounding = rp.Rounding
+		}
+		rp.AddRateInterval(drBinding.DestinationCode, drBinding.DestinationName, ri)
+	}
+	return nil
+}
+
 func (tpr *TpReader) LoadRatingProfile(el interface{}) error {
 	element := el.(*utils.TpRatingProfile)
 	tpr.loadStats.Tenants[element.Tenant] = true
@@ -571,6 +659,73 @@ func (tpr *TpReader) LoadCdrStats(el interface{}) (err error) {
 	return tpr.ratingStorage.SetCdrStats(cs)
 }
 
+func (tpr *TpReader) LoadExchangeRate(el interface{}) error {
+	element := el.(*utils.TpExchangeRate)
+	tpr.loadStats.Tenants[element.Tenant] = true
+	if element.From == "" || element.To == "" || element.From == element.To {
+		return fmt.Errorf("<LoadExchangeRate> invalid currencies %s and %s", element.From, element.To)
+	}
+	er := &ExchangeRate{
+		Tenant: element.Tenant,
+		From:   element.From,
+		To:     element.To,
+	}
+	for _, activation := range element.Activations {
+		at, err := utils.ParseDate(activation.ActivationTime)
+		if err != nil {
+			return fmt.Errorf("cannot parse activation time from %s", activation.ActivationTime)
+		}
+		if activation.Rate <= 0 {
+			return fmt.Errorf("<LoadExchangeRate> invalid rate %v from %s to %s", activation.Rate, element.From, element.To)
+		}
+		er.Activations = append(er.Activations, &ExchangeRateActivation{
+			ActivationTime: at,
+			Rate:           dec.NewFloat(activation.Rate),
+		})
+	}
+	er.Sort()
+	return tpr.ratingStorage.SetExchangeRate(er)
+}
+
+func (tpr *TpReader) LoadTaxRule(el interface{}) error {
+	element := el.(*utils.TpTaxRule)
+	tpr.loadStats.Tenants[element.Tenant] = true
+	tr := &TaxRule{
+		Tenant:         element.Tenant,
+		Name:           element.Tag,
+		DestinationIDs: utils.NewStringMap(element.DestinationTags...),
+		Categories:     utils.NewStringMap(element.Categories...),
+		Type:           element.Type,
+		Value:          dec.NewFloat(element.Value),
+		ExemptAccounts: utils.NewStringMap(element.ExemptAccounts...),
+		Weight:         element.Weight,
+	}
+	switch tr.Type {
+	case TAX_PERCENT, TAX_COMPOUND:
+	case TAX_PER_UNIT:
+		unit, err := utils.ParseDurationWithSecs(element.Unit)
+		if err != nil || unit <= 0 {
+			return fmt.Errorf("<LoadTaxRule> could not parse unit %s for tax rule %s (%v)", element.Unit, element.Tag, err)
+		}
+		tr.Unit = unit
+	default:
+		return fmt.Errorf("<LoadTaxRule> unsupported type %s for tax rule %s", element.Type, element.Tag)
+	}
+	var err error
+	if tr.ActivationTime, err = utils.ParseDate(element.ActivationTime); err != nil {
+		return fmt.Errorf("cannot parse activation time from %s", element.ActivationTime)
+	}
+	if tr.ExpirationTime, err = utils.ParseDate(element.ExpirationTime); err != nil {
+		return fmt.Errorf("cannot parse expiration time from %s", element.ExpirationTime)
+	}
+	for destTag := range tr.DestinationIDs {
+		if dests, err := tpr.ratingStorage.GetDestinations(element.Tenant, "", destTag, utils.DestExact, utils.CACHE_SKIP); err != nil || len(dests) == 0 {
+			return fmt.Errorf("<LoadTaxRule> could not get destination for tag %s (%v)", destTag, err)
+		}
+	}
+	return tpr.ratingStorage.SetTaxRule(tr)
+}
+
 func (tpr *TpReader) LoadUser(el interface{}) error {
 	element := el.(*utils.TpUser)
 	tpr.loadStats.UserTenants[element.Tenant] = true
@@ -620,10 +775,35 @@ func (tpr *TpReader) LoadAlias(el interface{}) error {
 	return tpr.accountingStorage.SetAlias(alias)
 }
 
+// LoadResourceLimit stores the limit with its filters, the limit attributes are taken from the first filter setting them
 func (tpr *TpReader) LoadResourceLimit(el interface{}) error {
-	//element := el.(*utils.TpResourceLimit)
-	//tpr.loadStats.Tenants[element.Tenant] = true
-	return nil
+	element := el.(*utils.TpResourceLimit)
+	tpr.loadStats.Tenants[element.Tenant] = true
+	rl := &ResourceLimit{Tenant: element.Tenant, ID: element.Tag}
+	for _, tpFilter := range element.Filters {
+		if rl.ActivationTime.IsZero() && tpFilter.ActivationTime != "" {
+			activationTime, err := utils.ParseTimeDetectLayout(tpFilter.ActivationTime, tpr.timezone)
+			if err != nil {
+				return fmt.Errorf("resource limit %s: %v", element.Tag, err)
+			}
+			rl.ActivationTime = activationTime
+		}
+		if rl.Weight == 0 {
+			rl.Weight = tpFilter.Weight
+		}
+		if rl.Limit == 0 {
+			rl.Limit = tpFilter.Limit
+		}
+		if rl.ActionTriggersID == "" {
+			rl.ActionTriggersID = tpFilter.ActionTriggersTag
+		}
+		rf := &RequestFilter{Tenant: element.Tenant, Type: tpFilter.Type, FieldName: tpFilter.FieldName, Values: tpFilter.Values}
+		if err := rf.CompileValues(); err != nil {
+			return fmt.Errorf("resource limit %s: %v", element.Tag, err)
+		}
+		rl.Filters = append(rl.Filters, rf)
+	}
+	return tpr.accountingStorage.SetResourceLimit(rl, "")
 }
 
 func (tpr *TpReader) LoadStats() *LoadStats {
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestLoadDerivedRatingPlan(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_reader_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	ratingDb, err := NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ratingDb.Close()
+	accountingDb, err := NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accountingDb.Close()
+	buyPlan := `{"Tenant":"drv", "Tag":"WHOLESALE", "Bindings":[{"DestinationRatesTag": "DR_BUY", "TimingTag": "*any", "Weight": 10}]}` + "\n"
+	writeTpFolder(t, path.Join(tmpDir, "v1"), map[string]string{
+		utils.DESTINATIONS_CSV: "drv,GERMANY,49\ndrv,FRANCE,33\n",
+		utils.RATES_CSV:        "drv,RT_DE,0,0.1,60s,1s,0s\ndrv,RT_FR,0.02,0.004,60s,1s,0s\ndrv,RT_FR_SELL,0,0.05,60s,60s,0s\n",
+		utils.DESTINATION_RATES_JSON: `{"Tenant":"drv", "Tag":"DR_BUY", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_DE"}, {"DestinationTag": "FRANCE", "RatesTag": "RT_FR"}]}
+{"Tenant":"drv", "Tag":"DR_SELL", "Bindings":[{"DestinationTag": "FRANCE", "RatesTag": "RT_FR_SELL"}]}`,
+		utils.RATING_PLANS_JSON: buyPlan +
+			`{"Tenant":"drv", "Tag":"RETAIL", "Bindings":[], "Derivation":{"ParentTag":"WHOLESALE", "MarkupPercent":15, "MinimumRate":0.01, "RoundingDecimals":2, "RoundingMethod":"*up"}}
+{"Tenant":"drv", "Tag":"PREMIUM", "Bindings":[{"DestinationRatesTag": "DR_SELL", "TimingTag": "*any", "Weight": 10}],
+  "Derivation":{"ParentTag":"RETAIL", "MarkupAbsolute":0.01, "MarkupConnectFee":true, "RoundingDecimals":4, "RoundingMethod":"*middle"}}`,
+	})
+	if _, err := LoadTariffPlanFromFolder(path.Join(tmpDir, "v1"), "UTC", utils.CSV_SEP, ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+	checkRate := func(plan, code string, rate, connectFee float64) {
+		rp, err := ratingDb.GetRatingPlan("drv", plan, utils.CACHE_SKIP)
+		if err != nil {
+			t.Fatalf("%s: %v", plan, err)
+		}
+		if _, found := rp.DestinationRates[code]; !found {
+			t.Fatalf("%s has no rates for %s", plan, code)
+		}
+		rating := rp.RateIntervalList(code)[0].Rating
+		if value := decFloat(rating.Rates[0].Value); value != rate || decFloat(rating.ConnectFee) != connectFee {
+			t.Errorf("%s %s: expected rate %v and connect fee %v, got %v and %v", plan, code, rate, connectFee, value, decFloat(rating.ConnectFee))
+		}
+	}
+	checkRate("RETAIL", "49", 0.12, 0)    // 0.115 rounded up
+	checkRate("RETAIL", "33", 0.01, 0.02) // the minimum rate, the connect fee as is
+	checkRate("PREMIUM", "49", 0.13, 0)
+	checkRate("PREMIUM", "33", 0.05, 0) // overridden
+	if rp, _ := ratingDb.GetRatingPlan("drv", "PREMIUM", utils.CACHE_SKIP); rp.RateIntervalList("33")[0].Rating.Rates[0].RateIncrement.Seconds() != 60 {
+		t.Error("the override should keep its own increments")
+	}
+
+	// reloading the parent re-derives the whole chain
+	writeTpFolder(t, path.Join(tmpDir, "v2"), map[string]string{
+		utils.RATES_CSV:              "drv,RT_DE2,0,0.2,60s,1s,0s\n",
+		utils.DESTINATION_RATES_JSON: `{"Tenant":"drv", "Tag":"DR_BUY", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_DE2"}, {"DestinationTag": "FRANCE", "RatesTag": "RT_FR"}]}`,
+		utils.RATING_PLANS_JSON:      buyPlan,
+	})
+	if _, err := LoadTariffPlanFromFolder(path.Join(tmpDir, "v2"), "UTC", utils.CSV_SEP, ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+	checkRate("RETAIL", "49", 0.23, 0)
+	checkRate("PREMIUM", "49", 0.24, 0)
+	checkRate("PREMIUM", "33", 0.05, 0)
+
+	// the derived plans follow the parent currency, the rates are not converted to another one
+	writeTpFolder(t, path.Join(tmpDir, "v3"), map[string]string{
+		utils.RATING_PLANS_JSON: `{"Tenant":"drv", "Tag":"WHOLESALE", "Currency":"EUR", "Bindings":[{"DestinationRatesTag": "DR_BUY", "TimingTag": "*any", "Weight": 10}]}`,
+	})
+	if _, err := LoadTariffPlanFromFolder(path.Join(tmpDir, "v3"), "UTC", utils.CSV_SEP, ratingDb, accountingDb); err != nil {
+		t.Fatal(err)
+	}
+	if rp, err := ratingDb.GetRatingPlan("drv", "PREMIUM", utils.CACHE_SKIP); err != nil || rp.Currency != "EUR" {
+		t.Errorf("the derived plan should follow the parent currency: %+v, %v", rp, err)
+	}
+	writeTpFolder(t, path.Join(tmpDir, "v4"), map[string]string{
+		utils.RATING_PLANS_JSON: `{"Tenant":"drv", "Tag":"RETAIL_USD", "Currency":"USD", "Bindings":[], "Derivation":{"ParentTag":"WHOLESALE", "MarkupPercent":15}}`,
+	})
+	if _, err := LoadTariffPlanFromFolder(path.Join(tmpDir, "v4"), "UTC", utils.CSV_SEP, ratingDb, accountingDb); err == nil {
+		t.Error("a plan in another currency than its parent should not be derived")
+	}
+
+	// the parents are exported first so the folder loads back
+	rps, err := NewTpExporter(ratingDb, accountingDb, "drv").ExportRatingPlans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rps) != 3 || rps[0].(*utils.TpRatingPlan).Tag != "WHOLESALE" || rps[1].(*utils.TpRatingPlan).Tag != "RETAIL" ||
+		rps[2].(*utils.TpRatingPlan).Derivation == nil || rps[2].(*utils.TpRatingPlan).Derivation.ParentTag != "RETAIL" {
+		t.Errorf("unexpected exported rating plans: %s", utils.ToIJSON(rps))
+	}
+	if _, err := ExportTariffPlanToFolder("drv", path.Join(tmpDir, "csv"), utils.CSV, utils.CSV_SEP, ratingDb, accountingDb); err == nil {
+		t.Error("derived rating plans can not be written in csv")
+	}
+}
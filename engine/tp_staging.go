@@ -0,0 +1,275 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/accurateproject/accurate/cache2go"
+	"github.com/accurateproject/accurate/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	// the tenant collections replaced by an activation, the accounts with their action plan bindings and tasks stay
+	stagedRatingCollections     = []string{ColDst, ColTmg, ColRts, ColDrt, ColXch, ColRpl, ColRpf, ColShg, ColLcr, ColAct, ColApl, ColAtr, ColDcs, ColCrs, ColTax}
+	stagedAccountingCollections = []string{ColUsr, ColAls, ColRL}
+	tpStagingMux                sync.Mutex // one staging or activation at a time
+)
+
+// StagedTpFile is a gzip compressed file of a staged tariff plan load
+type StagedTpFile struct {
+	Tenant  string `bson:"tenant"`
+	LoadID  string `bson:"load_id"`
+	Name    string `bson:"name"`
+	Content []byte `bson:"content"`
+}
+
+// StageTariffPlan loads the tariff plan folder in temporary storages and keeps a copy of every tenant found in it,
+// ready for ActivateTariffPlanLoad. The copy is stored next to the load history and written under stagingDir/tenant/LoadID.
+// The account actions are not staged.
+func StageTariffPlan(tpPath, stagingDir, timezone string, csvSep rune, accountingDb AccountingStorage) ([]*utils.LoadInstance, error) {
+	tpStagingMux.Lock()
+	defer tpStagingMux.Unlock()
+	var loads []*utils.LoadInstance
+	_, err := loadTariffPlanAside(tpPath, timezone, csvSep, func(tenants []string, stgRatingDb RatingStorage, stgAccountingDb AccountingStorage) error {
+		loadTime := time.Now()
+		loads = make([]*utils.LoadInstance, 0, len(tenants))
+		for _, tenant := range tenants {
+			load := &utils.LoadInstance{
+				LoadID:     utils.GenUUID(),
+				Tenant:     tenant,
+				SourcePath: tpPath,
+				LoadTime:   loadTime,
+			}
+			load.FolderPath = path.Join(stagingDir, tenant, load.LoadID)
+			exported, err := ExportTariffPlanToFolder(tenant, load.FolderPath, utils.JSON, utils.CSV_SEP, stgRatingDb, stgAccountingDb)
+			if err != nil {
+				return err
+			}
+			for fileName := range exported {
+				if err := storeStagedTpFile(accountingDb, load, fileName); err != nil {
+					return err
+				}
+			}
+			if err := accountingDb.SetLoadInstance(load); err != nil {
+				return err
+			}
+			loads = append(loads, load)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loads, nil
+}
+
+func storeStagedTpFile(accountingDb AccountingStorage, load *utils.LoadInstance, fileName string) error {
+	content, err := ioutil.ReadFile(path.Join(load.FolderPath, fileName))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if _, err := gzw.Write(content); err != nil {
+		return err
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+	return accountingDb.SetStagedTpFile(&StagedTpFile{Tenant: load.Tenant, LoadID: load.LoadID, Name: fileName, Content: buf.Bytes()})
+}
+
+// stagedTpFolder writes the stored files of the staged load in a temporary folder, removed by the caller
+func stagedTpFolder(accountingDb AccountingStorage, load *utils.LoadInstance) (string, error) {
+	files, err := accountingDb.GetStagedTpFiles(load.Tenant, load.LoadID)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		// history kept from before the staging or copied without its files by the migrator
+		return "", fmt.Errorf("no staged files for load %s of tenant %s", load.LoadID, load.Tenant)
+	}
+	tmpDir, err := ioutil.TempDir("", "tp_staged")
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		gzr, err := gzip.NewReader(bytes.NewReader(file.Content))
+		if err == nil {
+			var content []byte
+			if content, err = ioutil.ReadAll(gzr); err == nil {
+				err = ioutil.WriteFile(path.Join(tmpDir, path.Base(file.Name)), content, 0644)
+			}
+		}
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("%s: %v", file.Name, err)
+		}
+	}
+	return tmpDir, nil
+}
+
+// ActivateTariffPlanLoad replaces the tenant tariff plan in the storages with the staged load, an older LoadID rolls the tenant back.
+// The staged copy is loaded aside first, then the tenant documents are swapped with the loaded ones and the previous
+// documents are restored if that fails. The tenant cache keeps serving the previous plan until both storages are written.
+func ActivateTariffPlanLoad(tenant, loadID, timezone string, ratingDb RatingStorage, accountingDb AccountingStorage) (*utils.LoadInstance, *LoadStats, error) {
+	tpStagingMux.Lock()
+	defer tpStagingMux.Unlock()
+	loads, err := accountingDb.GetLoadHistory(tenant, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var load *utils.LoadInstance
+	for _, ld := range loads {
+		if ld.LoadID == loadID {
+			load = ld
+			break
+		}
+	}
+	if load == nil {
+		return nil, nil, utils.ErrNotFound
+	}
+	tpPath, err := stagedTpFolder(accountingDb, load)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(tpPath)
+	loadStats, err := loadTariffPlanAside(tpPath, timezone, utils.CSV_SEP, func(tenants []string, stgRatingDb RatingStorage, stgAccountingDb AccountingStorage) error {
+		// an activation replaces all the tenant documents, never with nothing
+		if !utils.IsSliceMember(tenants, tenant) {
+			return fmt.Errorf("load %s has no tariff plan data for tenant %s", loadID, tenant)
+		}
+		return replaceTenantTariffPlan(tenant, stgRatingDb, stgAccountingDb, ratingDb, accountingDb)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ld := range loads {
+		if ld.Active && ld != load {
+			ld.Active = false
+			if err := accountingDb.SetLoadInstance(ld); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	load.Active = true
+	load.ActivationTime = time.Now()
+	if err := accountingDb.SetLoadInstance(load); err != nil {
+		return nil, nil, err
+	}
+	return load, loadStats, nil
+}
+
+// replaceTenantTariffPlan swaps the tenant staged collections with the ones of the source storages, restoring the
+// previous documents on failure. The bolt storages swap each collection set in one transaction, the mongo ones
+// under a lock taken by the readers of the swapped collections, see RestoreTenantSnapshot.
+func replaceTenantTariffPlan(tenant string, srcRatingDb RatingStorage, srcAccountingDb AccountingStorage, ratingDb RatingStorage, accountingDb AccountingStorage) error {
+	newRating, err := srcRatingDb.GetTenantSnapshot(tenant, stagedRatingCollections...)
+	if err != nil {
+		return err
+	}
+	newAccounting, err := srcAccountingDb.GetTenantSnapshot(tenant, stagedAccountingCollections...)
+	if err != nil {
+		return err
+	}
+	oldRating, err := ratingDb.GetTenantSnapshot(tenant, stagedRatingCollections...)
+	if err != nil {
+		return err
+	}
+	oldAccounting, err := accountingDb.GetTenantSnapshot(tenant, stagedAccountingCollections...)
+	if err != nil {
+		return err
+	}
+	transID := cache2go.BeginTenantTransaction(tenant)
+	if err := ratingDb.RestoreTenantSnapshot(newRating); err != nil {
+		restoreTenantSnapshot(ratingDb, oldRating)
+		cache2go.RollbackTransaction(transID)
+		return err
+	}
+	if err := accountingDb.RestoreTenantSnapshot(newAccounting); err != nil {
+		restoreTenantSnapshot(accountingDb, oldAccounting)
+		restoreTenantSnapshot(ratingDb, oldRating)
+		cache2go.RollbackTransaction(transID)
+		return err
+	}
+	cache2go.CommitTransaction(transID)
+	return nil
+}
+
+func restoreTenantSnapshot(db Storage, snapshot *TenantSnapshot) {
+	if err := db.RestoreTenantSnapshot(snapshot); err != nil {
+		utils.Logger.Error("<TpStaging> could not restore the tariff plan of tenant "+snapshot.Tenant, zap.Error(err))
+	}
+}
+
+func getLoadInstance(accountingDb AccountingStorage, tenant, loadID string) (*utils.LoadInstance, error) {
+	loads, err := accountingDb.GetLoadHistory(tenant, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, load := range loads {
+		if load.LoadID == loadID {
+			return load, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+// loadTariffPlanAside loads the tariff plan folder in temporary storages with their own caches and passes them to the
+// callback together with the tenants found in the folder, tpStagingMux must be locked.
+func loadTariffPlanAside(tpPath, timezone string, csvSep rune, callback func(tenants []string, ratingDb RatingStorage, accountingDb AccountingStorage) error) (*LoadStats, error) {
+	tenants, err := tpFolderTenants(tpPath, csvSep)
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir("", "tp_staging")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	ratingDb, err := NewIsolatedBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB)
+	if err != nil {
+		return nil, err
+	}
+	defer ratingDb.Close()
+	accountingDb, err := NewIsolatedBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB)
+	if err != nil {
+		return nil, err
+	}
+	defer accountingDb.Close()
+	tpr, err := LoadTariffPlanFromFolder(tpPath, timezone, csvSep, ratingDb, accountingDb)
+	if err != nil {
+		return nil, err
+	}
+	if err := callback(tenants, ratingDb, accountingDb); err != nil {
+		return nil, err
+	}
+	return tpr.LoadStats(), nil
+}
+
+// tpFolderTenants returns the tenants found in the tariff plan folder, in the order they appear
+func tpFolderTenants(tpPath string, csvSep rune) ([]string, error) {
+	var tenants []string
+	found := utils.StringMap{}
+	for _, tpf := range tpFiles {
+		fileName, err := readTpFile(tpPath, tpf, csvSep, func(el interface{}) error {
+			if tenant, _ := tpElementKey(el); !found[tenant] {
+				found[tenant] = true
+				tenants = append(tenants, tenant)
+			}
+			return nil
+		})
+		if fileName != "" && err != nil {
+			return nil, err
+		}
+	}
+	return tenants, nil
+}
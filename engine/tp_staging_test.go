@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/cache2go"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func TestStageAndActivateTariffPlan(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_staging_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	liveRatingDb, err := NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer liveRatingDb.Close()
+	liveAccountingDb, err := NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer liveAccountingDb.Close()
+	stagingDir := path.Join(tmpDir, "staging")
+	stage := func(name, ratingPlan string) *utils.LoadInstance {
+		tpPath := path.Join(tmpDir, name)
+		writeTpFolder(t, tpPath, map[string]string{
+			utils.DESTINATIONS_CSV:       "stg,GERMANY,49\n",
+			utils.RATES_CSV:              "stg,RT_1,0,0.1,60s,1s,0s\n",
+			utils.DESTINATION_RATES_JSON: `{"Tenant":"stg", "Tag":"DR_1", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_1"}]}`,
+			utils.RATING_PLANS_JSON:      `{"Tenant":"stg", "Tag":"` + ratingPlan + `", "Bindings":[{"DestinationRatesTag": "DR_1", "TimingTag": "*any", "Weight": 10}]}`,
+			utils.RATING_PROFILES_JSON: `{"Direction":"*out", "Tenant":"stg", "Category":"call", "Subject":"*any", "Activations":[
+        {"ActivationTime":"2017-01-01T00:00:00Z", "RatingPlanTag":"` + ratingPlan + `"}]}`,
+		})
+		loads, err := StageTariffPlan(tpPath, stagingDir, "UTC", utils.CSV_SEP, liveAccountingDb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loads) != 1 || loads[0].Tenant != "stg" || loads[0].Active || loads[0].SourcePath != tpPath {
+			t.Fatalf("unexpected loads: %s", utils.ToIJSON(loads))
+		}
+		return loads[0]
+	}
+	checkRatingPlan := func(present, missing string) {
+		if _, err := liveRatingDb.GetRatingPlan("stg", present, utils.CACHED); err != nil {
+			t.Errorf("missing rating plan %s: %v", present, err)
+		}
+		if _, err := liveRatingDb.GetRatingPlan("stg", missing, utils.CACHED); err != utils.ErrNotFound {
+			t.Errorf("rating plan %s should be gone: %v", missing, err)
+		}
+	}
+
+	cache2go.Set("stg", "stg_marker", true, "")
+	load1 := stage("v1", "RP_1")
+	if _, err := liveRatingDb.GetRatingPlan("stg", "RP_1", utils.CACHE_SKIP); err != utils.ErrNotFound {
+		t.Error("staging wrote in the live storage: ", err)
+	}
+	if _, found := cache2go.Get("stg", utils.RATING_PLAN_PREFIX+"RP_1"); found {
+		t.Error("staging wrote in the tenant cache")
+	}
+	if _, found := cache2go.Get("stg", "stg_marker"); !found {
+		t.Error("staging reset the tenant cache")
+	}
+	// the staged copy is kept in the storage
+	if err := os.RemoveAll(stagingDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ActivateTariffPlanLoad("stg", load1.LoadID, "UTC", liveRatingDb, liveAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	checkRatingPlan("RP_1", "RP_2")
+
+	time.Sleep(5 * time.Millisecond)
+	load2 := stage("v2", "RP_2")
+	checkRatingPlan("RP_1", "RP_2")
+	if _, _, err := ActivateTariffPlanLoad("stg", load2.LoadID, "UTC", liveRatingDb, liveAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	checkRatingPlan("RP_2", "RP_1")
+	if rps, err := liveRatingDb.GetRatingProfiles("*out", "stg", "call", "*any", utils.CACHED); err != nil ||
+		len(rps) != 1 || rps[0].RatingPlanActivations[0].RatingPlanID != "RP_2" {
+		t.Errorf("unexpected rating profiles: %s, %v", utils.ToIJSON(rps), err)
+	}
+
+	// roll back
+	if _, _, err := ActivateTariffPlanLoad("stg", load1.LoadID, "UTC", liveRatingDb, liveAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	checkRatingPlan("RP_1", "RP_2")
+	loads, err := liveAccountingDb.GetLoadHistory("stg", 0)
+	if err != nil || len(loads) != 2 ||
+		loads[0].LoadID != load2.LoadID || loads[0].Active ||
+		loads[1].LoadID != load1.LoadID || !loads[1].Active || loads[1].ActivationTime.IsZero() {
+		t.Errorf("unexpected load history: %s, %v", utils.ToIJSON(loads), err)
+	}
+	if _, _, err := ActivateTariffPlanLoad("stg", "UNKNOWN", "UTC", liveRatingDb, liveAccountingDb); err != utils.ErrNotFound {
+		t.Error("activated unknown load: ", err)
+	}
+}
+
+// failingRestoreStorage fails to write the snapshots
+type failingRestoreStorage struct {
+	*BoltStorage
+}
+
+func (fs *failingRestoreStorage) RestoreTenantSnapshot(snapshot *TenantSnapshot) error {
+	if len(snapshot.Collections[ColUsr]) != 0 {
+		return utils.ErrServerError
+	}
+	return fs.BoltStorage.RestoreTenantSnapshot(snapshot)
+}
+
+func TestActivateTariffPlanRestore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_restore_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	liveRatingDb, err := NewBoltStorage(path.Join(tmpDir, "tariffplan.db"), utils.TariffPlanDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer liveRatingDb.Close()
+	liveAccountingDb, err := NewBoltStorage(path.Join(tmpDir, "data.db"), utils.DataDB, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer liveAccountingDb.Close()
+	writeTpFolder(t, path.Join(tmpDir, "v1"), map[string]string{
+		utils.RATES_CSV: "rst,RT_1,0,0.1,60s,1s,0s\n",
+	})
+	if _, err := LoadTariffPlanFromFolder(path.Join(tmpDir, "v1"), "UTC", utils.CSV_SEP, liveRatingDb, liveAccountingDb); err != nil {
+		t.Fatal(err)
+	}
+	writeTpFolder(t, path.Join(tmpDir, "v2"), map[string]string{
+		utils.RATES_CSV:  "rst,RT_2,0,0.2,60s,1s,0s\n",
+		utils.USERS_JSON: `{"Tenant":"rst", "Name":"u1", "Weight":10, "Query":"{'Account':{'$usr':'u1'}}"}`,
+	})
+	loads, err := StageTariffPlan(path.Join(tmpDir, "v2"), path.Join(tmpDir, "staging"), "UTC", utils.CSV_SEP, liveAccountingDb)
+	if err != nil || len(loads) != 1 {
+		t.Fatalf("unexpected loads: %s, %v", utils.ToIJSON(loads), err)
+	}
+	if _, _, err := ActivateTariffPlanLoad("rst", loads[0].LoadID, "UTC", liveRatingDb, &failingRestoreStorage{liveAccountingDb}); err != utils.ErrServerError {
+		t.Fatal("expected the storage error, got: ", err)
+	}
+	if _, err := liveRatingDb.GetRate("rst", "RT_1"); err != nil {
+		t.Error("the previous rate was not restored: ", err)
+	}
+	if _, err := liveRatingDb.GetRate("rst", "RT_2"); err != utils.ErrNotFound {
+		t.Error("the staged rate was left in the storage: ", err)
+	}
+	if loads, err := liveAccountingDb.GetLoadHistory("rst", 0); err != nil || len(loads) != 1 || loads[0].Active {
+		t.Errorf("unexpected load history: %s, %v", utils.ToIJSON(loads), err)
+	}
+	// a load without staged files (older history, migrated copies) would empty the tenant
+	if err := liveAccountingDb.SetLoadInstance(&utils.LoadInstance{Tenant: "rst", LoadID: "legacy", LoadTime: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ActivateTariffPlanLoad("rst", "legacy", "UTC", liveRatingDb, liveAccountingDb); err == nil {
+		t.Error("activated a load without staged files")
+	}
+	if _, err := liveRatingDb.GetRate("rst", "RT_1"); err != nil {
+		t.Error("the rate was removed by the refused activation: ", err)
+	}
+}
@@ -0,0 +1,628 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// TpIssue is one problem found by the tariff plan validation
+type TpIssue struct {
+	File    string
+	Tenant  string
+	ID      string // tag or key of the element
+	Message string
+}
+
+func (ti *TpIssue) String() string {
+	return fmt.Sprintf("%s %s:%s: %s", ti.File, ti.Tenant, ti.ID, ti.Message)
+}
+
+// TpValidationReport is the result of a tariff plan dry run, the folder loads only if there are no errors
+type TpValidationReport struct {
+	Files    map[string]int // number of elements read from each file
+	Errors   []*TpIssue
+	Warnings []*TpIssue // duplicates, unused elements and destinations not rated all week
+}
+
+func (tvr *TpValidationReport) IsValid() bool {
+	return len(tvr.Errors) == 0
+}
+
+// the kinds of elements expected to be referenced by others, with the kind of their users
+var tpUnusedWarnings = map[string]string{
+	utils.RATES_JSON:             "destination rate",
+	utils.DESTINATION_RATES_JSON: "rating plan",
+	utils.RATING_PLANS_JSON:      "rating profile",
+	utils.ACTIONS_JSON:           "action plan or action trigger",
+}
+
+type tpDefinition struct {
+	kind   string // the JSON file name of the element kind
+	file   string
+	tenant string
+	id     string
+}
+
+// tpValidator checks the tariff plan elements kept in memory, the references missing from the folder are searched in the storage
+type tpValidator struct {
+	ratingStorage      RatingStorage // nil to validate the folder on its own
+	timezone           string
+	report             *TpValidationReport
+	definitions        []*tpDefinition
+	defined            map[string]*tpDefinition // by kind, tenant and id
+	used               map[string]bool
+	destinationsByTag  map[string][]*Destination
+	destinationsByCode map[string][]*Destination
+	timings            map[string]*Timing
+	rates              map[string]*Rate // nil for the invalid ones
+	destinationRates   map[string]*DestinationRate
+	ratingCategories   utils.StringMap // tenant, direction and category of the rating profiles
+}
+
+// ValidateTariffPlanFolder reads the tariff plan files into memory and checks the elements and their references without writing anything.
+// The ratingDb is used for the references not found in the folder, it can be nil.
+func ValidateTariffPlanFolder(tpPath, timezone string, csvSep rune, ratingDb RatingStorage) (*TpValidationReport, error) {
+	if fi, err := os.Stat(tpPath); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", tpPath)
+	}
+	tv := &tpValidator{
+		ratingStorage:      ratingDb,
+		timezone:           timezone,
+		report:             &TpValidationReport{Files: make(map[string]int)},
+		defined:            make(map[string]*tpDefinition),
+		used:               make(map[string]bool),
+		destinationsByTag:  make(map[string][]*Destination),
+		destinationsByCode: make(map[string][]*Destination),
+		timings:            make(map[string]*Timing),
+		rates:              make(map[string]*Rate),
+		destinationRates:   make(map[string]*DestinationRate),
+		ratingCategories:   utils.StringMap{},
+	}
+	type tpFileElements struct {
+		kind     string
+		file     string
+		elements []interface{}
+	}
+	var files []*tpFileElements
+	for _, tpf := range tpFiles {
+		tfe := &tpFileElements{kind: tpf.jsonName}
+		fileName, err := readTpFile(tpPath, tpf, csvSep, func(el interface{}) error {
+			tfe.elements = append(tfe.elements, el)
+			return nil
+		})
+		if fileName == "" {
+			continue
+		}
+		if err != nil {
+			tv.addError(fileName, "", "", "%v", err)
+		}
+		tfe.file = fileName
+		tv.report.Files[fileName] = len(tfe.elements)
+		files = append(files, tfe)
+		for _, el := range tfe.elements {
+			tv.index(tfe.kind, fileName, el)
+		}
+	}
+	// the references point to the files loaded before so checking in the load order sees the same data as the loader
+	for _, tfe := range files {
+		for _, el := range tfe.elements {
+			tv.check(tfe.file, el)
+		}
+	}
+	for _, def := range tv.definitions {
+		if users, found := tpUnusedWarnings[def.kind]; found && !tv.used[utils.ConcatKey(def.kind, def.tenant, def.id)] {
+			tv.addWarning(def.file, def.tenant, def.id, "not used by any %s", users)
+		}
+	}
+	return tv.report, nil
+}
+
+func (tv *tpValidator) addError(file, tenant, id, format string, args ...interface{}) {
+	tv.report.Errors = append(tv.report.Errors, &TpIssue{File: file, Tenant: tenant, ID: id, Message: fmt.Sprintf(format, args...)})
+}
+
+func (tv *tpValidator) addWarning(file, tenant, id, format string, args ...interface{}) {
+	tv.report.Warnings = append(tv.report.Warnings, &TpIssue{File: file, Tenant: tenant, ID: id, Message: fmt.Sprintf(format, args...)})
+}
+
+// tpElementKey returns the tenant and the identifier of a tariff plan element
+func tpElementKey(el interface{}) (string, string) {
+	switch element := el.(type) {
+	case *utils.TpDestination:
+		return element.Tenant, element.Tag
+	case *utils.TpTiming:
+		return element.Tenant, element.Tag
+	case *utils.TpRate:
+		return element.Tenant, element.Tag
+	case *utils.TpDestinationRate:
+		return element.Tenant, element.Tag
+	case *utils.TpExchangeRate:
+		return element.Tenant, utils.ConcatKey(element.From, element.To)
+	case *utils.TpRatingPlan:
+		return element.Tenant, element.Tag
+	case *utils.TpRatingProfile:
+		return element.Tenant, utils.ConcatKey(element.Direction, element.Category, element.Subject)
+	case *utils.TpSharedGroup:
+		return element.Tenant, element.Tag
+	case *utils.TpLcrRule:
+		return element.Tenant, utils.ConcatKey(element.Direction, element.Category, element.Account, element.Subject)
+	case *utils.TpActionGroup:
+		return element.Tenant, element.Tag
+	case *utils.TpActionPlan:
+		return element.Tenant, element.Tag
+	case *utils.TpActionTrigger:
+		return element.Tenant, element.Tag
+	case *utils.TpAccountAction:
+		return element.Tenant, element.Account
+	case *utils.TpDerivedCharger:
+		return element.Tenant, utils.ConcatKey(element.Direction, element.Category, element.Account, element.Subject)
+	case *utils.TpCdrStats:
+		return element.Tenant, element.Tag
+	case *utils.TpTaxRule:
+		return element.Tenant, element.Tag
+	case *utils.TpUser:
+		return element.Tenant, element.Name
+	case *utils.TpAlias:
+		return element.Tenant, utils.ConcatKey(element.Direction, element.Category, element.Account, element.Subject, element.Context)
+	case *utils.TpResourceLimit:
+		return element.Tenant, element.Tag
+	}
+	return "", ""
+}
+
+// index records the element so it can be referenced, the destinations have one element for each code
+func (tv *tpValidator) index(kind, file string, el interface{}) {
+	tenant, id := tpElementKey(el)
+	key := utils.ConcatKey(kind, tenant, id)
+	if _, found := tv.defined[key]; found && kind != utils.DESTINATIONS_JSON {
+		tv.addWarning(file, tenant, id, "defined more than once, the last definition is loaded")
+	} else if !found {
+		def := &tpDefinition{kind: kind, file: file, tenant: tenant, id: id}
+		tv.defined[key] = def
+		tv.definitions = append(tv.definitions, def)
+	}
+	switch element := el.(type) {
+	case *utils.TpDestination:
+		dest := &Destination{Tenant: element.Tenant, Code: element.Code, Name: element.Tag}
+		tv.destinationsByTag[utils.ConcatKey(element.Tenant, element.Tag)] = append(tv.destinationsByTag[utils.ConcatKey(element.Tenant, element.Tag)], dest)
+		tv.destinationsByCode[utils.ConcatKey(element.Tenant, element.Code)] = append(tv.destinationsByCode[utils.ConcatKey(element.Tenant, element.Code)], dest)
+	case *utils.TpTiming:
+		tv.timings[utils.ConcatKey(tenant, id)] = &Timing{
+			Tenant:    element.Tenant,
+			Name:      element.Tag,
+			Years:     element.Years,
+			Months:    element.Months,
+			MonthDays: element.MonthDays,
+			WeekDays:  element.WeekDays,
+			Time:      element.Time,
+		}
+	case *utils.TpRate:
+		r, err := newRate(element)
+		if err != nil {
+			tv.addError(file, tenant, id, "%v", err)
+		} else if len(r.Slots) == 0 {
+			tv.addError(file, tenant, id, "the rate has no slots")
+			r = nil
+		}
+		tv.rates[utils.ConcatKey(tenant, id)] = r
+	case *utils.TpRatingProfile:
+		tv.ratingCategories[utils.ConcatKey(element.Tenant, element.Direction, element.Category)] = true
+	}
+}
+
+// has tells if the element is in the folder or else in the storage, marking it as used
+func (tv *tpValidator) has(kind, tenant, id string) bool {
+	key := utils.ConcatKey(kind, tenant, id)
+	tv.used[key] = true
+	if _, found := tv.defined[key]; found {
+		return true
+	}
+	if kind == utils.TIMINGS_JSON && getMetaTiming(tenant, id) != nil {
+		return true
+	}
+	if tv.ratingStorage == nil {
+		return false
+	}
+	switch kind {
+	case utils.DESTINATIONS_JSON:
+		dests, err := tv.ratingStorage.GetDestinations(tenant, "", id, utils.DestExact, utils.CACHE_SKIP)
+		return err == nil && len(dests) != 0
+	case utils.TIMINGS_JSON:
+		timing, err := tv.ratingStorage.GetTiming(tenant, id)
+		return err == nil && timing != nil
+	case utils.RATES_JSON:
+		rate, err := tv.ratingStorage.GetRate(tenant, id)
+		return err == nil && rate != nil
+	case utils.DESTINATION_RATES_JSON:
+		drate, err := tv.ratingStorage.GetDestinationRate(tenant, id)
+		return err == nil && drate != nil
+	case utils.RATING_PLANS_JSON:
+		rp, err := tv.ratingStorage.GetRatingPlan(tenant, id, utils.CACHE_SKIP)
+		return err == nil && rp != nil
+	case utils.SHARED_GROUPS_JSON:
+		sg, err := tv.ratingStorage.GetSharedGroup(tenant, id, utils.CACHE_SKIP)
+		return err == nil && sg != nil
+	case utils.ACTIONS_JSON:
+		ag, err := tv.ratingStorage.GetActionGroup(tenant, id, utils.CACHE_SKIP)
+		return err == nil && ag != nil
+	case utils.ACTION_PLANS_JSON:
+		apl, err := tv.ratingStorage.GetActionPlan(tenant, id, utils.CACHE_SKIP)
+		return err == nil && apl != nil
+	case utils.ACTION_TRIGGERS_JSON:
+		atrg, err := tv.ratingStorage.GetActionTriggers(tenant, id, utils.CACHE_SKIP)
+		return err == nil && atrg != nil
+	case utils.CDR_STATS_JSON:
+		cs, err := tv.ratingStorage.GetCdrStats(tenant, id)
+		return err == nil && cs != nil
+	}
+	return false
+}
+
+func (tv *tpValidator) getDestinations(tenant, code, tag string) []*Destination {
+	var dests []*Destination
+	if tag != "" {
+		tv.has(utils.DESTINATIONS_JSON, tenant, tag)
+		dests = tv.destinationsByTag[utils.ConcatKey(tenant, tag)]
+	} else {
+		dests = tv.destinationsByCode[utils.ConcatKey(tenant, code)]
+		for _, d := range dests {
+			tv.has(utils.DESTINATIONS_JSON, tenant, d.Name)
+		}
+	}
+	if len(dests) == 0 && tv.ratingStorage != nil {
+		dests, _ = tv.ratingStorage.GetDestinations(tenant, code, tag, utils.DestExact, utils.CACHE_SKIP)
+	}
+	return dests
+}
+
+func (tv *tpValidator) getTiming(tenant, tag string) *Timing {
+	if !tv.has(utils.TIMINGS_JSON, tenant, tag) {
+		return nil
+	}
+	if timing := getMetaTiming(tenant, tag); timing != nil {
+		return timing
+	}
+	if timing, found := tv.timings[utils.ConcatKey(tenant, tag)]; found {
+		return timing
+	}
+	timing, _ := tv.ratingStorage.GetTiming(tenant, tag)
+	return timing
+}
+
+func (tv *tpValidator) getRate(tenant, tag string) (*Rate, error) {
+	if !tv.has(utils.RATES_JSON, tenant, tag) {
+		return nil, utils.ErrNotFound
+	}
+	if rate, found := tv.rates[utils.ConcatKey(tenant, tag)]; found {
+		if rate == nil {
+			return nil, fmt.Errorf("invalid rate")
+		}
+		return rate, nil
+	}
+	return tv.ratingStorage.GetRate(tenant, tag)
+}
+
+func (tv *tpValidator) getDestinationRate(tenant, tag string) *DestinationRate {
+	if !tv.has(utils.DESTINATION_RATES_JSON, tenant, tag) {
+		return nil
+	}
+	if drate, found := tv.destinationRates[utils.ConcatKey(tenant, tag)]; found {
+		return drate
+	}
+	if _, found := tv.defined[utils.ConcatKey(utils.DESTINATION_RATES_JSON, tenant, tag)]; found {
+		return nil // not built because of errors
+	}
+	drate, _ := tv.ratingStorage.GetDestinationRate(tenant, tag)
+	return drate
+}
+
+func (tv *tpValidator) getRatingProfile(direction, tenant, category string) (*RatingProfile, error) {
+	if tv.ratingStorage == nil {
+		return nil, utils.ErrNotFound
+	}
+	return tv.ratingStorage.GetRatingProfile(direction, tenant, category, "", false, utils.CACHE_SKIP)
+}
+
+// checkActivationTime parses the activation time, reporting the unparsable and the repeated ones
+func (tv *tpValidator) checkActivationTime(file, tenant, id, activationTime string, seen map[time.Time]bool) {
+	at, err := utils.ParseDate(activationTime)
+	if err != nil {
+		tv.addError(file, tenant, id, "cannot parse activation time %s", activationTime)
+		return
+	}
+	if seen[at] {
+		tv.addError(file, tenant, id, "more than one activation at %s", activationTime)
+	}
+	seen[at] = true
+}
+
+func (tv *tpValidator) checkStructQ(file, tenant, id, field, query string) {
+	if query == "" {
+		return
+	}
+	if _, err := utils.NewStructQ(strings.Replace(query, `'`, `"`, -1)); err != nil {
+		tv.addError(file, tenant, id, "error parsing %s %s (%v)", field, query, err)
+	}
+}
+
+func (tv *tpValidator) checkDestinationIDs(file, tenant, id string, destIDs utils.StringMap) {
+	for destID := range destIDs {
+		if destID != "" && destID != utils.ANY && !tv.has(utils.DESTINATIONS_JSON, tenant, destID) {
+			tv.addError(file, tenant, id, "unknown destination %s", destID)
+		}
+	}
+}
+
+func (tv *tpValidator) checkRounding(file, tenant, id string, rounding *utils.TpRounding) {
+	if rounding == nil {
+		return
+	}
+	if !dec.IsRoundingMethod(rounding.Method) {
+		tv.addError(file, tenant, id, "unknown rounding method %s", rounding.Method)
+	}
+	if rounding.Decimals < 0 {
+		tv.addError(file, tenant, id, "negative rounding decimals %d", rounding.Decimals)
+	}
+}
+
+// check validates the values and the references of the element
+func (tv *tpValidator) check(file string, el interface{}) {
+	tenant, id := tpElementKey(el)
+	switch element := el.(type) {
+	case *utils.TpDestinationRate:
+		dr := &DestinationRate{
+			Tenant:   element.Tenant,
+			Name:     element.Tag,
+			Bindings: make(map[string]*DestinationRateBinding),
+		}
+		valid := true
+		for _, binding := range element.Bindings {
+			uniqueCodes := make(map[string]string)
+			if binding.DestinationTag == utils.ANY || binding.DestinationCode == utils.ANY {
+				uniqueCodes[utils.ANY] = utils.ANY
+			}
+			if binding.DestinationCode == "" && binding.DestinationTag == "" {
+				tv.addWarning(file, tenant, id, "the binding of rate %s has no destination", binding.RatesTag)
+			}
+			if binding.DestinationCode != "" && binding.DestinationCode != utils.ANY {
+				dests := tv.getDestinations(tenant, binding.DestinationCode, "")
+				if len(dests) == 0 {
+					tv.addError(file, tenant, id, "unknown destination code %s", binding.DestinationCode)
+					valid = false
+				}
+				for _, d := range dests {
+					uniqueCodes[d.Code] = d.Name
+				}
+			}
+			if binding.DestinationTag != "" && binding.DestinationTag != utils.ANY {
+				dests := tv.getDestinations(tenant, "", binding.DestinationTag)
+				if len(dests) == 0 {
+					tv.addError(file, tenant, id, "unknown destination %s", binding.DestinationTag)
+					valid = false
+				}
+				for _, d := range dests {
+					uniqueCodes[d.Code] = d.Name
+				}
+			}
+			if !tv.has(utils.RATES_JSON, tenant, binding.RatesTag) {
+				tv.addError(file, tenant, id, "unknown rate %s", binding.RatesTag)
+				valid = false
+			}
+			tv.checkRounding(file, tenant, id, binding.Rounding)
+			for destinationCode, destinationName := range uniqueCodes {
+				dr.Bindings[fmt.Sprintf("%s_%s", destinationCode, binding.RatesTag)] = &DestinationRateBinding{
+					DestinationCode: destinationCode,
+					DestinationName: destinationName,
+					RateID:          binding.RatesTag,
+					MaxCost:         binding.MaxCost,
+					MaxCostStrategy: binding.MaxCostStrategy,
+					Rounding:        newRounding(binding.Rounding),
+				}
+			}
+		}
+		if valid {
+			tv.destinationRates[utils.ConcatKey(tenant, id)] = dr
+		}
+	case *utils.TpRatingPlan:
+		rp := &RatingPlan{Tenant: element.Tenant, Name: element.Tag, Rounding: newRounding(element.Rounding)}
+		tv.checkRounding(file, tenant, id, element.Rounding)
+		if d := element.Derivation; d != nil {
+			if d.ParentTag == element.Tag {
+				tv.addError(file, tenant, id, "derived from itself")
+			} else if !tv.has(utils.RATING_PLANS_JSON, tenant, d.ParentTag) {
+				tv.addError(file, tenant, id, "unknown parent rating plan %s", d.ParentTag)
+			}
+			if d.RoundingMethod != "" && !dec.IsRoundingMethod(d.RoundingMethod) {
+				tv.addError(file, tenant, id, "unknown rounding method %s", d.RoundingMethod)
+			}
+		}
+		complete := true
+		for _, binding := range element.Bindings {
+			timing := tv.getTiming(tenant, binding.TimingTag)
+			if timing == nil {
+				tv.addError(file, tenant, id, "unknown timing %s", binding.TimingTag)
+			}
+			drate := tv.getDestinationRate(tenant, binding.DestinationRatesTag)
+			if drate == nil {
+				tv.addError(file, tenant, id, "unknown or invalid destination rate %s", binding.DestinationRatesTag)
+			}
+			if timing == nil || drate == nil {
+				complete = false
+				continue
+			}
+			if err := rp.addDestinationRate(drate, timing, binding.Weight, func(rateID string) (*Rate, error) {
+				return tv.getRate(tenant, rateID)
+			}); err != nil {
+				tv.addError(file, tenant, id, "%v", err)
+				complete = false
+			}
+		}
+		if !complete || element.Derivation != nil { // the parent rates complete the derived plans
+			break
+		}
+		if err := rp.checkSanity(); err != nil {
+			tv.addError(file, tenant, id, "%v", err)
+		}
+		if codes := rp.getUncoveredDestinations(); len(codes) != 0 {
+			tv.addWarning(file, tenant, id, "no rates on some weekdays for destination codes %s", strings.Join(codes, ","))
+		}
+	case *utils.TpRatingProfile:
+		seen := make(map[time.Time]bool)
+		for _, activation := range element.Activations {
+			tv.checkActivationTime(file, tenant, id, activation.ActivationTime, seen)
+			if !tv.has(utils.RATING_PLANS_JSON, tenant, activation.RatingPlanTag) {
+				tv.addError(file, tenant, id, "unknown rating plan %s", activation.RatingPlanTag)
+			}
+			for _, queueID := range activation.CdrStatQueueIDs {
+				if !tv.has(utils.CDR_STATS_JSON, tenant, queueID) {
+					tv.addError(file, tenant, id, "unknown cdr stats queue %s", queueID)
+				}
+			}
+		}
+	case *utils.TpExchangeRate:
+		if element.From == "" || element.To == "" || element.From == element.To {
+			tv.addError(file, tenant, id, "invalid currencies %s and %s", element.From, element.To)
+		}
+		seen := make(map[time.Time]bool)
+		for _, activation := range element.Activations {
+			tv.checkActivationTime(file, tenant, id, activation.ActivationTime, seen)
+			if activation.Rate <= 0 {
+				tv.addError(file, tenant, id, "invalid rate %v", activation.Rate)
+			}
+		}
+	case *utils.TpLcrRule:
+		seen := make(map[time.Time]bool)
+		for _, activation := range element.Activations {
+			tv.checkActivationTime(file, tenant, id, activation.ActivationTime, seen)
+			for _, entry := range activation.Entries {
+				if !tv.ratingCategories[utils.ConcatKey(tenant, element.Direction, entry.RPCategory)] {
+					if rpf, err := tv.getRatingProfile(element.Direction, tenant, entry.RPCategory); err != nil || rpf == nil {
+						tv.addError(file, tenant, id, "no rating profiles for category %s", entry.RPCategory)
+					}
+				}
+				if entry.DestinationTag != utils.ANY && !tv.has(utils.DESTINATIONS_JSON, tenant, entry.DestinationTag) {
+					tv.addError(file, tenant, id, "unknown destination %s", entry.DestinationTag)
+				}
+			}
+		}
+	case *utils.TpActionGroup:
+		for idx, action := range element.Actions {
+			actionID := fmt.Sprintf("%s[%d]", id, idx)
+			tv.checkStructQ(file, tenant, actionID, "exec filter", action.ExecFilter)
+			tv.checkStructQ(file, tenant, actionID, "filter", action.Filter)
+			if !strings.Contains(action.Params, "Balance") {
+				continue
+			}
+			var params struct {
+				Balance *struct {
+					SharedGroups   utils.StringMap
+					TimingTags     []string
+					DestinationIDs utils.StringMap
+				}
+			}
+			if err := json.Unmarshal([]byte(strings.Replace(action.Params, `'`, `"`, -1)), &params); err != nil {
+				tv.addError(file, tenant, actionID, "error parsing params %s (%v)", action.Params, err)
+				continue
+			}
+			if params.Balance == nil {
+				continue
+			}
+			for sgID := range params.Balance.SharedGroups {
+				if sgID != "" && !tv.has(utils.SHARED_GROUPS_JSON, tenant, sgID) {
+					tv.addError(file, tenant, actionID, "unknown shared group %s", sgID)
+				}
+			}
+			for _, timingTag := range params.Balance.TimingTags {
+				if !tv.has(utils.TIMINGS_JSON, tenant, timingTag) {
+					tv.addError(file, tenant, actionID, "unknown timing %s", timingTag)
+				}
+			}
+			tv.checkDestinationIDs(file, tenant, actionID, params.Balance.DestinationIDs)
+		}
+	case *utils.TpActionPlan:
+		for _, at := range element.ActionTimings {
+			if !tv.has(utils.ACTIONS_JSON, tenant, at.ActionsTag) {
+				tv.addError(file, tenant, id, "unknown actions %s", at.ActionsTag)
+			}
+			if !tv.has(utils.TIMINGS_JSON, tenant, at.TimingTag) {
+				tv.addError(file, tenant, id, "unknown timing %s", at.TimingTag)
+			}
+		}
+	case *utils.TpActionTrigger:
+		for _, atr := range element.Triggers {
+			if !tv.has(utils.ACTIONS_JSON, tenant, atr.ActionsTag) {
+				tv.addError(file, tenant, id, "unknown actions %s", atr.ActionsTag)
+			}
+			if _, err := utils.ParseTimeDetectLayout(atr.ExpiryTime, tv.timezone); err != nil {
+				tv.addError(file, tenant, id, "cannot parse expiry time %s", atr.ExpiryTime)
+			}
+			if _, err := utils.ParseTimeDetectLayout(atr.ActivationTime, tv.timezone); err != nil {
+				tv.addError(file, tenant, id, "cannot parse activation time %s", atr.ActivationTime)
+			}
+			if _, err := utils.ParseDurationWithSecs(atr.MinSleep); err != nil {
+				tv.addError(file, tenant, id, "cannot parse min sleep %s", atr.MinSleep)
+			}
+			tv.checkStructQ(file, tenant, id, "filter", atr.Filter)
+		}
+	case *utils.TpAccountAction:
+		for _, aplTag := range element.ActionPlanTags {
+			if strings.TrimSpace(aplTag) != "" && !tv.has(utils.ACTION_PLANS_JSON, tenant, aplTag) {
+				tv.addError(file, tenant, id, "unknown action plan %s", aplTag)
+			}
+		}
+		for _, atrTag := range element.ActionTriggerTags {
+			if strings.TrimSpace(atrTag) != "" && !tv.has(utils.ACTION_TRIGGERS_JSON, tenant, atrTag) {
+				tv.addError(file, tenant, id, "unknown action triggers %s", atrTag)
+			}
+		}
+	case *utils.TpDerivedCharger:
+		tv.checkDestinationIDs(file, tenant, id, utils.NewStringMap(element.DestinationIDs...))
+		for _, tpDc := range element.Chargers {
+			if _, err := utils.NewDerivedCharger(tpDc.RunID, strings.Replace(tpDc.Filter, `'`, `"`, -1), strings.Replace(tpDc.Fields, `'`, `"`, -1)); err != nil {
+				tv.addError(file, tenant, id, "invalid charger %s (%v)", tpDc.RunID, err)
+			}
+		}
+	case *utils.TpCdrStats:
+		if _, err := utils.ParseDurationWithSecs(element.TimeWindow); err != nil {
+			tv.addError(file, tenant, id, "cannot parse time window %s", element.TimeWindow)
+		}
+		tv.checkStructQ(file, tenant, id, "filter", element.Filter)
+		for _, atrTag := range element.ActionTriggerTags {
+			if !tv.has(utils.ACTION_TRIGGERS_JSON, tenant, atrTag) {
+				tv.addError(file, tenant, id, "unknown action triggers %s", atrTag)
+			}
+		}
+	case *utils.TpTaxRule:
+		switch element.Type {
+		case TAX_PERCENT, TAX_COMPOUND:
+		case TAX_PER_UNIT:
+			if unit, err := utils.ParseDurationWithSecs(element.Unit); err != nil || unit <= 0 {
+				tv.addError(file, tenant, id, "cannot parse unit %s", element.Unit)
+			}
+		default:
+			tv.addError(file, tenant, id, "unsupported type %s", element.Type)
+		}
+		if _, err := utils.ParseDate(element.ActivationTime); err != nil {
+			tv.addError(file, tenant, id, "cannot parse activation time %s", element.ActivationTime)
+		}
+		if _, err := utils.ParseDate(element.ExpirationTime); err != nil {
+			tv.addError(file, tenant, id, "cannot parse expiration time %s", element.ExpirationTime)
+		}
+		tv.checkDestinationIDs(file, tenant, id, utils.NewStringMap(element.DestinationTags...))
+	case *utils.TpUser:
+		tv.checkStructQ(file, tenant, id, "query", element.Query)
+	case *utils.TpAlias:
+		for _, value := range element.Values {
+			tv.checkStructQ(file, tenant, id, "fields", value.Fields)
+			tv.checkDestinationIDs(file, tenant, id, utils.NewStringMap(value.DestinationTag))
+		}
+	}
+}
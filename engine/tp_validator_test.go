@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/accurateproject/accurate/utils"
+)
+
+func writeTpFolder(t *testing.T, tpPath string, files map[string]string) {
+	if err := os.MkdirAll(tpPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, content := range files {
+		if err := ioutil.WriteFile(path.Join(tpPath, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func hasTpIssue(issues []*TpIssue, id, message string) bool {
+	for _, issue := range issues {
+		if issue.ID == id && strings.HasPrefix(issue.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateTariffPlanFolder(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tp_validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	validPath := path.Join(tmpDir, "valid")
+	writeTpFolder(t, validPath, map[string]string{
+		utils.DESTINATIONS_JSON:      destinations,
+		utils.TIMINGS_JSON:           timings,
+		utils.RATES_JSON:             rates,
+		utils.DESTINATION_RATES_JSON: destinationRates,
+		utils.RATING_PLANS_JSON:      ratingPlans,
+		utils.RATING_PROFILES_JSON:   ratingProfiles,
+		utils.SHARED_GROUPS_JSON:     sharedGroups,
+		utils.LCRS_JSON:              lcrs,
+		utils.ACTIONS_JSON:           actions,
+		utils.ACTION_PLANS_JSON:      actionPlans,
+		utils.ACTION_TRIGGERS_JSON:   actionTriggers,
+		utils.ACCOUNT_ACTIONS_JSON:   accountActions,
+		utils.DERIVED_CHARGERS_JSON:  derivedChargers,
+		utils.CDR_STATS_JSON:         cdrStats,
+		utils.USERS_JSON:             users,
+		utils.ALIASES_JSON:           aliases,
+	})
+	report, err := ValidateTariffPlanFolder(validPath, "UTC", utils.CSV_SEP, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the loader only checks some of the references
+	if len(report.Errors) != 9 || report.Files[utils.RATES_JSON] != 15 ||
+		!hasTpIssue(report.Errors, "STANDARD_TRIGGER", "unknown actions SOME_1") ||
+		!hasTpIssue(report.Errors, "STANDARD_TRIGGERS", "error parsing filter") ||
+		!hasTpIssue(report.Errors, "*out:call:dan:dan:*rating", "unknown destination GLOBAL1") {
+		t.Errorf("unexpected report: %s", utils.ToIJSON(report))
+	}
+	if !hasTpIssue(report.Warnings, "DEFAULT", "no rates on some weekdays for destination codes 43") {
+		t.Errorf("missing gap warning: %s", utils.ToIJSON(report.Warnings))
+	}
+
+	invalidPath := path.Join(tmpDir, "invalid")
+	writeTpFolder(t, invalidPath, map[string]string{
+		utils.DESTINATIONS_CSV: "x,GERMANY,49\n",
+		utils.TIMINGS_CSV:      "x,WORKDAYS,*any,*any,*any,1;2;3;4;5,00:00:00\n",
+		utils.RATES_CSV:        "x,RT_1,0,0.1,60s,1s,0s\nx,RT_2,0,0.1,sixty,1s,0s\nx,RT_3,0,0.1,60s,1s,0s\n",
+		utils.DESTINATION_RATES_JSON: `
+{"Tenant":"x", "Tag":"DR_1", "Bindings":[{"DestinationTag": "GERMANY", "RatesTag": "RT_1"}]}
+{"Tenant":"x", "Tag":"DR_2", "Bindings":[{"DestinationTag": "FRANCE", "RatesTag": "RT_4"}]}
+`,
+		utils.RATING_PLANS_JSON: `
+{"Tenant":"x", "Tag":"RP_WORKDAYS", "Bindings":[{"DestinationRatesTag": "DR_1", "TimingTag": "WORKDAYS", "Weight": 10}]}
+{"Tenant":"x", "Tag":"RP_2", "Bindings":[{"DestinationRatesTag": "DR_2", "TimingTag": "*any", "Weight": 10}]}
+{"Tenant":"x", "Tag":"RP_3", "Bindings":[], "Derivation":{"ParentTag":"RP_NONE", "MarkupPercent":10, "RoundingDecimals":2, "RoundingMethod":"*sideways"}}
+`,
+		utils.RATING_PROFILES_JSON: `
+{"Direction":"*out", "Tenant":"x", "Category":"call", "Subject":"*any", "Activations":[
+        {"ActivationTime":"2017-01-01T00:00:00Z", "RatingPlanTag":"RP_WORKDAYS"},
+        {"ActivationTime":"2017-01-01T00:00:00Z", "RatingPlanTag":"RP_MISSING"}
+]}
+`,
+		utils.ACTIONS_JSON: `
+{"Tenant":"x", "Tag":"TOPUP", "Actions":[
+         {"Action":"*topup", "TOR":"*monetary", "Params":"{'Balance':{'Value':10, 'SharedGroups':'SG_MISSING'}}", "Weight":10}
+]}
+`,
+		utils.ACTION_TRIGGERS_JSON: `
+{"Tenant":"x", "Tag":"STANDARD_TRIGGERS", "Triggers":[{"ThresholdType":"*min_balance", "ThresholdValue":2, "TOR":"*monetary", "MinSleep":"0s", "ActionsTag":"LOG_WARNING", "Weight":10}]}
+`,
+	})
+	if report, err = ValidateTariffPlanFolder(invalidPath, "UTC", utils.CSV_SEP, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []*TpIssue{
+		&TpIssue{ID: "RT_2", Message: "error parsing rate unit sixty"},
+		&TpIssue{ID: "DR_2", Message: "unknown destination FRANCE"},
+		&TpIssue{ID: "DR_2", Message: "unknown rate RT_4"},
+		&TpIssue{ID: "RP_WORKDAYS", Message: "The rating plan RP_WORKDAYS is not covering all weekdays"},
+		&TpIssue{ID: "RP_2", Message: "unknown or invalid destination rate DR_2"},
+		&TpIssue{ID: "RP_3", Message: "unknown parent rating plan RP_NONE"},
+		&TpIssue{ID: "RP_3", Message: "unknown rounding method *sideways"},
+		&TpIssue{ID: "*out:call:*any", Message: "more than one activation at 2017-01-01T00:00:00Z"},
+		&TpIssue{ID: "*out:call:*any", Message: "unknown rating plan RP_MISSING"},
+		&TpIssue{ID: "TOPUP[0]", Message: "unknown shared group SG_MISSING"},
+		&TpIssue{ID: "STANDARD_TRIGGERS", Message: "unknown actions LOG_WARNING"},
+	} {
+		if !hasTpIssue(report.Errors, expected.ID, expected.Message) {
+			t.Errorf("missing error %+v in: %s", expected, utils.ToIJSON(report.Errors))
+		}
+	}
+	if len(report.Errors) != 11 || !hasTpIssue(report.Warnings, "RT_3", "not used by any destination rate") ||
+		!hasTpIssue(report.Warnings, "RP_2", "not used by any rating profile") {
+		t.Errorf("unexpected report: %s", utils.ToIJSON(report))
+	}
+}
@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+	"go.uber.org/zap"
+)
+
+const TRANSFER = "*transfer"
+
+// BalanceTransfer moves a value between the balances of two accounts, possibly from different tenants
+type BalanceTransfer struct {
+	FromTenant  string
+	FromAccount string
+	ToTenant    string
+	ToAccount   string
+	BalanceType string   // *monetary, *voice, *sms...
+	Value       *dec.Dec // must be positive
+	FromFilter  string   // selects the debited balances, empty for all of the balance type
+	ToFilter    string   // selects the credited balance, empty for the *default one (created if missing)
+}
+
+// Execute locks both accounts, debits the source balances by weight and credits the destination balance.
+// The value is in the currency of the debited balances and is converted to the currency of the credited one.
+// Both accounts are written or none, the transfer is logged as a single cdr with the *transfer run id.
+// The action triggers crossed by the transfer are fired only after both accounts were written.
+func (bt *BalanceTransfer) Execute() (cdr *CDR, err error) {
+	if bt.Value == nil || !bt.Value.GtZero() {
+		return nil, errors.New("transfer value must be positive")
+	}
+	if bt.FromTenant == bt.ToTenant && bt.FromAccount == bt.ToAccount {
+		return nil, utils.ErrSameAccount
+	}
+	fromFilter, err := utils.NewStructQ(bt.FromFilter)
+	if err != nil {
+		return nil, err
+	}
+	toFilter, err := utils.NewStructQ(bt.ToFilter)
+	if err != nil {
+		return nil, err
+	}
+	// always lock in the same order so two opposite transfers cannot deadlock
+	lockKeys := []string{utils.ConcatKey(bt.FromTenant, bt.FromAccount), utils.ConcatKey(bt.ToTenant, bt.ToAccount)}
+	sort.Strings(lockKeys)
+	_, err = Guardian.Guard(func() (interface{}, error) {
+		cdr, err = bt.execute(fromFilter, toFilter)
+		return 0, err
+	}, 0, lockKeys...)
+	return cdr, err
+}
+
+func (bt *BalanceTransfer) getAccount(tenant, name string, out bool) (*Account, error) {
+	acc, err := accountingStorage.GetAccount(tenant, name)
+	if err == utils.ErrNotFound {
+		return nil, utils.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if acc.Disabled {
+		return nil, utils.ErrAccountDisabled
+	}
+	if err := acc.authorizeTransfer(out); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (bt *BalanceTransfer) execute(fromFilter, toFilter *utils.StructQ) (*CDR, error) {
+	from, err := bt.getAccount(bt.FromTenant, bt.FromAccount, true)
+	if err != nil {
+		return nil, err
+	}
+	to, err := bt.getAccount(bt.ToTenant, bt.ToAccount, false)
+	if err != nil {
+		return nil, err
+	}
+	originID := utils.GenUUID()
+	// untouched copies used to roll back a partial write
+	originals := make(map[*Account]*Account)
+	for _, acc := range []*Account{from, to} {
+		if originals[acc], err = accountingStorage.GetAccount(acc.Tenant, acc.Name); err != nil {
+			return nil, err
+		}
+		// journal the rollback against the written account
+		originals[acc].ledger = nil
+		originals[acc].SetLedgerCause(LEDGER_TRANSFER, originID)
+		acc.SetLedgerCause(LEDGER_TRANSFER, originID)
+	}
+	debited, currency, err := bt.debit(from, fromFilter)
+	if err != nil {
+		return nil, err
+	}
+	credited, creditedValue, err := bt.credit(to, toFilter, currency)
+	if err != nil {
+		return nil, err
+	}
+	// the crossed triggers are only marked here, their actions run once the transfer is written
+	postATIDs := make(map[*Account][]string)
+	for _, acc := range []*Account{from, to} {
+		acc.InitCounters()
+		postATIDs[acc] = acc.ExecuteActionTriggers(nil, true)
+	}
+
+	var written []*Account
+	rollback := func() {
+		for i := len(written) - 1; i >= 0; i-- {
+			acc := written[i]
+			if err := accountingStorage.SetAccount(originals[acc]); err != nil {
+				utils.Logger.Error("<BalanceTransfer> rollback failed", zap.String("tenant", acc.Tenant), zap.String("account", acc.Name), zap.Error(err))
+			}
+		}
+	}
+	for _, acc := range []*Account{from, to} {
+		if err := accountingStorage.SetAccount(acc); err != nil {
+			rollback()
+			return nil, err
+		}
+		written = append(written, acc)
+	}
+
+	cdr := bt.auditCDR(originID, debited, currency, credited, creditedValue)
+	if cdrStorage != nil {
+		if err := cdrStorage.SetCDR(cdr, false); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+	utils.Logger.Info("<BalanceTransfer> transferred", zap.String("from", utils.ConcatKey(bt.FromTenant, bt.FromAccount)),
+		zap.String("to", utils.ConcatKey(bt.ToTenant, bt.ToAccount)), zap.String("type", bt.BalanceType),
+		zap.String("value", bt.Value.String()), zap.String("id", cdr.UniqueID))
+	for _, acc := range []*Account{from, to} {
+		if len(postATIDs[acc]) == 0 {
+			continue
+		}
+		acc.processPostActionTriggers(postATIDs[acc], nil)
+		if err := accountingStorage.SetAccount(acc); err != nil {
+			utils.Logger.Error("<BalanceTransfer> saving the triggered actions failed", zap.String("tenant", acc.Tenant), zap.String("account", acc.Name), zap.Error(err))
+		}
+	}
+	return cdr, nil
+}
+
+// debit takes the value from the matching balances in weight order, returns the debited amount per balance uuid
+// and the currency of the debited balances, which must all share one
+func (bt *BalanceTransfer) debit(acc *Account, filter *utils.StructQ) (map[string]*dec.Dec, string, error) {
+	var balances Balances
+	for _, b := range acc.BalanceMap[bt.BalanceType] {
+		if b.IsExpired() || b.Disabled {
+			continue
+		}
+		match, err := filter.Query(b, false)
+		if err != nil {
+			return nil, "", err
+		}
+		if match {
+			balances = append(balances, b)
+		}
+	}
+	if len(balances) == 0 {
+		return nil, "", utils.ErrNotFound
+	}
+	currency := balances[0].Currency
+	for _, b := range balances {
+		if b.Currency != currency {
+			return nil, "", utils.ErrCurrencyMismatch
+		}
+	}
+	balances.Sort()
+	debited := make(map[string]*dec.Dec)
+	remaining := dec.New().Set(bt.Value)
+	for _, b := range balances {
+		amount := dec.New().Set(remaining)
+		if !b.Unlimited {
+			if !b.GetValue().GtZero() {
+				continue
+			}
+			if b.GetValue().Cmp(amount) < 0 {
+				amount.Set(b.GetValue())
+			}
+		}
+		b.SubstractValue(amount)
+		debited[b.UUID] = amount
+		remaining.SubS(amount)
+		if remaining.IsZero() {
+			return debited, currency, nil
+		}
+	}
+	b := balances[0]
+	if bt.BalanceType == utils.MONETARY && acc.hasCreditLimit(b) {
+		if acc.creditLeft(b).Cmp(remaining) < 0 {
+			return nil, "", utils.ErrInsufficientCredit
+		}
+	} else if !acc.AllowNegative {
+		return nil, "", utils.ErrInsufficientCredit
+	}
+	// the rest goes negative on the main balance
+	b.SubstractValue(remaining)
+	if amount, found := debited[b.UUID]; found {
+		amount.AddS(remaining)
+	} else {
+		debited[b.UUID] = remaining
+	}
+	return debited, currency, nil
+}
+
+// credit adds the value, converted from the debited currency, to the first matching balance.
+// Returns its uuid and the credited value.
+func (bt *BalanceTransfer) credit(acc *Account, filter *utils.StructQ, currency string) (string, *dec.Dec, error) {
+	if acc.BalanceMap == nil {
+		acc.BalanceMap = make(map[string]Balances, 1)
+	}
+	for _, b := range acc.BalanceMap[bt.BalanceType] {
+		if b.IsExpired() {
+			continue
+		}
+		if bt.ToFilter == "" {
+			if b.IsDefault() {
+				return bt.creditBalance(acc, b, currency)
+			}
+			continue
+		}
+		match, err := filter.Query(b, false)
+		if err != nil {
+			return "", nil, err
+		}
+		if match {
+			return bt.creditBalance(acc, b, currency)
+		}
+	}
+	if bt.ToFilter != "" {
+		return "", nil, utils.ErrNotFound
+	}
+	b := &Balance{UUID: utils.GenUUID(), ID: utils.META_DEFAULT, Value: dec.New().Set(bt.Value), Currency: currency, dirty: true}
+	acc.BalanceMap[bt.BalanceType] = append(acc.BalanceMap[bt.BalanceType], b)
+	return b.UUID, b.Value, nil
+}
+
+func (bt *BalanceTransfer) creditBalance(acc *Account, b *Balance, currency string) (string, *dec.Dec, error) {
+	conv, err := getCurrencyConversion(acc.Tenant, currency, b.Currency, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	value := conv.Convert(bt.Value)
+	b.AddValue(value)
+	return b.UUID, value, nil
+}
+
+func (bt *BalanceTransfer) auditCDR(originID string, debited map[string]*dec.Dec, currency, credited string, creditedValue *dec.Dec) *CDR {
+	now := time.Now()
+	cdr := &CDR{
+		RunID:       TRANSFER,
+		Source:      TRANSFER,
+		OriginHost:  "127.0.0.1",
+		OriginID:    originID,
+		ToR:         bt.BalanceType,
+		RequestType: utils.META_PREPAID,
+		Direction:   utils.OUT,
+		Tenant:      bt.FromTenant,
+		Account:     bt.FromAccount,
+		Subject:     bt.FromAccount,
+		Destination: bt.ToAccount,
+		SetupTime:   now,
+		AnswerTime:  now,
+		Cost:        dec.New().Set(bt.Value),
+		ExtraFields: map[string]string{
+			"ToTenant":        bt.ToTenant,
+			"ToAccount":       bt.ToAccount,
+			"Currency":        currency,
+			"CreditedBalance": credited,
+			"CreditedValue":   creditedValue.String(),
+		},
+	}
+	cdr.UniqueID = utils.Sha1(cdr.OriginID, cdr.SetupTime.String())
+	for uuid, amount := range debited {
+		cdr.ExtraFields["Debited:"+uuid] = amount.String()
+	}
+	return cdr
+}
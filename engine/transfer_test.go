@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+func setTransferAccounts(t *testing.T) {
+	for _, acc := range []*Account{
+		&Account{Tenant: "transfer1", Name: "wallet", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+			&Balance{UUID: "w1", ID: "bonus", Value: dec.NewFloat(3), Weight: 20},
+			&Balance{UUID: "w2", ID: utils.META_DEFAULT, Value: dec.NewFloat(10), Weight: 10},
+		}}},
+		&Account{Tenant: "transfer2", Name: "wallet", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+			&Balance{UUID: "w3", ID: utils.META_DEFAULT, Value: dec.NewFloat(1)},
+		}}},
+	} {
+		if err := accountingStorage.SetAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getTransferBalance(t *testing.T, tenant, uuid string) *dec.Dec {
+	acc, err := accountingStorage.GetAccount(tenant, "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := acc.BalanceMap[utils.MONETARY].GetBalance(uuid); b != nil {
+		return b.GetValue()
+	}
+	return nil
+}
+
+func TestBalanceTransfer(t *testing.T) {
+	setTransferAccounts(t)
+	bt := &BalanceTransfer{FromTenant: "transfer1", FromAccount: "wallet", ToTenant: "transfer2", ToAccount: "wallet",
+		BalanceType: utils.MONETARY, Value: dec.NewFloat(5)}
+	cdr, err := bt.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdr.RunID != TRANSFER || cdr.ExtraFields["CreditedBalance"] != "w3" || cdr.ExtraFields["Debited:w1"] == "" {
+		t.Errorf("bad audit record: %s", utils.ToIJSON(cdr))
+	}
+	// the heavier balance is used first
+	if v := getTransferBalance(t, "transfer1", "w1"); v.Cmp(dec.Zero) != 0 {
+		t.Error("bad bonus balance: ", v)
+	}
+	if v := getTransferBalance(t, "transfer1", "w2"); v.Cmp(dec.NewFloat(8)) != 0 {
+		t.Error("bad default balance: ", v)
+	}
+	if v := getTransferBalance(t, "transfer2", "w3"); v.Cmp(dec.NewFloat(6)) != 0 {
+		t.Error("bad credited balance: ", v)
+	}
+}
+
+func TestBalanceTransferFilters(t *testing.T) {
+	setTransferAccounts(t)
+	bt := &BalanceTransfer{FromTenant: "transfer1", FromAccount: "wallet", ToTenant: "transfer2", ToAccount: "wallet",
+		BalanceType: utils.MONETARY, Value: dec.NewFloat(2), FromFilter: `{"ID":"*default"}`, ToFilter: `{"UUID":"w3"}`}
+	if _, err := bt.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if v := getTransferBalance(t, "transfer1", "w1"); v.Cmp(dec.NewFloat(3)) != 0 {
+		t.Error("filtered out balance debited: ", v)
+	}
+	if v := getTransferBalance(t, "transfer1", "w2"); v.Cmp(dec.NewFloat(8)) != 0 {
+		t.Error("bad default balance: ", v)
+	}
+	bt.ToFilter = `{"UUID":"missing"}`
+	if _, err := bt.Execute(); err != utils.ErrNotFound {
+		t.Error("expected not found destination balance, got: ", err)
+	}
+	if v := getTransferBalance(t, "transfer1", "w2"); v.Cmp(dec.NewFloat(8)) != 0 {
+		t.Error("failed transfer changed the source: ", v)
+	}
+}
+
+func TestBalanceTransferInsufficientCredit(t *testing.T) {
+	setTransferAccounts(t)
+	bt := &BalanceTransfer{FromTenant: "transfer1", FromAccount: "wallet", ToTenant: "transfer2", ToAccount: "wallet",
+		BalanceType: utils.MONETARY, Value: dec.NewFloat(20)}
+	if _, err := bt.Execute(); err != utils.ErrInsufficientCredit {
+		t.Error("expected insufficient credit, got: ", err)
+	}
+	if v := getTransferBalance(t, "transfer1", "w2"); v.Cmp(dec.NewFloat(10)) != 0 {
+		t.Error("failed transfer changed the source: ", v)
+	}
+	if v := getTransferBalance(t, "transfer2", "w3"); v.Cmp(dec.NewFloat(1)) != 0 {
+		t.Error("failed transfer changed the destination: ", v)
+	}
+	bt.ToTenant = bt.FromTenant
+	if _, err := bt.Execute(); err != utils.ErrSameAccount {
+		t.Error("expected same account error, got: ", err)
+	}
+}
+
+func TestBalanceTransferOpposite(t *testing.T) {
+	setTransferAccounts(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			(&BalanceTransfer{FromTenant: "transfer1", FromAccount: "wallet", ToTenant: "transfer2", ToAccount: "wallet",
+				BalanceType: utils.MONETARY, Value: dec.NewFloat(1), FromFilter: `{"ID":"*default"}`}).Execute()
+		}()
+		go func() {
+			defer wg.Done()
+			(&BalanceTransfer{FromTenant: "transfer2", FromAccount: "wallet", ToTenant: "transfer1", ToAccount: "wallet",
+				BalanceType: utils.MONETARY, Value: dec.NewFloat(1)}).Execute()
+		}()
+	}
+	wg.Wait()
+	// whatever the order, no value is lost or created
+	total := dec.New().Add(getTransferBalance(t, "transfer1", "w2"), getTransferBalance(t, "transfer2", "w3"))
+	if total.Cmp(dec.NewFloat(11)) != 0 {
+		t.Error("bad total after opposite transfers: ", total)
+	}
+}
+
+func TestBalanceTransferCurrencyAndState(t *testing.T) {
+	if err := ratingStorage.SetExchangeRate(&ExchangeRate{Tenant: "transfer3", From: "USD", To: "EUR", Activations: []*ExchangeRateActivation{
+		&ExchangeRateActivation{ActivationTime: time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC), Rate: dec.NewVal(8, 1)},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, acc := range []*Account{
+		&Account{Tenant: "transfer3", Name: "usd", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+			&Balance{UUID: "u1", ID: utils.META_DEFAULT, Value: dec.NewFloat(10), Currency: "USD"},
+			&Balance{UUID: "u2", ID: "bonus", Value: dec.NewFloat(10), Currency: "GBP"},
+		}}},
+		&Account{Tenant: "transfer3", Name: "eur", BalanceMap: map[string]Balances{utils.MONETARY: Balances{
+			&Balance{UUID: "e1", ID: utils.META_DEFAULT, Value: dec.NewFloat(1), Currency: "EUR"},
+		}}},
+	} {
+		if err := accountingStorage.SetAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bt := &BalanceTransfer{FromTenant: "transfer3", FromAccount: "usd", ToTenant: "transfer3", ToAccount: "eur",
+		BalanceType: utils.MONETARY, Value: dec.NewFloat(5)}
+	if _, err := bt.Execute(); err != utils.ErrCurrencyMismatch {
+		t.Error("expected currency mismatch, got: ", err)
+	}
+	bt.FromFilter = `{"Currency":"USD"}`
+	cdr, err := bt.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdr.ExtraFields["Currency"] != "USD" || cdr.ExtraFields["CreditedValue"] != "4.0" {
+		t.Errorf("bad audit record: %s", utils.ToIJSON(cdr))
+	}
+	acc, err := accountingStorage.GetAccount("transfer3", "eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := acc.BalanceMap[utils.MONETARY].GetBalance("e1").GetValue(); v.Cmp(dec.NewFloat(5)) != 0 {
+		t.Error("the credit was not converted: ", v)
+	}
+	acc.InitState(ACCOUNT_CLOSED, "test")
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bt.Execute(); err != utils.ErrAccountClosed {
+		t.Error("expected closed destination, got: ", err)
+	}
+	bt.FromAccount, bt.ToAccount = "eur", "usd"
+	bt.FromFilter = ""
+	if _, err := bt.Execute(); err != utils.ErrAccountClosed {
+		t.Error("expected closed source, got: ", err)
+	}
+}
@@ -28,7 +28,7 @@ func CheckVersion(acntDB AccountingStorage) error {
 			}
 		} else if loadHistory[0].LoadID == "" {
 			// has data but no version => run migration
-			msg := "Could not detect data structures version: run appropriate migration"
+			msg := "Could not detect data structures version: run cc-migrator"
 			utils.Logger.Panic(msg)
 			return errors.New(msg)
 		}
@@ -36,7 +36,7 @@ func CheckVersion(acntDB AccountingStorage) error {
 		// comparing versions
 		if len(CurrentVersion.CompareAndMigrate(dbVersion)) > 0 {
 			// write the new values
-			msg := "Migration needed: please backup cgr data and run cgr-cloader -migrate"
+			msg := "Migration needed: please backup accuRate data and run cc-migrator"
 			utils.Logger.Panic(msg)
 			return errors.New(msg)
 		}
@@ -55,7 +55,7 @@ var (
 		ActionPlans:     "1",
 		ActionTriggers:  "1",
 		SharedGroups:    "1",
-		Accounts:        "1",
+		Accounts:        "2",
 		CdrStats:        "1",
 		Users:           "1",
 		Alias:           "1",
@@ -208,8 +208,8 @@ func (sv *StructVersion) CompareAndMigrate(dbVer *StructVersion) []*MigrationInf
 	if sv.Cdrs != dbVer.Cdrs {
 		migrationInfoList = append(migrationInfoList, &MigrationInfo{
 			Prefix:         utils.CDRS_SOURCE,
-			DbVersion:      dbVer.RatingPlans,
-			CurrentVersion: CurrentVersion.RatingPlans,
+			DbVersion:      dbVer.Cdrs,
+			CurrentVersion: CurrentVersion.Cdrs,
 		})
 	}
 	if sv.SMCosts != dbVer.SMCosts {
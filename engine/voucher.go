@@ -0,0 +1,382 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+	"go.uber.org/zap"
+)
+
+// voucher batch states
+const (
+	VOUCHER_CREATED   = "*created"   // generated, not yet usable
+	VOUCHER_ACTIVATED = "*activated" // released to the retail channel, redeemable
+	VOUCHER_SOLD      = "*sold"      // sold to the customers, redeemable
+	VOUCHER_REDEEMED  = "*redeemed"  // all the vouchers were redeemed
+	VOUCHER_BLOCKED   = "*blocked"   // lost or stolen, not redeemable
+)
+
+const (
+	VOUCHER_PIN_LENGTH     = 12
+	VOUCHER_MIN_PIN_LENGTH = 8
+	VOUCHER_MAX_BATCH_SIZE = 100000
+	voucherPINHashRounds   = 1000 // slows down guessing the pins from a copy of the vouchers
+)
+
+var (
+	// failed redemptions allowed per account and per source in the window before the redemptions are refused
+	VoucherMaxFailures       = 5
+	VoucherMaxSourceFailures = 20
+	VoucherFailureWindow     = time.Hour
+
+	voucherTransitions = map[string]utils.StringMap{
+		VOUCHER_CREATED:   utils.NewStringMap(VOUCHER_ACTIVATED, VOUCHER_BLOCKED),
+		VOUCHER_ACTIVATED: utils.NewStringMap(VOUCHER_SOLD, VOUCHER_BLOCKED),
+		VOUCHER_SOLD:      utils.NewStringMap(VOUCHER_BLOCKED),
+		VOUCHER_BLOCKED:   utils.NewStringMap(VOUCHER_ACTIVATED),
+		VOUCHER_REDEEMED:  utils.StringMap{},
+	}
+)
+
+// VoucherBatch groups the vouchers generated together, they share value, expiry, actions and state
+type VoucherBatch struct {
+	Tenant     string               `bson:"tenant"`
+	ID         string               `bson:"id"`
+	ActionsID  string               `bson:"actions_id"`  // action group executed on the redeeming account
+	Value      *dec.Dec             `bson:"value"`       // face value printed on the cards
+	ExpiryTime time.Time            `bson:"expiry_time"` // zero for no expiry
+	Size       int                  `bson:"size"`
+	Redeemed   int                  `bson:"redeemed"`
+	State      string               `bson:"state"`
+	StateTimes map[string]time.Time `bson:"state_times"` // when the batch entered each state
+}
+
+// Voucher is a recharge card, the pin is unique per tenant and only its hash is stored
+type Voucher struct {
+	Tenant     string    `bson:"tenant"`
+	BatchID    string    `bson:"batch_id"`
+	Serial     string    `bson:"serial"` // printed on the card, identifies it without the pin
+	PINHash    string    `bson:"pin_hash"`
+	Redeemed   bool      `bson:"redeemed"`
+	Account    string    `bson:"account"` // the account that redeemed it, or is redeeming it while not redeemed
+	RedeemedAt time.Time `bson:"redeemed_at"`
+}
+
+// voucherPINHash hashes the pin salted with the tenant, in many rounds since the pins are short
+func voucherPINHash(tenant, pin string) string {
+	sum := sha256.Sum256([]byte(tenant + utils.CONCATENATED_KEY_SEP + pin))
+	for i := 1; i < voucherPINHashRounds; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return hex.EncodeToString(sum[:])
+}
+
+func (batch *VoucherBatch) setState(state string) {
+	batch.State = state
+	if batch.StateTimes == nil {
+		batch.StateTimes = make(map[string]time.Time)
+	}
+	batch.StateTimes[state] = time.Now()
+}
+
+func (batch *VoucherBatch) redeemable(now time.Time) bool {
+	return (batch.State == VOUCHER_ACTIVATED || batch.State == VOUCHER_SOLD) &&
+		(batch.ExpiryTime.IsZero() || now.Before(batch.ExpiryTime))
+}
+
+// NewVoucherBatch generates and stores a batch of vouchers with unique pins of pinLength digits (0 for the default).
+// The pins are not stored, the returned csv records for printing are the only copy of them.
+func NewVoucherBatch(tenant, id, actionsID string, value *dec.Dec, expiryTime time.Time, size, pinLength int) (*VoucherBatch, []byte, error) {
+	if size <= 0 || size > VOUCHER_MAX_BATCH_SIZE {
+		return nil, nil, fmt.Errorf("invalid batch size: %d", size)
+	}
+	if pinLength == 0 {
+		pinLength = VOUCHER_PIN_LENGTH
+	}
+	if pinLength < VOUCHER_MIN_PIN_LENGTH {
+		return nil, nil, fmt.Errorf("pin length must be at least %d", VOUCHER_MIN_PIN_LENGTH)
+	}
+	if _, err := ratingStorage.GetActionGroup(tenant, actionsID, utils.CACHED); err != nil {
+		return nil, nil, fmt.Errorf("error getting action group %s: %v", actionsID, err)
+	}
+	if value == nil {
+		value = dec.New()
+	}
+	batch := &VoucherBatch{Tenant: tenant, ID: id, ActionsID: actionsID, Value: value, ExpiryTime: expiryTime, Size: size}
+	batch.setState(VOUCHER_CREATED)
+	// a pin already used by the tenant fails the whole batch, generate it again
+	for attempt := 0; ; attempt++ {
+		vouchers, pins, err := batch.generate(pinLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = accountingStorage.AddVoucherBatch(batch, vouchers)
+		if err == nil {
+			cards, err := batch.export(vouchers, pins)
+			return batch, cards, err
+		}
+		if err != utils.ErrExists || attempt == 2 {
+			return nil, nil, err
+		}
+		if existing, _ := accountingStorage.GetVoucherBatch(tenant, id); existing != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// generate returns the vouchers with the hashed pins and the pins in the same order
+func (batch *VoucherBatch) generate(pinLength int) ([]*Voucher, []string, error) {
+	seen := make(map[string]bool, batch.Size)
+	vouchers := make([]*Voucher, 0, batch.Size)
+	pins := make([]string, 0, batch.Size)
+	serialFormat := fmt.Sprintf("%%s-%%0%dd", len(fmt.Sprint(batch.Size)))
+	for len(vouchers) < batch.Size {
+		pin, err := randomPIN(pinLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen[pin] {
+			continue
+		}
+		seen[pin] = true
+		pins = append(pins, pin)
+		vouchers = append(vouchers, &Voucher{Tenant: batch.Tenant, BatchID: batch.ID,
+			Serial: fmt.Sprintf(serialFormat, batch.ID, len(vouchers)+1), PINHash: voucherPINHash(batch.Tenant, pin)})
+	}
+	return vouchers, pins, nil
+}
+
+func randomPIN(length int) (string, error) {
+	pin := make([]byte, length)
+	ten := big.NewInt(10)
+	for i := range pin {
+		d, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		pin[i] = byte('0' + d.Int64())
+	}
+	return string(pin), nil
+}
+
+// SetVoucherBatchState moves the batch to the state if the transition is allowed
+func SetVoucherBatchState(tenant, id, state string) (batch *VoucherBatch, err error) {
+	_, err = Guardian.Guard(func() (interface{}, error) {
+		if batch, err = accountingStorage.GetVoucherBatch(tenant, id); err != nil {
+			return 0, err
+		}
+		if _, known := voucherTransitions[state]; !known {
+			return 0, fmt.Errorf("unknown voucher batch state: %s", state)
+		}
+		if state == batch.State {
+			return 0, nil
+		}
+		if !voucherTransitions[batch.State][state] {
+			return 0, utils.ErrInvalidStateTransition
+		}
+		batch.setState(state)
+		return 0, accountingStorage.SetVoucherBatch(batch)
+	}, 0, utils.ConcatKey(ColVbt, tenant, id))
+	return
+}
+
+// ExportVoucherBatch renders the stored batch vouchers as csv records, without the pins which are not stored
+func ExportVoucherBatch(tenant, id string) ([]byte, error) {
+	batch, err := accountingStorage.GetVoucherBatch(tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	vouchers, err := accountingStorage.GetVouchers(tenant, id, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return batch.export(vouchers, nil)
+}
+
+// export renders the vouchers as csv records, with the pins column only if the pins are given
+func (batch *VoucherBatch) export(vouchers []*Voucher, pins []string) ([]byte, error) {
+	expiry := ""
+	if !batch.ExpiryTime.IsZero() {
+		expiry = batch.ExpiryTime.Format(time.RFC3339)
+	}
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	records := [][]string{{"Serial", "Value", "ExpiryTime", "Redeemed"}}
+	if pins != nil {
+		records = [][]string{{"Serial", "PIN", "Value", "ExpiryTime"}}
+	}
+	for i, v := range vouchers {
+		if pins != nil {
+			records = append(records, []string{v.Serial, pins[i], batch.Value.String(), expiry})
+		} else {
+			records = append(records, []string{v.Serial, batch.Value.String(), expiry, fmt.Sprint(v.Redeemed)})
+		}
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RedeemVoucher executes the voucher actions on the account, all or nothing, and marks the voucher redeemed.
+// Every attempt is counted before the pin is looked up, per account and per source (the caller address,
+// empty if unknown), and only the failed ones stay counted; past the limits the redemptions of that
+// account or source are refused until the failure window ends.
+// The unknown, used and blocked pins all return ErrVoucherNotRedeemable.
+func RedeemVoucher(tenant, account, source, pin string) (v *Voucher, err error) {
+	window := time.Now().Truncate(VoucherFailureWindow)
+	counted, err := countVoucherAttempt(tenant, account, source, window)
+	if err == nil {
+		pinHash := voucherPINHash(tenant, pin)
+		_, err = Guardian.Guard(func() (interface{}, error) {
+			v, err = redeemVoucher(tenant, account, pinHash)
+			return 0, err
+		}, 0, utils.ConcatKey(ColVch, tenant, pinHash))
+	}
+	if err != utils.ErrVoucherNotRedeemable && err != utils.ErrTooManyAttempts {
+		for _, key := range counted {
+			if _, cntErr := accountingStorage.AddVoucherAttempts(tenant, key, window, -1); cntErr != nil {
+				utils.Logger.Error("<Vouchers> could not uncount the redeem attempt", zap.String("tenant", tenant), zap.String("key", key), zap.Error(cntErr))
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// countVoucherAttempt counts the attempt on the account and on the source, the keys already counted are returned with the error
+func countVoucherAttempt(tenant, account, source string, window time.Time) (counted []string, err error) {
+	keys, limits := []string{account}, []int{VoucherMaxFailures}
+	if source != "" {
+		keys, limits = append(keys, voucherSourceKey(source)), append(limits, VoucherMaxSourceFailures)
+	}
+	for i, key := range keys {
+		count, err := accountingStorage.AddVoucherAttempts(tenant, key, window, 1)
+		if err != nil {
+			return counted, err
+		}
+		counted = append(counted, key)
+		if count > limits[i] {
+			return counted, utils.ErrTooManyAttempts
+		}
+	}
+	return counted, nil
+}
+
+// voucherSourceKey keeps the source attempts apart from the account ones
+func voucherSourceKey(source string) string {
+	return utils.ConcatKey("*source", source)
+}
+
+func redeemVoucher(tenant, account, pinHash string) (*Voucher, error) {
+	v, err := accountingStorage.GetVoucher(tenant, pinHash)
+	if err == utils.ErrNotFound {
+		return nil, utils.ErrVoucherNotRedeemable
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v.Redeemed {
+		return nil, utils.ErrVoucherNotRedeemable
+	}
+	batch, err := accountingStorage.GetVoucherBatch(tenant, v.BatchID)
+	if err == utils.ErrNotFound {
+		return nil, utils.ErrVoucherNotRedeemable
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !batch.redeemable(time.Now()) {
+		return nil, utils.ErrVoucherNotRedeemable
+	}
+	if err := v.redeem(batch, account); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// redeem records the redeeming account on the voucher first, then saves the topped up account together with
+// the voucher serial and marks the voucher redeemed. A redeem stopped after saving the account is found
+// by the serial on the recorded account, the next redeem only marks the voucher so the top-up is never
+// applied twice. Runs under the voucher lock, the account is locked with the same key as the debits.
+func (v *Voucher) redeem(batch *VoucherBatch, account string) error {
+	ag, err := ratingStorage.GetActionGroup(v.Tenant, batch.ActionsID, utils.CACHED)
+	if err != nil {
+		return err
+	}
+	ag.SetParentGroup()
+	if v.Account != "" {
+		if acc, err := accountingStorage.GetAccount(v.Tenant, v.Account); err == nil && acc.Vouchers[v.Serial] {
+			v.Redeemed, v.RedeemedAt = true, time.Now()
+			if err := accountingStorage.MarkVoucherRedeemed(v); err != nil {
+				return err
+			}
+			if v.Account != account {
+				return utils.ErrVoucherNotRedeemable
+			}
+			return nil
+		} else if err != nil && err != utils.ErrNotFound {
+			return err
+		}
+	}
+	v.Account = account
+	if err := accountingStorage.SetVoucher(v); err != nil {
+		return err
+	}
+	_, err = Guardian.Guard(func() (interface{}, error) {
+		acc, err := accountingStorage.GetAccount(v.Tenant, account)
+		if err != nil {
+			return 0, err
+		}
+		if acc.Disabled || acc.GetState() == ACCOUNT_CLOSED {
+			return 0, utils.ErrAccountDisabled
+		}
+		acc.SetLedgerCause(LEDGER_VOUCHER, v.Serial)
+		for _, a := range ag.Actions {
+			actionFunction, exists := getActionFunc(a.ActionType)
+			if !exists {
+				return 0, fmt.Errorf("unknown action type: %s", a.ActionType)
+			}
+			acc.setLedgerAction(a.ActionType)
+			// the account is saved only if all the actions succeed
+			if err := actionFunction(acc, nil, a, ag.Actions); err != nil {
+				return 0, err
+			}
+		}
+		if acc.Vouchers == nil {
+			acc.Vouchers = utils.StringMap{}
+		}
+		acc.Vouchers[v.Serial] = true
+		if err := accountingStorage.SetAccount(acc); err != nil {
+			return 0, err
+		}
+		v.Redeemed, v.RedeemedAt = true, time.Now()
+		return 0, accountingStorage.MarkVoucherRedeemed(v)
+	}, 0, utils.ConcatKey(v.Tenant, account))
+	return err
+}
+
+// countRedeemed counts a redeemed voucher, the batch is redeemed with its last voucher
+func (batch *VoucherBatch) countRedeemed() {
+	if batch.Redeemed++; batch.Redeemed >= batch.Size {
+		batch.setState(VOUCHER_REDEEMED)
+	}
+}
+
+// voucherAttempts counts the redeem attempts of an account or of a source in a failure window
+type voucherAttempts struct {
+	Tenant string    `bson:"tenant"`
+	Key    string    `bson:"key"`    // the account, or *source:<source>
+	Window time.Time `bson:"window"` // start of the failure window
+	Count  int       `bson:"count"`
+}
@@ -0,0 +1,245 @@
+package engine
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/accurateproject/accurate/dec"
+	"github.com/accurateproject/accurate/utils"
+)
+
+// voucherPins reads the pins out of the cards of a new batch, in serial order
+func voucherPins(t *testing.T, cards []byte) []string {
+	records, err := csv.NewReader(strings.NewReader(string(cards))).ReadAll()
+	if err != nil || len(records) == 0 || records[0][1] != "PIN" {
+		t.Fatalf("bad cards: %s, %v", cards, err)
+	}
+	pins := make([]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		pins = append(pins, record[1])
+	}
+	return pins
+}
+
+func TestVoucherBatchGeneration(t *testing.T) {
+	if err := ratingStorage.SetActionGroup(&ActionGroup{Tenant: "voucher", Name: "TOPUP", Actions: Actions{
+		&Action{ActionType: TOPUP, TOR: utils.MONETARY, Params: `{"Balance":{"Value":10}}`}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := NewVoucherBatch("voucher", "B0", "MISSING", dec.NewFloat(10), time.Time{}, 3, 0); err == nil {
+		t.Error("batch created with missing actions")
+	}
+	if _, _, err := NewVoucherBatch("voucher", "B0", "TOPUP", dec.NewFloat(10), time.Time{}, 3, 4); err == nil {
+		t.Error("batch created with short pins")
+	}
+	batch, cards, err := NewVoucherBatch("voucher", "B1", "TOPUP", dec.NewFloat(10), time.Time{}, 12, 0)
+	if err != nil || batch.State != VOUCHER_CREATED || batch.Size != 12 {
+		t.Fatalf("bad batch: %s, %v", utils.ToIJSON(batch), err)
+	}
+	if _, _, err := NewVoucherBatch("voucher", "B1", "TOPUP", dec.NewFloat(10), time.Time{}, 1, 0); err != utils.ErrExists {
+		t.Errorf("expected existing batch, got: %v", err)
+	}
+	vouchers, err := accountingStorage.GetVouchers("voucher", "B1", 0, 0)
+	if err != nil || len(vouchers) != 12 || vouchers[0].Serial != "B1-01" || vouchers[11].Serial != "B1-12" {
+		t.Fatalf("bad vouchers: %s, %v", utils.ToIJSON(vouchers), err)
+	}
+	pins := voucherPins(t, cards)
+	if len(pins) != 12 {
+		t.Fatalf("bad cards: %s", cards)
+	}
+	seen := make(map[string]bool)
+	for i, v := range vouchers {
+		if len(pins[i]) != VOUCHER_PIN_LENGTH || seen[pins[i]] || v.PINHash != voucherPINHash("voucher", pins[i]) {
+			t.Errorf("bad pin: %s for %s", pins[i], utils.ToIJSON(v))
+		}
+		seen[pins[i]] = true
+	}
+	out, err := ExportVoucherBatch("voucher", "B1")
+	if err != nil || strings.Count(string(out), "\n") != 13 || strings.Contains(string(out), pins[0]) {
+		t.Errorf("bad export: %s, %v", out, err)
+	}
+	if _, err := SetVoucherBatchState("voucher", "B1", VOUCHER_SOLD); err != utils.ErrInvalidStateTransition {
+		t.Errorf("expected invalid transition, got: %v", err)
+	}
+	if batch, err = SetVoucherBatchState("voucher", "B1", VOUCHER_ACTIVATED); err != nil || batch.State != VOUCHER_ACTIVATED ||
+		batch.StateTimes[VOUCHER_ACTIVATED].IsZero() {
+		t.Errorf("bad activated batch: %s, %v", utils.ToIJSON(batch), err)
+	}
+}
+
+func TestVoucherRedeem(t *testing.T) {
+	if err := ratingStorage.SetActionGroup(&ActionGroup{Tenant: "voucher", Name: "TOPUP", Actions: Actions{
+		&Action{ActionType: TOPUP, TOR: utils.MONETARY, Params: `{"Balance":{"Value":10}}`}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accountingStorage.SetAccount(&Account{Tenant: "voucher", Name: "redeemer"}); err != nil {
+		t.Fatal(err)
+	}
+	_, cards, err := NewVoucherBatch("voucher", "R1", "TOPUP", dec.NewFloat(10), time.Time{}, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins := voucherPins(t, cards)
+	if _, err := RedeemVoucher("voucher", "redeemer", "", pins[0]); err != utils.ErrVoucherNotRedeemable {
+		t.Errorf("redeemed a voucher of a created batch: %v", err)
+	}
+	if _, err := SetVoucherBatchState("voucher", "R1", VOUCHER_ACTIVATED); err != nil {
+		t.Fatal(err)
+	}
+	v, err := RedeemVoucher("voucher", "redeemer", "", pins[0])
+	if err != nil || !v.Redeemed || v.Account != "redeemer" {
+		t.Fatalf("bad redeemed voucher: %s, %v", utils.ToIJSON(v), err)
+	}
+	acc, err := accountingStorage.GetAccount("voucher", "redeemer")
+	if err != nil || len(acc.BalanceMap[utils.MONETARY]) != 1 || acc.BalanceMap[utils.MONETARY][0].GetValue().String() != "10" ||
+		!acc.Vouchers[v.Serial] {
+		t.Errorf("bad account after redeem: %s, %v", utils.ToIJSON(acc), err)
+	}
+	if _, err := RedeemVoucher("voucher", "redeemer", "", pins[0]); err != utils.ErrVoucherNotRedeemable {
+		t.Errorf("voucher redeemed twice: %v", err)
+	}
+	if _, err := RedeemVoucher("voucher", "redeemer", "", pins[1]); err != nil {
+		t.Fatal(err)
+	}
+	if batch, err := accountingStorage.GetVoucherBatch("voucher", "R1"); err != nil || batch.Redeemed != 2 || batch.State != VOUCHER_REDEEMED {
+		t.Errorf("bad batch after redeem: %s, %v", utils.ToIJSON(batch), err)
+	}
+}
+
+func TestVoucherRedeemAtomic(t *testing.T) {
+	if err := ratingStorage.SetActionGroup(&ActionGroup{Tenant: "voucher", Name: "BROKEN", Actions: Actions{
+		&Action{ActionType: TOPUP, TOR: utils.MONETARY, Params: `{"Balance":{"Value":10}}`},
+		&Action{ActionType: SET_CREDIT_LIMIT, Params: `{"CreditLimit": -1}`}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accountingStorage.SetAccount(&Account{Tenant: "voucher", Name: "atomic"}); err != nil {
+		t.Fatal(err)
+	}
+	_, cards, err := NewVoucherBatch("voucher", "A1", "BROKEN", dec.NewFloat(10), time.Time{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetVoucherBatchState("voucher", "A1", VOUCHER_ACTIVATED); err != nil {
+		t.Fatal(err)
+	}
+	pins := voucherPins(t, cards)
+	if _, err := RedeemVoucher("voucher", "atomic", "", pins[0]); err == nil {
+		t.Fatal("broken voucher redeemed")
+	}
+	if acc, err := accountingStorage.GetAccount("voucher", "atomic"); err != nil || len(acc.BalanceMap) != 0 {
+		t.Errorf("account changed by a failed redeem: %s, %v", utils.ToIJSON(acc), err)
+	}
+	if v, err := accountingStorage.GetVoucher("voucher", voucherPINHash("voucher", pins[0])); err != nil || v.Redeemed {
+		t.Errorf("voucher marked redeemed: %s, %v", utils.ToIJSON(v), err)
+	}
+}
+
+func TestVoucherRedeemRetry(t *testing.T) {
+	if err := ratingStorage.SetActionGroup(&ActionGroup{Tenant: "voucher", Name: "TOPUP", Actions: Actions{
+		&Action{ActionType: TOPUP, TOR: utils.MONETARY, Params: `{"Balance":{"Value":10}}`}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accountingStorage.SetAccount(&Account{Tenant: "voucher", Name: "retry"}); err != nil {
+		t.Fatal(err)
+	}
+	_, cards, err := NewVoucherBatch("voucher", "T1", "TOPUP", dec.NewFloat(10), time.Time{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetVoucherBatchState("voucher", "T1", VOUCHER_ACTIVATED); err != nil {
+		t.Fatal(err)
+	}
+	pins := voucherPins(t, cards)
+	v, err := accountingStorage.GetVoucher("voucher", voucherPINHash("voucher", pins[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a redeem that saved the account and stopped before marking the voucher
+	v.Account = "retry"
+	if err := accountingStorage.SetVoucher(v); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := accountingStorage.GetAccount("voucher", "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag, _ := ratingStorage.GetActionGroup("voucher", "TOPUP", utils.CACHED)
+	if err := topupAction(acc, nil, ag.Actions[0], ag.Actions); err != nil {
+		t.Fatal(err)
+	}
+	acc.Vouchers = utils.NewStringMap(v.Serial)
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RedeemVoucher("voucher", "other", "", pins[0]); err != utils.ErrVoucherNotRedeemable {
+		t.Errorf("voucher topped up on another account redeemed again: %v", err)
+	}
+	if v, err := accountingStorage.GetVoucher("voucher", v.PINHash); err != nil || !v.Redeemed || v.Account != "retry" {
+		t.Errorf("voucher not marked for the topped up account: %s, %v", utils.ToIJSON(v), err)
+	}
+	if acc, err = accountingStorage.GetAccount("voucher", "retry"); err != nil || acc.BalanceMap[utils.MONETARY][0].GetValue().String() != "10" {
+		t.Errorf("account topped up twice: %s, %v", utils.ToIJSON(acc), err)
+	}
+	if batch, err := accountingStorage.GetVoucherBatch("voucher", "T1"); err != nil || batch.Redeemed != 1 || batch.State != VOUCHER_REDEEMED {
+		t.Errorf("bad batch after retry: %s, %v", utils.ToIJSON(batch), err)
+	}
+}
+
+func TestVoucherBruteForce(t *testing.T) {
+	for i := 0; i < VoucherMaxFailures; i++ {
+		if _, err := RedeemVoucher("vbrute", "guesser", "10.0.0.1", "000000000000"); err != utils.ErrVoucherNotRedeemable {
+			t.Fatalf("expected not redeemable, got: %v", err)
+		}
+	}
+	if _, err := RedeemVoucher("vbrute", "guesser", "10.0.0.2", "000000000000"); err != utils.ErrTooManyAttempts {
+		t.Errorf("expected too many attempts, got: %v", err)
+	}
+	window := time.Now().Truncate(VoucherFailureWindow)
+	if count, err := accountingStorage.AddVoucherAttempts("vbrute", "guesser", window, 0); err != nil || count != VoucherMaxFailures+1 {
+		t.Errorf("attempts not stored: %d, %v", count, err)
+	}
+	if _, err := RedeemVoucher("vbrute", "other", "10.0.0.3", "000000000000"); err != utils.ErrVoucherNotRedeemable {
+		t.Errorf("other accounts should not be limited, got: %v", err)
+	}
+	// the failures of a source count across the accounts
+	defer func(max int) { VoucherMaxSourceFailures = max }(VoucherMaxSourceFailures)
+	VoucherMaxSourceFailures = VoucherMaxFailures
+	if _, err := RedeemVoucher("vbrute", "third", "10.0.0.1", "000000000000"); err != utils.ErrTooManyAttempts {
+		t.Errorf("expected too many source attempts, got: %v", err)
+	}
+	if _, err := RedeemVoucher("vbrute", "third", "", "000000000000"); err != utils.ErrVoucherNotRedeemable {
+		t.Errorf("the source limit should not block the account, got: %v", err)
+	}
+}
+
+func TestVoucherAttemptsNotCounted(t *testing.T) {
+	if err := ratingStorage.SetActionGroup(&ActionGroup{Tenant: "vcount", Name: "TOPUP", Actions: Actions{
+		&Action{ActionType: TOPUP, TOR: utils.MONETARY, Params: `{"Balance":{"Value":10}}`}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accountingStorage.SetAccount(&Account{Tenant: "vcount", Name: "buyer"}); err != nil {
+		t.Fatal(err)
+	}
+	_, cards, err := NewVoucherBatch("vcount", "C1", "TOPUP", dec.NewFloat(10), time.Time{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetVoucherBatchState("vcount", "C1", VOUCHER_ACTIVATED); err != nil {
+		t.Fatal(err)
+	}
+	pins := voucherPins(t, cards)
+	if _, err := RedeemVoucher("vcount", "missing", "src", pins[0]); err == nil || err == utils.ErrVoucherNotRedeemable {
+		t.Errorf("expected account error, got: %v", err)
+	}
+	if _, err := RedeemVoucher("vcount", "buyer", "src", pins[0]); err != nil {
+		t.Fatal(err)
+	}
+	window := time.Now().Truncate(VoucherFailureWindow)
+	for _, key := range []string{"missing", "buyer", voucherSourceKey("src")} {
+		if count, err := accountingStorage.AddVoucherAttempts("vcount", key, window, 0); err != nil || count != 0 {
+			t.Errorf("%s: valid pin attempts counted: %d, %v", key, count, err)
+		}
+	}
+}
@@ -91,7 +91,9 @@ func (s *Session) debitLoop(runIdx int) {
 		// update call duration with real debited duration
 		nextCd.DurationIndex -= debitPeriod
 		nextCd.DurationIndex += cc.GetDuration()
-		nextCd.GetMaxCostSoFar().AddS(cc.GetCost())
+		if err := nextCd.AddMaxCostSoFar(cc.GetCost(), cc.Currency); err != nil {
+			utils.Logger.Error("Could not count the session cost", zap.Error(err))
+		}
 		time.Sleep(cc.GetDuration())
 		index++
 	}
@@ -131,7 +131,9 @@ func (s *SMGSession) debit(dur time.Duration, lastUsed *time.Duration) (time.Dur
 	}
 	s.cd.DurationIndex -= dur
 	s.cd.DurationIndex += ccDuration
-	s.cd.GetMaxCostSoFar().AddS(cc.GetCost())
+	if err := s.cd.AddMaxCostSoFar(cc.GetCost(), cc.Currency); err != nil {
+		utils.Logger.Error("<SMGeneric> Could not count the session cost", zap.String("uuid", s.eventStart.GetUUID()), zap.Error(err))
+	}
 	s.cd.LoopIndex++
 	s.sessionCds = append(s.sessionCds, s.cd.Clone())
 	s.callCosts = append(s.callCosts, cc)
@@ -163,6 +165,7 @@ func (s *SMGSession) refund(refundDuration, postATSplitDuration time.Duration) e
 		cd := firstCC.CreateCallDescriptor()
 		cd.Increments = refundIncrements
 		cd.UniqueID = s.cd.UniqueID
+		cd.OriginID = s.cd.OriginID
 		cd.RunID = s.cd.RunID
 		cd.ExeATIDs, cd.UnexeATIDs = exe, unexe
 		//utils.Logger.Info(fmt.Sprintf("Refunding %s duration %v with cd: %s", cd.UniqueID, initialRefundDuration, utils.ToJSON(cd)))
@@ -228,6 +231,27 @@ func (s *SMGSession) close(endTime time.Time) (err error) {
 	return
 }
 
+// Hold the money the session can spend in its requested usage, so the parallel sessions on the account do not see it
+func (s *SMGSession) reserveBalance() error {
+	if s.cd.ReservationID == "" {
+		return nil
+	}
+	var reply float64
+	return s.rater.Call("Responder.ReserveSession", s.cd, &reply)
+}
+
+// Release the balance hold made for this session, nothing to do if there was none
+func (s *SMGSession) releaseReservation() error {
+	if s.cd.ReservationID == "" {
+		return nil
+	}
+	var reply float64
+	if err := s.rater.Call("Responder.ReleaseReservation", s.cd, &reply); err != nil && err.Error() != utils.ErrNotFound.Error() {
+		return err
+	}
+	return nil
+}
+
 // Send disconnect order to remote connection
 func (s *SMGSession) disconnectSession(reason string) error {
 	if s.clntConn == nil || reflect.ValueOf(s.clntConn).IsNil() {
@@ -248,9 +248,13 @@ func (smg *SMGeneric) sessionStart(evStart SMGenericEvent, clntConn rpcclient.Rp
 		}
 		stopDebitChan := make(chan struct{})
 		for _, sessionRun := range sessionRuns {
+			sessionRun.CallDescriptor.ReservationID = sessionID // balances held for this session can be used by it
 			s := &SMGSession{eventStart: evStart, runId: sessionRun.DerivedCharger.RunID, timezone: smg.timezone,
 				rater: smg.rater, cdrsrv: smg.cdrsrv, cd: sessionRun.CallDescriptor, clntConn: clntConn, postActionTrigger: *smg.cfg.SmGeneric.PostActionTrigger}
 			smg.recordSession(sessionID, s)
+			if err := s.reserveBalance(); err != nil {
+				utils.Logger.Warn("<SMGeneric> Could not reserve the session balance", zap.String("id", sessionID), zap.String("runid", s.runId), zap.Error(err))
+			}
 			//utils.Logger.Info(fmt.Sprintf("<SMGeneric> Starting session: %s, runId: %s", sessionID, s.runId))
 			if smg.cfg.SmGeneric.DebitInterval.D() != 0 {
 				s.stopDebit = stopDebitChan
@@ -290,6 +294,9 @@ func (smg *SMGeneric) sessionEnd(sessionID string, usage time.Duration) error {
 			if err := s.close(aTime.Add(usage)); err != nil {
 				utils.Logger.Error("<SMGeneric> Could not close session", zap.String("id", sessionID), zap.String("runid", s.runId), zap.Error(err))
 			}
+			if err := s.releaseReservation(); err != nil {
+				utils.Logger.Error("<SMGeneric> Could not release session reservation", zap.String("id", sessionID), zap.String("runid", s.runId), zap.Error(err))
+			}
 			if err := s.saveOperations(sessionID); err != nil {
 				utils.Logger.Error("<SMGeneric> Could not save session", zap.String("id", sessionID), zap.String("runid", s.runId), zap.Error(err))
 			}
@@ -513,6 +520,7 @@ func (smg *SMGeneric) ChargeEvent(gev SMGenericEvent) (maxDur time.Duration, err
 				cd := cc.CreateCallDescriptor()
 				cd.Increments = refundIncrements
 				cd.UniqueID = sR.CallDescriptor.UniqueID
+				cd.OriginID = sR.CallDescriptor.OriginID
 				cd.RunID = sR.CallDescriptor.RunID
 				//utils.Logger.Info(fmt.Sprintf("Refunding session run callcost: %s", utils.ToJSON(cd)))
 				var response float64
@@ -24,10 +24,10 @@ type TpDestination struct {
 type TpRate struct {
 	Tenant string
 	Tag    string
-	Slots  []*rateSlot
+	Slots  []*TpRateSlot
 }
 
-type rateSlot struct {
+type TpRateSlot struct {
 	ConnectFee         float64
 	Rate               float64
 	RateUnit           string
@@ -38,24 +38,45 @@ type rateSlot struct {
 type TpDestinationRate struct {
 	Tenant   string
 	Tag      string
-	Bindings []*destinationRateBinding
+	Bindings []*TpDestinationRateBinding
 }
 
-type destinationRateBinding struct {
+type TpDestinationRateBinding struct {
 	DestinationCode string
 	DestinationTag  string
 	RatesTag        string
 	MaxCost         float64
 	MaxCostStrategy string
+	Rounding        *TpRounding `json:",omitempty"` // JSON only, overrides the rating plan rounding
 }
 
 type TpRatingPlan struct {
-	Tenant   string
-	Tag      string
-	Bindings []*ratingPlanBinding
+	Tenant     string
+	Tag        string
+	Currency   string // currency of the rates, empty for the default one
+	Bindings   []*TpRatingPlanBinding
+	Derivation *TpRatingPlanDerivation `json:",omitempty"` // JSON only, the plan is built from another one and the Bindings override its destinations
+	Rounding   *TpRounding             `json:",omitempty"` // JSON only, overrides the tenant rounding for the destination rates of the plan
+}
+
+// TpRatingPlanDerivation marks up the rates of the parent rating plan, loaded before the derived one
+type TpRatingPlanDerivation struct {
+	ParentTag        string
+	MarkupPercent    float64 // 15 adds 15% to the parent rates
+	MarkupAbsolute   float64 // added after the percentage
+	MarkupConnectFee bool    // apply the markups to the connect fees as well
+	MinimumRate      float64
+	RoundingDecimals int
+	RoundingMethod   string // *up, *middle, *down, *half_even, *ceiling or *floor, no rounding when empty
 }
 
-type ratingPlanBinding struct {
+// TpRounding of the call costs, overriding the tenant one
+type TpRounding struct {
+	Decimals int
+	Method   string // *up, *middle, *down, *half_even, *ceiling or *floor
+}
+
+type TpRatingPlanBinding struct {
 	DestinationRatesTag string
 	TimingTag           string
 	Weight              float64
@@ -66,10 +87,10 @@ type TpRatingProfile struct {
 	Direction   string
 	Category    string
 	Subject     string
-	Activations []*ratingProfileActivation
+	Activations []*TpRatingProfileActivation
 }
 
-type ratingProfileActivation struct {
+type TpRatingProfileActivation struct {
 	ActivationTime   string
 	RatingPlanTag    string
 	FallbackSubjects []string
@@ -82,13 +103,13 @@ type TpLcrRule struct {
 	Category    string
 	Account     string
 	Subject     string
-	Activations []*lcrActivation
+	Activations []*TpLcrActivation
 }
-type lcrActivation struct {
+type TpLcrActivation struct {
 	ActivationTime string
-	Entries        []*lcrEntry
+	Entries        []*TpLcrEntry
 }
-type lcrEntry struct {
+type TpLcrEntry struct {
 	DestinationTag string
 	RPCategory     string
 	Strategy       string
@@ -99,10 +120,10 @@ type lcrEntry struct {
 type TpActionGroup struct {
 	Tenant  string
 	Tag     string
-	Actions []*actionBody
+	Actions []*TpActionBody
 }
 
-type actionBody struct {
+type TpActionBody struct {
 	Action     string
 	TOR        string
 	Params     string
@@ -114,10 +135,10 @@ type actionBody struct {
 type TpActionPlan struct {
 	Tenant        string
 	Tag           string
-	ActionTimings []*actionTiming
+	ActionTimings []*TpActionTiming
 }
 
-type actionTiming struct {
+type TpActionTiming struct {
 	TimingTag  string  `bson:"timing"`
 	ActionsTag string  `bson:"actions_id"`
 	Weight     float64 `bson:"weight"`
@@ -126,10 +147,10 @@ type actionTiming struct {
 type TpActionTrigger struct {
 	Tenant   string
 	Tag      string
-	Triggers []*triggerBody
+	Triggers []*TpTriggerBody
 }
 
-type triggerBody struct {
+type TpTriggerBody struct {
 	UniqueID       string
 	ThresholdType  string
 	ThresholdValue float64
@@ -156,11 +177,11 @@ type TpAccountAction struct {
 type TpSharedGroup struct {
 	Tenant            string
 	Tag               string
-	AccountParameters map[string]*sharingParameters
+	AccountParameters map[string]*TpSharingParameters
 	MemberIDs         []string
 }
 
-type sharingParameters struct {
+type TpSharingParameters struct {
 	Strategy      string
 	RatingSubject string
 }
@@ -172,10 +193,10 @@ type TpDerivedCharger struct {
 	Account        string
 	Subject        string
 	DestinationIDs []string
-	Chargers       []*derivedCharger
+	Chargers       []*TpDerivedChargerRun
 }
 
-type derivedCharger struct {
+type TpDerivedChargerRun struct {
 	RunID  string
 	Filter string
 	Fields string
@@ -192,6 +213,32 @@ type TpCdrStats struct {
 	Disabled          bool
 }
 
+type TpExchangeRate struct {
+	Tenant      string
+	From        string
+	To          string
+	Activations []*TpExchangeRateActivation
+}
+
+type TpExchangeRateActivation struct {
+	ActivationTime string
+	Rate           float64
+}
+
+type TpTaxRule struct {
+	Tenant          string
+	Tag             string
+	DestinationTags []string // empty for all destinations
+	Categories      []string // empty for all categories
+	Type            string   // *percent, *per_unit or *compound
+	Value           float64
+	Unit            string // usage unit for *per_unit
+	ActivationTime  string
+	ExpirationTime  string
+	ExemptAccounts  []string
+	Weight          float64
+}
+
 type TpUser struct {
 	Tenant string
 	Name   string
@@ -208,17 +255,17 @@ type TpAlias struct {
 	Account   string
 	Subject   string
 	Context   string
-	Indexes   []*aliasIndex
-	Values    []*aliasValue
+	Indexes   []*TpAliasIndex
+	Values    []*TpAliasValue
 }
 
-type aliasValue struct {
+type TpAliasValue struct {
 	DestinationTag string
 	Fields         string
 	Weight         float64
 }
 
-type aliasIndex struct {
+type TpAliasIndex struct {
 	Target string
 	Alias  string
 }
@@ -226,10 +273,10 @@ type aliasIndex struct {
 type TpResourceLimit struct {
 	Tenant  string
 	Tag     string
-	Filters []*rsFilter
+	Filters []*TpResourceFilter
 }
 
-type rsFilter struct {
+type TpResourceFilter struct {
 	Type              string
 	FieldName         string
 	Values            []string
@@ -8,6 +8,12 @@ type LoadInstance struct {
 	AccountingLoadID string `bson:"accounting_load_id"`
 	//TariffPlanID     string `bson:"tariff_plan_id"`    // Tariff plan identificator for the data loaded
 	LoadTime time.Time `bson:"load_time"` // Time of load
+	// staged loads
+	Tenant         string    `bson:"tenant"`
+	FolderPath     string    `bson:"folder_path"`     // versioned copy of the tenant tariff plan
+	SourcePath     string    `bson:"source_path"`     // folder the load was staged from
+	Active         bool      `bson:"active"`          // the load currently in the storage
+	ActivationTime time.Time `bson:"activation_time"` // last time the load was activated
 }
 
 type CacheFileInfo struct {
@@ -12,6 +12,10 @@ const (
 	DIAMETER_FIRMWARE_REVISION   = 918
 	REDIS_MAX_CONNS              = 10
 	MONGO                        = "mongo"
+	BOLT                         = "bolt"
+	MYSQL                        = "mysql"
+	POSTGRES                     = "postgres"
+	SQLITE                       = "sqlite3"
 	LOCALHOST                    = "127.0.0.1"
 	FSCDR_FILE_CSV               = "freeswitch_file_csv"
 	FSCDR_HTTP_JSON              = "freeswitch_http_json"
@@ -39,12 +43,38 @@ const (
 	ACCOUNT_ACTIONS_JSON         = "AccountActions.json"
 	DERIVED_CHARGERS_JSON        = "DerivedChargers.json"
 	CDR_STATS_JSON               = "CdrStats.json"
+	TAX_RULES_JSON               = "TaxRules.json"
+	EXCHANGE_RATES_JSON          = "ExchangeRates.json"
 	USERS_JSON                   = "Users.json"
 	ALIASES_JSON                 = "Aliases.json"
 	RESOURCE_LIMITS_JSON         = "ResourceLimits.json"
+	TIMINGS_CSV                  = "Timings.csv"
+	DESTINATIONS_CSV             = "Destinations.csv"
+	RATES_CSV                    = "Rates.csv"
+	DESTINATION_RATES_CSV        = "DestinationRates.csv"
+	RATING_PLANS_CSV             = "RatingPlans.csv"
+	RATING_PROFILES_CSV          = "RatingProfiles.csv"
+	SHARED_GROUPS_CSV            = "SharedGroups.csv"
+	LCRS_CSV                     = "LcrRules.csv"
+	ACTIONS_CSV                  = "Actions.csv"
+	ACTION_PLANS_CSV             = "ActionPlans.csv"
+	ACTION_TRIGGERS_CSV          = "ActionTriggers.csv"
+	ACCOUNT_ACTIONS_CSV          = "AccountActions.csv"
+	DERIVED_CHARGERS_CSV         = "DerivedChargers.csv"
+	CDR_STATS_CSV                = "CdrStats.csv"
+	TAX_RULES_CSV                = "TaxRules.csv"
+	EXCHANGE_RATES_CSV           = "ExchangeRates.csv"
+	USERS_CSV                    = "Users.csv"
+	ALIASES_CSV                  = "Aliases.csv"
+	RESOURCE_LIMITS_CSV          = "ResourceLimits.csv"
 	ROUNDING_UP                  = "*up"
 	ROUNDING_MIDDLE              = "*middle"
 	ROUNDING_DOWN                = "*down"
+	ROUNDING_HALF_EVEN           = "*half_even"
+	ROUNDING_CEILING             = "*ceiling"
+	ROUNDING_FLOOR               = "*floor"
+	ROUNDING_SCOPE_CALL          = "*call"
+	ROUNDING_SCOPE_INVOICE       = "*invoice"
 	ANY                          = "*any"
 	UNLIMITED                    = "*unlimited"
 	ZERO                         = "*zero"
@@ -130,6 +160,7 @@ const (
 	IN                           = "*in"
 	META_OUT                     = "*out"
 	META_ANY                     = "*any"
+	META_EMERGENCY               = "*emergency" // destination id of the numbers always reachable
 	CDR_IMPORT                   = "cdr_import"
 	CDR_EXPORT                   = "cdr_export"
 	ASR                          = "ASR"
@@ -152,6 +183,7 @@ const (
 	ALIASES_PREFIX               = "als_"
 	ResourceLimitsPrefix         = "rlm_"
 	CDR_STATS_PREFIX             = "cst_"
+	EXCHANGE_RATE_PREFIX         = "xch_"
 	TEMP_DESTINATION_PREFIX      = "tmp_"
 	LOG_CALL_COST_PREFIX         = "cco_"
 	LOG_ACTION_TIMMING_PREFIX    = "ltm_"
@@ -232,6 +264,7 @@ const (
 	TRIGGER_MIN_BALANCE          = "*min_balance"
 	TRIGGER_MAX_BALANCE          = "*max_balance"
 	TRIGGER_BALANCE_EXPIRED      = "*balance_expired"
+	TRIGGER_MIN_CREDIT           = "*min_credit" // the balance value plus the credit limit
 	HIERARCHY_SEP                = ">"
 	META_COMPOSED                = "*composed"
 	NegativePrefix               = "!"
@@ -261,6 +294,7 @@ const (
 	EVT_ACCOUNT_BALANCE_MODIFIED = "ACCOUNT_BALANCE_MODIFIED"
 	EVT_ACTION_TRIGGER_FIRED     = "ACTION_TRIGGER_FIRED"
 	EVT_ACTION_TIMING_FIRED      = "ACTION_TRIGGER_FIRED"
+	EVT_ACCOUNT_STATE_CHANGED    = "ACCOUNT_STATE_CHANGED"
 	SMAsterisk                   = "sm_asterisk"
 	TariffPlanDB                 = "tariffplan_db"
 	DataDB                       = "data_db"
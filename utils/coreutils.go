@@ -86,8 +86,22 @@ func Round(x float64, precision int32, method string) float64 {
 		} else {
 			rounder = math.Floor(intermed)
 		}
+	case ROUNDING_CEILING: // towards positive infinity, the negative values lose their fraction
+		if frac >= math.Pow10(-maxPrec) || frac < 0 {
+			rounder = math.Ceil(intermed)
+		} else {
+			rounder = math.Floor(intermed)
+		}
 	case ROUNDING_DOWN:
 		rounder = math.Floor(intermed)
+	case ROUNDING_FLOOR: // towards negative infinity, ignoring the float chaos below the whole negative values
+		if frac < 0 && frac > -math.Pow10(-maxPrec) {
+			rounder = math.Ceil(intermed)
+		} else {
+			rounder = math.Floor(intermed)
+		}
+	case ROUNDING_HALF_EVEN:
+		rounder = math.RoundToEven(intermed)
 	case ROUNDING_MIDDLE:
 		if frac >= 0.5 {
 			rounder = math.Ceil(intermed)
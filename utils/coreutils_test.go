@@ -65,6 +65,33 @@ func TestRoundByMethodDown2(t *testing.T) {
 	}
 }
 
+func TestRoundByMethodHalfEven(t *testing.T) {
+	result := Round(12.25, 1, ROUNDING_HALF_EVEN)
+	expected := 12.2
+	if result != expected {
+		t.Errorf("Error rounding half even: sould be %v was %v", expected, result)
+	}
+}
+
+func TestRoundByMethodCeilingFloor(t *testing.T) {
+	for _, tc := range []struct {
+		x        float64
+		method   string
+		expected float64
+	}{
+		{1.121, ROUNDING_CEILING, 1.13},
+		{-1.121, ROUNDING_CEILING, -1.12},
+		{-1.12, ROUNDING_CEILING, -1.12},
+		{1.129, ROUNDING_FLOOR, 1.12},
+		{-1.121, ROUNDING_FLOOR, -1.13},
+		{-1.12, ROUNDING_FLOOR, -1.12},
+	} {
+		if result := Round(tc.x, 2, tc.method); result != tc.expected {
+			t.Errorf("Error rounding %v %s: sould be %v was %v", tc.x, tc.method, tc.expected, result)
+		}
+	}
+}
+
 func TestParseTimeDetectLayout(t *testing.T) {
 	tmStr := "2013-12-30T15:00:01Z"
 	expectedTime := time.Date(2013, 12, 30, 15, 0, 1, 0, time.UTC)
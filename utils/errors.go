@@ -22,11 +22,21 @@ var (
 	ErrRatingPlanNotFound      = errors.New("RATING_PLAN_NOT_FOUND")
 	ErrAccountNotFound         = errors.New("ACCOUNT_NOT_FOUND")
 	ErrAccountDisabled         = errors.New("ACCOUNT_DISABLED")
+	ErrAccountNotActive        = errors.New("ACCOUNT_NOT_ACTIVE")
+	ErrAccountBarred           = errors.New("ACCOUNT_BARRED")
+	ErrAccountSuspended        = errors.New("ACCOUNT_SUSPENDED")
+	ErrAccountClosed           = errors.New("ACCOUNT_CLOSED")
+	ErrInvalidStateTransition  = errors.New("INVALID_STATE_TRANSITION")
+	ErrSameAccount             = errors.New("SAME_ACCOUNT")
 	ErrUserNotFound            = errors.New("USER_NOT_FOUND")
 	ErrInsufficientCredit      = errors.New("INSUFFICIENT_CREDIT")
 	ErrNotConvertible          = errors.New("NOT_CONVERTIBLE")
+	ErrCurrencyMismatch        = errors.New("CURRENCY_MISMATCH")
 	ErrResourceUnavailable     = errors.New("RESOURCE_UNAVAILABLE")
 	ErrNoActiveSession         = errors.New("NO_ACTIVE_SESSION")
+	ErrTooLarge                = errors.New("TOO_LARGE")
+	ErrTooManyAttempts         = errors.New("TOO_MANY_ATTEMPTS")
+	ErrVoucherNotRedeemable    = errors.New("VOUCHER_NOT_REDEEMABLE")
 )
 
 // NewCGRError initialises a new CGRError
@@ -0,0 +1,751 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVError locates an error inside a tariff plan csv file, Column is the 1-based field and zero when the whole row is concerned
+type CSVError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (err *CSVError) Error() string {
+	if err.Column == 0 {
+		return fmt.Sprintf("%s:%d: %v", err.File, err.Line, err.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", err.File, err.Line, err.Column, err.Err)
+}
+
+// csvFieldAt turns the 1-based byte column of a csv.ParseError into the 1-based field holding it
+func csvFieldAt(text string, sep rune, byteColumn int) int {
+	field, quoted := 1, false
+	for i, c := range text {
+		if i >= byteColumn-1 {
+			break
+		}
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			field++
+		}
+	}
+	return field
+}
+
+// csvLayout describes the columns of a tariff plan csv file.
+// The rows sharing the key columns are merged into one element, the other columns fill its nested items.
+type csvLayout struct {
+	columns    []string // in the order used when the file has no header
+	keyColumns int      // leading columns identifying the element, zero for one element per row
+	fill       func(el interface{}, row *csvRow, first bool) error
+	rows       func(el interface{}) [][]string // the reverse of fill, values in the columns order
+}
+
+type csvRow struct {
+	file   string
+	line   int
+	record []string
+	index  map[string]int // column name to record position
+}
+
+func (r *csvRow) str(column string) string {
+	if i, found := r.index[column]; found && i < len(r.record) {
+		return strings.TrimSpace(r.record[i])
+	}
+	return ""
+}
+
+func (r *csvRow) errorf(column string, err error) error {
+	return &CSVError{File: r.file, Line: r.line, Column: r.index[column] + 1, Err: fmt.Errorf("%s: %v", column, err)}
+}
+
+func (r *csvRow) float(column string, v *float64) (err error) {
+	if s := r.str(column); s != "" {
+		if *v, err = strconv.ParseFloat(s, 64); err != nil {
+			return r.errorf(column, err)
+		}
+	}
+	return nil
+}
+
+func (r *csvRow) int(column string, v *int) (err error) {
+	if s := r.str(column); s != "" {
+		if *v, err = strconv.Atoi(s); err != nil {
+			return r.errorf(column, err)
+		}
+	}
+	return nil
+}
+
+func (r *csvRow) bool(column string, v *bool) (err error) {
+	if s := r.str(column); s != "" {
+		if *v, err = strconv.ParseBool(s); err != nil {
+			return r.errorf(column, err)
+		}
+	}
+	return nil
+}
+
+// list splits the INFIELD_SEP separated values, nil for an empty cell
+func (r *csvRow) list(column string) []string {
+	s := r.str(column)
+	if s == "" {
+		return nil
+	}
+	values := strings.Split(s, INFIELD_SEP)
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return values
+}
+
+// ints parses the INFIELD_SEP separated numbers, nil for an empty cell or *any
+func (r *csvRow) ints(column string) ([]int, error) {
+	if r.str(column) == ANY {
+		return nil, nil
+	}
+	var values []int
+	for _, s := range r.list(column) {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, r.errorf(column, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// header maps the column names of a header record, the names are matched case insensitive and may end with [index]
+func (layout *csvLayout) header(file string, line int, record []string) (map[string]int, error) {
+	index := make(map[string]int)
+	for i, name := range record {
+		name = strings.TrimSpace(name)
+		if pos := strings.Index(name, "["); pos != -1 {
+			name = name[:pos]
+		}
+		found := false
+		for _, column := range layout.columns {
+			if strings.EqualFold(column, name) {
+				index[column] = i
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &CSVError{File: file, Line: line, Column: i + 1, Err: fmt.Errorf("unknown column %s", name)}
+		}
+	}
+	for _, column := range layout.columns[:layout.keyColumns] {
+		if _, found := index[column]; !found {
+			return nil, &CSVError{File: file, Line: line, Err: fmt.Errorf("missing column %s", column)}
+		}
+	}
+	return index, nil
+}
+
+// isHeader reports if all the record values are column names
+func (layout *csvLayout) isHeader(record []string) bool {
+	_, err := layout.header("", 0, record)
+	return err == nil
+}
+
+func (layout *csvLayout) defaultIndex() map[string]int {
+	index := make(map[string]int)
+	for i, column := range layout.columns {
+		index[column] = i
+	}
+	return index
+}
+
+// LoadCSV reads a tariff plan csv file, the file name selecting the columns layout, and passes each element to the callback.
+// The first line is the header when it starts with COMMENT_CHAR or holds only column names, the following COMMENT_CHAR lines are skipped.
+// List values are separated by INFIELD_SEP and the quoted values can not span multiple lines.
+func LoadCSV(r io.Reader, fileName string, sep rune, newElement func() interface{}, callback func(interface{}) error) error {
+	file := path.Base(fileName)
+	layout, found := csvLayouts[file]
+	if !found {
+		return fmt.Errorf("no csv layout for %s", file)
+	}
+	var index map[string]int
+	var elements []interface{}
+	elementLines := make(map[interface{}]int)
+	elementsByKey := make(map[string]interface{})
+	scanner := bufio.NewScanner(r)
+	for lineNr := 1; scanner.Scan(); lineNr++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		isComment := strings.HasPrefix(line, string(COMMENT_CHAR))
+		if isComment && index != nil {
+			continue
+		}
+		text := strings.TrimPrefix(line, string(COMMENT_CHAR))
+		csvReader := csv.NewReader(strings.NewReader(text))
+		csvReader.Comma = sep
+		csvReader.FieldsPerRecord = -1
+		record, err := csvReader.Read()
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				return &CSVError{File: file, Line: lineNr, Column: csvFieldAt(text, sep, parseErr.Column), Err: parseErr.Err}
+			}
+			return &CSVError{File: file, Line: lineNr, Err: err}
+		}
+		if index == nil {
+			if isComment || layout.isHeader(record) {
+				if index, err = layout.header(file, lineNr, record); err != nil {
+					return err
+				}
+				continue
+			}
+			index = layout.defaultIndex()
+		}
+		if len(record) > len(index) {
+			return &CSVError{File: file, Line: lineNr, Column: len(index) + 1, Err: fmt.Errorf("expected %d fields, found %d", len(index), len(record))}
+		}
+		row := &csvRow{file: file, line: lineNr, record: record, index: index}
+		var key string
+		if layout.keyColumns != 0 {
+			keyValues := make([]string, layout.keyColumns)
+			for i, column := range layout.columns[:layout.keyColumns] {
+				keyValues[i] = row.str(column)
+			}
+			key = strings.Join(keyValues, CONCATENATED_KEY_SEP)
+		}
+		el, found := elementsByKey[key]
+		if layout.keyColumns == 0 || !found {
+			el = newElement()
+			elements = append(elements, el)
+			elementLines[el] = lineNr
+			if layout.keyColumns != 0 {
+				elementsByKey[key] = el
+			}
+		}
+		if err := layout.fill(el, row, !found || layout.keyColumns == 0); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, el := range elements {
+		if err := callback(el); err != nil {
+			return &CSVError{File: file, Line: elementLines[el], Err: err}
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the elements in the tariff plan csv file layout, starting with a COMMENT_CHAR header
+func WriteCSV(w io.Writer, fileName string, sep rune, elements []interface{}) error {
+	file := path.Base(fileName)
+	layout, found := csvLayouts[file]
+	if !found {
+		return fmt.Errorf("no csv layout for %s", file)
+	}
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Comma = sep
+	header := append([]string{}, layout.columns...)
+	header[0] = string(COMMENT_CHAR) + header[0]
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+	for _, el := range elements {
+		if err := csvWriter.WriteAll(layout.rows(el)); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, INFIELD_SEP)
+}
+
+var csvLayouts = map[string]*csvLayout{
+	DESTINATIONS_CSV: &csvLayout{
+		columns: []string{"Tenant", "Tag", "Code"},
+		rows: func(el interface{}) [][]string {
+			dest := el.(*TpDestination)
+			return [][]string{{dest.Tenant, dest.Tag, dest.Code}}
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			dest := el.(*TpDestination)
+			dest.Tenant, dest.Tag, dest.Code = r.str("Tenant"), r.str("Tag"), r.str("Code")
+			return nil
+		},
+	},
+	TIMINGS_CSV: &csvLayout{
+		columns: []string{"Tenant", "Tag", "Years", "Months", "MonthDays", "WeekDays", "Time"},
+		rows: func(el interface{}) [][]string {
+			tm := el.(*TpTiming)
+			var months, weekDays []int
+			for _, m := range tm.Months {
+				months = append(months, int(m))
+			}
+			for _, wd := range tm.WeekDays {
+				weekDays = append(weekDays, int(wd))
+			}
+			return [][]string{{tm.Tenant, tm.Tag, joinInts(tm.Years), joinInts(months), joinInts(tm.MonthDays), joinInts(weekDays), tm.Time}}
+		},
+		fill: func(el interface{}, r *csvRow, first bool) (err error) {
+			tm := el.(*TpTiming)
+			tm.Tenant, tm.Tag, tm.Time = r.str("Tenant"), r.str("Tag"), r.str("Time")
+			if tm.Years, err = r.ints("Years"); err != nil {
+				return err
+			}
+			if tm.MonthDays, err = r.ints("MonthDays"); err != nil {
+				return err
+			}
+			months, err := r.ints("Months")
+			if err != nil {
+				return err
+			}
+			for _, m := range months {
+				tm.Months = append(tm.Months, time.Month(m))
+			}
+			weekDays, err := r.ints("WeekDays")
+			if err != nil {
+				return err
+			}
+			for _, wd := range weekDays {
+				tm.WeekDays = append(tm.WeekDays, time.Weekday(wd))
+			}
+			return nil
+		},
+	},
+	RATES_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "ConnectFee", "Rate", "RateUnit", "RateIncrement", "GroupIntervalStart"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			rate := el.(*TpRate)
+			for _, slot := range rate.Slots {
+				rows = append(rows, []string{rate.Tenant, rate.Tag, formatFloat(slot.ConnectFee), formatFloat(slot.Rate), slot.RateUnit, slot.RateIncrement, slot.GroupIntervalStart})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			rate := el.(*TpRate)
+			rate.Tenant, rate.Tag = r.str("Tenant"), r.str("Tag")
+			slot := &TpRateSlot{RateUnit: r.str("RateUnit"), RateIncrement: r.str("RateIncrement"), GroupIntervalStart: r.str("GroupIntervalStart")}
+			if err := r.float("ConnectFee", &slot.ConnectFee); err != nil {
+				return err
+			}
+			if err := r.float("Rate", &slot.Rate); err != nil {
+				return err
+			}
+			rate.Slots = append(rate.Slots, slot)
+			return nil
+		},
+	},
+	DESTINATION_RATES_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "DestinationCode", "DestinationTag", "RatesTag", "MaxCost", "MaxCostStrategy"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			dr := el.(*TpDestinationRate)
+			for _, b := range dr.Bindings {
+				rows = append(rows, []string{dr.Tenant, dr.Tag, b.DestinationCode, b.DestinationTag, b.RatesTag, formatFloat(b.MaxCost), b.MaxCostStrategy})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			dr := el.(*TpDestinationRate)
+			dr.Tenant, dr.Tag = r.str("Tenant"), r.str("Tag")
+			binding := &TpDestinationRateBinding{DestinationCode: r.str("DestinationCode"), DestinationTag: r.str("DestinationTag"),
+				RatesTag: r.str("RatesTag"), MaxCostStrategy: r.str("MaxCostStrategy")}
+			if err := r.float("MaxCost", &binding.MaxCost); err != nil {
+				return err
+			}
+			dr.Bindings = append(dr.Bindings, binding)
+			return nil
+		},
+	},
+	EXCHANGE_RATES_CSV: &csvLayout{
+		columns:    []string{"Tenant", "From", "To", "ActivationTime", "Rate"},
+		keyColumns: 3,
+		rows: func(el interface{}) (rows [][]string) {
+			er := el.(*TpExchangeRate)
+			for _, activation := range er.Activations {
+				rows = append(rows, []string{er.Tenant, er.From, er.To, activation.ActivationTime, formatFloat(activation.Rate)})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			er := el.(*TpExchangeRate)
+			er.Tenant, er.From, er.To = r.str("Tenant"), r.str("From"), r.str("To")
+			activation := &TpExchangeRateActivation{ActivationTime: r.str("ActivationTime")}
+			if err := r.float("Rate", &activation.Rate); err != nil {
+				return err
+			}
+			er.Activations = append(er.Activations, activation)
+			return nil
+		},
+	},
+	RATING_PLANS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "Currency", "DestinationRatesTag", "TimingTag", "Weight"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			rp := el.(*TpRatingPlan)
+			for _, b := range rp.Bindings {
+				rows = append(rows, []string{rp.Tenant, rp.Tag, rp.Currency, b.DestinationRatesTag, b.TimingTag, formatFloat(b.Weight)})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			rp := el.(*TpRatingPlan)
+			if first {
+				rp.Tenant, rp.Tag, rp.Currency = r.str("Tenant"), r.str("Tag"), r.str("Currency")
+			}
+			binding := &TpRatingPlanBinding{DestinationRatesTag: r.str("DestinationRatesTag"), TimingTag: r.str("TimingTag")}
+			if err := r.float("Weight", &binding.Weight); err != nil {
+				return err
+			}
+			rp.Bindings = append(rp.Bindings, binding)
+			return nil
+		},
+	},
+	RATING_PROFILES_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Direction", "Category", "Subject", "ActivationTime", "RatingPlanTag", "FallbackSubjects", "CdrStatQueueIDs"},
+		keyColumns: 4,
+		rows: func(el interface{}) (rows [][]string) {
+			rp := el.(*TpRatingProfile)
+			for _, a := range rp.Activations {
+				rows = append(rows, []string{rp.Tenant, rp.Direction, rp.Category, rp.Subject, a.ActivationTime, a.RatingPlanTag,
+					strings.Join(a.FallbackSubjects, INFIELD_SEP), strings.Join(a.CdrStatQueueIDs, INFIELD_SEP)})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			rp := el.(*TpRatingProfile)
+			rp.Tenant, rp.Direction, rp.Category, rp.Subject = r.str("Tenant"), r.str("Direction"), r.str("Category"), r.str("Subject")
+			rp.Activations = append(rp.Activations, &TpRatingProfileActivation{ActivationTime: r.str("ActivationTime"), RatingPlanTag: r.str("RatingPlanTag"),
+				FallbackSubjects: r.list("FallbackSubjects"), CdrStatQueueIDs: r.list("CdrStatQueueIDs")})
+			return nil
+		},
+	},
+	SHARED_GROUPS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "MemberIDs", "Account", "Strategy", "RatingSubject"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			sg := el.(*TpSharedGroup)
+			accounts := make([]string, 0, len(sg.AccountParameters))
+			for account := range sg.AccountParameters {
+				accounts = append(accounts, account)
+			}
+			sort.Strings(accounts)
+			members := strings.Join(sg.MemberIDs, INFIELD_SEP)
+			for _, account := range accounts {
+				params := sg.AccountParameters[account]
+				rows = append(rows, []string{sg.Tenant, sg.Tag, members, account, params.Strategy, params.RatingSubject})
+			}
+			if len(rows) == 0 {
+				rows = append(rows, []string{sg.Tenant, sg.Tag, members, "", "", ""})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			sg := el.(*TpSharedGroup)
+			if first {
+				sg.Tenant, sg.Tag, sg.MemberIDs = r.str("Tenant"), r.str("Tag"), r.list("MemberIDs")
+				sg.AccountParameters = make(map[string]*TpSharingParameters)
+			}
+			if account := r.str("Account"); account != "" {
+				sg.AccountParameters[account] = &TpSharingParameters{Strategy: r.str("Strategy"), RatingSubject: r.str("RatingSubject")}
+			}
+			return nil
+		},
+	},
+	LCRS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Direction", "Category", "Account", "Subject", "ActivationTime", "DestinationTag", "RPCategory", "Strategy", "StrategyParams", "Weight"},
+		keyColumns: 5,
+		rows: func(el interface{}) (rows [][]string) {
+			lcr := el.(*TpLcrRule)
+			for _, a := range lcr.Activations {
+				for _, e := range a.Entries {
+					rows = append(rows, []string{lcr.Tenant, lcr.Direction, lcr.Category, lcr.Account, lcr.Subject, a.ActivationTime,
+						e.DestinationTag, e.RPCategory, e.Strategy, e.StrategyParams, formatFloat(e.Weight)})
+				}
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			lcr := el.(*TpLcrRule)
+			lcr.Tenant, lcr.Direction, lcr.Category, lcr.Account, lcr.Subject = r.str("Tenant"), r.str("Direction"), r.str("Category"), r.str("Account"), r.str("Subject")
+			activationTime := r.str("ActivationTime")
+			if len(lcr.Activations) == 0 || lcr.Activations[len(lcr.Activations)-1].ActivationTime != activationTime {
+				lcr.Activations = append(lcr.Activations, &TpLcrActivation{ActivationTime: activationTime})
+			}
+			activation := lcr.Activations[len(lcr.Activations)-1]
+			entry := &TpLcrEntry{DestinationTag: r.str("DestinationTag"), RPCategory: r.str("RPCategory"), Strategy: r.str("Strategy"), StrategyParams: r.str("StrategyParams")}
+			if err := r.float("Weight", &entry.Weight); err != nil {
+				return err
+			}
+			activation.Entries = append(activation.Entries, entry)
+			return nil
+		},
+	},
+	ACTIONS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "Action", "TOR", "Params", "ExecFilter", "Filter", "Weight"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			ag := el.(*TpActionGroup)
+			for _, a := range ag.Actions {
+				rows = append(rows, []string{ag.Tenant, ag.Tag, a.Action, a.TOR, a.Params, a.ExecFilter, a.Filter, formatFloat(a.Weight)})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			ag := el.(*TpActionGroup)
+			ag.Tenant, ag.Tag = r.str("Tenant"), r.str("Tag")
+			action := &TpActionBody{Action: r.str("Action"), TOR: r.str("TOR"), Params: r.str("Params"), ExecFilter: r.str("ExecFilter"), Filter: r.str("Filter")}
+			if err := r.float("Weight", &action.Weight); err != nil {
+				return err
+			}
+			ag.Actions = append(ag.Actions, action)
+			return nil
+		},
+	},
+	ACTION_PLANS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "ActionsTag", "TimingTag", "Weight"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			ap := el.(*TpActionPlan)
+			for _, at := range ap.ActionTimings {
+				rows = append(rows, []string{ap.Tenant, ap.Tag, at.ActionsTag, at.TimingTag, formatFloat(at.Weight)})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			ap := el.(*TpActionPlan)
+			ap.Tenant, ap.Tag = r.str("Tenant"), r.str("Tag")
+			at := &TpActionTiming{ActionsTag: r.str("ActionsTag"), TimingTag: r.str("TimingTag")}
+			if err := r.float("Weight", &at.Weight); err != nil {
+				return err
+			}
+			ap.ActionTimings = append(ap.ActionTimings, at)
+			return nil
+		},
+	},
+	ACTION_TRIGGERS_CSV: &csvLayout{
+		columns: []string{"Tenant", "Tag", "UniqueID", "ThresholdType", "ThresholdValue", "Recurrent", "MinSleep", "ExpiryTime", "ActivationTime",
+			"TOR", "Filter", "MinQueuedItems", "ActionsTag", "Weight"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			atr := el.(*TpActionTrigger)
+			for _, t := range atr.Triggers {
+				rows = append(rows, []string{atr.Tenant, atr.Tag, t.UniqueID, t.ThresholdType, formatFloat(t.ThresholdValue), strconv.FormatBool(t.Recurrent),
+					t.MinSleep, t.ExpiryTime, t.ActivationTime, t.TOR, t.Filter, strconv.Itoa(t.MinQueuedItems), t.ActionsTag, formatFloat(t.Weight)})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			atr := el.(*TpActionTrigger)
+			atr.Tenant, atr.Tag = r.str("Tenant"), r.str("Tag")
+			trigger := &TpTriggerBody{UniqueID: r.str("UniqueID"), ThresholdType: r.str("ThresholdType"), MinSleep: r.str("MinSleep"),
+				ExpiryTime: r.str("ExpiryTime"), ActivationTime: r.str("ActivationTime"), TOR: r.str("TOR"), Filter: r.str("Filter"), ActionsTag: r.str("ActionsTag")}
+			if err := r.float("ThresholdValue", &trigger.ThresholdValue); err != nil {
+				return err
+			}
+			if err := r.bool("Recurrent", &trigger.Recurrent); err != nil {
+				return err
+			}
+			if err := r.int("MinQueuedItems", &trigger.MinQueuedItems); err != nil {
+				return err
+			}
+			if err := r.float("Weight", &trigger.Weight); err != nil {
+				return err
+			}
+			atr.Triggers = append(atr.Triggers, trigger)
+			return nil
+		},
+	},
+	ACCOUNT_ACTIONS_CSV: &csvLayout{
+		columns: []string{"Tenant", "Account", "ActionPlanTags", "ActionTriggerTags", "AllowNegative", "Disabled"},
+		rows: func(el interface{}) [][]string {
+			aa := el.(*TpAccountAction)
+			return [][]string{{aa.Tenant, aa.Account, strings.Join(aa.ActionPlanTags, INFIELD_SEP), strings.Join(aa.ActionTriggerTags, INFIELD_SEP),
+				strconv.FormatBool(aa.AllowNegative), strconv.FormatBool(aa.Disabled)}}
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			aa := el.(*TpAccountAction)
+			aa.Tenant, aa.Account, aa.ActionPlanTags, aa.ActionTriggerTags = r.str("Tenant"), r.str("Account"), r.list("ActionPlanTags"), r.list("ActionTriggerTags")
+			if err := r.bool("AllowNegative", &aa.AllowNegative); err != nil {
+				return err
+			}
+			return r.bool("Disabled", &aa.Disabled)
+		},
+	},
+	DERIVED_CHARGERS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Direction", "Category", "Account", "Subject", "DestinationIDs", "RunID", "Filter", "Fields"},
+		keyColumns: 6,
+		rows: func(el interface{}) (rows [][]string) {
+			dc := el.(*TpDerivedCharger)
+			for _, c := range dc.Chargers {
+				rows = append(rows, []string{dc.Tenant, dc.Direction, dc.Category, dc.Account, dc.Subject, strings.Join(dc.DestinationIDs, INFIELD_SEP),
+					c.RunID, c.Filter, c.Fields})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			dc := el.(*TpDerivedCharger)
+			if first {
+				dc.Tenant, dc.Direction, dc.Category, dc.Account, dc.Subject = r.str("Tenant"), r.str("Direction"), r.str("Category"), r.str("Account"), r.str("Subject")
+				dc.DestinationIDs = r.list("DestinationIDs")
+			}
+			dc.Chargers = append(dc.Chargers, &TpDerivedChargerRun{RunID: r.str("RunID"), Filter: r.str("Filter"), Fields: r.str("Fields")})
+			return nil
+		},
+	},
+	CDR_STATS_CSV: &csvLayout{
+		columns: []string{"Tenant", "Tag", "QueueLength", "TimeWindow", "Metrics", "Filter", "ActionTriggerTags", "Disabled"},
+		rows: func(el interface{}) [][]string {
+			cs := el.(*TpCdrStats)
+			return [][]string{{cs.Tenant, cs.Tag, strconv.Itoa(cs.QueueLength), cs.TimeWindow, strings.Join(cs.Metrics, INFIELD_SEP), cs.Filter,
+				strings.Join(cs.ActionTriggerTags, INFIELD_SEP), strconv.FormatBool(cs.Disabled)}}
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			cs := el.(*TpCdrStats)
+			cs.Tenant, cs.Tag, cs.TimeWindow, cs.Metrics, cs.Filter, cs.ActionTriggerTags = r.str("Tenant"), r.str("Tag"), r.str("TimeWindow"),
+				r.list("Metrics"), r.str("Filter"), r.list("ActionTriggerTags")
+			if err := r.int("QueueLength", &cs.QueueLength); err != nil {
+				return err
+			}
+			return r.bool("Disabled", &cs.Disabled)
+		},
+	},
+	TAX_RULES_CSV: &csvLayout{
+		columns: []string{"Tenant", "Tag", "DestinationTags", "Categories", "Type", "Value", "Unit", "ActivationTime", "ExpirationTime", "ExemptAccounts", "Weight"},
+		rows: func(el interface{}) [][]string {
+			tr := el.(*TpTaxRule)
+			return [][]string{{tr.Tenant, tr.Tag, strings.Join(tr.DestinationTags, INFIELD_SEP), strings.Join(tr.Categories, INFIELD_SEP), tr.Type,
+				formatFloat(tr.Value), tr.Unit, tr.ActivationTime, tr.ExpirationTime, strings.Join(tr.ExemptAccounts, INFIELD_SEP), formatFloat(tr.Weight)}}
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			tr := el.(*TpTaxRule)
+			tr.Tenant, tr.Tag, tr.DestinationTags, tr.Categories, tr.Type = r.str("Tenant"), r.str("Tag"), r.list("DestinationTags"), r.list("Categories"), r.str("Type")
+			tr.Unit, tr.ActivationTime, tr.ExpirationTime, tr.ExemptAccounts = r.str("Unit"), r.str("ActivationTime"), r.str("ExpirationTime"), r.list("ExemptAccounts")
+			if err := r.float("Value", &tr.Value); err != nil {
+				return err
+			}
+			return r.float("Weight", &tr.Weight)
+		},
+	},
+	USERS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Name", "Masked", "Query", "Weight", "AttributeName", "AttributeValue"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			user := el.(*TpUser)
+			names := make([]string, 0, len(user.Index))
+			for name := range user.Index {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				rows = append(rows, []string{user.Tenant, user.Name, strconv.FormatBool(user.Masked), user.Query, formatFloat(user.Weight), name, user.Index[name]})
+			}
+			if len(rows) == 0 {
+				rows = append(rows, []string{user.Tenant, user.Name, strconv.FormatBool(user.Masked), user.Query, formatFloat(user.Weight), "", ""})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			user := el.(*TpUser)
+			if first {
+				user.Tenant, user.Name, user.Query = r.str("Tenant"), r.str("Name"), r.str("Query")
+				user.Index = make(map[string]string)
+				if err := r.bool("Masked", &user.Masked); err != nil {
+					return err
+				}
+				if err := r.float("Weight", &user.Weight); err != nil {
+					return err
+				}
+			}
+			if name := r.str("AttributeName"); name != "" {
+				user.Index[name] = r.str("AttributeValue")
+			}
+			return nil
+		},
+	},
+	ALIASES_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Direction", "Category", "Account", "Subject", "Context", "Target", "Alias", "DestinationTag", "Fields", "Weight"},
+		keyColumns: 6,
+		rows: func(el interface{}) (rows [][]string) {
+			al := el.(*TpAlias)
+			// the indexes and the values share the rows
+			for i := 0; i < len(al.Indexes) || i < len(al.Values); i++ {
+				row := []string{al.Tenant, al.Direction, al.Category, al.Account, al.Subject, al.Context, "", "", "", "", ""}
+				if i < len(al.Indexes) {
+					row[6], row[7] = al.Indexes[i].Target, al.Indexes[i].Alias
+				}
+				if i < len(al.Values) {
+					row[8], row[9], row[10] = al.Values[i].DestinationTag, al.Values[i].Fields, formatFloat(al.Values[i].Weight)
+				}
+				rows = append(rows, row)
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			al := el.(*TpAlias)
+			al.Tenant, al.Direction, al.Category, al.Account, al.Subject, al.Context = r.str("Tenant"), r.str("Direction"), r.str("Category"),
+				r.str("Account"), r.str("Subject"), r.str("Context")
+			if r.str("Target") != "" || r.str("Alias") != "" {
+				al.Indexes = append(al.Indexes, &TpAliasIndex{Target: r.str("Target"), Alias: r.str("Alias")})
+			}
+			if r.str("DestinationTag") != "" || r.str("Fields") != "" {
+				value := &TpAliasValue{DestinationTag: r.str("DestinationTag"), Fields: r.str("Fields")}
+				if err := r.float("Weight", &value.Weight); err != nil {
+					return err
+				}
+				al.Values = append(al.Values, value)
+			}
+			return nil
+		},
+	},
+	RESOURCE_LIMITS_CSV: &csvLayout{
+		columns:    []string{"Tenant", "Tag", "Type", "FieldName", "Values", "ActivationTime", "Weight", "Limit", "ActionTriggersTag"},
+		keyColumns: 2,
+		rows: func(el interface{}) (rows [][]string) {
+			rl := el.(*TpResourceLimit)
+			for _, f := range rl.Filters {
+				rows = append(rows, []string{rl.Tenant, rl.Tag, f.Type, f.FieldName, strings.Join(f.Values, INFIELD_SEP), f.ActivationTime,
+					formatFloat(f.Weight), formatFloat(f.Limit), f.ActionTriggersTag})
+			}
+			return
+		},
+		fill: func(el interface{}, r *csvRow, first bool) error {
+			rl := el.(*TpResourceLimit)
+			rl.Tenant, rl.Tag = r.str("Tenant"), r.str("Tag")
+			filter := &TpResourceFilter{Type: r.str("Type"), FieldName: r.str("FieldName"), Values: r.list("Values"),
+				ActivationTime: r.str("ActivationTime"), ActionTriggersTag: r.str("ActionTriggersTag")}
+			if err := r.float("Weight", &filter.Weight); err != nil {
+				return err
+			}
+			if err := r.float("Limit", &filter.Limit); err != nil {
+				return err
+			}
+			rl.Filters = append(rl.Filters, filter)
+			return nil
+		},
+	},
+}
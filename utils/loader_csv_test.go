@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoaderCSVDestinationsDefaultOrder(t *testing.T) {
+	reader := strings.NewReader(`
+x,VODAFONE,0723
+y, VODAFONE ,+0724
+`)
+	result := make([]*TpDestination, 0)
+	newDest := func() interface{} { return &TpDestination{} }
+	callback := func(el interface{}) error {
+		result = append(result, el.(*TpDestination))
+		return nil
+	}
+	if err := LoadCSV(reader, DESTINATIONS_CSV, CSV_SEP, newDest, callback); err != nil {
+		t.Fatal("error loading destinations: ", err)
+	}
+	if len(result) != 2 ||
+		!reflect.DeepEqual(result[0], &TpDestination{Tenant: "x", Code: "0723", Tag: "VODAFONE"}) ||
+		!reflect.DeepEqual(result[1], &TpDestination{Tenant: "y", Code: "+0724", Tag: "VODAFONE"}) {
+		t.Error("error loading destinations: ", ToIJSON(result))
+	}
+}
+
+func TestLoaderCSVRatesHeaderGrouping(t *testing.T) {
+	reader := strings.NewReader(`#Tenant;Tag;Rate;RateUnit;RateIncrement;GroupIntervalStart;ConnectFee
+x;RT_1;0.2;60s;60s;0s;0.1
+x;RT_2;0.1;1s;1s;0s;
+# a comment
+x;RT_1;0.1;60s;1s;60s;0.1
+`)
+	result := make([]*TpRate, 0)
+	newRate := func() interface{} { return &TpRate{} }
+	callback := func(el interface{}) error {
+		result = append(result, el.(*TpRate))
+		return nil
+	}
+	if err := LoadCSV(reader, "/tmp/tp/"+RATES_CSV, ';', newRate, callback); err != nil {
+		t.Fatal("error loading rates: ", err)
+	}
+	if len(result) != 2 || result[0].Tag != "RT_1" || result[1].Tag != "RT_2" {
+		t.Fatal("error loading rates: ", ToIJSON(result))
+	}
+	if len(result[0].Slots) != 2 ||
+		!reflect.DeepEqual(result[0].Slots[1], &TpRateSlot{ConnectFee: 0.1, Rate: 0.1, RateUnit: "60s", RateIncrement: "1s", GroupIntervalStart: "60s"}) ||
+		!reflect.DeepEqual(result[1].Slots, []*TpRateSlot{&TpRateSlot{Rate: 0.1, RateUnit: "1s", RateIncrement: "1s", GroupIntervalStart: "0s"}}) {
+		t.Error("error grouping rate slots: ", ToIJSON(result))
+	}
+}
+
+func TestLoaderCSVLists(t *testing.T) {
+	reader := strings.NewReader(`Tenant,Tag,Years,Months,MonthDays,WeekDays,Time
+x,WORKDAYS,*any,*any,*any,1;2;3;4;5,08:00:00
+`)
+	var timing *TpTiming
+	if err := LoadCSV(reader, TIMINGS_CSV, CSV_SEP, func() interface{} { return &TpTiming{} }, func(el interface{}) error {
+		timing = el.(*TpTiming)
+		return nil
+	}); err != nil {
+		t.Fatal("error loading timings: ", err)
+	}
+	if timing == nil || timing.Years != nil || timing.Months != nil || len(timing.WeekDays) != 5 || timing.Time != "08:00:00" {
+		t.Error("error loading timing: ", ToIJSON(timing))
+	}
+	reader = strings.NewReader(`x,a1,AP_1;AP_2,,true,`)
+	var aa *TpAccountAction
+	if err := LoadCSV(reader, ACCOUNT_ACTIONS_CSV, CSV_SEP, func() interface{} { return &TpAccountAction{} }, func(el interface{}) error {
+		aa = el.(*TpAccountAction)
+		return nil
+	}); err != nil {
+		t.Fatal("error loading account actions: ", err)
+	}
+	if aa == nil || !reflect.DeepEqual(aa.ActionPlanTags, []string{"AP_1", "AP_2"}) || aa.ActionTriggerTags != nil || !aa.AllowNegative || aa.Disabled {
+		t.Error("error loading account action: ", ToIJSON(aa))
+	}
+}
+
+func TestLoaderCSVErrors(t *testing.T) {
+	newRate := func() interface{} { return &TpRate{} }
+	callback := func(el interface{}) error { return nil }
+	for data, expected := range map[string]string{
+		"#Tenant,Tag,Price\n": "Rates.csv:1:3: unknown column Price",
+		"#Tenant,Rate\n":      "Rates.csv:1: missing column Tag",
+		"\n#Tenant,Tag,Rate\nx,RT_1,0.1\nx,RT_2,zero\n": "Rates.csv:4:3: Rate: ",
+		"x,RT_1,0,0.1,60s,60s,0s,extra\n":               "Rates.csv:1:8: expected 7 fields, found 8",
+		"x,RT_1,0,\"0.1\"x,60s\n":                       "Rates.csv:1:4: extraneous",
+		"x,RT_1,a\"b\n":                                 "Rates.csv:1:3: bare \"",
+	} {
+		err := LoadCSV(strings.NewReader(data), RATES_CSV, CSV_SEP, newRate, callback)
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("expected %q, got %v", expected, err)
+		}
+	}
+	err := LoadCSV(strings.NewReader("x,RT_1,0,0.1\n\nx,RT_2,0,0.2\n"), RATES_CSV, CSV_SEP, newRate, func(el interface{}) error {
+		if el.(*TpRate).Tag == "RT_2" {
+			return errors.New("broken reference")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "Rates.csv:3: broken reference" {
+		t.Error("expected callback error position, got: ", err)
+	}
+	if err := LoadCSV(strings.NewReader(""), "Unknown.csv", CSV_SEP, newRate, callback); err == nil {
+		t.Error("expected unknown file error")
+	}
+}
@@ -20,3 +20,14 @@ func LoadJSON(r io.Reader, newElement func() interface{}, callback func(interfac
 	}
 	return nil
 }
+
+// WriteJSON writes the elements one per line, the format read by LoadJSON
+func WriteJSON(w io.Writer, elements []interface{}) error {
+	enc := json.NewEncoder(w)
+	for _, el := range elements {
+		if err := enc.Encode(el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -60,8 +60,8 @@ func TestLoaderJSONDestinationFile(t *testing.T) {
 		t.Error("error loading destinations: ", err)
 	}
 	if len(result) != 7 ||
-		!reflect.DeepEqual(result[0], &TpDestination{Tenant: "tut", Code: "1002", Tag: "DST_1002"}) ||
-		!reflect.DeepEqual(result[4], &TpDestination{Tenant: "tut", Code: "+49151", Tag: "DST_DE_MOBILE"}) {
+		!reflect.DeepEqual(result[0], &TpDestination{Tenant: "cgrates.org", Code: "1002", Tag: "DST_1002"}) ||
+		!reflect.DeepEqual(result[4], &TpDestination{Tenant: "cgrates.org", Code: "+49151", Tag: "DST_DE_MOBILE"}) {
 		t.Error("error loading destinations: ", ToIJSON(result))
 	}
 }
@@ -2,8 +2,10 @@ package utils
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -44,6 +46,59 @@ func (s *Server) RegisterHTTPFunc(pattern string, handler func(http.ResponseWrit
 	s.httpEnabled = true
 }
 
+// MaxUploadMemory is the part of a multipart upload kept in memory, the rest goes to temporary files
+const MaxUploadMemory = 32 << 20
+
+// MaxUploadSize caps the body of the uploads and of the rest calls, the requests going over it are refused
+var MaxUploadSize int64 = 64 << 20
+
+// RegisterHTTPUpload serves the multipart/form-data POSTs at pattern as calls of the rpc method.
+// The file part named fileField is passed as bytes in the argument with the same name and its file name in FileName,
+// the optional params part holds the other arguments as a JSON object. The reply is the one of /jsonrpc.
+func (s *Server) RegisterHTTPUpload(pattern, method, fileField string) {
+	s.RegisterHTTPFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+		req.Body = http.MaxBytesReader(w, req.Body, MaxUploadSize)
+		w.Header().Set("Content-Type", "application/json")
+		body, err := uploadRPCRequest(req, method, fileField)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 0, "result": nil, "error": err.Error()})
+			return
+		}
+		io.Copy(w, NewRPCRequest(bytes.NewReader(body)).Call())
+	})
+}
+
+// uploadRPCRequest composes the JSON-RPC request out of the multipart upload
+func uploadRPCRequest(req *http.Request, method, fileField string) ([]byte, error) {
+	if req.Method != http.MethodPost {
+		return nil, ErrNotImplemented
+	}
+	if err := req.ParseMultipartForm(MaxUploadMemory); err != nil {
+		return nil, fmt.Errorf("%s:%v", ErrParserError.Error(), err)
+	}
+	defer req.MultipartForm.RemoveAll()
+	args := make(map[string]interface{})
+	if params := req.FormValue("params"); params != "" {
+		if err := json.Unmarshal([]byte(params), &args); err != nil {
+			return nil, fmt.Errorf("%s:%v", ErrParserError.Error(), err)
+		}
+	}
+	file, header, err := req.FormFile(fileField)
+	if err != nil {
+		return nil, NewErrMandatoryIeMissing(fileField)
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	args[fileField] = data
+	args["FileName"] = header.Filename
+	return json.Marshal(map[string]interface{}{"method": method, "params": []interface{}{args}, "id": 0})
+}
+
 // Registers a new BiJsonRpc name
 func (s *Server) BiRPCRegisterName(method string, handlerFunc interface{}) {
 	if s.birpcSrv == nil {
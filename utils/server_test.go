@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+)
+
+type UploadRecorder struct{}
+
+type AttrUpload struct {
+	Archive  []byte
+	FileName string
+	FlushDB  bool
+}
+
+func (u *UploadRecorder) Load(attr AttrUpload, reply *string) error {
+	*reply = attr.FileName + ":" + string(attr.Archive)
+	if attr.FlushDB {
+		*reply += ":flush"
+	}
+	return nil
+}
+
+func TestServerHTTPUpload(t *testing.T) {
+	if err := rpc.Register(&UploadRecorder{}); err != nil {
+		t.Fatal(err)
+	}
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, _ := mw.CreateFormFile("Archive", "tp.zip")
+	fw.Write([]byte("data"))
+	mw.WriteField("params", `{"FlushDB":true}`)
+	mw.Close()
+
+	s := &Server{}
+	s.RegisterHTTPUpload("/test_upload", "UploadRecorder.Load", "Archive")
+	req := httptest.NewRequest(http.MethodPost, "/test_upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, req)
+	var reply struct {
+		Result string
+		Error  interface{}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if reply.Error != nil || reply.Result != "tp.zip:data:flush" {
+		t.Errorf("wrong reply: %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/test_upload", bytes.NewBufferString(""))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request, got %d: %s", w.Code, w.Body.String())
+	}
+
+	defer func(size int64) { MaxUploadSize = size }(MaxUploadSize)
+	MaxUploadSize = 10
+	body = &bytes.Buffer{}
+	mw = multipart.NewWriter(body)
+	fw, _ = mw.CreateFormFile("Archive", "tp.zip")
+	fw.Write([]byte("more data than allowed"))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/test_upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("expected too large body, got %d: %s", w.Code, w.Body.String())
+	}
+}